@@ -49,6 +49,7 @@ var (
 		// don't relicense vendored sources
 		"cmd/internal/browser",
 		"consensus/ethash/xor.go",
+		"core/vm/evmc/",
 		"crypto/bn256/",
 		"crypto/ecies/",
 		"crypto/secp256k1/curve.go",
@@ -0,0 +1,117 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/core/vm/eni"
+	"gopkg.in/urfave/cli.v1"
+)
+
+type outputCall struct {
+	Gas    uint64 `json:"gas"`
+	Result string `json:"result,omitempty"`
+	Err    string `json:"error,omitempty"`
+	Code   int    `json:"code,omitempty"`  // ENI fault code, if the invocation faulted
+	Local  bool   `json:"local,omitempty"` // whether the fault is specific to this node
+}
+
+func (out outputCall) equal(other outputCall) bool {
+	return out == other
+}
+
+func (out outputCall) String() string {
+	switch {
+	case out.Code != 0:
+		return fmt.Sprintf("gas %d, fault %d (local: %v): %s", out.Gas, out.Code, out.Local, out.Err)
+	case out.Err != "":
+		return fmt.Sprintf("gas %d, error: %s", out.Gas, out.Err)
+	default:
+		return fmt.Sprintf("gas %d, result %s", out.Gas, out.Result)
+	}
+}
+
+// invoke runs an ENI function the way the EVM does, charging its gas before
+// running it, and reports the outcome.
+func invoke(handler *eni.ENI, fn, argsText string) (out outputCall) {
+	fail := func(err error) outputCall {
+		out.Err = err.Error()
+		if fault, ok := err.(*eni.Fault); ok {
+			out.Code, out.Local = fault.Code, fault.Local
+		}
+		return out
+	}
+	if err := handler.InitENI(fn, argsText); err != nil {
+		return fail(err)
+	}
+	gas, err := handler.Gas()
+	out.Gas = gas
+	if err != nil {
+		return fail(err)
+	}
+	if out.Result, err = handler.ExecuteENI(); err != nil {
+		return fail(err)
+	}
+	return out
+}
+
+var libFlag = cli.StringFlag{
+	Name:  "lib",
+	Usage: "library to resolve the function in instead of the library path",
+}
+
+var commandCall = cli.Command{
+	Name:      "call",
+	Usage:     "invoke an ENI function",
+	ArgsUsage: "<function> <json-args>",
+	Description: `
+Invoke an ENI function with the given JSON arguments in the same sandbox the
+node runs it in, and print the gas charged and the result or the error.
+
+The function is resolved in the libraries of the library path canonical at the
+given block, or in the library given with --lib.`,
+	Flags: []cli.Flag{
+		blockFlag,
+		libFlag,
+		jsonFlag,
+	},
+	Action: func(ctx *cli.Context) error {
+		if len(ctx.Args()) != 2 {
+			utils.Fatalf("This command requires a function and its JSON arguments.")
+		}
+		handler := eni.NewENI(ctx.Uint64(blockFlag.Name))
+		if path := ctx.String(libFlag.Name); path != "" {
+			lib, err := eni.OpenLibrary(path)
+			if err != nil {
+				utils.Fatalf("Failed to open the library: %v", err)
+			}
+			handler = eni.NewLibraryENI(lib)
+		}
+		out := invoke(handler, ctx.Args()[0], ctx.Args()[1])
+		if ctx.Bool(jsonFlag.Name) {
+			mustPrintJSON(out)
+		} else {
+			fmt.Println(out)
+		}
+		if out.Err != "" {
+			return fmt.Errorf("ENI invocation failed")
+		}
+		return nil
+	},
+}
@@ -0,0 +1,185 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/core/vm/eni"
+	"gopkg.in/urfave/cli.v1"
+)
+
+type outputList struct {
+	Name      string   `json:"name"`
+	Version   string   `json:"version"`
+	Path      string   `json:"path"`
+	Functions []string `json:"functions"`
+	Unpaired  []string `json:"unpaired,omitempty"`
+}
+
+var commandList = cli.Command{
+	Name:  "list",
+	Usage: "list the ENI libraries and functions canonical at a block",
+	Description: `
+List the ENI libraries of the library path canonical at the given block, the
+way the node resolves them, along with the functions they export. Symbols
+lacking their _gas or _run counterpart are reported as unpaired.`,
+	Flags: []cli.Flag{
+		blockFlag,
+		jsonFlag,
+	},
+	Action: func(ctx *cli.Context) error {
+		registry, err := eni.DefaultRegistry()
+		if err != nil {
+			utils.Fatalf("Failed to load the ENI registry: %v", err)
+		}
+		var out []outputList
+		for _, lib := range registry.Libraries(ctx.Uint64(blockFlag.Name)) {
+			functions, unpaired := lib.Functions()
+			out = append(out, outputList{
+				Name:      lib.Name,
+				Version:   lib.Version,
+				Path:      lib.Path,
+				Functions: functions,
+				Unpaired:  unpaired,
+			})
+		}
+		if ctx.Bool(jsonFlag.Name) {
+			mustPrintJSON(out)
+			return nil
+		}
+		for _, lib := range out {
+			fmt.Printf("%s %s (%s)\n", lib.Name, lib.Version, lib.Path)
+			for _, fn := range lib.Functions {
+				fmt.Println("  ", fn)
+			}
+			for _, symbol := range lib.Unpaired {
+				fmt.Println("  ", symbol, "(unpaired)")
+			}
+		}
+		return nil
+	},
+}
+
+var commandChecksum = cli.Command{
+	Name:      "checksum",
+	Usage:     "print the SHA512 checksum of an ENI library",
+	ArgsUsage: "<library>",
+	Action: func(ctx *cli.Context) error {
+		if len(ctx.Args()) != 1 {
+			utils.Fatalf("This command requires exactly one argument.")
+		}
+		checksum, err := eni.Checksum(ctx.Args().First())
+		if err != nil {
+			utils.Fatalf("Failed to compute the checksum: %v", err)
+		}
+		fmt.Println(checksum)
+		return nil
+	},
+}
+
+var urlFlag = cli.StringSliceFlag{
+	Name:  "url",
+	Usage: "URL the library can be downloaded from (may be repeated)",
+}
+
+var commandPackage = cli.Command{
+	Name:      "package",
+	Usage:     "print the OTA upgrade manifest of an ENI library",
+	ArgsUsage: "<library>",
+	Description: `
+Print the manifest announcing an over-the-air upgrade to the given library. The
+library file must be named name_vX.Y.Z.so, as installed in the library path.`,
+	Flags: []cli.Flag{
+		urlFlag,
+	},
+	Action: func(ctx *cli.Context) error {
+		if len(ctx.Args()) != 1 {
+			utils.Fatalf("This command requires exactly one argument.")
+		}
+		urls := ctx.StringSlice(urlFlag.Name)
+		if len(urls) == 0 {
+			utils.Fatalf("At least one --%s is required.", urlFlag.Name)
+		}
+		info, err := eni.NewOTAInfo(ctx.Args().First(), urls)
+		if err != nil {
+			utils.Fatalf("Failed to package the library: %v", err)
+		}
+		mustPrintJSON(info)
+		return nil
+	},
+}
+
+var runsFlag = cli.IntFlag{
+	Name:  "runs",
+	Usage: "number of times each function is invoked",
+	Value: 3,
+}
+
+var commandVerify = cli.Command{
+	Name:      "verify",
+	Usage:     "check that an ENI library is well formed and deterministic",
+	ArgsUsage: "<library> [<function> <json-args>]...",
+	Description: `
+Check that every symbol the library exports has its _gas or _run counterpart,
+then invoke each given function with the given JSON arguments a number of
+times, checking that every run charges the same gas, returns the same valid
+JSON result or fails alike.`,
+	Flags: []cli.Flag{
+		runsFlag,
+	},
+	Action: func(ctx *cli.Context) error {
+		args := ctx.Args()
+		if len(args) == 0 || len(args)%2 != 1 {
+			utils.Fatalf("This command requires a library and pairs of functions and arguments.")
+		}
+		lib, err := eni.OpenLibrary(args[0])
+		if err != nil {
+			utils.Fatalf("Failed to open the library: %v", err)
+		}
+		functions, unpaired := lib.Functions()
+		if len(unpaired) > 0 {
+			utils.Fatalf("Unpaired ENI symbols: %s", strings.Join(unpaired, ", "))
+		}
+		fmt.Printf("%s %s exports %s\n", lib.Name, lib.Version, strings.Join(functions, ", "))
+
+		runs := ctx.Int(runsFlag.Name)
+		if runs < 1 {
+			utils.Fatalf("At least one run is required.")
+		}
+		for i := 1; i < len(args); i += 2 {
+			fn, argsText := args[i], args[i+1]
+			if !json.Valid([]byte(argsText)) {
+				utils.Fatalf("Invalid JSON arguments for %s: %s", fn, argsText)
+			}
+			first := invoke(eni.NewLibraryENI(lib), fn, argsText)
+			if first.Err == "" && !json.Valid([]byte(first.Result)) {
+				utils.Fatalf("%s returned invalid JSON: %s", fn, first.Result)
+			}
+			for run := 1; run < runs; run++ {
+				if out := invoke(eni.NewLibraryENI(lib), fn, argsText); !out.equal(first) {
+					utils.Fatalf("%s is not deterministic:\n  run 1: %s\n  run %d: %s", fn, first, run+1, out)
+				}
+			}
+			fmt.Printf("%s: %s\n", fn, first)
+		}
+		return nil
+	},
+}
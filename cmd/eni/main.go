@@ -0,0 +1,98 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+// eni is a toolkit for developers of ENI libraries, the native libraries
+// serving the Ethereum Native Interface.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/core/vm/eni"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// Git SHA1 commit hash of the release (set via linker flags)
+var gitCommit = ""
+
+var app *cli.App
+
+func init() {
+	app = utils.NewApp(gitCommit, "an ENI library developer toolkit")
+	app.Flags = []cli.Flag{
+		libPathFlag,
+		timeoutFlag,
+	}
+	app.Commands = []cli.Command{
+		commandList,
+		commandCall,
+		commandChecksum,
+		commandPackage,
+		commandVerify,
+	}
+	app.Before = func(ctx *cli.Context) error {
+		// The library path is read from the environment by the ENI package,
+		// as it is by the node.
+		if ctx.GlobalIsSet(libPathFlag.Name) {
+			if err := os.Setenv("ENI_LIBRARY_PATH", ctx.GlobalString(libPathFlag.Name)); err != nil {
+				return err
+			}
+		}
+		eni.SetTimeout(ctx.GlobalDuration(timeoutFlag.Name))
+		return nil
+	}
+}
+
+// Commonly used command line flags.
+var (
+	libPathFlag = cli.StringFlag{
+		Name:  "libpath",
+		Usage: "ENI library path (default: $ENI_LIBRARY_PATH or <datadir>/eni/lib)",
+	}
+	timeoutFlag = cli.DurationFlag{
+		Name:  "timeout",
+		Usage: "wall clock time after which ENI invocations are killed",
+		Value: eni.DefaultTimeout,
+	}
+	blockFlag = cli.Uint64Flag{
+		Name:  "block",
+		Usage: "block number the ENI functions are resolved at",
+	}
+	jsonFlag = cli.BoolFlag{
+		Name:  "json",
+		Usage: "output JSON instead of human-readable format",
+	}
+)
+
+func main() {
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// mustPrintJSON prints the JSON encoding of the given object and
+// exits the program with an error message when the marshaling fails.
+func mustPrintJSON(jsonObject interface{}) {
+	str, err := json.MarshalIndent(jsonObject, "", "  ")
+	if err != nil {
+		utils.Fatalf("Failed to marshal JSON object: %v", err)
+	}
+	fmt.Println(string(str))
+}
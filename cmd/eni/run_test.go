@@ -0,0 +1,133 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/docker/docker/pkg/reexec"
+	"github.com/ethereum/go-ethereum/core/vm/eni"
+	"github.com/ethereum/go-ethereum/internal/cmdtest"
+)
+
+type testEni struct {
+	*cmdtest.TestCmd
+}
+
+// spawns eni with the given command line args.
+func runEni(t *testing.T, args ...string) *testEni {
+	tt := new(testEni)
+	tt.TestCmd = cmdtest.NewTestCmd(t, tt)
+	tt.Run("eni-test", args...)
+	return tt
+}
+
+func TestMain(m *testing.M) {
+	// Run the app if we've been exec'd as "eni-test" in runEni.
+	reexec.Register("eni-test", func() {
+		if err := app.Run(os.Args); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	})
+	// check if we have been reexec'd
+	if reexec.Init() {
+		return
+	}
+	os.Exit(m.Run())
+}
+
+// echoLibrary exports an ENI function returning its arguments.
+const echoLibrary = `
+#include <stdint.h>
+#include <stdlib.h>
+#include <string.h>
+
+int64_t* echo_gas(char* a) { int64_t* g = malloc(sizeof(int64_t)); *g = strlen(a); return g; }
+char* echo_run(char* a) { return strdup(a); }
+`
+
+// buildEchoLibrary compiles echoLibrary into a fresh library path, canonical
+// from the genesis block on.
+func buildEchoLibrary(t *testing.T) (string, string) {
+	if runtime.GOOS != "linux" {
+		t.Skip("ENI is only supported on Linux")
+	}
+	cc, err := exec.LookPath("cc")
+	if err != nil {
+		t.Skip("C compiler not available")
+	}
+	dir, err := ioutil.TempDir("", "eni-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srcPath, libPath := filepath.Join(dir, "echo.c"), filepath.Join(dir, "echo_v1.0.0.so")
+	if err := ioutil.WriteFile(srcPath, []byte(echoLibrary), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command(cc, "-shared", "-fPIC", "-o", libPath, srcPath).CombinedOutput(); err != nil {
+		t.Fatalf("failed to build library: %v\n%s", err, out)
+	}
+	registry, err := eni.NewRegistry(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Schedule("echo", "v1.0.0", 0); err != nil {
+		t.Fatal(err)
+	}
+	return dir, libPath
+}
+
+func TestListCallVerify(t *testing.T) {
+	dir, libPath := buildEchoLibrary(t)
+	defer os.RemoveAll(dir)
+
+	lib, err := eni.OpenLibrary(libPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := invoke(eni.NewLibraryENI(lib), "echo", "[]"); out.Local {
+		t.Skip("ENI sandbox not available:", out.Err)
+	}
+
+	list := runEni(t, "--libpath", dir, "list")
+	list.Expect(fmt.Sprintf(`
+echo v1.0.0 (%s)
+   echo
+`, libPath))
+	list.ExpectExit()
+
+	call := runEni(t, "--libpath", dir, "call", "echo", `["abc"]`)
+	call.Expect(`
+gas 7, result ["abc"]
+`)
+	call.ExpectExit()
+
+	verify := runEni(t, "verify", libPath, "echo", `[1]`)
+	verify.Expect(`
+echo v1.0.0 exports echo
+echo: gas 3, result [1]
+`)
+	verify.ExpectExit()
+}
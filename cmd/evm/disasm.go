@@ -20,9 +20,11 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"math/big"
 	"strings"
 
 	"github.com/ethereum/go-ethereum/core/asm"
+	"github.com/ethereum/go-ethereum/params"
 	cli "gopkg.in/urfave/cli.v1"
 )
 
@@ -46,5 +48,18 @@ func disasmCmd(ctx *cli.Context) error {
 
 	code := strings.TrimSpace(string(in[:]))
 	fmt.Printf("%v\n", code)
+	if ctx.GlobalBool(LityFlag.Name) {
+		// Annotate for the genesis block if given, with every fork enabled
+		// otherwise.
+		config, number := params.TestChainConfig, new(big.Int)
+		if ctx.GlobalString(GenesisFlag.Name) != "" {
+			gen := readGenesis(ctx.GlobalString(GenesisFlag.Name))
+			if gen.Config != nil {
+				config = gen.Config
+			}
+			number.SetUint64(gen.Number)
+		}
+		return asm.PrintAnnotated(code, config, number)
+	}
 	return asm.PrintDisassembled(code)
 }
@@ -0,0 +1,82 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/core/vm/eni"
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+// eniProvider returns the ENI backend provider selected on the command line,
+// nil leaving ENI calls to the native libraries in the ENI library path.
+func eniProvider(ctx *cli.Context) eni.Provider {
+	if !ctx.GlobalBool(GoENIFlag.Name) {
+		return nil
+	}
+	return newGoENIBackend()
+}
+
+// newGoENIBackend creates an in-process ENI backend serving Go ports of the
+// libeni example functions, so that ENI contracts can be run without building
+// the native libraries.
+func newGoENIBackend() *eni.GoBackend {
+	backend := eni.NewGoBackend()
+	backend.Register("reverse", stringGas, stringFunc(func(args []string) (string, error) {
+		if len(args) != 1 {
+			return "", errors.New("reverse takes a single string")
+		}
+		runes := []rune(args[0])
+		for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+			runes[i], runes[j] = runes[j], runes[i]
+		}
+		return string(runes), nil
+	}))
+	return backend
+}
+
+// stringGas charges the total length of the string arguments of a function.
+func stringGas(argsText string) (uint64, error) {
+	var args []string
+	if err := json.Unmarshal([]byte(argsText), &args); err != nil {
+		return 0, err
+	}
+	var gas uint64
+	for _, arg := range args {
+		gas += uint64(len(arg))
+	}
+	return gas, nil
+}
+
+// stringFunc wraps a function of string arguments returning a single string
+// into an ENI run function.
+func stringFunc(fn func(args []string) (string, error)) eni.RunFunc {
+	return func(argsText string) (string, error) {
+		var args []string
+		if err := json.Unmarshal([]byte(argsText), &args); err != nil {
+			return "", err
+		}
+		ret, err := fn(args)
+		if err != nil {
+			return "", err
+		}
+		blob, err := json.Marshal([]string{ret})
+		return string(blob), err
+	}
+}
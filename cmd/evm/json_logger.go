@@ -68,6 +68,14 @@ func (l *JSONLogger) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cos
 	return nil
 }
 
+// CaptureENI outputs the native call of an ENI step on the logger.
+func (l *JSONLogger) CaptureENI(env *vm.EVM, call *vm.ENILog) error {
+	type eniLog struct {
+		ENI *vm.ENILog `json:"eni"`
+	}
+	return l.encoder.Encode(eniLog{call})
+}
+
 // CaptureEnd is triggered at end of execution.
 func (l *JSONLogger) CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error) error {
 	type endLog struct {
@@ -110,6 +110,26 @@ var (
 		Name:  "nostack",
 		Usage: "disable stack output",
 	}
+	ValidatorsFlag = cli.StringFlag{
+		Name:  "validators",
+		Usage: "comma separated validator addresses reported to the evm",
+	}
+	DefaultGasPriceFlag = utils.BigFlag{
+		Name:  "defaultgasprice",
+		Usage: "gas price charged to contracts for freegas transactions",
+	}
+	FreeGasLimitFlag = cli.Uint64Flag{
+		Name:  "freegaslimit",
+		Usage: "gas limit above which zero priced transactions are freegas ones",
+	}
+	GoENIFlag = cli.BoolFlag{
+		Name:  "eni.go",
+		Usage: "serve ENI calls by the built-in Go functions instead of the native libraries",
+	}
+	LityFlag = cli.BoolFlag{
+		Name:  "lity",
+		Usage: "annotate disassembled code with the Lity semantics, at the genesis block if given",
+	}
 )
 
 func init() {
@@ -133,6 +153,11 @@ func init() {
 		ReceiverFlag,
 		DisableMemoryFlag,
 		DisableStackFlag,
+		ValidatorsFlag,
+		DefaultGasPriceFlag,
+		FreeGasLimitFlag,
+		GoENIFlag,
+		LityFlag,
 	}
 	app.Commands = []cli.Command{
 		compileCommand,
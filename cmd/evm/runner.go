@@ -25,6 +25,7 @@ import (
 	"os"
 	goruntime "runtime"
 	"runtime/pprof"
+	"strings"
 	"time"
 
 	"github.com/ethereum/go-ethereum/cmd/evm/internal/compiler"
@@ -34,6 +35,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/core/vm/runtime"
+	"github.com/ethereum/go-ethereum/core/vm/umbrella"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/params"
@@ -166,6 +168,7 @@ func runCmd(ctx *cli.Context) error {
 		EVMConfig: vm.Config{
 			Tracer: tracer,
 			Debug:  ctx.GlobalBool(DebugFlag.Name) || ctx.GlobalBool(MachineFlag.Name),
+			ENI:    eniProvider(ctx),
 		},
 	}
 
@@ -185,6 +188,7 @@ func runCmd(ctx *cli.Context) error {
 	if chainConfig != nil {
 		runtimeConfig.ChainConfig = chainConfig
 	}
+	runtimeConfig.Umbrella = umbrella.NewStandalone(umbrellaConfig(ctx, chainConfig))
 	tstart := time.Now()
 	var leftOverGas uint64
 	if ctx.GlobalBool(CreateFlag.Name) {
@@ -246,3 +250,25 @@ Gas used:           %d
 
 	return nil
 }
+
+// umbrellaConfig assembles the standalone umbrella configuration from the
+// prestate chain config, overridden by the command line flags.
+func umbrellaConfig(ctx *cli.Context, chainConfig *params.ChainConfig) *params.UmbrellaConfig {
+	config := new(params.UmbrellaConfig)
+	if chainConfig != nil && chainConfig.Umbrella != nil {
+		*config = *chainConfig.Umbrella
+	}
+	if validators := ctx.GlobalString(ValidatorsFlag.Name); validators != "" {
+		config.Validators = nil
+		for _, validator := range strings.Split(validators, ",") {
+			config.Validators = append(config.Validators, common.HexToAddress(strings.TrimSpace(validator)))
+		}
+	}
+	if ctx.GlobalIsSet(DefaultGasPriceFlag.Name) {
+		config.DefaultGasPrice = utils.GlobalBig(ctx, DefaultGasPriceFlag.Name)
+	}
+	if ctx.GlobalIsSet(FreeGasLimitFlag.Name) {
+		config.FreeGasLimit = ctx.GlobalUint64(FreeGasLimitFlag.Name)
+	}
+	return config
+}
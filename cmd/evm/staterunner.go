@@ -90,6 +90,7 @@ func stateTestCmd(ctx *cli.Context) error {
 	cfg := vm.Config{
 		Tracer: tracer,
 		Debug:  ctx.GlobalBool(DebugFlag.Name) || ctx.GlobalBool(MachineFlag.Name),
+		ENI:    eniProvider(ctx),
 	}
 	results := make([]StatetestResult, 0, len(tests))
 	for key, test := range tests {
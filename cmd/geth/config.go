@@ -28,8 +28,11 @@ import (
 	cli "gopkg.in/urfave/cli.v1"
 
 	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/dashboard"
+	"github.com/ethereum/go-ethereum/eniota"
 	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/graphql"
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/params"
 	whisper "github.com/ethereum/go-ethereum/whisper/whisperv6"
@@ -80,6 +83,8 @@ type gethConfig struct {
 	Node      node.Config
 	Ethstats  ethstatsConfig
 	Dashboard dashboard.Config
+	ENI       eniota.Config
+	GraphQL   graphql.Config
 }
 
 func loadConfig(file string, cfg *gethConfig) error {
@@ -114,6 +119,7 @@ func makeConfigNode(ctx *cli.Context) (*node.Node, gethConfig) {
 		Shh:       whisper.DefaultConfig,
 		Node:      defaultNodeConfig(),
 		Dashboard: dashboard.DefaultConfig,
+		GraphQL:   graphql.DefaultConfig,
 	}
 
 	// Load config file.
@@ -136,6 +142,9 @@ func makeConfigNode(ctx *cli.Context) (*node.Node, gethConfig) {
 
 	utils.SetShhConfig(ctx, stack, &cfg.Shh)
 	utils.SetDashboardConfig(ctx, &cfg.Dashboard)
+	utils.SetENIUpgradeConfig(ctx, stack, &cfg.ENI)
+	utils.SetENITimeout(ctx)
+	utils.SetGraphQLConfig(ctx, &cfg.GraphQL)
 
 	return stack, cfg
 }
@@ -171,6 +180,16 @@ func makeFullNode(ctx *cli.Context) *node.Node {
 		utils.RegisterShhService(stack, &cfg.Shh)
 	}
 
+	// Add the ENI library upgrade daemon if requested.
+	if ctx.GlobalBool(utils.ENIUpgradeEnabledFlag.Name) || cfg.ENI.Registry != (common.Address{}) {
+		utils.RegisterENIUpgradeService(stack, &cfg.ENI)
+	}
+
+	// Add the GraphQL server next to the RPC endpoints if requested.
+	if cfg.GraphQL.Host != "" {
+		utils.RegisterGraphQLService(stack, &cfg.GraphQL)
+	}
+
 	// Add the Ethereum Stats daemon if requested.
 	if cfg.Ethstats.URL != "" {
 		utils.RegisterEthStatsService(stack, cfg.Ethstats.URL)
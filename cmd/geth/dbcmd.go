@@ -0,0 +1,159 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/olekukonko/tablewriter"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	dbCommand = cli.Command{
+		Name:        "db",
+		Usage:       "Low level database operations",
+		Category:    "DATABASE COMMANDS",
+		Description: "",
+		Subcommands: []cli.Command{
+			{
+				Name:      "inspect",
+				Usage:     "Inspect the storage size of each kind of data in the database",
+				ArgsUsage: " ",
+				Action:    utils.MigrateFlags(inspectDatabase),
+				Category:  "DATABASE COMMANDS",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.AncientFlag,
+					utils.AncientThresholdFlag,
+					utils.CacheFlag,
+					utils.LightModeFlag,
+					utils.TestnetFlag,
+					utils.RinkebyFlag,
+				},
+				Description: `
+geth db inspect
+walks the entire chain database, reporting the number and the size of the
+entries of each kind of data: headers, bodies, receipts, transaction lookups,
+trie nodes, snapshots and so on. The ancient tables are reported after them if
+the chain segments are frozen.`,
+			},
+			{
+				Name:      "prune-history",
+				Usage:     "Delete the receipts and transaction lookups of a block range",
+				ArgsUsage: "<blockNumFirst> <blockNumLast>",
+				Action:    utils.MigrateFlags(pruneHistory),
+				Category:  "DATABASE COMMANDS",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.AncientFlag,
+					utils.AncientThresholdFlag,
+					utils.CacheFlag,
+					utils.TestnetFlag,
+					utils.RinkebyFlag,
+				},
+				Description: `
+geth db prune-history <blockNumFirst> <blockNumLast>
+deletes the receipts of the blocks numbered from blockNumFirst up to but
+excluding blockNumLast, and the lookup entries of their transactions, which
+can't be retrieved by hash afterwards. The range must end at the head block
+at the latest. Receipts moved to the ancient store are kept.
+
+The pruning must not run alongside the node.`,
+			},
+		},
+	}
+)
+
+// inspectDatabase reports the storage size of each kind of data in the chain
+// database.
+func inspectDatabase(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+
+	start := time.Now()
+	stats, err := rawdb.InspectDatabase(chainDb)
+	if err != nil {
+		utils.Fatalf("Failed to inspect the database: %v", err)
+	}
+	var (
+		table = tablewriter.NewWriter(os.Stdout)
+		items uint64
+		size  common.StorageSize
+	)
+	table.SetAutoFormatHeaders(false) // Would mangle the sizes in the footer
+	table.SetHeader([]string{"Data", "Items", "Size"})
+	for _, stat := range stats {
+		table.Append([]string{stat.Name, strconv.FormatUint(stat.Items, 10), stat.Size.String()})
+		items, size = items+stat.Items, size+stat.Size
+	}
+	table.SetFooter([]string{"Total", strconv.FormatUint(items, 10), size.String()})
+	table.Render()
+
+	log.Info("Inspected the database", "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// pruneHistory deletes the receipts and transaction lookups of a block range
+// from the chain database.
+func pruneHistory(ctx *cli.Context) error {
+	if len(ctx.Args()) != 2 {
+		utils.Fatalf("This command requires the first and last block numbers of the range.")
+	}
+	first, ferr := strconv.ParseUint(ctx.Args().Get(0), 10, 64)
+	last, lerr := strconv.ParseUint(ctx.Args().Get(1), 10, 64)
+	if ferr != nil || lerr != nil {
+		utils.Fatalf("Block numbers must be unsigned integers")
+	}
+	if first >= last {
+		utils.Fatalf("Empty block range: %d-%d", first, last)
+	}
+	stack, _ := makeConfigNode(ctx)
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+
+	head := rawdb.ReadHeaderNumber(chainDb, rawdb.ReadHeadBlockHash(chainDb))
+	if head == nil || last > *head {
+		utils.Fatalf("Block range %d-%d ends past the head block", first, last)
+	}
+	start := time.Now()
+	receipts, err := rawdb.DeleteReceiptsRange(chainDb, first, last)
+	if err != nil {
+		utils.Fatalf("Failed to delete receipts: %v", err)
+	}
+	lookups, err := rawdb.DeleteTxLookupsRange(chainDb, first, last)
+	if err != nil {
+		utils.Fatalf("Failed to delete transaction lookups: %v", err)
+	}
+	log.Info("Pruned history", "first", first, "last", last, "receipts", receipts, "lookups", lookups, "elapsed", common.PrettyDuration(time.Since(start)))
+
+	// Reclaim the disk space of the deleted entries
+	cstart := time.Now()
+	log.Info("Compacting database")
+	if err := chainDb.Compact(nil, nil); err != nil {
+		utils.Fatalf("Compaction failed: %v", err)
+	}
+	log.Info("Compacted database", "elapsed", common.PrettyDuration(time.Since(cstart)))
+	return nil
+}
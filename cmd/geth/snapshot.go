@@ -0,0 +1,105 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/core/state/pruner"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	pruneRetainFlag = cli.Uint64Flag{
+		Name:  "retain",
+		Usage: "Number of recent block states to retain",
+		Value: 128,
+	}
+	pruneBloomSizeFlag = cli.Uint64Flag{
+		Name:  "bloomfilter.size",
+		Usage: "Megabytes of memory allocated to the bloom filter marking the retained state",
+		Value: 512,
+	}
+	pruneDryRunFlag = cli.BoolFlag{
+		Name:  "dry-run",
+		Usage: "Report the stale state without deleting it",
+	}
+)
+
+var (
+	snapshotCommand = cli.Command{
+		Name:        "snapshot",
+		Usage:       "A set of commands based on the state database",
+		Category:    "MISCELLANEOUS COMMANDS",
+		Description: "",
+		Subcommands: []cli.Command{
+			{
+				Name:      "prune-state",
+				Usage:     "Prune stale state data",
+				ArgsUsage: " ",
+				Action:    utils.MigrateFlags(pruneState),
+				Category:  "MISCELLANEOUS COMMANDS",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.AncientFlag,
+					utils.AncientThresholdFlag,
+					utils.CacheFlag,
+					utils.TestnetFlag,
+					utils.RinkebyFlag,
+					pruneRetainFlag,
+					pruneBloomSizeFlag,
+					pruneDryRunFlag,
+				},
+				Description: `
+geth snapshot prune-state
+will prune the state of a full node which is no longer reachable from the
+states of the most recent blocks, as set with --retain. The node must have
+been shut down cleanly, so that the state of its head block was written.
+
+Every trie node and contract code of the retained states is marked in a bloom
+filter, then every other state entry is deleted. The bloom filter is saved in
+the data directory, so that an interrupted pruning resumes without marking
+the state again, unless the chain progressed meanwhile.
+
+With --dry-run, the stale state is only reported.
+
+The pruning may take hours for large states and must not run alongside the
+node.`,
+			},
+		},
+	}
+)
+
+// pruneState prunes the stale state of the node's chain database.
+func pruneState(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+
+	statePruner, err := pruner.NewPruner(chainDb, pruner.Config{
+		Retain:    ctx.Uint64(pruneRetainFlag.Name),
+		BloomSize: ctx.Uint64(pruneBloomSizeFlag.Name),
+		BloomPath: stack.ResolvePath("statebloom.bf"),
+		DryRun:    ctx.Bool(pruneDryRunFlag.Name),
+	})
+	if err != nil {
+		utils.Fatalf("Failed to create the state pruner: %v", err)
+	}
+	if err := statePruner.Prune(); err != nil {
+		utils.Fatalf("Failed to prune the state: %v", err)
+	}
+	return nil
+}
@@ -33,6 +33,7 @@ type Compiler struct {
 	binary []interface{}
 
 	labels map[string]int
+	macros map[int]macro // expanded macros by the position of their element
 
 	pc, pos int
 
@@ -43,6 +44,7 @@ type Compiler struct {
 func NewCompiler(debug bool) *Compiler {
 	return &Compiler{
 		labels: make(map[string]int),
+		macros: make(map[int]macro),
 		debug:  debug,
 	}
 }
@@ -57,7 +59,25 @@ func NewCompiler(debug bool) *Compiler {
 // second stage to push labels and determine the right
 // position.
 func (c *Compiler) Feed(ch <-chan token) {
+	var (
+		macroPos  = -1 // position of the pending macro element, if any
+		macroArgs []token
+	)
 	for i := range ch {
+		if macroPos >= 0 {
+			// Collect the macro arguments up to the end of the line.
+			if i.typ != lineEnd && i.typ != eof {
+				macroArgs = append(macroArgs, i)
+				c.tokens = append(c.tokens, i)
+				continue
+			}
+			code, err := expandENI(c.tokens[macroPos], macroArgs)
+			c.macros[macroPos] = macro{len(macroArgs), code, err}
+			if err == nil {
+				c.pc += len(code) - 1 // the element was counted as an opcode
+			}
+			macroPos, macroArgs = -1, nil
+		}
 		switch i.typ {
 		case number:
 			num := math.MustParseBig256(i.text).Bytes()
@@ -68,6 +88,9 @@ func (c *Compiler) Feed(ch <-chan token) {
 		case stringValue:
 			c.pc += len(i.text) - 2
 		case element:
+			if isENIMacro(i) {
+				macroPos = len(c.tokens)
+			}
 			c.pc++
 		case labelDef:
 			c.labels[i.text] = c.pc
@@ -165,6 +188,15 @@ func (c *Compiler) compileNumber(element token) (int, error) {
 // to a binary representation and may error if incorrect statements
 // where fed.
 func (c *Compiler) compileElement(element token) error {
+	// macros were expanded while feeding, skip over their arguments.
+	if m, ok := c.macros[c.pos-1]; ok {
+		c.pos += m.args
+		if m.err != nil {
+			return m.err
+		}
+		c.pushBin(m.code)
+		return nil
+	}
 	// check for a jump. jumps must be read and compiled
 	// from right to left.
 	if isJump(element.text) {
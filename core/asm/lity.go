@@ -0,0 +1,477 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package asm
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/core/vm/eni"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// maxTrackedMemory bounds the memory offsets followed by the analysis.
+const maxTrackedMemory = 1 << 20
+
+// instruction is a single disassembled instruction.
+type instruction struct {
+	pc  uint64
+	op  vm.OpCode
+	arg []byte
+}
+
+// memoryWriters are the instructions writing memory the analysis does not
+// model, their writes discard whatever is known about the memory.
+var memoryWriters = map[vm.OpCode]bool{
+	vm.CALLDATACOPY: true, vm.EXTCODECOPY: true, vm.RETURNDATACOPY: true, vm.CALL: true,
+	vm.CALLCODE: true, vm.DELEGATECALL: true, vm.STATICCALL: true, vm.ENI: true,
+}
+
+// checkedOps describe the arithmetic Lity checks for overflow, wherever the
+// instructions are valid.
+var checkedOps = map[vm.OpCode]string{
+	vm.ADD:   "checked: fails on unsigned overflow",
+	vm.SUB:   "checked: fails on unsigned underflow",
+	vm.MUL:   "checked: fails on unsigned overflow",
+	vm.SADD:  "checked: fails on signed overflow",
+	vm.SSUB:  "checked: fails on signed overflow",
+	vm.SMUL:  "checked: fails on signed overflow",
+	vm.FMUL:  "checked fixed point: fails on unsigned overflow",
+	vm.SFMUL: "checked fixed point: fails on signed overflow",
+}
+
+// blockState holds what is statically known about the stack and memory
+// within a basic block. Unknown stack items are nil, the bottom of the stack
+// beyond the tracked items is unknown as well.
+type blockState struct {
+	stack  []*big.Int
+	memory map[uint64]byte
+}
+
+func newBlockState() *blockState {
+	return &blockState{memory: make(map[uint64]byte)}
+}
+
+// back returns the n'th item from the top of the stack, or nil if unknown.
+func (s *blockState) back(n int) *big.Int {
+	if n < len(s.stack) {
+		return s.stack[len(s.stack)-1-n]
+	}
+	return nil
+}
+
+func (s *blockState) push(v *big.Int) {
+	s.stack = append(s.stack, v)
+}
+
+func (s *blockState) pop(n int) {
+	if n > len(s.stack) {
+		n = len(s.stack)
+	}
+	s.stack = s.stack[:len(s.stack)-n]
+}
+
+// offset returns the n'th item from the top of the stack as a memory offset,
+// or false if it is unknown or beyond the tracked memory.
+func (s *blockState) offset(n int) (uint64, bool) {
+	v := s.back(n)
+	if v == nil || v.Cmp(big.NewInt(maxTrackedMemory)) >= 0 {
+		return 0, false
+	}
+	return v.Uint64(), true
+}
+
+// store records size bytes of memory at offset, value nil marks them unknown.
+func (s *blockState) store(offset uint64, size int, value []byte) {
+	for i := 0; i < size; i++ {
+		if value == nil {
+			delete(s.memory, offset+uint64(i))
+		} else {
+			s.memory[offset+uint64(i)] = value[i]
+		}
+	}
+}
+
+// load returns size bytes of memory at offset, or false if any is unknown.
+func (s *blockState) load(offset uint64, size uint64) ([]byte, bool) {
+	if offset+size > maxTrackedMemory {
+		return nil, false
+	}
+	data := make([]byte, size)
+	for i := range data {
+		b, ok := s.memory[offset+uint64(i)]
+		if !ok {
+			return nil, false
+		}
+		data[i] = b
+	}
+	return data, true
+}
+
+// loadLength returns the length word at offset, or false if it is unknown or
+// beyond the tracked memory.
+func (s *blockState) loadLength(offset uint64) (uint64, bool) {
+	word, ok := s.load(offset, 32)
+	if !ok {
+		return 0, false
+	}
+	leng := new(big.Int).SetBytes(word)
+	if leng.Cmp(big.NewInt(maxTrackedMemory)) >= 0 {
+		return 0, false
+	}
+	return leng.Uint64(), true
+}
+
+// step applies the instruction to the state. Instructions of code are passed
+// to resolve constant code copies, the effect of the instructions which are
+// not modelled is looked up in set.
+func (s *blockState) step(in instruction, code []byte, set *vm.InstructionSet) {
+	switch op := in.op; {
+	case op.IsPush():
+		s.push(new(big.Int).SetBytes(in.arg))
+	case vm.DUP1 <= op && op <= vm.DUP16:
+		s.push(s.back(int(op - vm.DUP1)))
+	case vm.SWAP1 <= op && op <= vm.SWAP16:
+		n := int(op-vm.SWAP1) + 1
+		for len(s.stack) <= n {
+			s.stack = append([]*big.Int{nil}, s.stack...)
+		}
+		top := len(s.stack) - 1
+		s.stack[top], s.stack[top-n] = s.stack[top-n], s.stack[top]
+	case op == vm.POP:
+		s.pop(1)
+	case op == vm.ADD || op == vm.SUB || op == vm.MUL:
+		x, y := s.back(0), s.back(1)
+		s.pop(2)
+		if x == nil || y == nil {
+			s.push(nil)
+			break
+		}
+		switch op {
+		case vm.ADD:
+			s.push(math.U256(new(big.Int).Add(x, y)))
+		case vm.SUB:
+			s.push(math.U256(new(big.Int).Sub(x, y)))
+		default:
+			s.push(math.U256(new(big.Int).Mul(x, y)))
+		}
+	case op == vm.MSTORE || op == vm.MSTORE8:
+		size := 32
+		if op == vm.MSTORE8 {
+			size = 1
+		}
+		offset, ok := s.offset(0)
+		if !ok {
+			s.memory = make(map[uint64]byte)
+		} else if value := s.back(1); value == nil {
+			s.store(offset, size, nil)
+		} else {
+			s.store(offset, size, math.PaddedBigBytes(math.U256(new(big.Int).Set(value)), 32)[32-size:])
+		}
+		s.pop(2)
+	case op == vm.CODECOPY:
+		dest, destOk := s.offset(0)
+		from, fromOk := s.offset(1)
+		size, sizeOk := s.offset(2)
+		if !destOk || !sizeOk || dest+size > maxTrackedMemory {
+			s.memory = make(map[uint64]byte)
+		} else if !fromOk {
+			s.store(dest, int(size), nil)
+		} else {
+			s.store(dest, int(size), common.RightPadBytes(getData(code, from, size), int(size)))
+		}
+		s.pop(3)
+	default:
+		if !set.Valid(op) {
+			// Execution stops here, forget about the stack altogether.
+			s.stack = nil
+			return
+		}
+		if memoryWriters[op] {
+			s.memory = make(map[uint64]byte)
+		}
+		pop, push := set.StackEffect(op)
+		s.pop(pop)
+		for i := 0; i < push; i++ {
+			s.push(nil)
+		}
+	}
+}
+
+// getData returns the code in [from, from+size), cut at the end of code.
+func getData(code []byte, from, size uint64) []byte {
+	if from >= uint64(len(code)) {
+		return nil
+	}
+	end := from + size
+	if end > uint64(len(code)) {
+		end = uint64(len(code))
+	}
+	return code[from:end]
+}
+
+// describeENI describes the ENI call about to be made in the given state, as
+// its function name and argument and return types, as far as they are known.
+func (s *blockState) describeENI() string {
+	name := "?"
+	if fn := s.back(0); fn != nil {
+		name = strings.Trim(string(fn.Bytes()), "\x00")
+	}
+	args, rets := "?", "?"
+	if typeOffset, ok := s.offset(1); ok {
+		if argsLength, ok := s.loadLength(typeOffset); ok {
+			if info, ok := s.load(typeOffset+32, argsLength); ok {
+				args = describeTypes(info)
+			}
+			// The return types follow the argument types, aligned to a word.
+			retOffset := typeOffset + 32 + argsLength
+			if retOffset%32 > 0 {
+				retOffset += 32 - retOffset%32
+			}
+			if retLength, ok := s.loadLength(retOffset); ok {
+				if info, ok := s.load(retOffset+32, retLength); ok {
+					rets = describeTypes(info)
+				}
+			}
+		}
+	}
+	return fmt.Sprintf("eni %s(%s) returns (%s)", name, args, rets)
+}
+
+func describeTypes(info []byte) string {
+	types, err := eni.DescribeTypes(info)
+	if err != nil {
+		return "invalid"
+	}
+	return strings.Join(types, ",")
+}
+
+// describeSchedule describes the SCHEDULE emission about to be made in the
+// given state.
+func (s *blockState) describeSchedule() string {
+	receiver, unixtime := "?", "?"
+	if v := s.back(2); v != nil {
+		receiver = common.BigToAddress(v).Hex()
+	}
+	if v := s.back(1); v != nil {
+		unixtime = v.String()
+	}
+	return fmt.Sprintf("schedule to %s at %s", receiver, unixtime)
+}
+
+// disassemble splits code into its instructions.
+func disassemble(script []byte) ([]instruction, error) {
+	var instrs []instruction
+	it := NewInstructionIterator(script)
+	for it.Next() {
+		instrs = append(instrs, instruction{it.PC(), it.Op(), it.Arg()})
+	}
+	return instrs, it.Error()
+}
+
+// Annotate analyses code for the semantics Lity adds to the EVM at the given
+// block and returns comments on the instructions, keyed by their program
+// counter:
+//   - ENI calls with the function name and argument and return types,
+//   - SCHEDULE emissions with their receiver and due time,
+//   - arithmetic checked for overflow,
+//   - the ABI selectors of the dispatcher, flagging the freegas functions.
+//
+// Operands are found by following the constants within each basic block, those
+// which are computed at runtime are shown as "?". Code executing before the
+// Lity fork gets no comments.
+func Annotate(script []byte, config *params.ChainConfig, number *big.Int) (map[uint64]string, error) {
+	instrs, err := disassemble(script)
+	if err != nil {
+		return nil, err
+	}
+	notes := make(map[uint64]string)
+	if !config.IsLity(number) {
+		return notes, nil
+	}
+	set := vm.NewInstructionSet(config, number)
+
+	state := newBlockState()
+	for _, in := range instrs {
+		if set.Valid(in.op) {
+			switch in.op {
+			case vm.JUMPDEST:
+				state = newBlockState()
+			case vm.ENI:
+				notes[in.pc] = state.describeENI()
+			case vm.SCHEDULE:
+				notes[in.pc] = state.describeSchedule()
+			case vm.FREEGAS:
+				notes[in.pc] = "freegas: the contract pays for the transaction"
+			default:
+				if note, ok := checkedOps[in.op]; ok {
+					notes[in.pc] = note
+				}
+			}
+		}
+		state.step(in, script, set)
+	}
+	for _, fn := range dispatch(instrs) {
+		note := fmt.Sprintf("function 0x%x", fn.selector)
+		if fn.freeGas {
+			note += " (freegas)"
+		}
+		notes[fn.pc] = note
+	}
+	return notes, nil
+}
+
+// abiFunction is an entry of the function dispatcher.
+type abiFunction struct {
+	pc       uint64 // Position of the selector push
+	selector [4]byte
+	entry    uint64 // Jump destination of the function
+	freeGas  bool   // Whether FREEGAS is reachable from the entry
+}
+
+// dispatch recognises the function dispatcher, comparing the selector of the
+// call data against a pushed constant and jumping to the matching function:
+//
+//	PUSH4 selector, [DUP] EQ, PUSH entry, JUMPI
+func dispatch(instrs []instruction) []abiFunction {
+	index := make(map[uint64]int)
+	for i, in := range instrs {
+		index[in.pc] = i
+	}
+	var fns []abiFunction
+	for i, in := range instrs {
+		if in.op != vm.PUSH4 {
+			continue
+		}
+		j := i + 1
+		if j < len(instrs) && vm.DUP1 <= instrs[j].op && instrs[j].op <= vm.DUP16 {
+			j++
+		}
+		if j+2 >= len(instrs) || instrs[j].op != vm.EQ || !instrs[j+1].op.IsPush() || instrs[j+2].op != vm.JUMPI {
+			continue
+		}
+		fn := abiFunction{pc: in.pc, entry: new(big.Int).SetBytes(instrs[j+1].arg).Uint64()}
+		copy(fn.selector[:], in.arg)
+		fn.freeGas = reaches(instrs, index, fn.entry, vm.FREEGAS)
+		fns = append(fns, fn)
+	}
+	return fns
+}
+
+// reaches reports whether op may execute from the jump destination at pc,
+// following the jumps to constant destinations. Instructions are indexed by
+// their program counter in index.
+func reaches(instrs []instruction, index map[uint64]int, pc uint64, op vm.OpCode) bool {
+	var (
+		visited = make(map[int]bool)
+		queue   []int
+	)
+	enqueue := func(dest uint64) {
+		if i, ok := index[dest]; ok && instrs[i].op == vm.JUMPDEST && !visited[i] {
+			visited[i] = true
+			queue = append(queue, i)
+		}
+	}
+	enqueue(pc)
+	for len(queue) > 0 {
+		start := queue[0]
+		queue = queue[1:]
+	scan:
+		for i := start; i < len(instrs); i++ {
+			switch in := instrs[i]; in.op {
+			case op:
+				return true
+			case vm.JUMP, vm.JUMPI:
+				if i > 0 && instrs[i-1].op.IsPush() {
+					enqueue(new(big.Int).SetBytes(instrs[i-1].arg).Uint64())
+				}
+				if in.op == vm.JUMP {
+					break scan
+				}
+			case vm.STOP, vm.RETURN, vm.REVERT, vm.SELFDESTRUCT:
+				break scan
+			case vm.JUMPDEST:
+				// Falling through into another block, unless already scanned.
+				if i != start {
+					if visited[i] {
+						break scan
+					}
+					visited[i] = true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// FreeGasSelectors returns the ABI selectors of the functions of the code
+// which may execute FREEGAS.
+func FreeGasSelectors(script []byte) ([][4]byte, error) {
+	instrs, err := disassemble(script)
+	if err != nil {
+		return nil, err
+	}
+	var selectors [][4]byte
+	for _, fn := range dispatch(instrs) {
+		if fn.freeGas {
+			selectors = append(selectors, fn.selector)
+		}
+	}
+	return selectors, nil
+}
+
+// PrintAnnotated pretty-prints the disassembled EVM instructions to stdout,
+// with the comments of Annotate.
+func PrintAnnotated(code string, config *params.ChainConfig, number *big.Int) error {
+	script, err := hex.DecodeString(code)
+	if err != nil {
+		return err
+	}
+	instrs, err := DisassembleAnnotated(script, config, number)
+	for _, instr := range instrs {
+		fmt.Print(instr)
+	}
+	return err
+}
+
+// DisassembleAnnotated returns all disassembled EVM instructions in
+// human-readable format, with the comments of Annotate.
+func DisassembleAnnotated(script []byte, config *params.ChainConfig, number *big.Int) ([]string, error) {
+	notes, err := Annotate(script, config, number)
+	if err != nil {
+		return nil, err
+	}
+	instrs := make([]string, 0)
+
+	it := NewInstructionIterator(script)
+	for it.Next() {
+		instr := fmt.Sprintf("%06v: %v", it.PC(), it.Op())
+		if it.Arg() != nil && 0 < len(it.Arg()) {
+			instr += fmt.Sprintf(" 0x%x", it.Arg())
+		}
+		if note, ok := notes[it.PC()]; ok {
+			instr += " ; " + note
+		}
+		instrs = append(instrs, instr+"\n")
+	}
+	return instrs, nil
+}
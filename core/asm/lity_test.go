@@ -0,0 +1,205 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package asm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func compile(t *testing.T, src string) []byte {
+	c := NewCompiler(false)
+	c.Feed(Lex("test.asm", []byte(src), false))
+	bin, errs := c.Compile()
+	if len(errs) > 0 {
+		t.Fatalf("failed to compile %q: %v", src, errs)
+	}
+	code, err := hex.DecodeString(bin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+// notes returns the annotations of the given instructions on a chain with
+// every fork enabled.
+func notes(t *testing.T, code []byte) map[string][]string {
+	return notesAt(t, code, params.TestChainConfig)
+}
+
+// notesAt returns the annotations of the given instructions at the genesis
+// block of the given chain.
+func notesAt(t *testing.T, code []byte, config *params.ChainConfig) map[string][]string {
+	annotations, err := Annotate(code, config, new(big.Int))
+	if err != nil {
+		t.Fatal(err)
+	}
+	notes := make(map[string][]string)
+	it := NewInstructionIterator(code)
+	for it.Next() {
+		if note, ok := annotations[it.PC()]; ok {
+			notes[it.Op().String()] = append(notes[it.Op().String()], note)
+		}
+	}
+	return notes
+}
+
+// Tests that ENI calls assembled by the macro are annotated with their types.
+func TestENIMacroAnnotation(t *testing.T) {
+	code := compile(t, `eni_call "add" uint 3 uint8 4 returns uint
+eni_call "reverse" string "hello" bool true returns string
+eni_call "now"
+jump @end
+end:
+`)
+	want := []string{
+		"eni add(uint,uint8) returns (uint)",
+		"eni reverse(string,bool) returns (string)",
+		"eni now() returns ()",
+	}
+	if have := notes(t, code)["ENI"]; strings.Join(have, "\n") != strings.Join(want, "\n") {
+		t.Errorf("ENI annotations mismatch:\nhave %q\nwant %q", have, want)
+	}
+	// The label following the macros must be placed past their expansion.
+	if op := code[len(code)-1]; vm.OpCode(op) != vm.JUMPDEST {
+		t.Fatalf("code doesn't end with a JUMPDEST: %x", code)
+	}
+	if have, want := int(binary.BigEndian.Uint32(code[len(code)-6:])), len(code)-1; have != want {
+		t.Errorf("label position mismatch: have %d, want %d", have, want)
+	}
+}
+
+// Tests that a plain ENI element compiles to the bare instruction.
+func TestENIInstruction(t *testing.T) {
+	if code := compile(t, "eni\n"); !bytes.Equal(code, []byte{byte(vm.ENI)}) {
+		t.Errorf("code mismatch: have %x, want %x", code, []byte{byte(vm.ENI)})
+	}
+}
+
+func TestENIMacroErrors(t *testing.T) {
+	for _, src := range []string{
+		`eni_call`,
+		`eni_call add uint 3`,
+		`eni_call "add" uint`,
+		`eni_call "add" uint7 3`,
+		`eni_call "add" uint8 256`,
+		`eni_call "add" string 3`,
+		`eni_call "add" uint 3 returns`,
+	} {
+		c := NewCompiler(false)
+		c.Feed(Lex("test.asm", []byte(src), false))
+		if _, errs := c.Compile(); len(errs) == 0 {
+			t.Errorf("%q: expected compile error", src)
+		}
+	}
+}
+
+func TestScheduleAnnotation(t *testing.T) {
+	code := compile(t, `push 0xc0ffee
+push 1546300800
+push 0x1234
+schedule
+calldataload
+schedule
+`)
+	want := []string{
+		"schedule to 0x0000000000000000000000000000000000C0FFEE at 1546300800",
+		"schedule to ? at ?",
+	}
+	if have := notes(t, code)["SCHEDULE"]; strings.Join(have, "\n") != strings.Join(want, "\n") {
+		t.Errorf("SCHEDULE annotations mismatch:\nhave %q\nwant %q", have, want)
+	}
+}
+
+// Tests that the functions of the dispatcher executing FREEGAS are detected.
+func TestFreeGasSelectors(t *testing.T) {
+	code := compile(t, `push 0
+calldataload
+push 0xe0
+shr
+dup1
+push 0x11111111
+eq
+jumpi @paid
+dup1
+push 0x22222222
+eq
+jumpi @free
+stop
+paid:
+add
+stop
+free:
+jump @body
+body:
+freegas
+stop
+`)
+	selectors, err := FreeGasSelectors(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(selectors) != 1 || selectors[0] != [4]byte{0x22, 0x22, 0x22, 0x22} {
+		t.Errorf("freegas selectors mismatch: have %x", selectors)
+	}
+	want := []string{"function 0x11111111", "function 0x22222222 (freegas)"}
+	if have := notes(t, code)["PUSH4"]; strings.Join(have, "\n") != strings.Join(want, "\n") {
+		t.Errorf("selector annotations mismatch:\nhave %q\nwant %q", have, want)
+	}
+	if have := notes(t, code)["ADD"]; len(have) != 1 || have[0] != checkedOps[vm.ADD] {
+		t.Errorf("checked arithmetic annotation mismatch: have %q", have)
+	}
+}
+
+// Tests that arithmetic is only annotated as checked past the Lity fork.
+func TestCheckedAnnotationForks(t *testing.T) {
+	code := compile(t, `add
+push 0
+push 1
+push 2
+fmul
+`)
+	lity := *params.MainnetChainConfig
+	lity.LityBlock = new(big.Int)
+
+	tests := []struct {
+		config *params.ChainConfig
+		want   []string
+	}{
+		{params.MainnetChainConfig, nil},
+		{&lity, []string{"ADD", "FMUL"}},
+	}
+	for i, tt := range tests {
+		notes := notesAt(t, code, tt.config)
+		var have []string
+		for _, op := range []string{"ADD", "FMUL"} {
+			if len(notes[op]) > 0 {
+				have = append(have, op)
+			}
+		}
+		if strings.Join(have, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("test %d: checked instructions mismatch: have %v, want %v", i, have, tt.want)
+		}
+	}
+}
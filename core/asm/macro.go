@@ -0,0 +1,199 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package asm
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/core/vm/eni"
+)
+
+// eniTypeOffset is where the ENI macro lays out the type information, past
+// the scratch space, free memory pointer and zero slot reserved by Solidity.
+const eniTypeOffset = 0x80
+
+// macro is an expanded macro, along with the number of tokens it spans after
+// the macro element.
+type macro struct {
+	args int
+	code []byte
+	err  error
+}
+
+// isENIMacro returns whether the element starts an ENI call macro, the element
+// followed by its arguments:
+//
+//	eni_call "function" type value type value ... returns type ...
+//
+// A plain ENI element compiles to the bare instruction.
+// Supported types are the value types, string and bytes, with numbers for
+// the values of value types, true or false for bools and strings otherwise.
+// The macro lays the type information and data out in memory from 0x80 and
+// leaves the offset of the returned data on the stack.
+func isENIMacro(element token) bool {
+	return strings.ToUpper(element.text) == "ENI_CALL"
+}
+
+// expandENI expands the arguments of an ENI call macro into code.
+func expandENI(call token, args []token) ([]byte, error) {
+	if len(args) == 0 {
+		return nil, compileErr(call, "end of line", "function name")
+	}
+	if args[0].typ != stringValue {
+		return nil, compileErr(args[0], args[0].text, "function name")
+	}
+	function := unquote(args[0])
+	if len(function) == 0 || len(function) > 32 {
+		return nil, fmt.Errorf("%d type error: function name must be 1 to 32 bytes", args[0].lineno)
+	}
+	var (
+		argTypes, retTypes []byte
+		data               []byte
+		returns            bool
+	)
+	for i := 1; i < len(args); i++ {
+		if args[i].typ != element {
+			return nil, compileErr(args[i], args[i].text, "type")
+		}
+		if args[i].text == "returns" && !returns {
+			returns = true
+			continue
+		}
+		t, ok := eni.TypeCode(args[i].text)
+		if !ok || t == eni.STRINGPTR {
+			return nil, fmt.Errorf("%d type error: unsupported ENI type %s", args[i].lineno, args[i].text)
+		}
+		if returns {
+			retTypes = append(retTypes, t)
+			continue
+		}
+		if i++; i == len(args) {
+			return nil, compileErr(args[i-1], "end of line", "value")
+		}
+		value, err := encodeENIValue(t, args[i])
+		if err != nil {
+			return nil, err
+		}
+		argTypes = append(argTypes, t)
+		data = append(data, value...)
+	}
+	if returns && len(retTypes) == 0 {
+		return nil, compileErr(args[len(args)-1], "end of line", "type")
+	}
+	// Lay out the argument types, then the return types and the data, each
+	// prefixed by its length and aligned to a word.
+	var memory []byte
+	memory = append(memory, lengthWord(len(argTypes))...)
+	memory = append(memory, common.RightPadBytes(argTypes, wordSize(len(argTypes)))...)
+	memory = append(memory, lengthWord(len(retTypes))...)
+	memory = append(memory, common.RightPadBytes(retTypes, wordSize(len(retTypes)))...)
+	dataOffset := eniTypeOffset + len(memory)
+	memory = append(memory, lengthWord(len(data))...)
+	memory = append(memory, data...)
+
+	var code []byte
+	for i := 0; i < len(memory); i += 32 {
+		code = appendPush(code, memory[i:i+32])
+		code = appendPush(code, big.NewInt(int64(eniTypeOffset+i)).Bytes())
+		code = append(code, byte(vm.MSTORE))
+	}
+	code = appendPush(code, big.NewInt(int64(dataOffset)).Bytes())
+	code = appendPush(code, big.NewInt(eniTypeOffset).Bytes())
+	code = append(code, byte(vm.PUSH1)+byte(len(function)-1))
+	code = append(code, function...)
+	return append(code, byte(vm.ENI)), nil
+}
+
+// encodeENIValue encodes the value of an argument of type t in ENI encoding.
+func encodeENIValue(t byte, value token) ([]byte, error) {
+	if t == eni.STRING || t == eni.BYTES {
+		if value.typ != stringValue {
+			return nil, compileErr(value, value.text, "string")
+		}
+		content := []byte(unquote(value))
+		return append(lengthWord(len(content)), common.RightPadBytes(content, wordSize(len(content)))...), nil
+	}
+	var (
+		num  *big.Int
+		bits int
+	)
+	switch {
+	case t == eni.BOOL && value.typ == element && (value.text == "true" || value.text == "false"):
+		num = new(big.Int)
+		if value.text == "true" {
+			num.SetInt64(1)
+		}
+	case value.typ == number:
+		var ok bool
+		if num, ok = math.ParseBig256(value.text); !ok {
+			return nil, compileErr(value, value.text, "number")
+		}
+	default:
+		return nil, compileErr(value, value.text, "number")
+	}
+	switch {
+	case t == eni.BOOL:
+		bits = 1
+	case t == eni.ADDRESS:
+		bits = 160
+	case t == eni.ENUM:
+		bits = 8
+	case t == eni.INT:
+		bits = 255 // positive values only, the sign bit must be clear
+	case eni.IsSint(t):
+		bits = 8*int(t-eni.INT8+1) - 1
+	case t == eni.UINT:
+		bits = 256
+	case eni.UINT8 <= t && t <= eni.UINT256:
+		bits = 8 * int(t-eni.UINT8+1)
+	default:
+		bits = 8 * int(t-eni.BYTE1+1)
+	}
+	if num.BitLen() > bits {
+		return nil, fmt.Errorf("%d type error: %s out of range for %s", value.lineno, value.text, eni.TypeName(t))
+	}
+	return math.PaddedBigBytes(num, 32), nil
+}
+
+// unquote returns the contents of a string token.
+func unquote(s token) string {
+	return s.text[1 : len(s.text)-1]
+}
+
+// wordSize returns n rounded up to a multiple of the word size.
+func wordSize(n int) int {
+	return (n + 31) / 32 * 32
+}
+
+func lengthWord(n int) []byte {
+	return math.PaddedBigBytes(big.NewInt(int64(n)), 32)
+}
+
+// appendPush appends the shortest push of value to code.
+func appendPush(code []byte, value []byte) []byte {
+	value = new(big.Int).SetBytes(value).Bytes()
+	if len(value) == 0 {
+		value = []byte{0}
+	}
+	code = append(code, byte(vm.PUSH1)+byte(len(value)-1))
+	return append(code, value...)
+}
@@ -19,9 +19,11 @@ package core
 import (
 	"fmt"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm/umbrella"
 	"github.com/ethereum/go-ethereum/params"
 )
 
@@ -70,6 +72,12 @@ func (v *BlockValidator) ValidateBody(block *types.Block) error {
 	if hash := types.DeriveSha(block.Transactions()); hash != header.TxHash {
 		return fmt.Errorf("transaction root hash mismatch: have %x, want %x", hash, header.TxHash)
 	}
+	// Past the RandBeacon fork, an umbrella providing beacons must have the block's
+	if v.config.IsRandBeacon(header.Number) {
+		if b, ok := v.bc.Umbrella().(umbrella.Beacon); ok && b.GetBeacon(header.Number) == (common.Hash{}) {
+			return errMissingBeacon
+		}
+	}
 	return nil
 }
 
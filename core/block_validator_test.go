@@ -17,13 +17,16 @@
 package core
 
 import (
+	"math/big"
 	"runtime"
 	"testing"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/core/vm/umbrella"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/params"
 )
@@ -197,3 +200,44 @@ func testHeaderConcurrentAbortion(t *testing.T, threads int) {
 		t.Errorf("verification count too large: have %d, want below %d", verified, 2*threads)
 	}
 }
+
+// beaconUmbrella is a standalone umbrella providing a randomness beacon.
+type beaconUmbrella struct {
+	*umbrella.Standalone
+	beacons map[uint64]common.Hash
+}
+
+func (u *beaconUmbrella) GetBeacon(number *big.Int) common.Hash {
+	return u.beacons[number.Uint64()]
+}
+
+// Tests that blocks past the RandBeacon fork mix the umbrella's beacon into
+// RAND, whatever their extra-data, and are rejected if the umbrella has none.
+func TestBeaconValidation(t *testing.T) {
+	var (
+		testdb  = ethdb.NewMemDatabase()
+		gspec   = &Genesis{Config: params.TestChainConfig}
+		genesis = gspec.MustCommit(testdb)
+		umb     = &beaconUmbrella{umbrella.NewStandalone(nil), map[uint64]common.Hash{1: {1}, 2: {2}}}
+	)
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), testdb, 3, func(i int, b *BlockGen) {
+		b.SetExtra([]byte("yeehaw"))
+	})
+	chain, _ := NewBlockChain(testdb, nil, params.TestChainConfig, ethash.NewFaker(), vm.Config{})
+	defer chain.Stop()
+
+	if beacon := BlockBeacon(chain.Umbrella(), blocks[0].Header()); beacon != genesis.Hash() {
+		t.Errorf("beacon without umbrella beacons mismatch: have %x, want parent hash %x", beacon, genesis.Hash())
+	}
+	chain.SetUmbrella(umb)
+	if beacon := BlockBeacon(chain.Umbrella(), blocks[0].Header()); beacon != (common.Hash{1}) {
+		t.Errorf("beacon mismatch: have %x, want %x", beacon, common.Hash{1})
+	}
+	if _, err := chain.InsertChain(blocks[:2]); err != nil {
+		t.Fatalf("failed to insert blocks with umbrella beacons: %v", err)
+	}
+	// The umbrella has no beacon for the third block.
+	if _, err := chain.InsertChain(blocks[2:]); err != errMissingBeacon {
+		t.Fatalf("error mismatch: have %v, want %v", err, errMissingBeacon)
+	}
+}
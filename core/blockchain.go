@@ -31,9 +31,12 @@ import (
 	"github.com/ethereum/go-ethereum/common/mclock"
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/schedule"
 	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/core/vm/eni"
 	"github.com/ethereum/go-ethereum/core/vm/umbrella"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
@@ -54,12 +57,13 @@ var (
 )
 
 const (
-	bodyCacheLimit      = 256
-	blockCacheLimit     = 256
-	maxFutureBlocks     = 256
-	maxTimeFutureBlocks = 30
-	badBlockLimit       = 10
-	triesInMemory       = 128
+	bodyCacheLimit       = 256
+	blockCacheLimit      = 256
+	maxFutureBlocks      = 256
+	maxTimeFutureBlocks  = 30
+	badBlockLimit        = 10
+	validatorsCacheLimit = 256
+	triesInMemory        = 128
 
 	// BlockChainVersion ensures that an incompatible database forces a resync from scratch.
 	BlockChainVersion = 3
@@ -71,6 +75,8 @@ type CacheConfig struct {
 	Disabled      bool          // Whether to disable trie write caching (archive node)
 	TrieNodeLimit int           // Memory limit (MB) at which to flush the current in-memory trie to disk
 	TrieTimeLimit time.Duration // Time limit after which to flush the current in-memory trie to disk
+
+	SnapshotLimit int // Memory allowance (MB) to cache the state snapshot entries, zero disables the snapshot
 }
 
 // BlockChain represents the canonical chain given a database with a genesis
@@ -113,11 +119,14 @@ type BlockChain struct {
 	currentFastBlock atomic.Value // Current head of the fast-sync chain (may be above the block chain!)
 
 	stateCache   state.Database // State database to reuse between imports (contains state cache)
+	snaps        *snapshot.Tree // Snapshot tree for fast state access, nil if disabled
 	bodyCache    *lru.Cache     // Cache for the most recent block bodies
 	bodyRLPCache *lru.Cache     // Cache for the most recent block bodies in RLP encoded format
 	blockCache   *lru.Cache     // Cache for the most recent entire blocks
 	futureBlocks *lru.Cache     // future blocks are blocks added for later processing
 
+	validatorsCache *lru.Cache // Cache for the most recent indexed validator sets
+
 	quit    chan struct{} // blockchain quit channel
 	running int32         // running must be called atomically
 	// procInterrupt must be atomically called
@@ -148,6 +157,7 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	blockCache, _ := lru.New(blockCacheLimit)
 	futureBlocks, _ := lru.New(maxFutureBlocks)
 	badBlocks, _ := lru.New(badBlockLimit)
+	validatorsCache, _ := lru.New(validatorsCacheLimit)
 
 	bc := &BlockChain{
 		chainConfig:  chainConfig,
@@ -162,7 +172,10 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 		futureBlocks: futureBlocks,
 		engine:       engine,
 		vmConfig:     vmConfig,
+		umbrella:     umbrella.NewStandalone(chainConfig.Umbrella),
 		badBlocks:    badBlocks,
+
+		validatorsCache: validatorsCache,
 	}
 	bc.SetValidator(NewBlockValidator(chainConfig, bc, engine))
 	bc.SetProcessor(NewStateProcessor(chainConfig, bc, engine))
@@ -192,6 +205,12 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 			}
 		}
 	}
+	// Load the state snapshot, regenerating it in the background if missing
+	if bc.cacheConfig.SnapshotLimit > 0 {
+		if bc.snaps, err = snapshot.New(db, bc.stateCache.TrieDB(), bc.cacheConfig.SnapshotLimit, bc.CurrentBlock().Root()); err != nil {
+			return nil, err
+		}
+	}
 	// Take ownership of this particular state
 	go bc.update()
 	return bc, nil
@@ -273,6 +292,7 @@ func (bc *BlockChain) SetHead(head uint64) error {
 	// Rewind the header chain, deleting all block bodies until then
 	delFn := func(db rawdb.DatabaseDeleter, hash common.Hash, num uint64) {
 		rawdb.DeleteBody(db, hash, num)
+		rawdb.DeleteValidators(db, num)
 	}
 	bc.hc.SetHead(head, delFn)
 	currentHeader := bc.hc.CurrentHeader()
@@ -282,6 +302,7 @@ func (bc *BlockChain) SetHead(head uint64) error {
 	bc.bodyRLPCache.Purge()
 	bc.blockCache.Purge()
 	bc.futureBlocks.Purge()
+	bc.validatorsCache.Purge()
 
 	// Rewind the block chain, ensuring we don't end up with a stateless head block
 	if currentBlock := bc.CurrentBlock(); currentBlock != nil && currentHeader.Number.Uint64() < currentBlock.NumberU64() {
@@ -385,7 +406,17 @@ func (bc *BlockChain) State() (*state.StateDB, error) {
 
 // StateAt returns a new mutable state based on a particular point in time.
 func (bc *BlockChain) StateAt(root common.Hash) (*state.StateDB, error) {
-	return state.New(root, bc.stateCache)
+	return state.NewWithSnapshot(root, bc.stateCache, bc.snaps)
+}
+
+// StateCache returns the caching database underpinning the blockchain instance.
+func (bc *BlockChain) StateCache() state.Database {
+	return bc.stateCache
+}
+
+// Snapshots returns the state snapshot tree of the blockchain, nil if disabled.
+func (bc *BlockChain) Snapshots() *snapshot.Tree {
+	return bc.snaps
 }
 
 // Reset purges the entire blockchain, restoring it to its genesis state.
@@ -655,6 +686,14 @@ func (bc *BlockChain) Stop() {
 
 	bc.wg.Wait()
 
+	// Journal the diff layers of the state snapshot up to the head block, or
+	// flatten them into the disk layer at the head block, whose state is stored
+	// to disk below, if the snapshot is still being generated
+	if bc.snaps != nil {
+		if err := bc.snaps.Journal(bc.CurrentBlock().Root()); err != nil {
+			log.Error("Failed to journal state snapshot", "err", err)
+		}
+	}
 	// Ensure the state of a recent block is also stored to disk before exiting.
 	// We're writing three different states to catch different restart scenarios:
 	//  - HEAD:     So we don't need to reprocess any blocks in the general case
@@ -741,18 +780,31 @@ func SetReceiptsData(config *params.ChainConfig, block *types.Block, receipts ty
 
 	transactions, logIndex := block.Transactions(), uint(0)
 	if len(transactions) != len(receipts) {
-		return errors.New("transaction and receipt count mismatch")
+		// The receipts of scheduled transactions precede those of the block's own
+		if !config.IsSchedule(block.Number()) || len(receipts) < len(transactions) {
+			return errors.New("transaction and receipt count mismatch")
+		}
 	}
+	scheduled := len(receipts) - len(transactions)
 
 	for j := 0; j < len(receipts); j++ {
-		// The transaction hash can be retrieved from the transaction itself
-		receipts[j].TxHash = transactions[j].Hash()
-
-		// The contract address can be derived from the transaction itself
-		if transactions[j].To() == nil {
-			// Deriving the signer is expensive, only do if it's actually needed
-			from, _ := types.Sender(signer, transactions[j])
-			receipts[j].ContractAddress = crypto.CreateAddress(from, transactions[j].Nonce())
+		// Scheduled transactions are identified by their position in the queue
+		// run, the block's own ones by their position in the block
+		index := j
+		if j < scheduled {
+			receipts[j].TxHash = schedule.TxHash(block.NumberU64(), j)
+		} else {
+			index = j - scheduled
+
+			// The transaction hash can be retrieved from the transaction itself
+			receipts[j].TxHash = transactions[index].Hash()
+
+			// The contract address can be derived from the transaction itself
+			if transactions[index].To() == nil {
+				// Deriving the signer is expensive, only do if it's actually needed
+				from, _ := types.Sender(signer, transactions[index])
+				receipts[j].ContractAddress = crypto.CreateAddress(from, transactions[index].Nonce())
+			}
 		}
 		// The used gas can be calculated based on previous receipts
 		if j == 0 {
@@ -765,7 +817,7 @@ func SetReceiptsData(config *params.ChainConfig, block *types.Block, receipts ty
 			receipts[j].Logs[k].BlockNumber = block.NumberU64()
 			receipts[j].Logs[k].BlockHash = block.Hash()
 			receipts[j].Logs[k].TxHash = receipts[j].TxHash
-			receipts[j].Logs[k].TxIndex = uint(j)
+			receipts[j].Logs[k].TxIndex = uint(index)
 			receipts[j].Logs[k].Index = logIndex
 			logIndex++
 		}
@@ -905,6 +957,13 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 	if err != nil {
 		return NonStatTy, err
 	}
+	// Aggregate the snapshot layers beyond the state tries retained in memory,
+	// flushing them into the disk layer once they outgrow their allowance
+	if bc.snaps != nil && bc.snaps.Snapshot(root) != nil {
+		if err := bc.snaps.Cap(root, triesInMemory-1); err != nil {
+			log.Warn("Failed to cap snapshot tree", "root", root, "err", err)
+		}
+	}
 	triedb := bc.stateCache.TrieDB()
 
 	// If we're running an archive node, always flush
@@ -954,6 +1013,7 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 		}
 	}
 	rawdb.WriteReceipts(batch, block.Hash(), block.NumberU64(), receipts)
+	bc.writeValidators(batch, block.NumberU64())
 
 	// If the total difficulty is higher than our known, add it to the canonical chain
 	// Second clause in the if statement reduces the vulnerability to selfish mining.
@@ -975,6 +1035,11 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 		rawdb.WriteTxLookupEntries(batch, block)
 		rawdb.WritePreimages(batch, block.NumberU64(), state.Preimages())
 
+		// The snapshot can't follow a chain forked off below its bottom layer,
+		// reload it from its journal if that has the state or regenerate it
+		if bc.snaps != nil && bc.snaps.Snapshot(root) == nil {
+			bc.snaps.Rebuild(root)
+		}
 		status = CanonStatTy
 	} else {
 		status = SideStatTy
@@ -1141,10 +1206,16 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 		} else {
 			parent = chain[i-1]
 		}
-		state, err := state.New(parent.Root(), bc.stateCache)
+		state, err := state.NewWithSnapshot(parent.Root(), bc.stateCache, bc.snaps)
 		if err != nil {
 			return i, events, coalescedLogs, err
 		}
+		// Enable the ENI library upgrades activated at this block, refusing it
+		// until they are available locally.
+		if err := eni.Activate(block.NumberU64()); err != nil {
+			log.Error("Failed to enable ENI upgrades", "number", block.Number(), "hash", block.Hash(), "err", err)
+			return i, events, coalescedLogs, err
+		}
 		// Process block using the parent state as reference point.
 		receipts, logs, usedGas, err := bc.processor.Process(block, state, bc.vmConfig)
 		if err != nil {
@@ -1553,16 +1624,47 @@ func (bc *BlockChain) Config() *params.ChainConfig { return bc.chainConfig }
 // Engine retrieves the blockchain's consensus engine.
 func (bc *BlockChain) Engine() consensus.Engine { return bc.engine }
 
-// SetUmbrella sets the umbrella which is used to communacate with Travis database.
+// SetUmbrella sets the umbrella which is used to communacate with Travis database,
+// replacing the standalone one configured by the chain config.
 func (bc *BlockChain) SetUmbrella(umbrella umbrella.Umbrella) {
 	bc.umbrella = umbrella
 }
 
 // Umbrella retrieves the blockchain's travis database interface.
 func (bc *BlockChain) Umbrella() umbrella.Umbrella {
+	// Blocks may be generated without any chain (see BlockGen.AddTx), leave
+	// it to the EVM to fall back to the standalone umbrella then.
+	if bc == nil {
+		return nil
+	}
 	return bc.umbrella
 }
 
+// GetValidatorsAt retrieves the validator set of the block with the given
+// number. Sets are indexed in the database as blocks are imported, so that
+// blocks replay with the set they were executed with whatever the umbrella
+// later says. The umbrella is asked for the sets of heights not imported yet.
+func (bc *BlockChain) GetValidatorsAt(number uint64) umbrella.ValidatorSet {
+	if set, ok := bc.validatorsCache.Get(number); ok {
+		return set.(umbrella.ValidatorSet)
+	}
+	validators := rawdb.ReadValidators(bc.db, number)
+	if validators == nil {
+		return umbrella.NewValidatorSet(bc.umbrella.GetValidatorsAt(new(big.Int).SetUint64(number)))
+	}
+	set := umbrella.NewValidatorSet(validators)
+	bc.validatorsCache.Add(number, set)
+	return set
+}
+
+// writeValidators indexes the validator set of an imported block, unless the
+// height already has one.
+func (bc *BlockChain) writeValidators(db rawdb.DatabaseWriter, number uint64) {
+	if rawdb.ReadValidators(bc.db, number) == nil {
+		rawdb.WriteValidators(db, number, bc.umbrella.GetValidatorsAt(new(big.Int).SetUint64(number)))
+	}
+}
+
 // SubscribeRemovedLogsEvent registers a subscription of RemovedLogsEvent.
 func (bc *BlockChain) SubscribeRemovedLogsEvent(ch chan<- RemovedLogsEvent) event.Subscription {
 	return bc.scope.Track(bc.rmLogsFeed.Subscribe(ch))
@@ -31,9 +31,11 @@ import (
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/core/vm/umbrella"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
 )
 
 // So we can deterministically seed different blockchains
@@ -1374,6 +1376,159 @@ func TestLargeReorgTrieGC(t *testing.T) {
 	}
 }
 
+// Tests that the state snapshot follows the chain, flattening the layers beyond
+// the retained tries, and that it's persisted and reloaded across restarts.
+func TestSnapshotChain(t *testing.T) {
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		gspec   = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  GenesisAlloc{address: {Balance: big.NewInt(1000000000)}},
+		}
+		engine = ethash.NewFaker()
+		db     = ethdb.NewMemDatabase()
+		signer = types.NewEIP155Signer(gspec.Config.ChainID)
+
+		// Stores the block number at its own slot, self destructs if called with data
+		code = common.Hex2Bytes("600b600c600039600b6000f3" + "43435536600857005b33ff")
+
+		keeper    = crypto.CreateAddress(address, 0)
+		destroyed = crypto.CreateAddress(address, 1)
+	)
+	genesis := gspec.MustCommit(db)
+	blocks, _ := GenerateChain(gspec.Config, genesis, engine, db, 2*triesInMemory, func(i int, block *BlockGen) {
+		var txs []*types.Transaction
+		if i == 0 {
+			txs = append(txs,
+				types.NewContractCreation(block.TxNonce(address), new(big.Int), 100000, new(big.Int), code),
+				types.NewContractCreation(block.TxNonce(address)+1, new(big.Int), 100000, new(big.Int), code),
+			)
+		} else {
+			txs = append(txs, types.NewTransaction(block.TxNonce(address), keeper, new(big.Int), 100000, new(big.Int), nil))
+			if i == triesInMemory {
+				txs = append(txs, types.NewTransaction(block.TxNonce(address)+1, destroyed, new(big.Int), 100000, new(big.Int), []byte{0x01}))
+			} else {
+				txs = append(txs, types.NewTransaction(block.TxNonce(address)+1, destroyed, new(big.Int), 100000, new(big.Int), nil))
+			}
+		}
+		txs = append(txs, types.NewTransaction(block.TxNonce(address)+2, common.Address{byte(i)}, big.NewInt(1), 21000, new(big.Int), nil))
+		for _, tx := range txs {
+			signed, err := types.SignTx(tx, signer, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			block.AddTx(signed)
+		}
+	})
+	diskdb := ethdb.NewMemDatabase()
+	gspec.MustCommit(diskdb)
+
+	cacheConfig := &CacheConfig{TrieNodeLimit: 256, TrieTimeLimit: 5 * time.Minute, SnapshotLimit: 1}
+	chain, err := NewBlockChain(diskdb, cacheConfig, gspec.Config, engine, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	// checkSnapshot verifies the snapshot of the head state against its trie
+	checkSnapshot := func(chain *BlockChain) {
+		t.Helper()
+
+		head := chain.CurrentBlock()
+		snap := chain.snaps.Snapshot(head.Root())
+		if snap == nil {
+			t.Fatalf("snapshot of head %d missing", head.NumberU64())
+		}
+		statedb, _ := state.New(head.Root(), chain.stateCache)
+
+		for _, addr := range []common.Address{address, keeper, destroyed, {0x01}, {0xff}} {
+			acc, err := snap.Account(crypto.Keccak256Hash(addr[:]))
+			if err != nil {
+				t.Fatalf("account %x: %v", addr, err)
+			}
+			if !statedb.Exist(addr) {
+				if acc != nil {
+					t.Errorf("account %x: deleted account in snapshot", addr)
+				}
+				continue
+			}
+			if acc == nil || acc.Nonce != statedb.GetNonce(addr) || acc.Balance.Cmp(statedb.GetBalance(addr)) != 0 {
+				t.Errorf("account %x mismatch: have %v, want nonce %d, balance %v", addr, acc, statedb.GetNonce(addr), statedb.GetBalance(addr))
+			}
+		}
+		for _, number := range []int64{1, triesInMemory, int64(head.NumberU64())} {
+			slot := common.BigToHash(big.NewInt(number))
+			blob, err := snap.Storage(crypto.Keccak256Hash(keeper[:]), crypto.Keccak256Hash(slot[:]))
+			if err != nil {
+				t.Fatalf("slot %d: %v", number, err)
+			}
+			_, content, _, _ := rlp.Split(blob)
+			if have, want := common.BytesToHash(content), statedb.GetState(keeper, slot); have != want {
+				t.Errorf("slot %d mismatch: have %x, want %x", number, have, want)
+			}
+		}
+	}
+	checkSnapshot(chain)
+	if statedb, _ := chain.State(); statedb.Exist(destroyed) {
+		t.Errorf("self destructed contract exists")
+	}
+	// Stop the chain, journalling the snapshot, and ensure it's reused on restart
+	chain.Stop()
+	if root := rawdb.ReadSnapshotRoot(diskdb); root != chain.CurrentBlock().Root() && rawdb.ReadSnapshotJournal(diskdb) == nil {
+		t.Fatalf("snapshot neither persisted nor journalled: have root %x, want %x", root, chain.CurrentBlock().Root())
+	}
+	chain, err = NewBlockChain(diskdb, cacheConfig, gspec.Config, engine, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to recreate tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	checkSnapshot(chain)
+}
+
+// Tests that the validator set of a block is indexed as the block is imported,
+// so that it doesn't change along with the umbrella afterwards, and that the
+// index is dropped when rewinding the chain.
+func TestValidatorsIndex(t *testing.T) {
+	db, blockchain, err := newCanonical(ethash.NewFaker(), 0, true)
+	if err != nil {
+		t.Fatalf("failed to create pristine chain: %v", err)
+	}
+	defer blockchain.Stop()
+
+	first, second := common.Address{1}, common.Address{2}
+	blockchain.SetUmbrella(umbrella.NewStandalone(&params.UmbrellaConfig{Validators: []common.Address{first}}))
+	if set := blockchain.GetValidatorsAt(1); !set.Contains(first) {
+		t.Fatalf("validator set mismatch: have %v, want %v", set, first)
+	}
+	if validators := rawdb.ReadValidators(db, 1); validators != nil {
+		t.Fatalf("validator set indexed before import: %v", validators)
+	}
+	if _, err := blockchain.InsertChain(makeBlockChain(blockchain.CurrentBlock(), 1, ethash.NewFaker(), db, canonicalSeed)); err != nil {
+		t.Fatalf("failed to insert block: %v", err)
+	}
+	if validators := rawdb.ReadValidators(db, 1); len(validators) != 1 || validators[0] != first {
+		t.Errorf("validator set not indexed: have %v, want %v", validators, first)
+	}
+	blockchain.SetUmbrella(umbrella.NewStandalone(&params.UmbrellaConfig{Validators: []common.Address{second}}))
+	if set := blockchain.GetValidatorsAt(1); !set.Contains(first) || set.Contains(second) {
+		t.Errorf("indexed validator set changed: have %v, want %v", set, first)
+	}
+	if set := blockchain.GetValidatorsAt(2); !set.Contains(second) || set.Contains(first) {
+		t.Errorf("validator set mismatch: have %v, want %v", set, second)
+	}
+	// Rewinding the chain drops the sets of the removed blocks
+	blockchain.SetHead(0)
+	if validators := rawdb.ReadValidators(db, 1); validators != nil {
+		t.Errorf("validator set not deleted on rewind: %v", validators)
+	}
+	if set := blockchain.GetValidatorsAt(1); !set.Contains(second) || set.Contains(first) {
+		t.Errorf("validator set mismatch: have %v, want %v", set, second)
+	}
+}
+
 // Benchmarks large blocks with value transfers to non-existing accounts
 func benchmarkLargeNumberOfValueToNonexisting(b *testing.B, numTxs, numBlocks int, recipientFn func(uint64) common.Address, dataFn func(uint64) []byte) {
 	var (
@@ -40,10 +40,11 @@ type BlockGen struct {
 	header      *types.Header
 	statedb     *state.StateDB
 
-	gasPool  *GasPool
-	txs      []*types.Transaction
-	receipts []*types.Receipt
-	uncles   []*types.Header
+	gasPool   *GasPool
+	scheduled bool // whether the due scheduled transactions were executed
+	txs       []*types.Transaction
+	receipts  []*types.Receipt
+	uncles    []*types.Header
 
 	config *params.ChainConfig
 	engine consensus.Engine
@@ -62,6 +63,21 @@ func (b *BlockGen) SetCoinbase(addr common.Address) {
 	b.gasPool = new(GasPool).AddGas(b.header.GasLimit)
 }
 
+// applySchedules executes the scheduled transactions fallen due, which the
+// block processor runs ahead of any of the block's own transactions.
+func (b *BlockGen) applySchedules(bc *BlockChain) {
+	if b.scheduled {
+		return
+	}
+	b.scheduled = true
+
+	gp := b.gasPool
+	if gp == nil {
+		gp = new(GasPool).AddGas(b.header.GasLimit)
+	}
+	b.receipts = append(b.receipts, ApplyDueSchedules(b.config, bc, &b.header.Coinbase, gp, b.statedb, b.header, &b.header.GasUsed, vm.Config{})...)
+}
+
 // SetExtra sets the extra data field of the generated block.
 func (b *BlockGen) SetExtra(data []byte) {
 	b.header.Extra = data
@@ -91,6 +107,7 @@ func (b *BlockGen) AddTxWithChain(bc *BlockChain, tx *types.Transaction) {
 	if b.gasPool == nil {
 		b.SetCoinbase(common.Address{})
 	}
+	b.applySchedules(bc)
 	b.statedb.Prepare(tx.Hash(), common.Hash{}, len(b.txs))
 	receipt, _, err := ApplyTransaction(b.config, bc, &b.header.Coinbase, b.gasPool, b.statedb, b.header, tx, &b.header.GasUsed, vm.Config{})
 	if err != nil {
@@ -194,6 +211,7 @@ func GenerateChain(config *params.ChainConfig, parent *types.Block, engine conse
 		if gen != nil {
 			gen(i, b)
 		}
+		b.applySchedules(nil)
 
 		if b.engine != nil {
 			block, _ := b.engine.Finalize(b.chainReader, b.header, statedb, b.txs, b.uncles, b.receipts)
@@ -32,4 +32,8 @@ var (
 	// ErrNonceTooHigh is returned if the nonce of a transaction is higher than the
 	// next one expected based on the local chain.
 	ErrNonceTooHigh = errors.New("nonce too high")
+
+	// errMissingBeacon is returned if the umbrella provides no randomness beacon
+	// for a block past the RandBeacon fork.
+	errMissingBeacon = errors.New("missing randomness beacon")
 )
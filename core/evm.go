@@ -39,6 +39,25 @@ type ChainContext interface {
 	Umbrella() umbrella.Umbrella
 }
 
+// validatorIndex is implemented by chains indexing the validator set of every
+// block, see BlockChain.GetValidatorsAt.
+type validatorIndex interface {
+	GetValidatorsAt(number uint64) umbrella.ValidatorSet
+}
+
+// BlockBeacon returns the randomness beacon mixed into RAND past the RandBeacon
+// fork: the beacon the umbrella provides for the block, which its consensus
+// engine commits to and verifies, or the parent hash if the umbrella provides
+// none. Neither can be ground by the block's proposer, but the parent hash is
+// known before the block. The beacon is zero if the umbrella is missing it,
+// which ValidateBody rejects.
+func BlockBeacon(umb umbrella.Umbrella, header *types.Header) common.Hash {
+	if b, ok := umb.(umbrella.Beacon); ok {
+		return b.GetBeacon(header.Number)
+	}
+	return header.ParentHash
+}
+
 // NewEVMContext creates a new context for use in the EVM.
 func NewEVMContext(msg Message, header *types.Header, chain ChainContext, author *common.Address) vm.Context {
 	// If we don't have an explicit author (i.e. not mining), extract from the header
@@ -48,18 +67,31 @@ func NewEVMContext(msg Message, header *types.Header, chain ChainContext, author
 	} else {
 		beneficiary = *author
 	}
+	var (
+		umb           umbrella.Umbrella
+		getValidators vm.GetValidatorsFunc
+	)
+	if chain != nil {
+		umb = chain.Umbrella()
+	}
+	// Chains without an umbrella leave the EVM to ask the standalone one.
+	if index, ok := chain.(validatorIndex); ok && umb != nil {
+		getValidators = index.GetValidatorsAt
+	}
 	return vm.Context{
-		CanTransfer: CanTransfer,
-		Transfer:    Transfer,
-		GetHash:     GetHashFn(header, chain),
-		Origin:      msg.From(),
-		Coinbase:    beneficiary,
-		BlockNumber: new(big.Int).Set(header.Number),
-		Time:        new(big.Int).Set(header.Time),
-		Difficulty:  new(big.Int).Set(header.Difficulty),
-		GasLimit:    header.GasLimit,
-		GasPrice:    new(big.Int).Set(msg.GasPrice()),
-		Umbrella:    chain.Umbrella(),
+		CanTransfer:   CanTransfer,
+		Transfer:      Transfer,
+		GetHash:       GetHashFn(header, chain),
+		GetValidators: getValidators,
+		Origin:        msg.From(),
+		Coinbase:      beneficiary,
+		BlockNumber:   new(big.Int).Set(header.Number),
+		Time:          new(big.Int).Set(header.Time),
+		Difficulty:    new(big.Int).Set(header.Difficulty),
+		GasLimit:      header.GasLimit,
+		GasPrice:      new(big.Int).Set(msg.GasPrice()),
+		Beacon:        BlockBeacon(umb, header),
+		Umbrella:      umb,
 	}
 }
 
@@ -151,6 +151,14 @@ func (e *GenesisMismatchError) Error() string {
 //
 // The returned chain configuration is never nil.
 func SetupGenesisBlock(db ethdb.Database, genesis *Genesis) (*params.ChainConfig, common.Hash, error) {
+	return SetupGenesisBlockWithOverride(db, genesis, nil)
+}
+
+// SetupGenesisBlockWithOverride is SetupGenesisBlock, but sets the Lity fork block
+// of the chain configuration to overrideLity if it's not nil. Chains that ran the
+// Lity opcodes before the fork block was introduced leave it unset, and need it
+// activated at genesis to keep processing their blocks the same way.
+func SetupGenesisBlockWithOverride(db ethdb.Database, genesis *Genesis, overrideLity *big.Int) (*params.ChainConfig, common.Hash, error) {
 	if genesis != nil && genesis.Config == nil {
 		return params.AllEthashProtocolChanges, common.Hash{}, errGenesisNoConfig
 	}
@@ -165,7 +173,14 @@ func SetupGenesisBlock(db ethdb.Database, genesis *Genesis) (*params.ChainConfig
 			log.Info("Writing custom genesis block")
 		}
 		block, err := genesis.Commit(db)
-		return genesis.Config, block.Hash(), err
+		if err != nil {
+			return genesis.Config, common.Hash{}, err
+		}
+		config := withLityBlock(genesis.Config, overrideLity)
+		if config != genesis.Config {
+			rawdb.WriteChainConfig(db, block.Hash(), config)
+		}
+		return config, block.Hash(), nil
 	}
 
 	// Check whether the genesis block is already written.
@@ -177,7 +192,7 @@ func SetupGenesisBlock(db ethdb.Database, genesis *Genesis) (*params.ChainConfig
 	}
 
 	// Get the existing chain configuration.
-	newcfg := genesis.configOrDefault(stored)
+	newcfg := withLityBlock(genesis.configOrDefault(stored), overrideLity)
 	storedcfg := rawdb.ReadChainConfig(db, stored)
 	if storedcfg == nil {
 		log.Warn("Found genesis block without chain config")
@@ -188,6 +203,11 @@ func SetupGenesisBlock(db ethdb.Database, genesis *Genesis) (*params.ChainConfig
 	// config is supplied. These chains would get AllProtocolChanges (and a compat error)
 	// if we just continued here.
 	if genesis == nil && stored != params.MainnetGenesisHash {
+		if overridden := withLityBlock(storedcfg, overrideLity); overridden != storedcfg {
+			log.Info("Overriding the Lity fork block", "block", overrideLity)
+			rawdb.WriteChainConfig(db, stored, overridden)
+			return overridden, stored, nil
+		}
 		return storedcfg, stored, nil
 	}
 
@@ -205,6 +225,17 @@ func SetupGenesisBlock(db ethdb.Database, genesis *Genesis) (*params.ChainConfig
 	return newcfg, stored, nil
 }
 
+// withLityBlock returns a copy of config with the Lity fork block set to number,
+// or config itself if number is nil or already the fork block.
+func withLityBlock(config *params.ChainConfig, number *big.Int) *params.ChainConfig {
+	if number == nil || (config.LityBlock != nil && config.LityBlock.Cmp(number) == 0) {
+		return config
+	}
+	overridden := *config
+	overridden.LityBlock = new(big.Int).Set(number)
+	return &overridden
+}
+
 func (g *Genesis) configOrDefault(ghash common.Hash) *params.ChainConfig {
 	switch {
 	case g != nil:
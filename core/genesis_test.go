@@ -39,20 +39,30 @@ func TestDefaultGenesisBlock(t *testing.T) {
 	if block.Hash() != params.TestnetGenesisHash {
 		t.Errorf("wrong testnet genesis hash, got %v, want %v", block.Hash(), params.TestnetGenesisHash)
 	}
+	block = DefaultRinkebyGenesisBlock().ToBlock(nil)
+	if block.Hash() != params.RinkebyGenesisHash {
+		t.Errorf("wrong rinkeby genesis hash, got %v, want %v", block.Hash(), params.RinkebyGenesisHash)
+	}
 }
 
 func TestSetupGenesis(t *testing.T) {
 	var (
 		customghash = common.HexToHash("0x89c99d90b79719238d2645c7642f2c9295246e80775b38cfd162b696817fbd50")
 		customg     = Genesis{
-			Config: &params.ChainConfig{HomesteadBlock: big.NewInt(3)},
+			Config: &params.ChainConfig{HomesteadBlock: big.NewInt(3), LityBlock: big.NewInt(0)},
 			Alloc: GenesisAlloc{
 				{1}: {Balance: big.NewInt(1), Storage: map[common.Hash]common.Hash{{1}: {1}}},
 			},
 		}
 		oldcustomg = customg
+
+		// A chain without the Lity fork, or set up before the fork block was
+		// introduced and running the Lity opcodes since genesis.
+		legacyg       = customg
+		migratedlityg = &params.ChainConfig{HomesteadBlock: big.NewInt(3), LityBlock: big.NewInt(0)}
 	)
-	oldcustomg.Config = &params.ChainConfig{HomesteadBlock: big.NewInt(2)}
+	oldcustomg.Config = &params.ChainConfig{HomesteadBlock: big.NewInt(2), LityBlock: big.NewInt(0)}
+	legacyg.Config = &params.ChainConfig{HomesteadBlock: big.NewInt(3)}
 	tests := []struct {
 		name       string
 		fn         func(ethdb.Database) (*params.ChainConfig, common.Hash, error)
@@ -94,6 +104,41 @@ func TestSetupGenesis(t *testing.T) {
 			wantHash:   customghash,
 			wantConfig: customg.Config,
 		},
+		{
+			name: "legacy custom block in DB, genesis == nil",
+			fn: func(db ethdb.Database) (*params.ChainConfig, common.Hash, error) {
+				legacyg.MustCommit(db)
+				return SetupGenesisBlock(db, nil)
+			},
+			wantHash:   customghash,
+			wantConfig: legacyg.Config,
+		},
+		{
+			name: "legacy custom block in DB, genesis == nil, Lity at genesis",
+			fn: func(db ethdb.Database) (*params.ChainConfig, common.Hash, error) {
+				legacyg.MustCommit(db)
+				return SetupGenesisBlockWithOverride(db, nil, big.NewInt(0))
+			},
+			wantHash:   customghash,
+			wantConfig: migratedlityg,
+		},
+		{
+			name: "legacy custom block in DB, genesis == legacy, Lity at genesis",
+			fn: func(db ethdb.Database) (*params.ChainConfig, common.Hash, error) {
+				legacyg.MustCommit(db)
+				return SetupGenesisBlockWithOverride(db, &legacyg, big.NewInt(0))
+			},
+			wantHash:   customghash,
+			wantConfig: migratedlityg,
+		},
+		{
+			name: "no block in DB, genesis == legacy",
+			fn: func(db ethdb.Database) (*params.ChainConfig, common.Hash, error) {
+				return SetupGenesisBlock(db, &legacyg)
+			},
+			wantHash:   customghash,
+			wantConfig: legacyg.Config,
+		},
 		{
 			name: "custom block in DB, genesis == testnet",
 			fn: func(db ethdb.Database) (*params.ChainConfig, common.Hash, error) {
@@ -159,6 +204,9 @@ func TestSetupGenesis(t *testing.T) {
 			if stored.Hash() != test.wantHash {
 				t.Errorf("%s: block in DB has hash %s, want %s", test.name, stored.Hash(), test.wantHash)
 			}
+			if stored := rawdb.ReadChainConfig(db, test.wantHash); !reflect.DeepEqual(stored, test.wantConfig) {
+				t.Errorf("%s:\nstored config %v\nwant          %v", test.name, stored, test.wantConfig)
+			}
 		}
 	}
 }
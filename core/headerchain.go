@@ -469,6 +469,13 @@ func (hc *HeaderChain) SetHead(head uint64, delFn DeleteCallback) {
 	}
 	batch.Write()
 
+	// Discard the frozen blocks above the new head, if any
+	if ancients, ok := hc.chainDb.(ethdb.AncientWriter); ok {
+		if err := ancients.TruncateAncients(head + 1); err != nil {
+			log.Crit("Failed to truncate ancient chain segments", "number", head, "err", err)
+		}
+	}
+
 	// Clear out any stale content from the caches
 	hc.headerCache.Purge()
 	hc.tdCache.Purge()
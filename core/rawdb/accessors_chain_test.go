@@ -317,3 +317,30 @@ func TestBlockReceiptStorage(t *testing.T) {
 		t.Fatalf("deleted receipts returned: %v", rs)
 	}
 }
+
+// Tests that the receipts of a block range can be deleted, leaving those of the
+// other blocks.
+func TestReceiptsRangeDeletion(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+
+	receipts := types.Receipts{&types.Receipt{Status: types.ReceiptStatusSuccessful, Logs: []*types.Log{}}}
+	for number := uint64(0); number < 4; number++ {
+		WriteReceipts(db, common.Hash{byte(number)}, number, receipts)
+		WriteReceipts(db, common.Hash{byte(number), 1}, number, receipts)
+	}
+	deleted, err := DeleteReceiptsRange(db, 1, 3)
+	if err != nil {
+		t.Fatalf("failed to delete receipts: %v", err)
+	}
+	if deleted != 4 {
+		t.Errorf("deleted receipt count mismatch: have %d, want %d", deleted, 4)
+	}
+	for number := uint64(0); number < 4; number++ {
+		pruned := number == 1 || number == 2
+		for _, hash := range []common.Hash{{byte(number)}, {byte(number), 1}} {
+			if have := ReadReceipts(db, hash, number); pruned != (have == nil) {
+				t.Errorf("block %d %x: receipts presence mismatch: have %v, want %v", number, hash, have != nil, !pruned)
+			}
+		}
+	}
+}
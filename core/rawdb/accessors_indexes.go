@@ -19,6 +19,7 @@ package rawdb
 import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/rlp"
 )
@@ -62,6 +63,46 @@ func DeleteTxLookupEntry(db DatabaseDeleter, hash common.Hash) {
 	db.Delete(txLookupKey(hash))
 }
 
+// DeleteTxLookupsRange removes the lookup entries of the transactions included
+// in the blocks numbered from first up to but excluding last, returning the
+// number of entries deleted. Lookup entries are keyed by transaction hash, so
+// all of them are walked.
+func DeleteTxLookupsRange(db ethdb.Database, first, last uint64) (int, error) {
+	var (
+		batch   = db.NewBatch()
+		deleted int
+	)
+	it := db.NewIterator(txLookupPrefix, nil)
+	defer it.Release()
+
+	for it.Next() {
+		if len(it.Key()) != len(txLookupPrefix)+common.HashLength {
+			continue
+		}
+		var entry TxLookupEntry
+		if err := rlp.DecodeBytes(it.Value(), &entry); err != nil {
+			log.Error("Invalid transaction lookup entry RLP", "key", common.ToHex(it.Key()), "err", err)
+			continue
+		}
+		if entry.BlockIndex < first || entry.BlockIndex >= last {
+			continue
+		}
+		batch.Delete(it.Key())
+		deleted++
+
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return deleted, err
+			}
+			batch.Reset()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return deleted, err
+	}
+	return deleted, batch.Write()
+}
+
 // ReadTransaction retrieves a specific transaction from the database, along with
 // its added positional metadata.
 func ReadTransaction(db DatabaseReader, hash common.Hash) (*types.Transaction, common.Hash, uint64, uint64) {
@@ -84,12 +125,15 @@ func ReadReceipt(db DatabaseReader, hash common.Hash) (*types.Receipt, common.Ha
 	if blockHash == (common.Hash{}) {
 		return nil, common.Hash{}, 0, 0
 	}
-	receipts := ReadReceipts(db, blockHash, blockNumber)
-	if len(receipts) <= int(receiptIndex) {
-		log.Error("Receipt refereced missing", "number", blockNumber, "hash", blockHash, "index", receiptIndex)
-		return nil, common.Hash{}, 0, 0
+	// The receipts of scheduled transactions precede those of the block's own
+	// transactions, look the receipt up by hash rather than by position.
+	for _, receipt := range ReadReceipts(db, blockHash, blockNumber) {
+		if receipt.TxHash == hash {
+			return receipt, blockHash, blockNumber, receiptIndex
+		}
 	}
-	return receipts[receiptIndex], blockHash, blockNumber, receiptIndex
+	log.Error("Receipt refereced missing", "number", blockNumber, "hash", blockHash, "index", receiptIndex)
+	return nil, common.Hash{}, 0, 0
 }
 
 // ReadBloomBits retrieves the compressed bloom bit vector belonging to the given
@@ -105,3 +149,36 @@ func WriteBloomBits(db DatabaseWriter, bit uint, section uint64, head common.Has
 		log.Crit("Failed to store bloom bits", "err", err)
 	}
 }
+
+// ReadValidators retrieves the validator set indexed for the given block
+// number, or nil if none was. An indexed empty set is returned non-nil.
+func ReadValidators(db DatabaseReader, number uint64) []common.Address {
+	data, _ := db.Get(validatorsKey(number))
+	if len(data) == 0 {
+		return nil
+	}
+	var validators []common.Address
+	if err := rlp.DecodeBytes(data, &validators); err != nil {
+		log.Error("Invalid validator set RLP", "number", number, "err", err)
+		return nil
+	}
+	return validators
+}
+
+// WriteValidators stores the validator set of the given block number.
+func WriteValidators(db DatabaseWriter, number uint64, validators []common.Address) {
+	data, err := rlp.EncodeToBytes(validators)
+	if err != nil {
+		log.Crit("Failed to RLP encode validator set", "err", err)
+	}
+	if err := db.Put(validatorsKey(number), data); err != nil {
+		log.Crit("Failed to store validator set", "err", err)
+	}
+}
+
+// DeleteValidators removes the validator set of the given block number.
+func DeleteValidators(db DatabaseDeleter, number uint64) {
+	if err := db.Delete(validatorsKey(number)); err != nil {
+		log.Crit("Failed to delete validator set", "err", err)
+	}
+}
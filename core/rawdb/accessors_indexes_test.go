@@ -18,6 +18,7 @@ package rawdb
 
 import (
 	"math/big"
+	"reflect"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -66,3 +67,53 @@ func TestLookupStorage(t *testing.T) {
 		}
 	}
 }
+
+// Tests that validator sets can be stored, retrieved and deleted per height.
+func TestValidatorsStorage(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+
+	if validators := ReadValidators(db, 314); validators != nil {
+		t.Fatalf("non existent validator set returned: %v", validators)
+	}
+	validators := []common.Address{{0x11}, {0x22}}
+	WriteValidators(db, 314, validators)
+	WriteValidators(db, 315, nil)
+
+	if have := ReadValidators(db, 314); !reflect.DeepEqual(have, validators) {
+		t.Fatalf("validator set mismatch: have %v, want %v", have, validators)
+	}
+	if have := ReadValidators(db, 315); have == nil || len(have) != 0 {
+		t.Fatalf("empty validator set mismatch: have %v", have)
+	}
+	DeleteValidators(db, 314)
+	if have := ReadValidators(db, 314); have != nil {
+		t.Fatalf("deleted validator set returned: %v", have)
+	}
+}
+
+// Tests that the lookup entries of the transactions of a block range can be
+// deleted, leaving those of the other blocks.
+func TestLookupRangeDeletion(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+
+	var blocks []*types.Block
+	for i := 0; i < 4; i++ {
+		tx := types.NewTransaction(uint64(i), common.Address{}, big.NewInt(0), 0, big.NewInt(0), nil)
+		block := types.NewBlock(&types.Header{Number: big.NewInt(int64(i))}, []*types.Transaction{tx}, nil, nil)
+		WriteTxLookupEntries(db, block)
+		blocks = append(blocks, block)
+	}
+	deleted, err := DeleteTxLookupsRange(db, 1, 3)
+	if err != nil {
+		t.Fatalf("failed to delete lookup entries: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("deleted entry count mismatch: have %d, want %d", deleted, 2)
+	}
+	for i, block := range blocks {
+		hash, _, _ := ReadTxLookupEntry(db, block.Transactions()[0].Hash())
+		if pruned := i == 1 || i == 2; pruned != (hash == common.Hash{}) {
+			t.Errorf("block %d: lookup entry presence mismatch: have %v, want %v", i, hash != common.Hash{}, !pruned)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// freezerdb is a database wrapper that enables freezer data retrievals.
+type freezerdb struct {
+	ethdb.Database
+	*freezer
+}
+
+// Close implements ethdb.Database, closing both the fast key-value store and
+// the slow ancient tables.
+func (frdb *freezerdb) Close() {
+	if err := frdb.freezer.Close(); err != nil {
+		log.Error("Failed to close ancient database", "err", err)
+	}
+	frdb.Database.Close()
+}
+
+// NewDatabaseWithFreezer creates a high level database on top of a given key-
+// value data store with a freezer moving immutable chain segments into cold
+// storage at the freezer path. Blocks older than threshold below the head block
+// are migrated in the background; the rawdb accessors read them from either
+// store.
+func NewDatabaseWithFreezer(db ethdb.Database, freezer string, threshold uint64) (ethdb.Database, error) {
+	frdb, err := newFreezer(freezer, threshold)
+	if err != nil {
+		return nil, err
+	}
+	// Since the freezer can be stored separately from the user's key-value database,
+	// there's a fairly high probability that the user requests invalid combinations
+	// of the freezer and database. Ensure that we don't shoot ourselves in the foot
+	// by serving up conflicting data, leading to both datastores getting corrupted.
+	if frozen, _ := frdb.Ancients(); frozen > 0 {
+		switch kvgenesis, kvhead := ReadCanonicalHash(db, 0), ReadHeadBlockHash(db); {
+		case kvhead == (common.Hash{}):
+			// The key-value store is empty, it can't continue the ancient chain
+			frdb.Close()
+			return nil, fmt.Errorf("ancient chain segments already extracted, please set --datadir.ancient to the correct path")
+
+		case kvgenesis != (common.Hash{}):
+			// The genesis block is still in the key-value store, it must match
+			if frgenesis, _ := frdb.Ancient(freezerHashTable, 0); !bytes.Equal(kvgenesis[:], frgenesis) {
+				frdb.Close()
+				return nil, fmt.Errorf("genesis mismatch: %#x (leveldb) != %#x (ancients)", kvgenesis, frgenesis)
+			}
+		}
+	}
+	// Freezer is consistent with the key-value database, permit combining the two
+	frdb.wg.Add(1)
+	go frdb.freeze(db)
+
+	return &freezerdb{
+		Database: db,
+		freezer:  frdb,
+	}, nil
+}
+
+// KeyValueStore returns the key-value store backing the database, stripping the
+// ancient store off databases created by NewDatabaseWithFreezer.
+func KeyValueStore(db ethdb.Database) ethdb.Database {
+	if frdb, ok := db.(*freezerdb); ok {
+		return frdb.Database
+	}
+	return db
+}
+
+// DatabaseStat is the number and total size of the entries of a kind of data
+// held in a chain database.
+type DatabaseStat struct {
+	Name  string
+	Items uint64
+	Size  common.StorageSize
+}
+
+// InspectDatabase walks the key-value store of a chain database, reporting the
+// number and size of the entries of every kind of data of the schema, followed
+// by the sizes of the ancient tables, if any.
+func InspectDatabase(db ethdb.Database) ([]DatabaseStat, error) {
+	kinds := []struct {
+		name   string
+		prefix []byte
+		keylen int // Length of the entry keys, 0 for any
+		suffix []byte
+	}{
+		{"Headers", headerPrefix, len(headerPrefix) + 8 + common.HashLength, nil},
+		{"Total difficulties", headerPrefix, len(headerPrefix) + 8 + common.HashLength + len(headerTDSuffix), headerTDSuffix},
+		{"Canonical hashes", headerPrefix, len(headerPrefix) + 8 + len(headerHashSuffix), headerHashSuffix},
+		{"Header numbers", headerNumberPrefix, len(headerNumberPrefix) + common.HashLength, nil},
+		{"Bodies", blockBodyPrefix, len(blockBodyPrefix) + 8 + common.HashLength, nil},
+		{"Receipts", blockReceiptsPrefix, len(blockReceiptsPrefix) + 8 + common.HashLength, nil},
+		{"Transaction lookups", txLookupPrefix, len(txLookupPrefix) + common.HashLength, nil},
+		{"Bloom bits", bloomBitsPrefix, len(bloomBitsPrefix) + 10 + common.HashLength, nil},
+		{"Validator sets", validatorsPrefix, len(validatorsPrefix) + 8, nil},
+		{"Account snapshots", SnapshotAccountPrefix, len(SnapshotAccountPrefix) + common.HashLength, nil},
+		{"Storage snapshots", SnapshotStoragePrefix, len(SnapshotStoragePrefix) + 2*common.HashLength, nil},
+		{"Preimages", preimagePrefix, len(preimagePrefix) + common.HashLength, nil},
+		{"Chain configs", configPrefix, len(configPrefix) + common.HashLength, nil},
+		{"Trie nodes and codes", nil, common.HashLength, nil},
+		{"Chain indexes", []byte("i"), 0, nil}, // After the hash keys, which may begin with i
+	}
+	stats := make([]DatabaseStat, len(kinds)+1)
+	for i, kind := range kinds {
+		stats[i].Name = kind.name
+	}
+	other := &stats[len(kinds)]
+	other.Name = "Other"
+
+	it := db.NewIterator(nil, nil)
+	defer it.Release()
+
+	for it.Next() {
+		key, size := it.Key(), common.StorageSize(len(it.Key())+len(it.Value()))
+
+		stat := other
+		for i, kind := range kinds {
+			if bytes.HasPrefix(key, kind.prefix) && (kind.keylen == 0 || len(key) == kind.keylen) && bytes.HasSuffix(key, kind.suffix) {
+				stat = &stats[i]
+				break
+			}
+		}
+		stat.Items++
+		stat.Size += size
+	}
+	if err := it.Error(); err != nil {
+		return nil, err
+	}
+	// Report the ancient tables after the key-value store
+	if ancients, ok := db.(ethdb.AncientReader); ok {
+		items, err := ancients.Ancients()
+		if err != nil {
+			return nil, err
+		}
+		tables := make([]string, 0, len(freezerNoSnappy))
+		for table := range freezerNoSnappy {
+			tables = append(tables, table)
+		}
+		sort.Strings(tables)
+
+		for _, table := range tables {
+			size, err := ancients.AncientSize(table)
+			if err != nil {
+				return nil, err
+			}
+			stats = append(stats, DatabaseStat{Name: "Ancient " + table, Items: items, Size: common.StorageSize(size)})
+		}
+	}
+	return stats, nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// Tests that the database inspection tells the kinds of data apart.
+func TestInspectDatabase(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1)})
+	WriteBlock(db, block)
+	WriteCanonicalHash(db, block.Hash(), 1)
+	WriteTd(db, block.Hash(), 1, big.NewInt(1))
+	WriteHeadBlockHash(db, block.Hash())
+	WriteValidators(db, 1, []common.Address{{1}})
+
+	node := []byte("node")
+	db.Put(crypto.Keccak256(node), node)
+	db.Put(append([]byte("i"), crypto.Keccak256(node)[1:]...), node)
+
+	stats, err := InspectDatabase(db)
+	if err != nil {
+		t.Fatalf("failed to inspect database: %v", err)
+	}
+	want := map[string]uint64{
+		"Headers":              1,
+		"Total difficulties":   1,
+		"Canonical hashes":     1,
+		"Header numbers":       1,
+		"Bodies":               1,
+		"Validator sets":       1,
+		"Trie nodes and codes": 2,
+		"Other":                1,
+	}
+	for _, stat := range stats {
+		if stat.Items != want[stat.Name] {
+			t.Errorf("%s: item count mismatch: have %d, want %d", stat.Name, stat.Items, want[stat.Name])
+		}
+	}
+}
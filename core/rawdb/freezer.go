@@ -0,0 +1,406 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/prometheus/prometheus/util/flock"
+)
+
+// errUnknownTable is returned if the user attempts to read from a table that is
+// not tracked by the freezer.
+var errUnknownTable = errors.New("unknown table")
+
+const (
+	// freezerRecheckInterval is the frequency to check the key-value database for
+	// chain progression that might permit new blocks to be frozen into immutable
+	// storage.
+	freezerRecheckInterval = time.Minute
+
+	// freezerBatchLimit is the maximum number of blocks to freeze in one batch
+	// before doing an fsync and deleting it from the key-value store.
+	freezerBatchLimit = 30000
+)
+
+// freezer is an append-only database to store immutable chain data
+// into flat files:
+//
+//   - The append only nature ensures that disk writes are minimized.
+//   - The in-order nature of the tables allows lookups by block number with a
+//     single positioned read of the index, without any compaction.
+//   - The immutability of the data allows the files to live on cheaper disks.
+//
+// Blocks older than the threshold below the head block are migrated from the
+// key-value store by a background goroutine, see freeze.
+type freezer struct {
+	frozen    uint64 // Number of blocks already frozen (atomic)
+	threshold uint64 // Number of recent blocks kept in the key-value store
+
+	tables       map[string]*freezerTable // Data tables for storing everything
+	instanceLock flock.Releaser           // File-system lock to prevent double opens
+	writeLock    sync.Mutex               // Serialises appends and truncations
+
+	quit      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// newFreezer creates a chain freezer that moves ancient chain data into
+// append-only flat file containers.
+func newFreezer(datadir string, threshold uint64) (*freezer, error) {
+	var (
+		readMeter  = metrics.NewRegisteredMeter("eth/db/chaindata/ancient/read", nil)
+		writeMeter = metrics.NewRegisteredMeter("eth/db/chaindata/ancient/write", nil)
+	)
+	if err := os.MkdirAll(datadir, 0755); err != nil {
+		return nil, err
+	}
+	// Leveldb uses LOCK as the filelock filename. To prevent the
+	// name collision, we use FLOCK as the lock name.
+	lock, _, err := flock.New(filepath.Join(datadir, "FLOCK"))
+	if err != nil {
+		return nil, err
+	}
+	// Open all the supported data tables
+	freezer := &freezer{
+		threshold:    threshold,
+		tables:       make(map[string]*freezerTable),
+		instanceLock: lock,
+		quit:         make(chan struct{}),
+	}
+	for name, disableSnappy := range freezerNoSnappy {
+		table, err := newTable(datadir, name, readMeter, writeMeter, disableSnappy)
+		if err != nil {
+			for _, table := range freezer.tables {
+				table.Close()
+			}
+			lock.Release()
+			return nil, err
+		}
+		freezer.tables[name] = table
+	}
+	if err := freezer.repair(); err != nil {
+		for _, table := range freezer.tables {
+			table.Close()
+		}
+		lock.Release()
+		return nil, err
+	}
+	log.Info("Opened ancient database", "database", datadir, "frozen", freezer.frozen)
+	return freezer, nil
+}
+
+// Close terminates the chain freezer, closing all the data files.
+func (f *freezer) Close() error {
+	var errs []error
+	f.closeOnce.Do(func() {
+		close(f.quit)
+		f.wg.Wait()
+
+		for _, table := range f.tables {
+			if err := table.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if err := f.instanceLock.Release(); err != nil {
+			errs = append(errs, err)
+		}
+	})
+	if errs != nil {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
+
+// HasAncient returns an indicator whether the specified ancient data exists
+// in the freezer.
+func (f *freezer) HasAncient(kind string, number uint64) (bool, error) {
+	if table := f.tables[kind]; table != nil {
+		return table.has(number), nil
+	}
+	return false, nil
+}
+
+// Ancient retrieves an ancient binary blob from the append-only immutable files.
+func (f *freezer) Ancient(kind string, number uint64) ([]byte, error) {
+	if table := f.tables[kind]; table != nil {
+		return table.Retrieve(number)
+	}
+	return nil, errUnknownTable
+}
+
+// Ancients returns the length of the frozen items.
+func (f *freezer) Ancients() (uint64, error) {
+	return atomic.LoadUint64(&f.frozen), nil
+}
+
+// AncientSize returns the ancient size of the specified category.
+func (f *freezer) AncientSize(kind string) (uint64, error) {
+	if table := f.tables[kind]; table != nil {
+		return table.size()
+	}
+	return 0, errUnknownTable
+}
+
+// AppendAncient injects all binary blobs belong to block at the end of the
+// append-only immutable table files. Out-of-order injections are rejected.
+func (f *freezer) AppendAncient(number uint64, hash, header, body, receipts, td []byte) error {
+	f.writeLock.Lock()
+	defer f.writeLock.Unlock()
+
+	return f.appendAncient(number, hash, header, body, receipts, td)
+}
+
+// appendAncient is AppendAncient with the write lock held.
+func (f *freezer) appendAncient(number uint64, hash, header, body, receipts, td []byte) (err error) {
+	// Ensure the binary blobs we are appending is continuous with freezer.
+	if atomic.LoadUint64(&f.frozen) != number {
+		return errOutOrderInsert
+	}
+	// Rollback all inserted data if any insertion below failed to ensure
+	// the tables won't out of sync.
+	defer func() {
+		if err != nil {
+			if rerr := f.repair(); rerr != nil {
+				log.Crit("Failed to repair freezer", "err", rerr)
+			}
+			log.Info("Append ancient failed", "number", number, "err", err)
+		}
+	}()
+	// Inject all the components into the relevant data tables
+	if err := f.tables[freezerHashTable].Append(f.frozen, hash); err != nil {
+		log.Error("Failed to append ancient hash", "number", f.frozen, "hash", fmt.Sprintf("%x", hash), "err", err)
+		return err
+	}
+	if err := f.tables[freezerHeaderTable].Append(f.frozen, header); err != nil {
+		log.Error("Failed to append ancient header", "number", f.frozen, "hash", fmt.Sprintf("%x", hash), "err", err)
+		return err
+	}
+	if err := f.tables[freezerBodiesTable].Append(f.frozen, body); err != nil {
+		log.Error("Failed to append ancient body", "number", f.frozen, "hash", fmt.Sprintf("%x", hash), "err", err)
+		return err
+	}
+	if err := f.tables[freezerReceiptTable].Append(f.frozen, receipts); err != nil {
+		log.Error("Failed to append ancient receipts", "number", f.frozen, "hash", fmt.Sprintf("%x", hash), "err", err)
+		return err
+	}
+	if err := f.tables[freezerDifficultyTable].Append(f.frozen, td); err != nil {
+		log.Error("Failed to append ancient difficulty", "number", f.frozen, "hash", fmt.Sprintf("%x", hash), "err", err)
+		return err
+	}
+	atomic.AddUint64(&f.frozen, 1) // Only modify atomically
+	return nil
+}
+
+// TruncateAncients discards any recent data above the provided threshold number.
+func (f *freezer) TruncateAncients(items uint64) error {
+	f.writeLock.Lock()
+	defer f.writeLock.Unlock()
+
+	if atomic.LoadUint64(&f.frozen) <= items {
+		return nil
+	}
+	for _, table := range f.tables {
+		if err := table.truncate(items); err != nil {
+			return err
+		}
+	}
+	atomic.StoreUint64(&f.frozen, items)
+	return nil
+}
+
+// Sync flushes all data tables to disk.
+func (f *freezer) Sync() error {
+	var errs []error
+	for _, table := range f.tables {
+		if err := table.Sync(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if errs != nil {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
+
+// freeze is a background thread that periodically checks the blockchain for any
+// import progress and moves ancient data from the fast database into the freezer.
+//
+// This functionality is deliberately broken off from block importing to avoid
+// incurring additional data shuffling delays on block propagation.
+func (f *freezer) freeze(db ethdb.Database) {
+	defer f.wg.Done()
+
+	for {
+		// Keep freezing without a break while there is a backlog
+		if f.freezeBatch(db) < freezerBatchLimit {
+			select {
+			case <-f.quit:
+				return
+			case <-time.After(freezerRecheckInterval):
+			}
+		} else {
+			select {
+			case <-f.quit:
+				return
+			default:
+			}
+		}
+	}
+}
+
+// freezeBatch moves up to freezerBatchLimit canonical blocks older than the
+// threshold from the key-value store db into the freezer, returning the number
+// of blocks frozen.
+func (f *freezer) freezeBatch(db ethdb.Database) int {
+	f.writeLock.Lock()
+	defer f.writeLock.Unlock()
+
+	// Retrieve the freezing threshold.
+	hash := ReadHeadBlockHash(db)
+	if hash == (common.Hash{}) {
+		log.Debug("Current full block hash unavailable") // new chain, empty database
+		return 0
+	}
+	number := ReadHeaderNumber(db, hash)
+	switch {
+	case number == nil:
+		log.Error("Current full block number unavailable", "hash", hash)
+		return 0
+
+	case *number < f.threshold:
+		log.Debug("Current full block not old enough", "number", *number, "hash", hash, "delay", f.threshold)
+		return 0
+	}
+	// Seems we have data ready to be frozen, process in usable batches
+	var (
+		first = atomic.LoadUint64(&f.frozen)
+		limit = *number - f.threshold
+		start = time.Now()
+	)
+	if limit < first {
+		return 0
+	}
+	if limit-first >= freezerBatchLimit {
+		limit = first + freezerBatchLimit - 1
+	}
+	ancients := make([]common.Hash, 0, limit-first+1)
+	for f.frozen <= limit {
+		// Retrieves all the components of the canonical block
+		number := f.frozen
+		hash := ReadCanonicalHash(db, number)
+		if hash == (common.Hash{}) {
+			log.Error("Canonical hash missing, can't freeze", "number", number)
+			break
+		}
+		header := ReadHeaderRLP(db, hash, number)
+		if len(header) == 0 {
+			log.Error("Block header missing, can't freeze", "number", number, "hash", hash)
+			break
+		}
+		body := ReadBodyRLP(db, hash, number)
+		if len(body) == 0 {
+			log.Error("Block body missing, can't freeze", "number", number, "hash", hash)
+			break
+		}
+		receipts, _ := db.Get(blockReceiptsKey(number, hash))
+		if len(receipts) == 0 {
+			log.Error("Block receipts missing, can't freeze", "number", number, "hash", hash)
+			break
+		}
+		td, _ := db.Get(headerTDKey(number, hash))
+		if len(td) == 0 {
+			log.Error("Total difficulty missing, can't freeze", "number", number, "hash", hash)
+			break
+		}
+		// Inject all the components into the relevant data tables
+		if err := f.appendAncient(number, hash[:], header, body, receipts, td); err != nil {
+			break
+		}
+		ancients = append(ancients, hash)
+	}
+	if len(ancients) == 0 {
+		return 0
+	}
+	// Batch of blocks have been frozen, flush them before wiping from leveldb
+	if err := f.Sync(); err != nil {
+		log.Crit("Failed to flush frozen tables", "err", err)
+	}
+	// Wipe out all data from the active database, keeping the hash to number
+	// mappings needed to look blocks up by hash. Side chains at the frozen
+	// heights can never become canonical anymore, drop them altogether.
+	batch := db.NewBatch()
+	for i, hash := range ancients {
+		number := first + uint64(i)
+
+		DeleteCanonicalHash(batch, number)
+		deleteHeaderWithoutNumber(batch, hash, number)
+		DeleteBody(batch, hash, number)
+		DeleteReceipts(batch, hash, number)
+		DeleteTd(batch, hash, number)
+
+		for _, side := range ReadAllHashes(db, number) {
+			if side != hash {
+				DeleteBlock(batch, side, number)
+			}
+		}
+
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				log.Crit("Failed to delete frozen canonical blocks", "err", err)
+			}
+			batch.Reset()
+		}
+	}
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to delete frozen canonical blocks", "err", err)
+	}
+	// Log something friendly for the user
+	log.Info("Deep froze chain segment", "blocks", len(ancients), "elapsed", common.PrettyDuration(time.Since(start)),
+		"number", first+uint64(len(ancients))-1, "hash", ancients[len(ancients)-1])
+
+	return len(ancients)
+}
+
+// repair truncates all data tables to the same length.
+func (f *freezer) repair() error {
+	min := uint64(math.MaxUint64)
+	for _, table := range f.tables {
+		if items := atomic.LoadUint64(&table.items); items < min {
+			min = items
+		}
+	}
+	for _, table := range f.tables {
+		if err := table.truncate(min); err != nil {
+			return err
+		}
+	}
+	atomic.StoreUint64(&f.frozen, min)
+	return nil
+}
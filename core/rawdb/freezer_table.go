@@ -0,0 +1,328 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/golang/snappy"
+)
+
+var (
+	// errClosed is returned if an operation attempts to read from or write to
+	// the freezer table after it has already been closed.
+	errClosed = errors.New("closed")
+
+	// errOutOfBounds is returned if the item requested is not contained within
+	// the freezer table.
+	errOutOfBounds = errors.New("out of bounds")
+
+	// errOutOrderInsert is returned if the user attempts to inject out-of-order
+	// binary blobs into the freezer.
+	errOutOrderInsert = errors.New("the append operation is out-order")
+)
+
+// indexEntrySize is the size of an index entry, the big endian offset in the
+// data file at which the item ends.
+const indexEntrySize = 8
+
+// freezerTable is an append-only table of binary blobs, stored back to back in
+// a flat data file. The index file holds the end offset of every blob, so that
+// the n-th item is found with a single positioned read of the index.
+type freezerTable struct {
+	items uint64 // Number of items stored in the table (atomic)
+
+	noCompression bool // if true, disables snappy compression
+	index         *os.File
+	data          *os.File
+	bytes         uint64 // Number of bytes in the data file
+
+	readMeter  metrics.Meter // Meter for measuring the effective amount of data read
+	writeMeter metrics.Meter // Meter for measuring the effective amount of data written
+
+	logger log.Logger
+	lock   sync.RWMutex // Mutex protecting the data file descriptors
+}
+
+// newTable opens a freezer table, creating the data and index files if they
+// are non-existent. Both files are truncated to the shortest common length to
+// ensure they don't go out of sync.
+func newTable(path string, name string, readMeter metrics.Meter, writeMeter metrics.Meter, disableSnappy bool) (*freezerTable, error) {
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, err
+	}
+	// Compressed and uncompressed tables use different file names, so that a
+	// table is never read with the wrong setting.
+	idxName, datName := fmt.Sprintf("%s.cidx", name), fmt.Sprintf("%s.cdat", name)
+	if disableSnappy {
+		idxName, datName = fmt.Sprintf("%s.ridx", name), fmt.Sprintf("%s.rdat", name)
+	}
+	index, err := os.OpenFile(filepath.Join(path, idxName), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.OpenFile(filepath.Join(path, datName), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		index.Close()
+		return nil, err
+	}
+	tab := &freezerTable{
+		noCompression: disableSnappy,
+		index:         index,
+		data:          data,
+		readMeter:     readMeter,
+		writeMeter:    writeMeter,
+		logger:        log.New("table", name),
+	}
+	if err := tab.repair(); err != nil {
+		tab.Close()
+		return nil, err
+	}
+	return tab, nil
+}
+
+// repair cross checks the index and data files and truncates them to be in
+// sync with each other after a potential crash. Partially written index
+// entries are dropped, as are entries pointing past the end of the data file
+// and data past the end of the last entry.
+func (t *freezerTable) repair() error {
+	stat, err := t.index.Stat()
+	if err != nil {
+		return err
+	}
+	items := uint64(stat.Size()) / indexEntrySize
+	if uint64(stat.Size()) != items*indexEntrySize {
+		if err := t.index.Truncate(int64(items * indexEntrySize)); err != nil {
+			return err
+		}
+	}
+	if stat, err = t.data.Stat(); err != nil {
+		return err
+	}
+	size := uint64(stat.Size())
+
+	// Drop the trailing items whose data wasn't fully written
+	for items > 0 {
+		end, err := t.offset(items)
+		if err != nil {
+			return err
+		}
+		if end <= size {
+			break
+		}
+		items--
+	}
+	if err := t.index.Truncate(int64(items * indexEntrySize)); err != nil {
+		return err
+	}
+	end, err := t.offset(items)
+	if err != nil {
+		return err
+	}
+	if end != size {
+		t.logger.Warn("Truncating dangling freezer data", "indexed", end, "stored", size)
+		if err := t.data.Truncate(int64(end)); err != nil {
+			return err
+		}
+	}
+	if err := t.index.Sync(); err != nil {
+		return err
+	}
+	if err := t.data.Sync(); err != nil {
+		return err
+	}
+	t.items, t.bytes = items, end
+
+	t.logger.Debug("Chain freezer table opened", "items", t.items, "size", t.bytes)
+	return nil
+}
+
+// offset returns the offset in the data file at which the item before the
+// given one ends, i.e. at which the given item starts.
+func (t *freezerTable) offset(item uint64) (uint64, error) {
+	if item == 0 {
+		return 0, nil
+	}
+	var entry [indexEntrySize]byte
+	if _, err := t.index.ReadAt(entry[:], int64((item-1)*indexEntrySize)); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(entry[:]), nil
+}
+
+// truncate discards any recent data above the provided threshold number.
+func (t *freezerTable) truncate(items uint64) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.index == nil {
+		return errClosed
+	}
+	// If our item count is correct, don't do anything
+	if atomic.LoadUint64(&t.items) <= items {
+		return nil
+	}
+	t.logger.Warn("Truncating freezer table", "items", t.items, "limit", items)
+
+	end, err := t.offset(items)
+	if err != nil {
+		return err
+	}
+	if err := t.index.Truncate(int64(items * indexEntrySize)); err != nil {
+		return err
+	}
+	if err := t.data.Truncate(int64(end)); err != nil {
+		return err
+	}
+	atomic.StoreUint64(&t.items, items)
+	t.bytes = end
+	return nil
+}
+
+// Close closes all opened files.
+func (t *freezerTable) Close() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	var errs []error
+	for _, f := range []*os.File{t.index, t.data} {
+		if f == nil {
+			continue
+		}
+		if err := f.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	t.index, t.data = nil, nil
+
+	if errs != nil {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
+
+// Append injects a binary blob at the end of the freezer table. The item number
+// is a precautionary parameter to ensure data correctness, but the table will
+// reject already existing data.
+//
+// Note, this method will *not* flush any data to disk so be sure to explicitly
+// fsync before irreversibly deleting data from the database.
+func (t *freezerTable) Append(item uint64, blob []byte) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.index == nil {
+		return errClosed
+	}
+	// Ensure the table is still accessible and the item is the next one
+	if atomic.LoadUint64(&t.items) != item {
+		return errOutOrderInsert
+	}
+	if !t.noCompression {
+		blob = snappy.Encode(nil, blob)
+	}
+	// Write the data first, so that an index entry never points to missing data
+	if _, err := t.data.Write(blob); err != nil {
+		return err
+	}
+	var entry [indexEntrySize]byte
+	binary.BigEndian.PutUint64(entry[:], t.bytes+uint64(len(blob)))
+	if _, err := t.index.Write(entry[:]); err != nil {
+		return err
+	}
+	t.bytes += uint64(len(blob))
+	t.writeMeter.Mark(int64(len(blob) + indexEntrySize))
+	atomic.AddUint64(&t.items, 1)
+	return nil
+}
+
+// Retrieve looks up the data offset of an item with the given number and
+// retrieves the raw binary blob from the data file.
+func (t *freezerTable) Retrieve(item uint64) ([]byte, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	// Ensure the table and the item is accessible
+	if t.index == nil {
+		return nil, errClosed
+	}
+	if atomic.LoadUint64(&t.items) <= item {
+		return nil, errOutOfBounds
+	}
+	var entries [2 * indexEntrySize]byte
+	if item == 0 {
+		if _, err := t.index.ReadAt(entries[indexEntrySize:], 0); err != nil {
+			return nil, err
+		}
+	} else if _, err := t.index.ReadAt(entries[:], int64((item-1)*indexEntrySize)); err != nil {
+		return nil, err
+	}
+	start, end := binary.BigEndian.Uint64(entries[:]), binary.BigEndian.Uint64(entries[indexEntrySize:])
+	if start > end {
+		return nil, fmt.Errorf("corrupt index entry %d: start %d, end %d", item, start, end)
+	}
+	blob := make([]byte, end-start)
+	if _, err := t.data.ReadAt(blob, int64(start)); err != nil {
+		return nil, err
+	}
+	t.readMeter.Mark(int64(len(blob) + 2*indexEntrySize))
+
+	if t.noCompression {
+		return blob, nil
+	}
+	return snappy.Decode(nil, blob)
+}
+
+// has returns an indicator whether the specified number data
+// exists in the freezer table.
+func (t *freezerTable) has(number uint64) bool {
+	return atomic.LoadUint64(&t.items) > number
+}
+
+// size returns the total data size in the freezer table.
+func (t *freezerTable) size() (uint64, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if t.index == nil {
+		return 0, errClosed
+	}
+	return t.bytes + atomic.LoadUint64(&t.items)*indexEntrySize, nil
+}
+
+// Sync pushes any pending data from memory out to disk. This is an expensive
+// operation, so use it with care.
+func (t *freezerTable) Sync() error {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if t.index == nil {
+		return errClosed
+	}
+	if err := t.index.Sync(); err != nil {
+		return err
+	}
+	return t.data.Sync()
+}
@@ -0,0 +1,203 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// Tests that freezer tables retrieve what was appended, compressed or not, and
+// recover from partially written items.
+func TestFreezerTable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, noSnappy := range []bool{false, true} {
+		name := "compressed"
+		if noSnappy {
+			name = "raw"
+		}
+		table, err := newTable(dir, name, metrics.NilMeter{}, metrics.NilMeter{}, noSnappy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		blob := func(i uint64) []byte { return bytes.Repeat([]byte{byte(i)}, int(i)*10) }
+		for i := uint64(0); i < 100; i++ {
+			if err := table.Append(i, blob(i)); err != nil {
+				t.Fatalf("%s: failed to append item %d: %v", name, i, err)
+			}
+		}
+		if err := table.Append(101, nil); err != errOutOrderInsert {
+			t.Errorf("%s: out of order append error mismatch: have %v, want %v", name, err, errOutOrderInsert)
+		}
+		if _, err := table.Retrieve(100); err != errOutOfBounds {
+			t.Errorf("%s: out of bounds retrieval error mismatch: have %v, want %v", name, err, errOutOfBounds)
+		}
+		for i := uint64(0); i < 100; i++ {
+			if data, err := table.Retrieve(i); err != nil || !bytes.Equal(data, blob(i)) {
+				t.Fatalf("%s: item %d mismatch: have %x (%v), want %x", name, i, data, err, blob(i))
+			}
+		}
+		// Simulate a crash in the middle of an append and reopen
+		if _, err := table.data.Write([]byte("dangling")); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := table.index.Write([]byte{0xff, 0xff}); err != nil {
+			t.Fatal(err)
+		}
+		table.Close()
+
+		if table, err = newTable(dir, name, metrics.NilMeter{}, metrics.NilMeter{}, noSnappy); err != nil {
+			t.Fatal(err)
+		}
+		if table.items != 100 {
+			t.Errorf("%s: repaired item count mismatch: have %d, want %d", name, table.items, 100)
+		}
+		if err := table.Append(100, blob(100)); err != nil {
+			t.Fatalf("%s: failed to append after repair: %v", name, err)
+		}
+		if data, err := table.Retrieve(100); err != nil || !bytes.Equal(data, blob(100)) {
+			t.Errorf("%s: item mismatch after repair: have %x (%v), want %x", name, data, err, blob(100))
+		}
+		// Truncate and check the discarded items are gone
+		if err := table.truncate(50); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := table.Retrieve(50); err != errOutOfBounds {
+			t.Errorf("%s: truncated retrieval error mismatch: have %v, want %v", name, err, errOutOfBounds)
+		}
+		if data, err := table.Retrieve(49); err != nil || !bytes.Equal(data, blob(49)) {
+			t.Errorf("%s: item mismatch after truncation: have %x (%v), want %x", name, data, err, blob(49))
+		}
+		table.Close()
+	}
+}
+
+// Tests that old canonical blocks are moved into the freezer and that the
+// accessors serve them from there transparently.
+func TestFreezerMigration(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Write a chain of blocks into the key-value store
+	kvdb := ethdb.NewMemDatabase()
+
+	var blocks []*types.Block
+	parent := common.Hash{}
+	for i := uint64(0); i < 10; i++ {
+		header := &types.Header{Number: new(big.Int).SetUint64(i), ParentHash: parent, Extra: []byte("test freezer")}
+		block := types.NewBlockWithHeader(header)
+		receipts := types.Receipts{&types.Receipt{Status: types.ReceiptStatusSuccessful, CumulativeGasUsed: i, Logs: []*types.Log{}}}
+
+		WriteBlock(kvdb, block)
+		WriteReceipts(kvdb, block.Hash(), i, receipts)
+		WriteTd(kvdb, block.Hash(), i, big.NewInt(int64(i)))
+		WriteCanonicalHash(kvdb, block.Hash(), i)
+
+		blocks, parent = append(blocks, block), block.Hash()
+	}
+	WriteHeadBlockHash(kvdb, parent)
+
+	// Fork off side blocks at a height to be frozen and a recent one
+	var sides []*types.Block
+	for _, i := range []uint64{2, 8} {
+		header := &types.Header{Number: new(big.Int).SetUint64(i), ParentHash: blocks[i-1].Hash(), Extra: []byte("test side chain")}
+		block := types.NewBlockWithHeader(header)
+
+		WriteBlock(kvdb, block)
+		WriteReceipts(kvdb, block.Hash(), i, types.Receipts{})
+		WriteTd(kvdb, block.Hash(), i, big.NewInt(int64(i)))
+
+		sides = append(sides, block)
+	}
+	// Freeze all but the last three blocks
+	f, err := newFreezer(filepath.Join(dir, "ancient"), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := &freezerdb{Database: kvdb, freezer: f}
+	if n := f.freezeBatch(kvdb); n != 7 {
+		t.Fatalf("frozen block count mismatch: have %d, want %d", n, 7)
+	}
+	if n := f.freezeBatch(kvdb); n != 0 {
+		t.Fatalf("refrozen block count mismatch: have %d, want %d", n, 0)
+	}
+	for i, block := range blocks {
+		hash, number := block.Hash(), uint64(i)
+
+		frozen := i < 7
+		if has, _ := kvdb.Has(headerKey(number, hash)); has == frozen {
+			t.Errorf("block %d: key-value store presence mismatch: have %v, want %v", i, has, !frozen)
+		}
+		if ReadHeaderNumber(db, hash) == nil {
+			t.Errorf("block %d: hash to number mapping missing", i)
+		}
+		if have := ReadCanonicalHash(db, number); have != hash {
+			t.Errorf("block %d: canonical hash mismatch: have %x, want %x", i, have, hash)
+		}
+		if have := ReadBlock(db, hash, number); have == nil || have.Hash() != hash {
+			t.Errorf("block %d: block mismatch: have %v", i, have)
+		}
+		if !HasHeader(db, hash, number) || !HasBody(db, hash, number) {
+			t.Errorf("block %d: header or body reported missing", i)
+		}
+		if have := ReadTd(db, hash, number); have == nil || have.Uint64() != number {
+			t.Errorf("block %d: total difficulty mismatch: have %v, want %d", i, have, number)
+		}
+		if have := ReadReceipts(db, hash, number); len(have) != 1 || have[0].CumulativeGasUsed != number {
+			t.Errorf("block %d: receipts mismatch: have %v", i, have)
+		}
+		// Frozen blocks must not be served for another hash
+		if ReadHeader(db, common.Hash{0x01}, number) != nil || HasBody(db, common.Hash{0x01}, number) {
+			t.Errorf("block %d: served for a foreign hash", i)
+		}
+	}
+	// Side chains are dropped at the frozen heights only
+	if HasHeader(db, sides[0].Hash(), 2) || HasBody(db, sides[0].Hash(), 2) || ReadTd(db, sides[0].Hash(), 2) != nil || ReadHeaderNumber(db, sides[0].Hash()) != nil {
+		t.Errorf("side block at frozen height retained")
+	}
+	if !HasHeader(db, sides[1].Hash(), 8) || !HasBody(db, sides[1].Hash(), 8) || ReadTd(db, sides[1].Hash(), 8) == nil {
+		t.Errorf("side block at recent height dropped")
+	}
+	// Truncating the freezer discards the most recent frozen blocks
+	if err := db.TruncateAncients(5); err != nil {
+		t.Fatal(err)
+	}
+	if ReadHeader(db, blocks[5].Hash(), 5) != nil {
+		t.Errorf("truncated block still served")
+	}
+	if ReadHeader(db, blocks[4].Hash(), 4) == nil {
+		t.Errorf("retained block missing")
+	}
+	db.Close()
+}
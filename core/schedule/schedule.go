@@ -0,0 +1,268 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package schedule implements the queue of transactions emitted by the SCHEDULE
+// opcode.
+//
+// The queue lives in the storage of a reserved system account, so emissions are
+// journaled and reverted along with the call frame that made them, and the queue
+// contents are covered by the state root. Pending entries form a doubly linked
+// list ordered by due time and then by emission order.
+package schedule
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm/umbrella"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// QueueAddress is the system account whose storage holds the schedule queue.
+// Calling it with CancelInput cancels a pending entry.
+var QueueAddress = common.BytesToAddress(crypto.Keccak256([]byte("lity.schedule"))[12:])
+
+var (
+	// ErrUnknownEntry is returned when cancelling an entry which is not pending.
+	ErrUnknownEntry = errors.New("unknown scheduled transaction")
+
+	// ErrNotPermitted is returned when an entry is cancelled by an account which
+	// is neither its sender nor its receiver.
+	ErrNotPermitted = errors.New("only the sender or receiver may cancel a scheduled transaction")
+
+	// ErrWalkLimit is returned when an entry falls due before more pending
+	// entries than the caller allowed to walk past.
+	ErrWalkLimit = errors.New("scheduled transaction falls due too far ahead of the queue tail")
+)
+
+// cancelSelector is the ABI selector of cancel(uint256), the only call the
+// queue account understands.
+var cancelSelector = crypto.Keccak256([]byte("cancel(uint256)"))[:4]
+
+// Global slots of the queue account.
+var (
+	counterSlot = common.BigToHash(big.NewInt(0)) // Last assigned entry id
+	headSlot    = common.BigToHash(big.NewInt(1)) // Id of the first entry to fall due
+	tailSlot    = common.BigToHash(big.NewInt(2)) // Id of the last entry to fall due
+	sizeSlot    = common.BigToHash(big.NewInt(3)) // Number of pending entries
+)
+
+// Per entry slots, relative to the entry's base slot.
+const (
+	existsField = iota
+	senderField
+	receiverField
+	unixtimeField
+	prevField
+	nextField
+	lengthField
+	dataField // First of the 32 byte words holding the transaction data
+)
+
+// StateDB is the subset of the state the queue is kept in.
+type StateDB interface {
+	GetState(common.Address, common.Hash) common.Hash
+	SetState(common.Address, common.Hash, common.Hash)
+	GetNonce(common.Address) uint64
+	SetNonce(common.Address, uint64)
+}
+
+// Entry is a scheduled transaction pending in the queue.
+type Entry struct {
+	ID uint64 // Emission sequence number, unique within the chain
+	umbrella.ScheduleTx
+}
+
+// Push inserts a scheduled transaction into the queue and returns its id along
+// with the number of pending entries walked past to find its position. An entry
+// falling due no earlier than the tail is appended without walking, otherwise
+// the queue is walked back from the tail, entries due at the same time keeping
+// their emission order. If more than maxSteps entries would have to be walked
+// past, the queue is left untouched and ErrWalkLimit is returned.
+func Push(db StateDB, tx umbrella.ScheduleTx, maxSteps uint64) (uint64, uint64, error) {
+	prev, steps := getUint(db, tailSlot), uint64(0)
+	for prev != 0 && getUint(db, slot(prev, unixtimeField)) > tx.Unixtime {
+		if steps == maxSteps {
+			return 0, steps, ErrWalkLimit
+		}
+		prev = getUint(db, slot(prev, prevField))
+		steps++
+	}
+	// Keep the account non-empty, lest EIP158 sweeps it along with the queue.
+	if db.GetNonce(QueueAddress) == 0 {
+		db.SetNonce(QueueAddress, 1)
+	}
+	id := getUint(db, counterSlot) + 1
+	setUint(db, counterSlot, id)
+
+	setUint(db, slot(id, existsField), 1)
+	db.SetState(QueueAddress, slot(id, senderField), tx.Sender.Hash())
+	db.SetState(QueueAddress, slot(id, receiverField), tx.Receiver.Hash())
+	setUint(db, slot(id, unixtimeField), tx.Unixtime)
+	setUint(db, slot(id, lengthField), uint64(len(tx.TxData)))
+	for i := 0; i < len(tx.TxData); i += common.HashLength {
+		var word common.Hash
+		copy(word[:], tx.TxData[i:])
+		db.SetState(QueueAddress, slot(id, dataField+uint64(i/common.HashLength)), word)
+	}
+	var next uint64
+	if prev == 0 {
+		next = getUint(db, headSlot)
+		setUint(db, headSlot, id)
+	} else {
+		next = getUint(db, slot(prev, nextField))
+		setUint(db, slot(prev, nextField), id)
+	}
+	if next == 0 {
+		setUint(db, tailSlot, id)
+	} else {
+		setUint(db, slot(next, prevField), id)
+	}
+	setUint(db, slot(id, prevField), prev)
+	setUint(db, slot(id, nextField), next)
+	setUint(db, sizeSlot, getUint(db, sizeSlot)+1)
+
+	return id, steps, nil
+}
+
+// Get retrieves a pending entry, or nil if there is no such entry.
+func Get(db StateDB, id uint64) *Entry {
+	if id == 0 || getUint(db, slot(id, existsField)) == 0 {
+		return nil
+	}
+	entry := &Entry{ID: id}
+	entry.Sender = common.BytesToAddress(db.GetState(QueueAddress, slot(id, senderField)).Bytes())
+	entry.Receiver = common.BytesToAddress(db.GetState(QueueAddress, slot(id, receiverField)).Bytes())
+	entry.Unixtime = getUint(db, slot(id, unixtimeField))
+
+	length := getUint(db, slot(id, lengthField))
+	entry.TxData = make([]byte, 0, length)
+	for i := uint64(0); uint64(len(entry.TxData)) < length; i++ {
+		word := db.GetState(QueueAddress, slot(id, dataField+i))
+		if rest := length - uint64(len(entry.TxData)); rest < common.HashLength {
+			entry.TxData = append(entry.TxData, word[:rest]...)
+		} else {
+			entry.TxData = append(entry.TxData, word[:]...)
+		}
+	}
+	return entry
+}
+
+// Head retrieves the entry falling due first, or nil if the queue is empty.
+func Head(db StateDB) *Entry {
+	return Get(db, getUint(db, headSlot))
+}
+
+// Pending retrieves every pending entry in the order they fall due.
+func Pending(db StateDB) []*Entry {
+	var entries []*Entry
+	for id := getUint(db, headSlot); id != 0; id = getUint(db, slot(id, nextField)) {
+		entries = append(entries, Get(db, id))
+	}
+	return entries
+}
+
+// Size returns the number of pending entries.
+func Size(db StateDB) uint64 {
+	return getUint(db, sizeSlot)
+}
+
+// Remove unlinks a pending entry from the queue and clears its storage. It is
+// a no-op for entries which are not pending.
+func Remove(db StateDB, id uint64) {
+	if id == 0 || getUint(db, slot(id, existsField)) == 0 {
+		return
+	}
+	prev, next := getUint(db, slot(id, prevField)), getUint(db, slot(id, nextField))
+	if prev == 0 {
+		setUint(db, headSlot, next)
+	} else {
+		setUint(db, slot(prev, nextField), next)
+	}
+	if next == 0 {
+		setUint(db, tailSlot, prev)
+	} else {
+		setUint(db, slot(next, prevField), prev)
+	}
+	words := (getUint(db, slot(id, lengthField)) + common.HashLength - 1) / common.HashLength
+	for field := uint64(existsField); field < dataField+words; field++ {
+		db.SetState(QueueAddress, slot(id, field), common.Hash{})
+	}
+	setUint(db, sizeSlot, getUint(db, sizeSlot)-1)
+}
+
+// Cancel removes a pending entry on behalf of the given account, which must be
+// either the entry's sender or its receiver.
+func Cancel(db StateDB, id uint64, by common.Address) error {
+	entry := Get(db, id)
+	if entry == nil {
+		return ErrUnknownEntry
+	}
+	if by != entry.Sender && by != entry.Receiver {
+		return ErrNotPermitted
+	}
+	Remove(db, id)
+	return nil
+}
+
+// TxHash returns the hash standing in for the transaction hash in the receipt
+// of the index-th scheduled transaction executed by the block of the given
+// number. It only depends on the block, so that it can be derived again for
+// receipts downloaded without executing the block.
+func TxHash(number uint64, index int) common.Hash {
+	var key [16]byte
+	binary.BigEndian.PutUint64(key[:8], number)
+	binary.BigEndian.PutUint64(key[8:], uint64(index))
+	return crypto.Keccak256Hash(QueueAddress.Bytes(), key[:])
+}
+
+// CancelInput returns the call data cancelling the given entry when sent to
+// QueueAddress.
+func CancelInput(id uint64) []byte {
+	return append(common.CopyBytes(cancelSelector), common.BigToHash(new(big.Int).SetUint64(id)).Bytes()...)
+}
+
+// ParseCancelInput extracts the entry id from call data built by CancelInput.
+func ParseCancelInput(input []byte) (uint64, bool) {
+	if len(input) != len(cancelSelector)+common.HashLength || !bytes.Equal(input[:len(cancelSelector)], cancelSelector) {
+		return 0, false
+	}
+	id := new(big.Int).SetBytes(input[len(cancelSelector):])
+	if !id.IsUint64() {
+		return 0, false
+	}
+	return id.Uint64(), true
+}
+
+// slot returns the storage slot of a field of the given entry.
+func slot(id uint64, field uint64) common.Hash {
+	var key [8]byte
+	binary.BigEndian.PutUint64(key[:], id)
+
+	base := new(big.Int).SetBytes(crypto.Keccak256(key[:]))
+	return common.BigToHash(base.Add(base, new(big.Int).SetUint64(field)))
+}
+
+func getUint(db StateDB, key common.Hash) uint64 {
+	return db.GetState(QueueAddress, key).Big().Uint64()
+}
+
+func setUint(db StateDB, key common.Hash, value uint64) {
+	db.SetState(QueueAddress, key, common.BigToHash(new(big.Int).SetUint64(value)))
+}
@@ -0,0 +1,189 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package schedule
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/vm/umbrella"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+func newState() *state.StateDB {
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+	return statedb
+}
+
+// push inserts a scheduled transaction without any walk limit.
+func push(db StateDB, tx umbrella.ScheduleTx) uint64 {
+	id, _, err := Push(db, tx, math.MaxUint64)
+	if err != nil {
+		panic(err)
+	}
+	return id
+}
+
+func unixtimes(entries []*Entry) []uint64 {
+	var times []uint64
+	for _, entry := range entries {
+		times = append(times, entry.Unixtime)
+	}
+	return times
+}
+
+func TestQueueOrder(t *testing.T) {
+	statedb := newState()
+
+	for i, at := range []uint64{30, 10, 20, 10, 40} {
+		if id := push(statedb, umbrella.ScheduleTx{Unixtime: at, TxData: []byte{byte(i)}}); id != uint64(i+1) {
+			t.Fatalf("entry %d: id mismatch: have %d, want %d", i, id, i+1)
+		}
+	}
+	entries := Pending(statedb)
+	if have, want := unixtimes(entries), []uint64{10, 10, 20, 30, 40}; !equal(have, want) {
+		t.Fatalf("order mismatch: have %v, want %v", have, want)
+	}
+	// Entries due at the same time keep their emission order.
+	if entries[0].ID != 2 || entries[1].ID != 4 {
+		t.Fatalf("emission order not kept: have %d, %d", entries[0].ID, entries[1].ID)
+	}
+	Remove(statedb, 2)
+	Remove(statedb, 5)
+	Remove(statedb, 3)
+	if have, want := unixtimes(Pending(statedb)), []uint64{10, 30}; !equal(have, want) {
+		t.Fatalf("order mismatch after removal: have %v, want %v", have, want)
+	}
+	if Size(statedb) != 2 {
+		t.Fatalf("size mismatch: have %d, want 2", Size(statedb))
+	}
+	push(statedb, umbrella.ScheduleTx{Unixtime: 5})
+	if head := Head(statedb); head == nil || head.ID != 6 {
+		t.Fatalf("head mismatch: have %v, want entry 6", head)
+	}
+}
+
+// Tests that inserting an entry reports the pending entries walked past, and
+// fails without altering the queue beyond the walk limit.
+func TestQueueWalkLimit(t *testing.T) {
+	statedb := newState()
+	for _, at := range []uint64{10, 20, 30} {
+		push(statedb, umbrella.ScheduleTx{Unixtime: at})
+	}
+	if _, steps, err := Push(statedb, umbrella.ScheduleTx{Unixtime: 30}, 0); err != nil || steps != 0 {
+		t.Fatalf("tail insertion: have %d steps (%v), want 0", steps, err)
+	}
+	if _, _, err := Push(statedb, umbrella.ScheduleTx{Unixtime: 15}, 2); err != ErrWalkLimit {
+		t.Fatalf("insertion past the limit: have %v, want %v", err, ErrWalkLimit)
+	}
+	if Size(statedb) != 4 {
+		t.Fatalf("size after failed insertion: have %d, want 4", Size(statedb))
+	}
+	if _, steps, err := Push(statedb, umbrella.ScheduleTx{Unixtime: 15}, 3); err != nil || steps != 3 {
+		t.Fatalf("insertion within the limit: have %d steps (%v), want 3", steps, err)
+	}
+	if have, want := unixtimes(Pending(statedb)), []uint64{10, 15, 20, 30, 30}; !equal(have, want) {
+		t.Fatalf("order mismatch: have %v, want %v", have, want)
+	}
+}
+
+func TestQueueData(t *testing.T) {
+	statedb := newState()
+
+	for _, size := range []int{0, 1, 31, 32, 33, 100} {
+		tx := umbrella.ScheduleTx{
+			Sender:   common.Address{1},
+			Receiver: common.Address{2},
+			TxData:   bytes.Repeat([]byte{0xff}, size),
+			Unixtime: 1,
+		}
+		entry := Get(statedb, push(statedb, tx))
+		if entry == nil {
+			t.Fatalf("size %d: entry missing", size)
+		}
+		if entry.Sender != tx.Sender || entry.Receiver != tx.Receiver || !bytes.Equal(entry.TxData, tx.TxData) {
+			t.Errorf("size %d: entry mismatch: have %+v, want %+v", size, entry.ScheduleTx, tx)
+		}
+		Remove(statedb, entry.ID)
+	}
+	// Removed entries leave nothing but the global counters behind.
+	for id := uint64(1); id <= 6; id++ {
+		for field := uint64(existsField); field < dataField+4; field++ {
+			if value := statedb.GetState(QueueAddress, slot(id, field)); value != (common.Hash{}) {
+				t.Fatalf("entry %d: field %d not cleared: %x", id, field, value)
+			}
+		}
+	}
+}
+
+func TestQueueRevert(t *testing.T) {
+	statedb := newState()
+	push(statedb, umbrella.ScheduleTx{Unixtime: 1})
+
+	snapshot := statedb.Snapshot()
+	push(statedb, umbrella.ScheduleTx{Unixtime: 2})
+	Remove(statedb, 1)
+	statedb.RevertToSnapshot(snapshot)
+
+	if have, want := unixtimes(Pending(statedb)), []uint64{1}; !equal(have, want) {
+		t.Fatalf("revert mismatch: have %v, want %v", have, want)
+	}
+	// Ids are not reused after a revert, the counter reverts too.
+	if id := push(statedb, umbrella.ScheduleTx{Unixtime: 3}); id != 2 {
+		t.Fatalf("id mismatch: have %d, want 2", id)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	var (
+		statedb  = newState()
+		sender   = common.Address{1}
+		receiver = common.Address{2}
+	)
+	id := push(statedb, umbrella.ScheduleTx{Sender: sender, Receiver: receiver})
+
+	if err := Cancel(statedb, id, common.Address{3}); err != ErrNotPermitted {
+		t.Fatalf("cancel by stranger: have %v, want %v", err, ErrNotPermitted)
+	}
+	if err := Cancel(statedb, id, receiver); err != nil {
+		t.Fatalf("cancel by receiver failed: %v", err)
+	}
+	if err := Cancel(statedb, id, sender); err != ErrUnknownEntry {
+		t.Fatalf("cancel twice: have %v, want %v", err, ErrUnknownEntry)
+	}
+	if parsed, ok := ParseCancelInput(CancelInput(id)); !ok || parsed != id {
+		t.Fatalf("cancel input mismatch: have %d, want %d", parsed, id)
+	}
+	if _, ok := ParseCancelInput(CancelInput(id)[1:]); ok {
+		t.Fatalf("malformed cancel input accepted")
+	}
+}
+
+func equal(a, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -90,7 +90,9 @@ type (
 		account *common.Address
 	}
 	resetObjectChange struct {
-		prev *stateObject
+		prev         *stateObject
+		prevdestruct bool                   // whether the snapshot destructed the account already
+		prevstorage  map[common.Hash][]byte // snapshot storage writes dropped by the reset
 	}
 	suicideChange struct {
 		account     *common.Address
@@ -126,6 +128,12 @@ type (
 	addPreimageChange struct {
 		hash common.Hash
 	}
+	addScheduleChange struct {
+		txhash common.Hash
+	}
+	freeGasChange struct {
+		account *common.Address
+	}
 	touchChange struct {
 		account   *common.Address
 		prev      bool
@@ -144,6 +152,12 @@ func (ch createObjectChange) dirtied() *common.Address {
 
 func (ch resetObjectChange) revert(s *StateDB) {
 	s.setStateObject(ch.prev)
+	if !ch.prevdestruct && s.snap != nil {
+		delete(s.snapDestructs, ch.prev.addrHash)
+	}
+	if ch.prevstorage != nil {
+		s.snapStorage[ch.prev.addrHash] = ch.prevstorage
+	}
 }
 
 func (ch resetObjectChange) dirtied() *common.Address {
@@ -225,6 +239,27 @@ func (ch addLogChange) dirtied() *common.Address {
 	return nil
 }
 
+func (ch addScheduleChange) revert(s *StateDB) {
+	schedules := s.schedules[ch.txhash]
+	if len(schedules) == 1 {
+		delete(s.schedules, ch.txhash)
+	} else {
+		s.schedules[ch.txhash] = schedules[:len(schedules)-1]
+	}
+}
+
+func (ch addScheduleChange) dirtied() *common.Address {
+	return nil
+}
+
+func (ch freeGasChange) revert(s *StateDB) {
+	delete(s.freegas, *ch.account)
+}
+
+func (ch freeGasChange) dirtied() *common.Address {
+	return nil
+}
+
 func (ch addPreimageChange) revert(s *StateDB) {
 	delete(s.preimages, ch.hash)
 }
@@ -0,0 +1,137 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pruner
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// bloomMagic identifies persisted state bloom filters.
+var bloomMagic = []byte("statebloom/v1")
+
+// stateBloom is a bloom filter over the hashes of the state trie nodes and
+// contract codes to retain. The hashes are uniformly random, so each 8 byte
+// word of a hash serves as one of the hash functions.
+//
+// False positives merely retain some stale state, whereas there are no false
+// negatives, which would delete live state.
+type stateBloom struct {
+	bits []uint64
+}
+
+// newStateBloom creates a bloom filter of the given size in bytes.
+func newStateBloom(size uint64) *stateBloom {
+	if size < 8 {
+		size = 8
+	}
+	return &stateBloom{bits: make([]uint64, size/8)}
+}
+
+// add inserts a hash into the filter.
+func (b *stateBloom) add(hash []byte) {
+	m := uint64(len(b.bits)) * 64
+	for i := 0; i < common.HashLength; i += 8 {
+		bit := binary.BigEndian.Uint64(hash[i:]) % m
+		b.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+// contains reports whether the hash may have been inserted into the filter.
+func (b *stateBloom) contains(hash []byte) bool {
+	m := uint64(len(b.bits)) * 64
+	for i := 0; i < common.HashLength; i += 8 {
+		bit := binary.BigEndian.Uint64(hash[i:]) % m
+		if b.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// commit persists the filter along with the head block it was built for, so
+// that an interrupted pruning can resume without marking the state again. The
+// file is written atomically.
+func (b *stateBloom) commit(path string, head common.Hash) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	w.Write(bloomMagic)
+	w.Write(head[:])
+
+	var word [8]byte
+	binary.BigEndian.PutUint64(word[:], uint64(len(b.bits)))
+	w.Write(word[:])
+	for _, bits := range b.bits {
+		binary.BigEndian.PutUint64(word[:], bits)
+		w.Write(word[:])
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// loadStateBloom reads a filter persisted by commit, returning the head block it
+// was built for.
+func loadStateBloom(path string) (*stateBloom, common.Hash, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, common.Hash{}, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	header := make([]byte, len(bloomMagic)+common.HashLength+8)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, common.Hash{}, err
+	}
+	if !bytes.Equal(header[:len(bloomMagic)], bloomMagic) {
+		return nil, common.Hash{}, errors.New("invalid state bloom filter file")
+	}
+	head := common.BytesToHash(header[len(bloomMagic) : len(bloomMagic)+common.HashLength])
+
+	b := &stateBloom{bits: make([]uint64, binary.BigEndian.Uint64(header[len(bloomMagic)+common.HashLength:]))}
+	if len(b.bits) == 0 {
+		return nil, common.Hash{}, errors.New("empty state bloom filter")
+	}
+	var word [8]byte
+	for i := range b.bits {
+		if _, err := io.ReadFull(r, word[:]); err != nil {
+			return nil, common.Hash{}, err
+		}
+		b.bits[i] = binary.BigEndian.Uint64(word[:])
+	}
+	return b, head, nil
+}
@@ -0,0 +1,262 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package pruner deletes the state of a full node which is no longer reachable
+// from the recent blocks.
+package pruner
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// logInterval is the frequency of the progress reports.
+const logInterval = 8 * time.Second
+
+// Config includes all the configurations for pruning.
+type Config struct {
+	Retain    uint64 // Number of recent block states to retain
+	BloomSize uint64 // Size of the bloom filter marking the retained state, in megabytes
+	BloomPath string // File persisting the bloom filter, for resuming an interrupted pruning
+	DryRun    bool   // Only report the state that would be deleted
+}
+
+// Pruner is an offline tool to prune the stale state of a full node.
+//
+// The state of a full node is only garbage collected while it is held in
+// memory, so the state nodes flushed to disk are never deleted. Pruning marks
+// every trie node and contract code reachable from the state roots of the
+// recent blocks in a bloom filter, then deletes every other state entry. Trie
+// nodes and codes are stored under their Keccak256 hash, which distinguishes
+// them from the rest of the database.
+//
+// The pruning must not run alongside the node, which would write new state.
+type Pruner struct {
+	db     ethdb.Database
+	config Config
+}
+
+// NewPruner creates the pruner instance.
+func NewPruner(db ethdb.Database, config Config) (*Pruner, error) {
+	if _, ok := rawdb.KeyValueStore(db).(*ethdb.LDBDatabase); !ok {
+		return nil, errors.New("state pruning requires a persistent database")
+	}
+	if config.Retain == 0 {
+		return nil, errors.New("at least the head state must be retained")
+	}
+	if config.BloomSize == 0 {
+		return nil, errors.New("bloom filter size must be positive")
+	}
+	return &Pruner{db: db, config: config}, nil
+}
+
+// Prune deletes the state which isn't reachable from the retained recent block
+// states. If a bloom filter persisted by an interrupted pruning of the same
+// chain head is found, the marking is skipped.
+func (p *Pruner) Prune() error {
+	head := rawdb.ReadHeadBlockHash(p.db)
+	number := rawdb.ReadHeaderNumber(p.db, head)
+	if number == nil {
+		return errors.New("head block missing")
+	}
+	// Resume an interrupted pruning if the chain didn't move since
+	bloom, err := p.resume(head)
+	if err != nil {
+		return err
+	}
+	if bloom == nil {
+		roots, err := p.retainedRoots(*number)
+		if err != nil {
+			return err
+		}
+		if bloom, err = p.mark(roots); err != nil {
+			return err
+		}
+		if !p.config.DryRun && p.config.BloomPath != "" {
+			if err := bloom.commit(p.config.BloomPath, head); err != nil {
+				return err
+			}
+		}
+	}
+	if err := p.sweep(bloom); err != nil {
+		return err
+	}
+	if !p.config.DryRun && p.config.BloomPath != "" {
+		os.Remove(p.config.BloomPath)
+	}
+	return nil
+}
+
+// resume loads the bloom filter persisted by an interrupted pruning, or returns
+// nil if there is none for the current head block.
+func (p *Pruner) resume(head common.Hash) (*stateBloom, error) {
+	if p.config.BloomPath == "" || !common.FileExist(p.config.BloomPath) {
+		return nil, nil
+	}
+	bloom, bloomHead, err := loadStateBloom(p.config.BloomPath)
+	switch {
+	case err != nil:
+		log.Warn("Discarding unreadable state bloom filter", "path", p.config.BloomPath, "err", err)
+		return nil, nil
+
+	case bloomHead != head:
+		// The node ran since, the new state isn't marked. The state deleted
+		// already wasn't reachable from the retained states, start over.
+		log.Warn("Discarding state bloom filter of a previous head", "path", p.config.BloomPath, "head", bloomHead)
+		return nil, nil
+	}
+	log.Info("Resuming interrupted state pruning", "path", p.config.BloomPath)
+	return bloom, nil
+}
+
+// retainedRoots returns the state roots of the recent canonical blocks present
+// in the database, which must include the state of the head block.
+func (p *Pruner) retainedRoots(number uint64) ([]common.Hash, error) {
+	var (
+		roots []common.Hash
+		seen  = make(map[common.Hash]bool)
+	)
+	for i := uint64(0); i < p.config.Retain && i <= number; i++ {
+		hash := rawdb.ReadCanonicalHash(p.db, number-i)
+		header := rawdb.ReadHeader(p.db, hash, number-i)
+		if header == nil {
+			return nil, fmt.Errorf("canonical header #%d missing", number-i)
+		}
+		if seen[header.Root] {
+			continue
+		}
+		if ok, _ := p.db.Has(header.Root[:]); !ok {
+			if i == 0 {
+				return nil, fmt.Errorf("head state %x missing, was the node shut down cleanly?", header.Root)
+			}
+			continue // State garbage collected in memory, never flushed
+		}
+		seen[header.Root] = true
+		roots = append(roots, header.Root)
+	}
+	log.Info("Selected states to retain", "head", number, "states", len(roots))
+	return roots, nil
+}
+
+// mark inserts every trie node and contract code of the given states into a
+// new bloom filter.
+func (p *Pruner) mark(roots []common.Hash) (*stateBloom, error) {
+	var (
+		bloom   = newStateBloom(p.config.BloomSize * 1024 * 1024)
+		statedb = state.NewDatabase(p.db)
+		nodes   uint64
+		start   = time.Now()
+		logged  = time.Now()
+	)
+	for i, root := range roots {
+		st, err := state.New(root, statedb)
+		if err != nil {
+			return nil, err
+		}
+		it := state.NewNodeIterator(st)
+		for it.Next() {
+			if it.Hash != (common.Hash{}) {
+				bloom.add(it.Hash[:])
+				nodes++
+			}
+			if time.Since(logged) > logInterval {
+				log.Info("Marking retained state", "states", fmt.Sprintf("%d/%d", i, len(roots)), "nodes", nodes, "elapsed", common.PrettyDuration(time.Since(start)))
+				logged = time.Now()
+			}
+		}
+		if it.Error != nil {
+			return nil, fmt.Errorf("failed to iterate state %x: %v", root, it.Error)
+		}
+	}
+	log.Info("Marked retained state", "states", len(roots), "nodes", nodes, "elapsed", common.PrettyDuration(time.Since(start)))
+	return bloom, nil
+}
+
+// sweep deletes the state entries missing from the bloom filter, then compacts
+// the database to reclaim the space.
+func (p *Pruner) sweep(bloom *stateBloom) error {
+	var (
+		batch  = p.db.NewBatch()
+		count  uint64
+		size   common.StorageSize
+		start  = time.Now()
+		logged = time.Now()
+	)
+	it := p.db.NewIterator(nil, nil)
+	defer it.Release()
+
+	for it.Next() {
+		key, value := it.Key(), it.Value()
+
+		// Trie nodes and contract codes are keyed by the hash of their content,
+		// anything else isn't state
+		if len(key) != common.HashLength || bloom.contains(key) {
+			continue
+		}
+		if !bytes.Equal(key, crypto.Keccak256(value)) {
+			continue
+		}
+		count++
+		size += common.StorageSize(len(key) + len(value))
+
+		if !p.config.DryRun {
+			batch.Delete(key)
+			if batch.ValueSize() >= ethdb.IdealBatchSize {
+				if err := batch.Write(); err != nil {
+					return err
+				}
+				batch.Reset()
+			}
+		}
+		if time.Since(logged) > logInterval {
+			// Hash keys are uniformly distributed, estimate the progress from the position
+			done := float64(binary.BigEndian.Uint16(key)) / 65536 * 100
+			log.Info("Pruning stale state", "progress", fmt.Sprintf("%.2f%%", done), "nodes", count, "size", size, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	if p.config.DryRun {
+		log.Info("Found stale state (dry run)", "nodes", count, "size", size, "elapsed", common.PrettyDuration(time.Since(start)))
+		return nil
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	log.Info("Pruned stale state", "nodes", count, "size", size, "elapsed", common.PrettyDuration(time.Since(start)))
+
+	// Reclaim the disk space of the deleted entries
+	cstart := time.Now()
+	log.Info("Compacting database")
+	if err := p.db.Compact(nil, nil); err != nil {
+		return err
+	}
+	log.Info("Compacted database", "elapsed", common.PrettyDuration(time.Since(cstart)))
+	return nil
+}
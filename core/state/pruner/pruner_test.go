@@ -0,0 +1,195 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pruner
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// makeChain commits a series of states changing an account and the code and
+// storage of a contract, and writes a canonical chain of headers on top.
+func makeChain(t *testing.T, db ethdb.Database, blocks int) []common.Hash {
+	var (
+		sdb     = state.NewDatabase(db)
+		roots   []common.Hash
+		root    common.Hash
+		parent  common.Hash
+		account = common.Address{0x01}
+		storage = common.Address{0x02}
+	)
+	for i := 0; i < blocks; i++ {
+		st, err := state.New(root, sdb)
+		if err != nil {
+			t.Fatal(err)
+		}
+		st.SetBalance(account, big.NewInt(int64(i+1)))
+		st.SetCode(storage, []byte{0x60, byte(i)})
+		st.SetState(storage, common.Hash{byte(i)}, common.Hash{0x01})
+		st.SetState(storage, common.Hash{0xff}, common.Hash{byte(i + 1)})
+
+		if root, err = st.Commit(true); err != nil {
+			t.Fatal(err)
+		}
+		if err := sdb.TrieDB().Commit(root, false); err != nil {
+			t.Fatal(err)
+		}
+		header := &types.Header{Number: big.NewInt(int64(i)), ParentHash: parent, Root: root}
+		rawdb.WriteHeader(db, header)
+		rawdb.WriteCanonicalHash(db, header.Hash(), uint64(i))
+		rawdb.WriteHeadBlockHash(db, header.Hash())
+
+		roots, parent = append(roots, root), header.Hash()
+	}
+	return roots
+}
+
+// countState returns the number of entries which are keyed by their hash.
+func countState(db ethdb.Database) int {
+	count := 0
+	it := db.NewIterator(nil, nil)
+	defer it.Release()
+	for it.Next() {
+		if len(it.Key()) == common.HashLength {
+			count++
+		}
+	}
+	return count
+}
+
+// checkState verifies that the state at root is complete.
+func checkState(db ethdb.Database, root common.Hash) error {
+	st, err := state.New(root, state.NewDatabase(db))
+	if err != nil {
+		return err
+	}
+	it := state.NewNodeIterator(st)
+	for it.Next() {
+	}
+	return it.Error
+}
+
+func TestPruneState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pruner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := ethdb.NewLDBDatabase(filepath.Join(dir, "chaindata"), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	roots := makeChain(t, db, 5)
+	config := Config{Retain: 2, BloomSize: 1, BloomPath: filepath.Join(dir, "statebloom.bf"), DryRun: true}
+
+	// A dry run doesn't delete anything
+	before := countState(db)
+	p, err := NewPruner(db, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Prune(); err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	if have := countState(db); have != before {
+		t.Fatalf("dry run deleted state: have %d entries, want %d", have, before)
+	}
+	// Pruning keeps the retained states only
+	config.DryRun = false
+	if p, err = NewPruner(db, config); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Prune(); err != nil {
+		t.Fatalf("pruning failed: %v", err)
+	}
+	if have := countState(db); have >= before {
+		t.Errorf("no state pruned: have %d entries, had %d", have, before)
+	}
+	for i, root := range roots {
+		err := checkState(db, root)
+		if retained := i >= len(roots)-2; retained && err != nil {
+			t.Errorf("state %d: retained state incomplete: %v", i, err)
+		} else if !retained {
+			if has, _ := db.Has(root[:]); has {
+				t.Errorf("state %d: stale state root retained", i)
+			}
+		}
+	}
+	if common.FileExist(config.BloomPath) {
+		t.Errorf("state bloom filter not removed after pruning")
+	}
+	// The rest of the database is untouched
+	if header := rawdb.ReadHeader(db, rawdb.ReadCanonicalHash(db, 0), 0); header == nil || header.Root != roots[0] {
+		t.Errorf("header pruned")
+	}
+}
+
+// Tests that an interrupted pruning resumes with the persisted bloom filter of
+// the same head, but starts over once the chain progressed.
+func TestPruneStateResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pruner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := ethdb.NewLDBDatabase(filepath.Join(dir, "chaindata"), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	roots := makeChain(t, db, 3)
+	config := Config{Retain: 1, BloomSize: 1, BloomPath: filepath.Join(dir, "statebloom.bf")}
+	p, err := NewPruner(db, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Persist a filter for the current head, as if the sweeping was interrupted
+	head := rawdb.ReadHeadBlockHash(db)
+	if err := newStateBloom(1024).commit(config.BloomPath, head); err != nil {
+		t.Fatal(err)
+	}
+	if bloom, err := p.resume(head); err != nil || bloom == nil {
+		t.Fatalf("failed to resume: %v", err)
+	}
+	// A filter of another head is discarded
+	if bloom, err := p.resume(common.Hash{0x01}); err != nil || bloom != nil {
+		t.Fatalf("resumed with a stale filter: %v", err)
+	}
+	if err := newStateBloom(1024).commit(config.BloomPath, common.Hash{0x01}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Prune(); err != nil {
+		t.Fatalf("pruning failed: %v", err)
+	}
+	if err := checkState(db, roots[len(roots)-1]); err != nil {
+		t.Errorf("head state incomplete after discarding a stale filter: %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/hashicorp/golang-lru"
+)
+
+// cacheItemSize is the estimated memory footprint of a cached snapshot entry,
+// used to convert the cache allowance into a number of entries.
+const cacheItemSize = 128
+
+// diskLayer is a low level persistent snapshot built on top of a key-value store.
+type diskLayer struct {
+	diskdb ethdb.Database // Key-value store containing the base snapshot
+	triedb *trie.Database // Trie node cache for reconstruction purposes
+	cache  *lru.Cache     // Cache to avoid hitting the disk for direct access
+
+	root  common.Hash // Root hash of the base snapshot
+	stale bool        // Signals that the layer became stale (state progressed)
+
+	genMarker  []byte                    // Marker for the state that's indexed during initial layer generation
+	genPending chan struct{}             // Notification channel when generation is done (test synchronicity)
+	genAbort   chan chan *generatorStats // Notification channel to abort generating the snapshot in this layer
+
+	lock sync.RWMutex
+}
+
+// newDiskLayer creates a disk layer of the given root with an empty cache.
+func newDiskLayer(diskdb ethdb.Database, triedb *trie.Database, cache int, root common.Hash) *diskLayer {
+	items := cache * 1024 * 1024 / cacheItemSize
+	if items < 1 {
+		items = 1
+	}
+	entries, _ := lru.New(items)
+	return &diskLayer{
+		diskdb:     diskdb,
+		triedb:     triedb,
+		cache:      entries,
+		root:       root,
+		genPending: make(chan struct{}),
+	}
+}
+
+// Root returns root hash for which this snapshot was made.
+func (dl *diskLayer) Root() common.Hash {
+	return dl.root
+}
+
+// Parent always returns nil as there's no layer below the disk.
+func (dl *diskLayer) Parent() snapshot {
+	return nil
+}
+
+// Stale return whether this layer has become stale (was flattened across) or if
+// it's still live.
+func (dl *diskLayer) Stale() bool {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	return dl.stale
+}
+
+// Account directly retrieves the account associated with a particular hash in
+// the snapshot.
+func (dl *diskLayer) Account(hash common.Hash) (*Account, error) {
+	return decodeAccount(dl.AccountRLP(hash))
+}
+
+// AccountRLP directly retrieves the account RLP associated with a particular
+// hash in the snapshot.
+func (dl *diskLayer) AccountRLP(hash common.Hash) ([]byte, error) {
+	return dl.read(hash[:], func() []byte {
+		return rawdb.ReadAccountSnapshot(dl.diskdb, hash)
+	})
+}
+
+// Storage directly retrieves the storage data associated with a particular hash,
+// within a particular account.
+func (dl *diskLayer) Storage(accountHash, storageHash common.Hash) ([]byte, error) {
+	return dl.read(append(accountHash[:], storageHash[:]...), func() []byte {
+		return rawdb.ReadStorageSnapshot(dl.diskdb, accountHash, storageHash)
+	})
+}
+
+// read retrieves a snapshot entry from the cache, or from the database if it was
+// generated already.
+func (dl *diskLayer) read(key []byte, load func() []byte) ([]byte, error) {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	// If the layer was flattened into, it should be invalidated
+	if dl.stale {
+		return nil, ErrSnapshotStale
+	}
+	// If the layer is being generated, ensure the requested item is covered
+	if dl.genMarker != nil && bytes.Compare(key, dl.genMarker) > 0 {
+		return nil, ErrNotCoveredYet
+	}
+	if blob, ok := dl.cache.Get(string(key)); ok {
+		return blob.([]byte), nil
+	}
+	blob := load()
+	dl.cache.Add(string(key), blob)
+	return blob, nil
+}
+
+// Update creates a new layer on top of the existing snapshot diff tree with
+// the specified data items.
+func (dl *diskLayer) Update(blockRoot common.Hash, destructs map[common.Hash]struct{}, accounts map[common.Hash][]byte, storage map[common.Hash]map[common.Hash][]byte) *diffLayer {
+	return newDiffLayer(dl, blockRoot, destructs, accounts, storage)
+}
+
+// stopGeneration aborts the background generation of the layer if running,
+// returning its progress. The caller must hold the tree lock.
+func (dl *diskLayer) stopGeneration() *generatorStats {
+	if dl.genAbort == nil {
+		return nil
+	}
+	abort := make(chan *generatorStats)
+	dl.genAbort <- abort
+	dl.genAbort = nil
+	return <-abort
+}
+
+// decodeAccount decodes an account in its trie encoding, or returns nil if the
+// account doesn't exist.
+func decodeAccount(blob []byte, err error) (*Account, error) {
+	if err != nil || len(blob) == 0 {
+		return nil, err
+	}
+	account := new(Account)
+	if err := rlp.DecodeBytes(blob, account); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
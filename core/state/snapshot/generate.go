@@ -0,0 +1,289 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+const (
+	// logInterval is the frequency of the generation progress reports.
+	logInterval = 8 * time.Second
+
+	// wipeBatchSize is the number of stale entries deleted in one batch.
+	wipeBatchSize = 10000
+)
+
+// storageDone is appended to the hash of an account as the generation marker
+// once all of its storage slots were generated.
+var storageDone = bytes.Repeat([]byte{0xff}, common.HashLength)
+
+// journalGenerator is the persisted progress of the snapshot generation, which
+// is resumed on restart.
+type journalGenerator struct {
+	Wiping   bool   // Whether the stale snapshot is still being deleted
+	Done     bool   // Whether the generator finished creating the snapshot
+	Marker   []byte // Key of the last generated entry
+	Accounts uint64 // Number of accounts generated
+	Slots    uint64 // Number of storage slots generated
+}
+
+// generatorStats is a collection of statistics gathered by the snapshot
+// generator for logging purposes.
+type generatorStats struct {
+	wiping   bool      // Whether the stale snapshot is still being deleted
+	accounts uint64    // Number of accounts generated
+	slots    uint64    // Number of storage slots generated
+	start    time.Time // Timestamp when the current generator run started
+	logged   time.Time // Timestamp of the last progress report
+}
+
+// log reports the progress of the generation.
+func (gs *generatorStats) log(msg string, root common.Hash, marker []byte) {
+	ctx := []interface{}{"root", root, "accounts", gs.accounts, "slots", gs.slots, "elapsed", common.PrettyDuration(time.Since(gs.start))}
+	if len(marker) > 0 {
+		// Hashes are uniformly distributed, estimate the progress from the position
+		done := float64(binary.BigEndian.Uint16(marker)) / 65536 * 100
+		ctx = append(ctx, "progress", fmt.Sprintf("%.2f%%", done))
+	}
+	log.Info(msg, ctx...)
+}
+
+// generateSnapshot wipes the persisted snapshot and starts generating a new one
+// of the given state in the background.
+func generateSnapshot(diskdb ethdb.Database, triedb *trie.Database, cache int, root common.Hash) *diskLayer {
+	stats := &generatorStats{wiping: true}
+
+	batch := diskdb.NewBatch()
+	rawdb.WriteSnapshotRoot(batch, root)
+	journalProgress(batch, []byte{}, stats)
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to write initialized state marker", "err", err)
+	}
+	base := newDiskLayer(diskdb, triedb, cache, root)
+	base.genMarker = []byte{}
+	base.genAbort = make(chan chan *generatorStats)
+
+	log.Info("Started state snapshot generation", "root", root)
+	go base.generate(stats)
+	return base
+}
+
+// journalProgress persists the generator progress into a database batch.
+func journalProgress(db ethdb.Putter, marker []byte, stats *generatorStats) {
+	entry := journalGenerator{
+		Wiping:   stats.wiping,
+		Done:     marker == nil,
+		Marker:   marker,
+		Accounts: stats.accounts,
+		Slots:    stats.slots,
+	}
+	blob, err := rlp.EncodeToBytes(entry)
+	if err != nil {
+		panic(err) // Cannot happen, here to catch dev errors
+	}
+	rawdb.WriteSnapshotGenerator(db, blob)
+}
+
+// generate is a background thread that iterates over the state and storage tries
+// of the disk layer and constructs the snapshot entries. A stale snapshot is
+// wiped first. The generation is aborted through the genAbort channel, when the
+// layer is flattened into or discarded, responding with the progress to resume.
+func (dl *diskLayer) generate(stats *generatorStats) {
+	stats.start, stats.logged = time.Now(), time.Now()
+
+	if stats.wiping {
+		if abort := dl.wipe(stats); abort != nil {
+			abort <- stats
+			return
+		}
+	}
+	batch := dl.diskdb.NewBatch()
+
+	// checkAndFlush writes the batch once it's large enough or the generation is
+	// aborted, advancing the marker, and reports whether it was aborted.
+	checkAndFlush := func(marker []byte) bool {
+		var abort chan *generatorStats
+		select {
+		case abort = <-dl.genAbort:
+		default:
+		}
+		if batch.ValueSize() >= ethdb.IdealBatchSize || abort != nil {
+			journalProgress(batch, marker, stats)
+			if err := batch.Write(); err != nil {
+				log.Crit("Failed to write snapshot", "err", err)
+			}
+			batch.Reset()
+
+			dl.lock.Lock()
+			dl.genMarker = marker
+			dl.lock.Unlock()
+		}
+		if abort != nil {
+			stats.log("Aborted state snapshot generation", dl.root, marker)
+			abort <- stats
+			return true
+		}
+		if time.Since(stats.logged) > logInterval {
+			stats.log("Generating state snapshot", dl.root, marker)
+			stats.logged = time.Now()
+		}
+		return false
+	}
+	// fail waits for the layer to be discarded after the state became
+	// unavailable, retaining the progress flushed before
+	fail := func(err error) {
+		log.Warn("Failed to generate state snapshot", "root", dl.root, "err", err)
+		abort := <-dl.genAbort
+		abort <- stats
+	}
+	accTrie, err := trie.New(dl.root, dl.triedb)
+	if err != nil {
+		fail(err)
+		return
+	}
+	dl.lock.RLock()
+	marker := dl.genMarker
+	dl.lock.RUnlock()
+
+	var accMarker, storeMarker []byte
+	if len(marker) > 0 {
+		accMarker = marker[:common.HashLength]
+	}
+	if len(marker) > common.HashLength {
+		storeMarker = marker[common.HashLength:]
+	}
+	accIt := trie.NewIterator(accTrie.NodeIterator(accMarker))
+	for accIt.Next() {
+		accountHash := common.BytesToHash(accIt.Key)
+
+		var acc Account
+		if err := rlp.DecodeBytes(accIt.Value, &acc); err != nil {
+			log.Crit("Invalid account encountered during snapshot creation", "err", err)
+		}
+		rawdb.WriteAccountSnapshot(batch, accountHash, accIt.Value)
+		stats.accounts++
+
+		if checkAndFlush(accountHash[:]) {
+			return
+		}
+		// Generate the storage of the account, resuming a partially done one
+		if acc.Root != emptyRoot {
+			var start []byte
+			if bytes.Equal(accountHash[:], accMarker) {
+				start = storeMarker
+			}
+			storeTrie, err := trie.New(acc.Root, dl.triedb)
+			if err != nil {
+				fail(err)
+				return
+			}
+			storeIt := trie.NewIterator(storeTrie.NodeIterator(start))
+			for storeIt.Next() {
+				rawdb.WriteStorageSnapshot(batch, accountHash, common.BytesToHash(storeIt.Key), storeIt.Value)
+				stats.slots++
+
+				if checkAndFlush(append(accountHash[:], storeIt.Key...)) {
+					return
+				}
+			}
+			if storeIt.Err != nil {
+				fail(storeIt.Err)
+				return
+			}
+		}
+		if checkAndFlush(append(accountHash[:], storageDone...)) {
+			return
+		}
+	}
+	if accIt.Err != nil {
+		fail(accIt.Err)
+		return
+	}
+	// Snapshot fully generated, persist the marker and wait for the abort
+	journalProgress(batch, nil, stats)
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to flush snapshot", "err", err)
+	}
+	dl.lock.Lock()
+	dl.genMarker = nil
+	close(dl.genPending)
+	dl.lock.Unlock()
+
+	stats.log("Generated state snapshot", dl.root, nil)
+
+	abort := <-dl.genAbort
+	abort <- nil
+}
+
+// wipe deletes the entries of a stale snapshot before it's generated anew,
+// returning the abort request if it was interrupted.
+func (dl *diskLayer) wipe(stats *generatorStats) chan *generatorStats {
+	var (
+		batch   = dl.diskdb.NewBatch()
+		deleted int
+	)
+	// Trie nodes keyed by hash may share the prefixes, only the keys of the
+	// snapshot entry lengths are deleted
+	wiped := []struct {
+		prefix []byte
+		keylen int
+	}{
+		{rawdb.SnapshotAccountPrefix, len(rawdb.SnapshotAccountPrefix) + common.HashLength},
+		{rawdb.SnapshotStoragePrefix, len(rawdb.SnapshotStoragePrefix) + 2*common.HashLength},
+	}
+	for _, entries := range wiped {
+		it := dl.diskdb.NewIterator(entries.prefix, nil)
+		for it.Next() {
+			if len(it.Key()) != entries.keylen {
+				continue
+			}
+			batch.Delete(it.Key())
+			if deleted++; deleted%wipeBatchSize != 0 {
+				continue
+			}
+			if err := batch.Write(); err != nil {
+				log.Crit("Failed to wipe snapshot", "err", err)
+			}
+			batch.Reset()
+
+			select {
+			case abort := <-dl.genAbort:
+				it.Release()
+				return abort
+			default:
+			}
+		}
+		it.Release()
+	}
+	stats.wiping = false
+	journalProgress(batch, []byte{}, stats)
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to wipe snapshot", "err", err)
+	}
+	return nil
+}
@@ -0,0 +1,257 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// Iterator iterates over the accounts of a snapshot, or over the storage slots
+// of an account, in ascending hash order.
+type Iterator interface {
+	// Next steps the iterator forward one element, returning false if exhausted.
+	Next() bool
+
+	// Error returns any failure that occurred during iteration, which might have
+	// caused a premature iteration exit.
+	Error() error
+
+	// Hash returns the hash of the account or storage slot the iterator is
+	// currently at.
+	Hash() common.Hash
+
+	// Value returns the account or storage slot the iterator is currently at, in
+	// its trie encoding.
+	Value() []byte
+
+	// Release releases associated resources. Release should always succeed and
+	// can be called multiple times without causing error.
+	Release()
+}
+
+// overlayEntry is an item changed by the diff layers, nil if deleted.
+type overlayEntry struct {
+	hash  common.Hash
+	value []byte
+}
+
+// layeredIterator merges the changes of the diff layers of a snapshot into the
+// entries of its disk layer.
+type layeredIterator struct {
+	overlay []overlayEntry // Changes of the diff layers, sorted by hash
+	disk    ethdb.Iterator // Iterator over the disk layer entries, nil if wiped
+	keylen  int            // Length of the disk layer keys of the entries
+
+	diskHash  common.Hash // Hash of the pending disk layer entry
+	diskValue []byte      // Value of the pending disk layer entry
+	diskValid bool        // Whether a disk layer entry is pending
+
+	hash  common.Hash // Hash of the current entry
+	value []byte      // Value of the current entry
+}
+
+// AccountIterator creates an iterator over the accounts of the snapshot of the
+// given root, starting at the seek hash. The snapshot must be fully generated.
+func (t *Tree) AccountIterator(root common.Hash, seek common.Hash) (Iterator, error) {
+	diffs, base, err := t.stack(root)
+	if err != nil {
+		return nil, err
+	}
+	changes := make(map[common.Hash][]byte)
+	for i := len(diffs) - 1; i >= 0; i-- {
+		diff := diffs[i]
+		diff.lock.RLock()
+		if diff.Stale() {
+			diff.lock.RUnlock()
+			return nil, ErrSnapshotStale
+		}
+		for hash := range diff.destructSet {
+			changes[hash] = nil
+		}
+		for hash, data := range diff.accountData {
+			changes[hash] = data
+		}
+		diff.lock.RUnlock()
+	}
+	it := &layeredIterator{
+		overlay: sortOverlay(changes, seek),
+		disk:    base.diskdb.NewIterator(rawdb.SnapshotAccountPrefix, seek[:]),
+		keylen:  len(rawdb.SnapshotAccountPrefix) + common.HashLength,
+	}
+	return it, nil
+}
+
+// StorageIterator creates an iterator over the storage slots of an account in
+// the snapshot of the given root, starting at the seek hash. The snapshot must
+// be fully generated.
+func (t *Tree) StorageIterator(root common.Hash, account common.Hash, seek common.Hash) (Iterator, error) {
+	diffs, base, err := t.stack(root)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		changes = make(map[common.Hash][]byte)
+		wiped   bool
+	)
+	for i := len(diffs) - 1; i >= 0; i-- {
+		diff := diffs[i]
+		diff.lock.RLock()
+		if diff.Stale() {
+			diff.lock.RUnlock()
+			return nil, ErrSnapshotStale
+		}
+		// A destructed account drops all the storage below
+		if _, ok := diff.destructSet[account]; ok {
+			changes, wiped = make(map[common.Hash][]byte), true
+		}
+		for hash, data := range diff.storageData[account] {
+			changes[hash] = data
+		}
+		diff.lock.RUnlock()
+	}
+	it := &layeredIterator{
+		overlay: sortOverlay(changes, seek),
+		keylen:  len(rawdb.SnapshotStoragePrefix) + 2*common.HashLength,
+	}
+	if !wiped {
+		it.disk = base.diskdb.NewIterator(rawdb.StorageSnapshotsKey(account), seek[:])
+	}
+	return it, nil
+}
+
+// stack retrieves the diff layers of the snapshot of the given root, top first,
+// and the disk layer below them, ensuring that the latter is fully generated.
+func (t *Tree) stack(root common.Hash) ([]*diffLayer, *diskLayer, error) {
+	t.lock.RLock()
+	snap, ok := t.layers[root]
+	t.lock.RUnlock()
+	if !ok {
+		return nil, nil, fmt.Errorf("snapshot [%#x] missing", root)
+	}
+	var diffs []*diffLayer
+	for {
+		diff, ok := snap.(*diffLayer)
+		if !ok {
+			break
+		}
+		diffs = append(diffs, diff)
+		snap = diff.Parent()
+	}
+	base := snap.(*diskLayer)
+
+	base.lock.RLock()
+	defer base.lock.RUnlock()
+
+	if base.stale {
+		return nil, nil, ErrSnapshotStale
+	}
+	if base.genMarker != nil {
+		return nil, nil, ErrNotCoveredYet
+	}
+	return diffs, base, nil
+}
+
+// sortOverlay sorts the changes of the diff layers from the seek hash onwards.
+func sortOverlay(changes map[common.Hash][]byte, seek common.Hash) []overlayEntry {
+	overlay := make([]overlayEntry, 0, len(changes))
+	for hash, value := range changes {
+		if bytes.Compare(hash[:], seek[:]) >= 0 {
+			overlay = append(overlay, overlayEntry{hash: hash, value: value})
+		}
+	}
+	sort.Slice(overlay, func(i, j int) bool {
+		return bytes.Compare(overlay[i].hash[:], overlay[j].hash[:]) < 0
+	})
+	return overlay
+}
+
+// Next steps the iterator forward one element, the changes of the diff layers
+// taking precedence over the disk layer entries of the same hash.
+func (it *layeredIterator) Next() bool {
+	for {
+		if it.disk != nil && !it.diskValid {
+			it.diskValid = it.nextDisk()
+		}
+		switch {
+		case len(it.overlay) > 0 && (!it.diskValid || bytes.Compare(it.overlay[0].hash[:], it.diskHash[:]) <= 0):
+			entry := it.overlay[0]
+			it.overlay = it.overlay[1:]
+			if it.diskValid && entry.hash == it.diskHash {
+				it.diskValid = false
+			}
+			if len(entry.value) == 0 {
+				continue // Deleted by a diff layer
+			}
+			it.hash, it.value = entry.hash, entry.value
+			return true
+
+		case it.diskValid:
+			it.hash, it.value = it.diskHash, it.diskValue
+			it.diskValid = false
+			return true
+
+		default:
+			return false
+		}
+	}
+}
+
+// nextDisk advances the disk layer iterator to the next snapshot entry, skipping
+// the keys of other kinds sharing the prefix.
+func (it *layeredIterator) nextDisk() bool {
+	for it.disk.Next() {
+		key := it.disk.Key()
+		if len(key) != it.keylen {
+			continue
+		}
+		it.diskHash = common.BytesToHash(key[it.keylen-common.HashLength:])
+		it.diskValue = common.CopyBytes(it.disk.Value())
+		return true
+	}
+	return false
+}
+
+// Error returns any failure that occurred during iteration.
+func (it *layeredIterator) Error() error {
+	if it.disk == nil {
+		return nil
+	}
+	return it.disk.Error()
+}
+
+// Hash returns the hash of the current entry.
+func (it *layeredIterator) Hash() common.Hash {
+	return it.hash
+}
+
+// Value returns the value of the current entry.
+func (it *layeredIterator) Value() []byte {
+	return it.value
+}
+
+// Release releases the disk layer iterator.
+func (it *layeredIterator) Release() {
+	if it.disk != nil {
+		it.disk.Release()
+	}
+}
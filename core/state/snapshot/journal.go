@@ -0,0 +1,208 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// journal is the persisted stack of diff layers on top of the disk layer, saved
+// on shutdown so that the snapshot survives a restart without regeneration.
+type journal struct {
+	Base  common.Hash   // Root of the disk layer the diffs are stacked on
+	Diffs []journalDiff // Diff layers, oldest first
+}
+
+// journalDiff is the persisted form of a diff layer.
+type journalDiff struct {
+	Root      common.Hash
+	Destructs []common.Hash
+	Accounts  []journalAccount
+	Storage   []journalStorage
+}
+
+// journalAccount is an account entry of a journalled diff layer.
+type journalAccount struct {
+	Hash common.Hash
+	Blob []byte
+}
+
+// journalStorage is the storage of an account in a journalled diff layer.
+type journalStorage struct {
+	Hash common.Hash
+	Keys []common.Hash
+	Vals [][]byte
+}
+
+// Journal persists the diff layers from the disk layer up to the given root, to
+// be restored by New on the next startup, and stops the background generation.
+// A snapshot still being generated is flattened into its disk layer instead,
+// whose generation needs the state of the root. The tree must not be modified
+// afterwards.
+func (t *Tree) Journal(root common.Hash) error {
+	t.lock.RLock()
+	snap, ok := t.layers[root]
+	t.lock.RUnlock()
+	if !ok {
+		return fmt.Errorf("snapshot [%#x] missing", root)
+	}
+	var diffs []journalDiff
+	for {
+		diff, ok := snap.(*diffLayer)
+		if !ok {
+			break
+		}
+		diffs = append(diffs, diff.journal())
+		snap = diff.Parent()
+	}
+	base := snap.(*diskLayer)
+	base.lock.RLock()
+	generating := base.genMarker != nil
+	base.lock.RUnlock()
+
+	if generating {
+		return t.Persist(root)
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	base.stopGeneration()
+
+	for i, j := 0, len(diffs)-1; i < j; i, j = i+1, j-1 {
+		diffs[i], diffs[j] = diffs[j], diffs[i]
+	}
+	blob, err := rlp.EncodeToBytes(&journal{Base: base.root, Diffs: diffs})
+	if err != nil {
+		return err
+	}
+	rawdb.WriteSnapshotJournal(t.diskdb, blob)
+
+	log.Info("Journalled state snapshot", "root", root, "base", base.root, "layers", len(diffs))
+	return nil
+}
+
+// journal converts the diff layer into its persisted form.
+func (dl *diffLayer) journal() journalDiff {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	entry := journalDiff{Root: dl.root}
+	for hash := range dl.destructSet {
+		entry.Destructs = append(entry.Destructs, hash)
+	}
+	for hash, blob := range dl.accountData {
+		entry.Accounts = append(entry.Accounts, journalAccount{Hash: hash, Blob: blob})
+	}
+	for accountHash, storage := range dl.storageData {
+		slots := journalStorage{Hash: accountHash}
+		for storageHash, data := range storage {
+			slots.Keys = append(slots.Keys, storageHash)
+			slots.Vals = append(slots.Vals, data)
+		}
+		entry.Storage = append(entry.Storage, slots)
+	}
+	return entry
+}
+
+// loadSnapshot loads the persisted disk layer along with the journalled diff
+// layers on top, if they contain the state of the given root, and resumes the
+// generation of the disk layer if unfinished.
+func loadSnapshot(diskdb ethdb.Database, triedb *trie.Database, cache int, root common.Hash) (map[common.Hash]snapshot, error) {
+	baseRoot := rawdb.ReadSnapshotRoot(diskdb)
+	if baseRoot == (common.Hash{}) {
+		return nil, errors.New("missing or corrupted snapshot")
+	}
+	var generator journalGenerator
+	if err := rlp.DecodeBytes(rawdb.ReadSnapshotGenerator(diskdb), &generator); err != nil {
+		return nil, fmt.Errorf("failed to load snapshot progress: %v", err)
+	}
+	base := newDiskLayer(diskdb, triedb, cache, baseRoot)
+	layers := map[common.Hash]snapshot{baseRoot: base}
+
+	if baseRoot != root {
+		if err := loadDiffLayers(layers, base, rawdb.ReadSnapshotJournal(diskdb)); err != nil {
+			return nil, err
+		}
+		if _, ok := layers[root]; !ok {
+			return nil, fmt.Errorf("head doesn't match snapshot: have %#x, want %#x", baseRoot, root)
+		}
+	}
+	if generator.Done {
+		close(base.genPending)
+		return layers, nil
+	}
+	base.genMarker = generator.Marker
+	if base.genMarker == nil {
+		base.genMarker = []byte{}
+	}
+	base.genAbort = make(chan chan *generatorStats)
+
+	log.Info("Resuming state snapshot generation", "root", baseRoot, "accounts", generator.Accounts, "slots", generator.Slots)
+	go base.generate(&generatorStats{wiping: generator.Wiping, accounts: generator.Accounts, slots: generator.Slots})
+	return layers, nil
+}
+
+// loadDiffLayers decodes the journalled diff layers and stacks them on the disk
+// layer they were saved on.
+func loadDiffLayers(layers map[common.Hash]snapshot, base *diskLayer, blob []byte) error {
+	if len(blob) == 0 {
+		return errors.New("missing snapshot journal")
+	}
+	var entry journal
+	if err := rlp.DecodeBytes(blob, &entry); err != nil {
+		return fmt.Errorf("failed to load snapshot journal: %v", err)
+	}
+	if entry.Base != base.root {
+		return fmt.Errorf("journal doesn't match snapshot: have %#x, want %#x", entry.Base, base.root)
+	}
+	var parent snapshot = base
+	for _, diff := range entry.Diffs {
+		destructs := make(map[common.Hash]struct{}, len(diff.Destructs))
+		for _, hash := range diff.Destructs {
+			destructs[hash] = struct{}{}
+		}
+		accounts := make(map[common.Hash][]byte, len(diff.Accounts))
+		for _, account := range diff.Accounts {
+			accounts[account.Hash] = account.Blob
+		}
+		storage := make(map[common.Hash]map[common.Hash][]byte, len(diff.Storage))
+		for _, slots := range diff.Storage {
+			if len(slots.Keys) != len(slots.Vals) {
+				return errors.New("invalid snapshot journal storage")
+			}
+			storage[slots.Hash] = make(map[common.Hash][]byte, len(slots.Keys))
+			for i, key := range slots.Keys {
+				if len(slots.Vals[i]) > 0 {
+					storage[slots.Hash][key] = slots.Vals[i]
+				} else {
+					storage[slots.Hash][key] = nil
+				}
+			}
+		}
+		parent = parent.Update(diff.Root, destructs, accounts, storage)
+		layers[diff.Root] = parent
+	}
+	return nil
+}
@@ -0,0 +1,415 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package snapshot implements a flat snapshot of the accounts and storage slots
+// of the state, which serves reads without traversing the tries.
+package snapshot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+var (
+	// ErrSnapshotStale is returned from data accessors if the underlying snapshot
+	// layer had been invalidated due to the chain progressing forward far enough
+	// to not maintain the layer's original state.
+	ErrSnapshotStale = errors.New("snapshot stale")
+
+	// ErrNotCoveredYet is returned from data accessors if the underlying snapshot
+	// is being generated currently and the requested data item is not yet in the
+	// range of accounts covered.
+	ErrNotCoveredYet = errors.New("not covered yet")
+
+	// errSnapshotCycle is returned if a snapshot is attempted to be inserted
+	// that forms a cycle in the snapshot tree.
+	errSnapshotCycle = errors.New("snapshot cycle")
+)
+
+// aggregatorMemoryLimit is the maximum size of the bottom-most diff layer that
+// aggregates the writes from above until it's flushed into the disk layer.
+const aggregatorMemoryLimit = 4 * 1024 * 1024
+
+// emptyRoot is the known root hash of an empty trie.
+var emptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+
+// Account is the consensus representation of an account, in the same encoding
+// as it is stored in the account trie and the snapshot.
+type Account struct {
+	Nonce    uint64
+	Balance  *big.Int
+	Root     common.Hash
+	CodeHash []byte
+}
+
+// Snapshot represents the functionality supported by a snapshot storage layer.
+type Snapshot interface {
+	// Root returns the root hash for which this snapshot was made.
+	Root() common.Hash
+
+	// Account directly retrieves the account associated with a particular hash
+	// in the snapshot. A nil account is returned if it doesn't exist.
+	Account(hash common.Hash) (*Account, error)
+
+	// AccountRLP directly retrieves the account RLP associated with a particular
+	// hash in the snapshot.
+	AccountRLP(hash common.Hash) ([]byte, error)
+
+	// Storage directly retrieves the storage data associated with a particular
+	// hash, within a particular account, as stored in the storage trie.
+	Storage(accountHash, storageHash common.Hash) ([]byte, error)
+}
+
+// snapshot is the internal version of the snapshot data layer that supports some
+// additional methods compared to the public API.
+type snapshot interface {
+	Snapshot
+
+	// Parent returns the subsequent layer of a snapshot, or nil if the base was
+	// reached.
+	Parent() snapshot
+
+	// Update creates a new layer on top of the existing snapshot diff tree with
+	// the specified data items. Accounts and storage slots are in their trie
+	// encoding, an empty storage slot is deleted, whereas the destructed
+	// accounts are wiped before the updates are applied.
+	Update(blockRoot common.Hash, destructs map[common.Hash]struct{}, accounts map[common.Hash][]byte, storage map[common.Hash]map[common.Hash][]byte) *diffLayer
+
+	// Stale returns whether this layer has become stale (was flattened across)
+	// or if it's still live.
+	Stale() bool
+}
+
+// Tree is an Ethereum state snapshot tree. It consists of one persistent base
+// layer backed by a key-value store, on top of which arbitrarily many in-memory
+// diff layers are topped. The memory diffs can form a tree with branching, but
+// the disk layer is singleton and common to all. If a reorg goes deeper than the
+// disk layer, the snapshot has to be rebuilt.
+//
+// The goal of a state snapshot is to allow direct access to account and storage
+// data to avoid expensive multi-level trie lookups.
+type Tree struct {
+	diskdb ethdb.Database           // Persistent database to store the snapshot
+	triedb *trie.Database           // In-memory cache to access the trie through
+	cache  int                      // Megabytes permitted to use for read caches
+	layers map[common.Hash]snapshot // Collection of all known layers
+	lock   sync.RWMutex
+}
+
+// New attempts to load an already existing snapshot from a persistent key-value
+// store along with the journal of its diff layers, ensuring that the snapshot
+// contains the expected head.
+//
+// If the snapshot is missing or inconsistent, the entirety is deleted and will
+// be reconstructed from scratch based on the tries in the key-value store, on a
+// background thread.
+func New(diskdb ethdb.Database, triedb *trie.Database, cache int, root common.Hash) (*Tree, error) {
+	snap := &Tree{
+		diskdb: diskdb,
+		triedb: triedb,
+		cache:  cache,
+	}
+	layers, err := loadSnapshot(diskdb, triedb, cache, root)
+	if err != nil {
+		log.Warn("Failed to load snapshot, regenerating", "err", err)
+		base := generateSnapshot(diskdb, triedb, cache, root)
+		layers = map[common.Hash]snapshot{base.root: base}
+	}
+	snap.layers = layers
+	return snap, nil
+}
+
+// Snapshot retrieves a snapshot belonging to the given block root, or nil if no
+// snapshot is maintained for that block.
+func (t *Tree) Snapshot(blockRoot common.Hash) Snapshot {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if layer, ok := t.layers[blockRoot]; ok {
+		return layer
+	}
+	return nil
+}
+
+// Update adds a new snapshot into the tree, if that can be linked to an existing
+// old parent. It is disallowed to insert a disk layer (the origin of all).
+func (t *Tree) Update(blockRoot common.Hash, parentRoot common.Hash, destructs map[common.Hash]struct{}, accounts map[common.Hash][]byte, storage map[common.Hash]map[common.Hash][]byte) error {
+	// Reject noop updates to avoid self-loops in the snapshot tree. This is a
+	// special case that can only happen for blocks without state changes.
+	if blockRoot == parentRoot {
+		return errSnapshotCycle
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	// The same state may be reached through a different block, it needs no
+	// second layer
+	if _, ok := t.layers[blockRoot]; ok {
+		return nil
+	}
+	parent, ok := t.layers[parentRoot]
+	if !ok {
+		return fmt.Errorf("parent [%#x] snapshot missing", parentRoot)
+	}
+	snap := parent.Update(blockRoot, destructs, accounts, storage)
+	t.layers[snap.root] = snap
+	return nil
+}
+
+// Cap traverses downwards the snapshot tree from a head block hash until the
+// number of allowed layers are crossed. All layers beyond the permitted number
+// are aggregated into the bottom-most diff layer, which is flattened into the
+// disk layer once it outgrows its memory allowance, and the layers which aren't
+// descendants of the new bottom layer are discarded.
+func (t *Tree) Cap(root common.Hash, layers int) error {
+	return t.cap(root, layers, aggregatorMemoryLimit)
+}
+
+// cap aggregates the layers below the permitted number of layers under the
+// given root, flattening the aggregate into the disk layer if it uses more
+// memory than the limit or if the disk layer is still being generated.
+func (t *Tree) cap(root common.Hash, layers int, limit uint64) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	snap, ok := t.layers[root]
+	if !ok {
+		return fmt.Errorf("snapshot [%#x] missing", root)
+	}
+	diff, ok := snap.(*diffLayer)
+	if !ok {
+		return nil // Already the disk layer, nothing to flatten
+	}
+	// Dive until we run out of layers or reach the persistent database
+	var child *diffLayer
+	for i := 0; i < layers; i++ {
+		parent, ok := diff.Parent().(*diffLayer)
+		if !ok {
+			return nil // Fewer layers than permitted
+		}
+		child, diff = diff, parent
+	}
+	// Aggregate the layer and everything below it, oldest first
+	var flattened []*diffLayer
+	for layer := diff; layer != nil; {
+		flattened = append(flattened, layer)
+		layer, _ = layer.Parent().(*diffLayer)
+	}
+	aggregate := flattened[len(flattened)-1]
+	for i := len(flattened) - 2; i >= 0; i-- {
+		delete(t.layers, aggregate.root)
+		aggregate = merge(aggregate, flattened[i])
+	}
+	t.layers[aggregate.root] = aggregate
+
+	// Flatten the aggregate into the disk if it's too large, or if the disk
+	// layer is generating, whose state would be garbage collected otherwise
+	var bottom snapshot = aggregate
+
+	base := aggregate.parent.(*diskLayer)
+	base.lock.RLock()
+	generating := base.genMarker != nil
+	base.lock.RUnlock()
+
+	if aggregate.memory >= limit || generating {
+		delete(t.layers, base.root)
+		delete(t.layers, aggregate.root)
+
+		base = diffToDisk(base, aggregate)
+		t.layers[base.root] = base
+		bottom = base
+	}
+	if child != nil {
+		child.lock.Lock()
+		child.parent = bottom
+		child.lock.Unlock()
+	}
+	// Discard the layers which were forked off below the new bottom layer
+	for root, layer := range t.layers {
+		if diff, ok := layer.(*diffLayer); ok && !reachable(diff, bottom) {
+			diff.markStale()
+			delete(t.layers, root)
+		}
+	}
+	return nil
+}
+
+// reachable reports whether the live bottom layer is an ancestor of a diff
+// layer, or the layer itself, without stale layers in between.
+func reachable(diff *diffLayer, bottom snapshot) bool {
+	for {
+		if diff == bottom {
+			return true
+		}
+		if diff.Stale() {
+			return false
+		}
+		switch parent := diff.Parent().(type) {
+		case *diffLayer:
+			diff = parent
+		case *diskLayer:
+			return parent == bottom
+		default:
+			return false
+		}
+	}
+}
+
+// Persist flattens every layer up to the given root into the disk layer and
+// stops its background generation, saving the progress to resume on the next
+// start.
+func (t *Tree) Persist(root common.Hash) error {
+	if err := t.cap(root, 0, 0); err != nil {
+		return err
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	base, ok := t.layers[root].(*diskLayer)
+	if !ok {
+		return fmt.Errorf("snapshot [%#x] not persisted", root)
+	}
+	base.stopGeneration()
+	return nil
+}
+
+// Rebuild discards all caches and diff layers, reloading the persisted snapshot
+// if its journal contains the given root hash. Otherwise, it wipes all available
+// snapshot data from the persistent database and starts a new snapshot generator
+// with the given root hash.
+func (t *Tree) Rebuild(root common.Hash) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for _, layer := range t.layers {
+		switch layer := layer.(type) {
+		case *diskLayer:
+			layer.stopGeneration()
+			layer.lock.Lock()
+			layer.stale = true
+			layer.lock.Unlock()
+
+		case *diffLayer:
+			layer.markStale()
+		}
+	}
+	layers, err := loadSnapshot(t.diskdb, t.triedb, t.cache, root)
+	if err == nil {
+		log.Info("Reloaded state snapshot", "root", root)
+		t.layers = layers
+		return
+	}
+	log.Info("Rebuilding state snapshot", "root", root, "err", err)
+	base := generateSnapshot(t.diskdb, t.triedb, t.cache, root)
+	t.layers = map[common.Hash]snapshot{root: base}
+}
+
+// diffToDisk merges a bottom-most diff into the persistent disk layer underneath
+// it, returning the new disk layer. The generation of the old layer, if still
+// running, is continued by the new one.
+func diffToDisk(base *diskLayer, bottom *diffLayer) *diskLayer {
+	stats := base.stopGeneration()
+
+	base.lock.Lock()
+	base.stale = true
+	marker := base.genMarker
+	base.lock.Unlock()
+
+	// Items beyond the generation marker are left to the generator
+	covered := func(key []byte) bool {
+		return marker == nil || bytes.Compare(key, marker) <= 0
+	}
+	batch := base.diskdb.NewBatch()
+	flush := func() {
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				log.Crit("Failed to write snapshot", "err", err)
+			}
+			batch.Reset()
+		}
+	}
+	for hash := range bottom.destructSet {
+		if !covered(hash[:]) {
+			continue
+		}
+		rawdb.DeleteAccountSnapshot(batch, hash)
+		base.cache.Remove(string(hash[:]))
+
+		it := base.diskdb.NewIterator(rawdb.StorageSnapshotsKey(hash), nil)
+		for it.Next() {
+			key := it.Key()
+			batch.Delete(key)
+			base.cache.Remove(string(key[len(rawdb.SnapshotStoragePrefix):]))
+			flush()
+		}
+		it.Release()
+	}
+	for hash, data := range bottom.accountData {
+		if !covered(hash[:]) {
+			continue
+		}
+		rawdb.WriteAccountSnapshot(batch, hash, data)
+		base.cache.Add(string(hash[:]), data)
+		flush()
+	}
+	for accountHash, storage := range bottom.storageData {
+		for storageHash, data := range storage {
+			key := append(accountHash[:], storageHash[:]...)
+			if !covered(key) {
+				continue
+			}
+			if len(data) == 0 {
+				rawdb.DeleteStorageSnapshot(batch, accountHash, storageHash)
+				base.cache.Add(string(key), []byte(nil))
+			} else {
+				rawdb.WriteStorageSnapshot(batch, accountHash, storageHash, data)
+				base.cache.Add(string(key), data)
+			}
+			flush()
+		}
+	}
+	rawdb.WriteSnapshotRoot(batch, bottom.root)
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to write snapshot", "err", err)
+	}
+	bottom.markStale()
+
+	res := &diskLayer{
+		diskdb:     base.diskdb,
+		triedb:     base.triedb,
+		cache:      base.cache,
+		root:       bottom.root,
+		genMarker:  marker,
+		genPending: base.genPending,
+	}
+	if marker != nil {
+		if stats == nil {
+			stats = &generatorStats{}
+		}
+		res.genAbort = make(chan chan *generatorStats)
+		go res.generate(stats)
+	}
+	return res
+}
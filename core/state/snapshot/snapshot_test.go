@@ -0,0 +1,393 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// makeState commits a state of the given number of accounts, every other one
+// with a few storage slots, and returns its root.
+func makeState(t *testing.T, triedb *trie.Database, accounts int) common.Hash {
+	accTrie, _ := trie.New(common.Hash{}, triedb)
+	for i := 0; i < accounts; i++ {
+		root := emptyRoot
+		if i%2 == 0 {
+			storeTrie, _ := trie.New(common.Hash{}, triedb)
+			for j := 0; j < 5; j++ {
+				value, _ := rlp.EncodeToBytes([]byte{byte(i), byte(j + 1)})
+				storeTrie.Update(hashOf(j).Bytes(), value)
+			}
+			var err error
+			if root, err = storeTrie.Commit(nil); err != nil {
+				t.Fatal(err)
+			}
+			if err := triedb.Commit(root, false); err != nil {
+				t.Fatal(err)
+			}
+		}
+		blob, _ := rlp.EncodeToBytes(&Account{Nonce: uint64(i), Balance: big.NewInt(int64(i)), Root: root, CodeHash: crypto.Keccak256(nil)})
+		accTrie.Update(hashOf(i).Bytes(), blob)
+	}
+	root, err := accTrie.Commit(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := triedb.Commit(root, false); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+// hashOf derives a test key from a number.
+func hashOf(i int) common.Hash {
+	return crypto.Keccak256Hash([]byte{byte(i >> 8), byte(i)})
+}
+
+// waitGeneration waits for the snapshot generation of the disk layer of a tree.
+func waitGeneration(t *testing.T, snaps *Tree, root common.Hash) *diskLayer {
+	base := snaps.Snapshot(root).(*diskLayer)
+	select {
+	case <-base.genPending:
+	case <-time.After(5 * time.Second):
+		t.Fatal("snapshot generation timed out")
+	}
+	return base
+}
+
+// checkState verifies that a snapshot contains the state created by makeState.
+func checkState(t *testing.T, snap Snapshot, accounts int) {
+	for i := 0; i < accounts; i++ {
+		acc, err := snap.Account(hashOf(i))
+		if err != nil || acc == nil || acc.Nonce != uint64(i) {
+			t.Fatalf("account %d mismatch: have %v (%v)", i, acc, err)
+		}
+		for j := 0; j < 5; j++ {
+			data, err := snap.Storage(hashOf(i), hashOf(j))
+			if err != nil {
+				t.Fatalf("account %d slot %d: %v", i, j, err)
+			}
+			var want []byte
+			if i%2 == 0 {
+				want, _ = rlp.EncodeToBytes([]byte{byte(i), byte(j + 1)})
+			}
+			if !bytes.Equal(data, want) {
+				t.Fatalf("account %d slot %d mismatch: have %x, want %x", i, j, data, want)
+			}
+		}
+	}
+}
+
+// Tests that a snapshot is generated from the state trie, replacing the stale
+// entries of a previous snapshot, and that a generated snapshot is reused.
+func TestGeneration(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	triedb := trie.NewDatabase(db)
+	root := makeState(t, triedb, 100)
+
+	// Leave a stale account behind, which is wiped by the generation
+	rawdb.WriteAccountSnapshot(db, common.Hash{0x01}, []byte{0x01})
+	rawdb.WriteStorageSnapshot(db, common.Hash{0x01}, common.Hash{0x02}, []byte{0x02})
+
+	snaps, err := New(db, triedb, 1, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := waitGeneration(t, snaps, root)
+	checkState(t, base, 100)
+
+	if acc, err := base.Account(common.Hash{0x01}); err != nil || acc != nil {
+		t.Errorf("stale account retained: %v (%v)", acc, err)
+	}
+	if data := rawdb.ReadStorageSnapshot(db, common.Hash{0x01}, common.Hash{0x02}); data != nil {
+		t.Errorf("stale storage retained: %x", data)
+	}
+	// Reopening the snapshot of the same root doesn't regenerate it
+	if err := snaps.Persist(root); err != nil {
+		t.Fatal(err)
+	}
+	rawdb.WriteAccountSnapshot(db, common.Hash{0x01}, []byte{0x01})
+	if snaps, err = New(db, triedb, 1, root); err != nil {
+		t.Fatal(err)
+	}
+	if base = waitGeneration(t, snaps, root); base.genMarker != nil {
+		t.Errorf("reloaded snapshot regenerating")
+	}
+	if data := rawdb.ReadAccountSnapshot(db, common.Hash{0x01}); data == nil {
+		t.Errorf("reloaded snapshot wiped")
+	}
+	// Data beyond the generation marker is reported missing
+	base.genMarker = []byte{0x80}
+	if _, err := base.AccountRLP(common.Hash{0xff}); err != ErrNotCoveredYet {
+		t.Errorf("uncovered account error mismatch: have %v, want %v", err, ErrNotCoveredYet)
+	}
+}
+
+// Tests that diff layers serve their changes on top of their parents, and that
+// the layers beyond the cap are flattened into the disk layer.
+func TestDiffLayers(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	triedb := trie.NewDatabase(db)
+	root := makeState(t, triedb, 10)
+
+	snaps, err := New(db, triedb, 1, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitGeneration(t, snaps, root)
+
+	// Layer 1 modifies an account and a slot, layer 2 destructs the account
+	// and creates it anew with a single slot
+	acc1, _ := rlp.EncodeToBytes(&Account{Nonce: 100, Balance: big.NewInt(0), Root: emptyRoot, CodeHash: crypto.Keccak256(nil)})
+	acc2, _ := rlp.EncodeToBytes(&Account{Nonce: 200, Balance: big.NewInt(0), Root: emptyRoot, CodeHash: crypto.Keccak256(nil)})
+
+	root1, root2, fork := common.Hash{0x01}, common.Hash{0x02}, common.Hash{0x03}
+	if err := snaps.Update(root1, root, nil, map[common.Hash][]byte{hashOf(0): acc1}, map[common.Hash]map[common.Hash][]byte{
+		hashOf(0): {hashOf(1): {0x01}, hashOf(2): nil},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := snaps.Update(root2, root1, map[common.Hash]struct{}{hashOf(0): {}, hashOf(1): {}}, map[common.Hash][]byte{hashOf(0): acc2}, map[common.Hash]map[common.Hash][]byte{
+		hashOf(0): {hashOf(3): {0x03}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := snaps.Update(fork, root, nil, map[common.Hash][]byte{hashOf(5): acc1}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := snaps.Update(root1, root1, nil, nil, nil); err != errSnapshotCycle {
+		t.Errorf("cycle error mismatch: have %v, want %v", err, errSnapshotCycle)
+	}
+	check := func(snap Snapshot, nonce uint64, slots map[common.Hash][]byte) {
+		t.Helper()
+		if acc, err := snap.Account(hashOf(0)); err != nil || acc == nil || acc.Nonce != nonce {
+			t.Errorf("account mismatch: have %v (%v), want nonce %d", acc, err, nonce)
+		}
+		for hash, want := range slots {
+			if data, err := snap.Storage(hashOf(0), hash); err != nil || !bytes.Equal(data, want) {
+				t.Errorf("slot %x mismatch: have %x (%v), want %x", hash, data, err, want)
+			}
+		}
+	}
+	slot, _ := rlp.EncodeToBytes([]byte{0, 4})
+	check(snaps.Snapshot(root1), 100, map[common.Hash][]byte{hashOf(1): {0x01}, hashOf(2): nil, hashOf(3): slot})
+	check(snaps.Snapshot(root2), 200, map[common.Hash][]byte{hashOf(1): nil, hashOf(2): nil, hashOf(3): {0x03}})
+	if acc, err := snaps.Snapshot(root2).Account(hashOf(1)); err != nil || acc != nil {
+		t.Errorf("destructed account served: %v (%v)", acc, err)
+	}
+	// Cap the second layer, which is small enough to be kept in memory
+	if err := snaps.Cap(root2, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := snaps.Snapshot(root1).(*diffLayer); !ok {
+		t.Fatalf("layer within the memory allowance flattened into the disk")
+	}
+	// Aggregate both layers into one, discarding the fork off the disk layer
+	old := snaps.Snapshot(root1)
+	if err := snaps.Cap(root2, 0); err != nil {
+		t.Fatal(err)
+	}
+	aggregate, ok := snaps.Snapshot(root2).(*diffLayer)
+	if !ok || aggregate.Parent() != snaps.Snapshot(root) {
+		t.Fatalf("layers not aggregated on top of the disk layer")
+	}
+	if snaps.Snapshot(fork) != nil || snaps.Snapshot(root1) != nil {
+		t.Errorf("stale layers retained")
+	}
+	if _, err := old.Account(hashOf(0)); err != ErrSnapshotStale {
+		t.Errorf("aggregated layer error mismatch: have %v, want %v", err, ErrSnapshotStale)
+	}
+	check(snaps.Snapshot(root2), 200, map[common.Hash][]byte{hashOf(1): nil, hashOf(2): nil, hashOf(3): {0x03}})
+	if acc, err := snaps.Snapshot(root2).Account(hashOf(1)); err != nil || acc != nil {
+		t.Errorf("destructed account served after aggregation: %v (%v)", acc, err)
+	}
+	// Flatten the aggregate once it outgrows its allowance
+	if err := snaps.cap(root2, 0, aggregate.memory); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := snaps.Snapshot(root2).(*diskLayer); !ok {
+		t.Fatalf("layer beyond the memory allowance not flattened into the disk")
+	}
+	if snaps.Snapshot(root) != nil {
+		t.Errorf("stale disk layer retained")
+	}
+	check(snaps.Snapshot(root2), 200, map[common.Hash][]byte{hashOf(1): nil, hashOf(3): {0x03}})
+
+	// Flatten everything, the destructed storage is wiped from the disk
+	if err := snaps.Persist(root2); err != nil {
+		t.Fatal(err)
+	}
+	check(snaps.Snapshot(root2), 200, map[common.Hash][]byte{hashOf(1): nil, hashOf(3): {0x03}})
+	if data := rawdb.ReadStorageSnapshot(db, hashOf(0), hashOf(4)); data != nil {
+		t.Errorf("destructed storage retained: %x", data)
+	}
+	if data := rawdb.ReadAccountSnapshot(db, hashOf(1)); data != nil {
+		t.Errorf("destructed account retained: %x", data)
+	}
+	if have := rawdb.ReadSnapshotRoot(db); have != root2 {
+		t.Errorf("persisted root mismatch: have %x, want %x", have, root2)
+	}
+}
+
+// Tests that the diff layers are journalled on shutdown and restored on startup,
+// and that a rebuild reloads them from the journal.
+func TestJournal(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	triedb := trie.NewDatabase(db)
+	root := makeState(t, triedb, 10)
+
+	snaps, err := New(db, triedb, 1, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitGeneration(t, snaps, root)
+
+	acc, _ := rlp.EncodeToBytes(&Account{Nonce: 100, Balance: big.NewInt(0), Root: emptyRoot, CodeHash: crypto.Keccak256(nil)})
+	root1, root2 := common.Hash{0x01}, common.Hash{0x02}
+	if err := snaps.Update(root1, root, map[common.Hash]struct{}{hashOf(1): {}}, map[common.Hash][]byte{hashOf(0): acc}, map[common.Hash]map[common.Hash][]byte{
+		hashOf(0): {hashOf(1): {0x01}, hashOf(2): nil},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := snaps.Update(root2, root1, nil, nil, map[common.Hash]map[common.Hash][]byte{
+		hashOf(0): {hashOf(3): {0x03}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := snaps.Journal(root2); err != nil {
+		t.Fatal(err)
+	}
+	check := func(snaps *Tree) {
+		t.Helper()
+		snap := snaps.Snapshot(root2)
+		if _, ok := snap.(*diffLayer); !ok {
+			t.Fatalf("diff layer not restored: %T", snap)
+		}
+		if acc, err := snap.Account(hashOf(0)); err != nil || acc == nil || acc.Nonce != 100 {
+			t.Errorf("account mismatch: have %v (%v)", acc, err)
+		}
+		if acc, err := snap.Account(hashOf(1)); err != nil || acc != nil {
+			t.Errorf("destructed account served: %v (%v)", acc, err)
+		}
+		for hash, want := range map[common.Hash][]byte{hashOf(1): {0x01}, hashOf(2): nil, hashOf(3): {0x03}} {
+			if data, err := snap.Storage(hashOf(0), hash); err != nil || !bytes.Equal(data, want) {
+				t.Errorf("slot %x mismatch: have %x (%v), want %x", hash, data, err, want)
+			}
+		}
+	}
+	if snaps, err = New(db, triedb, 1, root2); err != nil {
+		t.Fatal(err)
+	}
+	if base := waitGeneration(t, snaps, root); base.genMarker != nil {
+		t.Errorf("journalled snapshot regenerating")
+	}
+	check(snaps)
+
+	// A rebuild of a journalled root restores the layers instead of regenerating
+	snaps.Rebuild(root2)
+	check(snaps)
+}
+
+// Tests that the iterators merge the changes of the diff layers into the disk
+// layer entries, in hash order from the seek position.
+func TestIterators(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	triedb := trie.NewDatabase(db)
+	root := makeState(t, triedb, 10)
+
+	snaps, err := New(db, triedb, 1, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := snaps.AccountIterator(root, common.Hash{}); err != ErrNotCoveredYet {
+		t.Errorf("iterator error mismatch during generation: have %v, want %v", err, ErrNotCoveredYet)
+	}
+	waitGeneration(t, snaps, root)
+
+	acc, _ := rlp.EncodeToBytes(&Account{Nonce: 100, Balance: big.NewInt(0), Root: emptyRoot, CodeHash: crypto.Keccak256(nil)})
+	root1, root2 := common.Hash{0x01}, common.Hash{0x02}
+	if err := snaps.Update(root1, root, nil, map[common.Hash][]byte{hashOf(0): acc, hashOf(10): acc}, map[common.Hash]map[common.Hash][]byte{
+		hashOf(0): {hashOf(1): {0x01}, hashOf(2): nil},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := snaps.Update(root2, root1, map[common.Hash]struct{}{hashOf(0): {}, hashOf(1): {}}, map[common.Hash][]byte{hashOf(0): acc}, map[common.Hash]map[common.Hash][]byte{
+		hashOf(0): {hashOf(3): {0x03}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// collect gathers the entries of an iterator, ensuring they're ordered
+	collect := func(it Iterator, err error) map[common.Hash][]byte {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer it.Release()
+
+		entries := make(map[common.Hash][]byte)
+		var last common.Hash
+		for it.Next() {
+			if len(entries) > 0 && bytes.Compare(it.Hash().Bytes(), last.Bytes()) <= 0 {
+				t.Fatalf("iterator out of order: %x after %x", it.Hash(), last)
+			}
+			entries[it.Hash()], last = it.Value(), it.Hash()
+		}
+		if err := it.Error(); err != nil {
+			t.Fatal(err)
+		}
+		return entries
+	}
+	// Iterate over the accounts, of which the second one was destructed
+	accounts := collect(snaps.AccountIterator(root2, common.Hash{}))
+	if len(accounts) != 10 {
+		t.Errorf("account count mismatch: have %d, want %d", len(accounts), 10)
+	}
+	if _, ok := accounts[hashOf(1)]; ok {
+		t.Errorf("destructed account iterated")
+	}
+	if !bytes.Equal(accounts[hashOf(0)], acc) || !bytes.Equal(accounts[hashOf(10)], acc) {
+		t.Errorf("changed accounts mismatch")
+	}
+	for hash := range collect(snaps.AccountIterator(root2, hashOf(5))) {
+		if bytes.Compare(hash[:], hashOf(5).Bytes()) < 0 {
+			t.Errorf("account %x before the seek position iterated", hash)
+		}
+	}
+	// Iterate over the storage, modified in the first layer and wiped in the second
+	slot, _ := rlp.EncodeToBytes([]byte{0, 4})
+	storage := collect(snaps.StorageIterator(root1, hashOf(0), common.Hash{}))
+	if len(storage) != 4 || !bytes.Equal(storage[hashOf(1)], []byte{0x01}) || !bytes.Equal(storage[hashOf(3)], slot) {
+		t.Errorf("modified storage mismatch: %x", storage)
+	}
+	if _, ok := storage[hashOf(2)]; ok {
+		t.Errorf("deleted slot iterated")
+	}
+	storage = collect(snaps.StorageIterator(root2, hashOf(0), common.Hash{}))
+	if len(storage) != 1 || !bytes.Equal(storage[hashOf(3)], []byte{0x03}) {
+		t.Errorf("recreated storage mismatch: %x", storage)
+	}
+}
@@ -24,7 +24,9 @@ import (
 	"sync"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm/umbrella"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/rlp"
@@ -53,6 +55,14 @@ type StateDB struct {
 	db   Database
 	trie Trie
 
+	// The snapshot of the state root to read through, if available, and the
+	// changes to extend the snapshot tree with on commit.
+	snaps         *snapshot.Tree
+	snap          snapshot.Snapshot
+	snapDestructs map[common.Hash]struct{}
+	snapAccounts  map[common.Hash][]byte
+	snapStorage   map[common.Hash]map[common.Hash][]byte
+
 	// This map holds 'live' objects, which will get modified while processing a state transition.
 	stateObjects      map[common.Address]*stateObject
 	stateObjectsDirty map[common.Address]struct{}
@@ -67,11 +77,18 @@ type StateDB struct {
 	// The refund counter, also used by state transitioning.
 	refund uint64
 
+	// The contracts which opted in to pay for the current transaction through
+	// FREEGAS, also used by state transitioning.
+	freegas map[common.Address]struct{}
+
 	thash, bhash common.Hash
 	txIndex      int
 	logs         map[common.Hash][]*types.Log
 	logSize      uint
 
+	// Transactions emitted by SCHEDULE, pending their handover to the umbrella.
+	schedules map[common.Hash][]umbrella.ScheduleTx
+
 	preimages map[common.Hash][]byte
 
 	// Journal of state modifications. This is the backbone of
@@ -95,11 +112,37 @@ func New(root common.Hash, db Database) (*StateDB, error) {
 		stateObjects:      make(map[common.Address]*stateObject),
 		stateObjectsDirty: make(map[common.Address]struct{}),
 		logs:              make(map[common.Hash][]*types.Log),
+		schedules:         make(map[common.Hash][]umbrella.ScheduleTx),
 		preimages:         make(map[common.Hash][]byte),
 		journal:           newJournal(),
 	}, nil
 }
 
+// NewWithSnapshot creates a new state from a given trie, reading through the
+// snapshot of the root if the snapshot tree maintains one.
+func NewWithSnapshot(root common.Hash, db Database, snaps *snapshot.Tree) (*StateDB, error) {
+	sdb, err := New(root, db)
+	if err != nil {
+		return nil, err
+	}
+	sdb.snaps = snaps
+	sdb.resetSnapshot(root)
+	return sdb, nil
+}
+
+// resetSnapshot attaches the snapshot of the given root, if available.
+func (self *StateDB) resetSnapshot(root common.Hash) {
+	self.snap, self.snapDestructs, self.snapAccounts, self.snapStorage = nil, nil, nil, nil
+	if self.snaps == nil {
+		return
+	}
+	if self.snap = self.snaps.Snapshot(root); self.snap != nil {
+		self.snapDestructs = make(map[common.Hash]struct{})
+		self.snapAccounts = make(map[common.Hash][]byte)
+		self.snapStorage = make(map[common.Hash]map[common.Hash][]byte)
+	}
+}
+
 // setError remembers the first non-nil error it is called with.
 func (self *StateDB) setError(err error) {
 	if self.dbErr == nil {
@@ -126,7 +169,9 @@ func (self *StateDB) Reset(root common.Hash) error {
 	self.txIndex = 0
 	self.logs = make(map[common.Hash][]*types.Log)
 	self.logSize = 0
+	self.schedules = make(map[common.Hash][]umbrella.ScheduleTx)
 	self.preimages = make(map[common.Hash][]byte)
+	self.resetSnapshot(root)
 	self.clearJournalAndRefund()
 	return nil
 }
@@ -154,6 +199,38 @@ func (self *StateDB) Logs() []*types.Log {
 	return logs
 }
 
+// AddScheduleTx records a transaction emitted by SCHEDULE, to be handed over to
+// the umbrella once the current transaction succeeds.
+func (self *StateDB) AddScheduleTx(tx umbrella.ScheduleTx) {
+	self.journal.append(addScheduleChange{txhash: self.thash})
+	self.schedules[self.thash] = append(self.schedules[self.thash], tx)
+}
+
+// GetScheduleTxs retrieves the transactions scheduled by the given transaction.
+func (self *StateDB) GetScheduleTxs(hash common.Hash) []umbrella.ScheduleTx {
+	return self.schedules[hash]
+}
+
+// SetFreeGas records that the given contract opted in to pay for the current
+// transaction.
+func (self *StateDB) SetFreeGas(addr common.Address) {
+	if _, ok := self.freegas[addr]; ok {
+		return
+	}
+	self.journal.append(freeGasChange{account: &addr})
+	if self.freegas == nil {
+		self.freegas = make(map[common.Address]struct{})
+	}
+	self.freegas[addr] = struct{}{}
+}
+
+// IsFreeGas reports whether the given contract opted in to pay for the current
+// transaction.
+func (self *StateDB) IsFreeGas(addr common.Address) bool {
+	_, ok := self.freegas[addr]
+	return ok
+}
+
 // AddPreimage records a SHA3 preimage seen by the VM.
 func (self *StateDB) AddPreimage(hash common.Hash, preimage []byte) {
 	if _, ok := self.preimages[hash]; !ok {
@@ -349,6 +426,10 @@ func (self *StateDB) updateStateObject(stateObject *stateObject) {
 		panic(fmt.Errorf("can't encode object at %x: %v", addr[:], err))
 	}
 	self.setError(self.trie.TryUpdate(addr[:], data))
+
+	if self.snap != nil {
+		self.snapAccounts[stateObject.addrHash] = data
+	}
 }
 
 // deleteStateObject removes the given object from the state trie.
@@ -356,6 +437,12 @@ func (self *StateDB) deleteStateObject(stateObject *stateObject) {
 	stateObject.deleted = true
 	addr := stateObject.Address()
 	self.setError(self.trie.TryDelete(addr[:]))
+
+	if self.snap != nil {
+		self.snapDestructs[stateObject.addrHash] = struct{}{}
+		delete(self.snapAccounts, stateObject.addrHash)
+		delete(self.snapStorage, stateObject.addrHash)
+	}
 }
 
 // Retrieve a state object given by the address. Returns nil if not found.
@@ -368,8 +455,17 @@ func (self *StateDB) getStateObject(addr common.Address) (stateObject *stateObje
 		return obj
 	}
 
-	// Load the object from the database.
-	enc, err := self.trie.TryGet(addr[:])
+	// Load the object from the snapshot if available, or from the database.
+	var (
+		enc []byte
+		err error
+	)
+	if self.snap != nil {
+		enc, err = self.snap.AccountRLP(crypto.Keccak256Hash(addr[:]))
+	}
+	if self.snap == nil || err != nil {
+		enc, err = self.trie.TryGet(addr[:])
+	}
 	if len(enc) == 0 {
 		self.setError(err)
 		return nil
@@ -402,12 +498,28 @@ func (self *StateDB) GetOrNewStateObject(addr common.Address) *stateObject {
 // the given address, it is overwritten and returned as the second return value.
 func (self *StateDB) createObject(addr common.Address) (newobj, prev *stateObject) {
 	prev = self.getStateObject(addr)
+
+	// The storage of an overwritten account is cleared, which the snapshot
+	// applies by destructing the account first and dropping the slots already
+	// written to it
+	var (
+		prevdestruct bool
+		prevstorage  map[common.Hash][]byte
+	)
+	if self.snap != nil && prev != nil {
+		_, prevdestruct = self.snapDestructs[prev.addrHash]
+		if !prevdestruct {
+			self.snapDestructs[prev.addrHash] = struct{}{}
+		}
+		prevstorage = self.snapStorage[prev.addrHash]
+		delete(self.snapStorage, prev.addrHash)
+	}
 	newobj = newObject(self, addr, Account{})
 	newobj.setNonce(0) // sets the object to dirty
 	if prev == nil {
 		self.journal.append(createObjectChange{account: &addr})
 	} else {
-		self.journal.append(resetObjectChange{prev: prev})
+		self.journal.append(resetObjectChange{prev: prev, prevdestruct: prevdestruct, prevstorage: prevstorage})
 	}
 	self.setStateObject(newobj)
 	return newobj, prev
@@ -464,10 +576,14 @@ func (self *StateDB) Copy() *StateDB {
 		stateObjects:      make(map[common.Address]*stateObject, len(self.journal.dirties)),
 		stateObjectsDirty: make(map[common.Address]struct{}, len(self.journal.dirties)),
 		refund:            self.refund,
+		freegas:           make(map[common.Address]struct{}, len(self.freegas)),
 		logs:              make(map[common.Hash][]*types.Log, len(self.logs)),
 		logSize:           self.logSize,
+		schedules:         make(map[common.Hash][]umbrella.ScheduleTx, len(self.schedules)),
 		preimages:         make(map[common.Hash][]byte),
 		journal:           newJournal(),
+		snaps:             self.snaps,
+		snap:              self.snap,
 	}
 	// Copy the dirty states, logs, and preimages
 	for addr := range self.journal.dirties {
@@ -494,9 +610,34 @@ func (self *StateDB) Copy() *StateDB {
 		state.logs[hash] = make([]*types.Log, len(logs))
 		copy(state.logs[hash], logs)
 	}
+	for addr := range self.freegas {
+		state.freegas[addr] = struct{}{}
+	}
+	for hash, schedules := range self.schedules {
+		state.schedules[hash] = make([]umbrella.ScheduleTx, len(schedules))
+		copy(state.schedules[hash], schedules)
+	}
 	for hash, preimage := range self.preimages {
 		state.preimages[hash] = preimage
 	}
+	// Copy the pending snapshot changes, both states are committed separately
+	if self.snap != nil {
+		state.snapDestructs = make(map[common.Hash]struct{}, len(self.snapDestructs))
+		for hash := range self.snapDestructs {
+			state.snapDestructs[hash] = struct{}{}
+		}
+		state.snapAccounts = make(map[common.Hash][]byte, len(self.snapAccounts))
+		for hash, data := range self.snapAccounts {
+			state.snapAccounts[hash] = data
+		}
+		state.snapStorage = make(map[common.Hash]map[common.Hash][]byte, len(self.snapStorage))
+		for hash, storage := range self.snapStorage {
+			state.snapStorage[hash] = make(map[common.Hash][]byte, len(storage))
+			for key, data := range storage {
+				state.snapStorage[hash][key] = data
+			}
+		}
+	}
 	return state
 }
 
@@ -576,6 +717,7 @@ func (s *StateDB) clearJournalAndRefund() {
 	s.journal = newJournal()
 	s.validRevisions = s.validRevisions[:0]
 	s.refund = 0
+	s.freegas = nil
 }
 
 // Commit writes the state to the underlying in-memory trie database.
@@ -624,5 +766,15 @@ func (s *StateDB) Commit(deleteEmptyObjects bool) (root common.Hash, err error)
 		return nil
 	})
 	log.Debug("Trie cache stats after commit", "misses", trie.CacheMisses(), "unloads", trie.CacheUnloads())
+
+	// Extend the snapshot tree with the changes, unless the state didn't change
+	if err == nil && s.snap != nil {
+		if parent := s.snap.Root(); parent != root {
+			if err := s.snaps.Update(root, parent, s.snapDestructs, s.snapAccounts, s.snapStorage); err != nil {
+				log.Warn("Failed to update snapshot tree", "from", parent, "to", root, "err", err)
+			}
+		}
+		s.snap, s.snapDestructs, s.snapAccounts, s.snapStorage = nil, nil, nil, nil
+	}
 	return root, err
 }
@@ -31,7 +31,9 @@ import (
 	check "gopkg.in/check.v1"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
 )
 
@@ -435,3 +437,40 @@ func TestCopyOfCopy(t *testing.T) {
 		t.Fatalf("2nd copy fail, expected 42, got %v", got)
 	}
 }
+
+// Tests that recreating an account drops the storage changes of the snapshot
+// made to it before, and that reverting the recreation restores them.
+func TestSnapshotRecreateStorage(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	sdb := NewDatabase(db)
+	state, _ := New(common.Hash{}, sdb)
+	root, _ := state.Commit(false)
+	sdb.TrieDB().Commit(root, false)
+
+	snaps, _ := snapshot.New(db, sdb.TrieDB(), 1, root)
+	state, _ = NewWithSnapshot(root, sdb, snaps)
+
+	addr := common.BytesToAddress([]byte{0x01})
+	addrHash := crypto.Keccak256Hash(addr[:])
+
+	state.SetState(addr, common.Hash{0x01}, common.Hash{0x01})
+	state.Finalise(false)
+	if len(state.snapStorage[addrHash]) != 1 {
+		t.Fatalf("storage change not tracked: %v", state.snapStorage[addrHash])
+	}
+	id := state.Snapshot()
+	state.CreateAccount(addr)
+	if _, ok := state.snapStorage[addrHash]; ok {
+		t.Errorf("storage change retained after recreation")
+	}
+	if _, ok := state.snapDestructs[addrHash]; !ok {
+		t.Errorf("recreated account not destructed")
+	}
+	state.RevertToSnapshot(id)
+	if len(state.snapStorage[addrHash]) != 1 {
+		t.Errorf("storage change not restored by revert: %v", state.snapStorage[addrHash])
+	}
+	if _, ok := state.snapDestructs[addrHash]; ok {
+		t.Errorf("destruct retained after revert")
+	}
+}
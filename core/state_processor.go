@@ -17,14 +17,18 @@
 package core
 
 import (
+	"math/big"
+
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/consensus/misc"
+	"github.com/ethereum/go-ethereum/core/schedule"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/core/vm/umbrella"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/params"
 )
 
@@ -66,6 +70,9 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 	if p.config.DAOForkSupport && p.config.DAOForkBlock != nil && p.config.DAOForkBlock.Cmp(block.Number()) == 0 {
 		misc.ApplyDAOHardFork(statedb)
 	}
+	// Execute the scheduled transactions fallen due ahead of the block's own
+	receipts = ApplyDueSchedules(p.config, p.bc, nil, gp, statedb, header, usedGas, cfg)
+
 	// Iterate over and process the individual transactions
 	for i, tx := range block.Transactions() {
 		statedb.Prepare(tx.Hash(), block.Hash(), i)
@@ -74,6 +81,8 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 			return nil, nil, 0, err
 		}
 		receipts = append(receipts, receipt)
+	}
+	for _, receipt := range receipts {
 		allLogs = append(allLogs, receipt.Logs...)
 	}
 	// Finalize the block, applying any consensus engine specific extras (e.g. block rewards)
@@ -97,7 +106,8 @@ func ApplyTransaction(config *params.ChainConfig, bc ChainContext, author *commo
 	// about the transaction and calling mechanisms.
 	vmenv := vm.NewEVM(context, statedb, config, cfg)
 	// Apply the transaction to the current state (included in the env)
-	_, gas, failed, err := ApplyMessage(vmenv, msg, gp)
+	st := NewStateTransition(vmenv, msg, gp)
+	ret, gas, failed, err := st.TransitionDb()
 	if err != nil {
 		return nil, 0, err
 	}
@@ -115,6 +125,12 @@ func ApplyTransaction(config *params.ChainConfig, bc ChainContext, author *commo
 	receipt := types.NewReceipt(root, failed, *usedGas)
 	receipt.TxHash = tx.Hash()
 	receipt.GasUsed = gas
+	// Failed transactions only return data when reverting, or when halted by
+	// a Lity checked arithmetic overflow (see StateTransition.TransitionDb).
+	if failed {
+		receipt.RevertReason = ret
+	}
+	receipt.FreeGas = st.FreeGas()
 	// if the transaction created a contract, store the creation address in the receipt.
 	if msg.To() == nil {
 		receipt.ContractAddress = crypto.CreateAddress(vmenv.Context.Origin, tx.Nonce())
@@ -123,6 +139,12 @@ func ApplyTransaction(config *params.ChainConfig, bc ChainContext, author *commo
 	receipt.Logs = statedb.GetLogs(tx.Hash())
 	receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
 
+	// Hand the transactions scheduled by a successful transaction over to the umbrella
+	if !failed {
+		for _, stx := range statedb.GetScheduleTxs(tx.Hash()) {
+			vmenv.Umbrella.EmitScheduleTx(stx)
+		}
+	}
 	return receipt, gas, err
 }
 
@@ -146,7 +168,8 @@ func ApplyScheduleTransaction(config *params.ChainConfig, bc ChainContext, autho
 	// about the transaction and calling mechanisms.
 	vmenv := vm.NewEVM(context, statedb, config, cfg)
 	// Apply the transaction to the current state (included in the env)
-	_, gas, failed, err := ApplyMessage(vmenv, msg, gp)
+	st := NewStateTransition(vmenv, msg, gp)
+	ret, gas, failed, err := st.TransitionDb()
 	if err != nil {
 		return nil, 0, err
 	}
@@ -164,6 +187,12 @@ func ApplyScheduleTransaction(config *params.ChainConfig, bc ChainContext, autho
 	receipt := types.NewReceipt(root, failed, *usedGas)
 	receipt.TxHash = tx.Hash()
 	receipt.GasUsed = gas
+	// Failed transactions only return data when reverting, or when halted by
+	// a Lity checked arithmetic overflow (see StateTransition.TransitionDb).
+	if failed {
+		receipt.RevertReason = ret
+	}
+	receipt.FreeGas = st.FreeGas()
 	// if the transaction created a contract, store the creation address in the receipt.
 	if msg.To() == nil {
 		receipt.ContractAddress = crypto.CreateAddress(vmenv.Context.Origin, tx.Nonce())
@@ -172,5 +201,82 @@ func ApplyScheduleTransaction(config *params.ChainConfig, bc ChainContext, autho
 	receipt.Logs = statedb.GetLogs(tx.Hash())
 	receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
 
+	// Hand the transactions scheduled by a successful transaction over to the umbrella
+	if !failed {
+		for _, stx := range statedb.GetScheduleTxs(tx.Hash()) {
+			vmenv.Umbrella.EmitScheduleTx(stx)
+		}
+	}
 	return receipt, gas, err
 }
+
+// ApplyDueSchedules executes, in queue order, the scheduled transactions which
+// have fallen due by the time of the given header, for as long as the block has
+// gas left to run them. Each one is sent by its scheduler with a zero gas price
+// and the free gas limit of the umbrella, capped by the block gas limit lest an
+// oversized free gas limit stalls the queue for good.
+//
+// The scheduled transactions run ahead of the block's own, so their receipts,
+// which are returned, precede those of the latter. Their gas is added to
+// usedGas, which the cumulative gas used of the receipts accounts for.
+func ApplyDueSchedules(config *params.ChainConfig, bc ChainContext, author *common.Address, gp *GasPool, statedb *state.StateDB, header *types.Header, usedGas *uint64, cfg vm.Config) types.Receipts {
+	if !config.IsSchedule(header.Number) {
+		return nil
+	}
+	var (
+		receipts  types.Receipts
+		blockHash common.Hash
+	)
+	for {
+		entry := schedule.Head(statedb)
+		if entry == nil || entry.Unixtime > header.Time.Uint64() {
+			return receipts
+		}
+		msg := types.NewMessage(entry.Sender, &entry.Receiver, 0, new(big.Int), 0, new(big.Int), entry.TxData, false)
+		vmenv := vm.NewEVM(NewEVMContext(msg, header, bc, author), statedb, config, cfg)
+
+		// Leave the rest of the queue to the following blocks if out of gas.
+		gas := vmenv.Umbrella.FreeGasLimit().Uint64()
+		if gas > header.GasLimit {
+			gas = header.GasLimit
+		}
+		if gp.Gas() < gas {
+			return receipts
+		}
+		schedule.Remove(statedb, entry.ID)
+
+		if blockHash == (common.Hash{}) {
+			blockHash = header.Hash()
+		}
+		var (
+			snapshot = statedb.Snapshot()
+			gasLeft  = gp.Gas()
+			txHash   = schedule.TxHash(header.Number.Uint64(), len(receipts))
+		)
+		statedb.Prepare(txHash, blockHash, len(receipts))
+
+		msg = types.NewMessage(entry.Sender, &entry.Receiver, 0, new(big.Int), gas, new(big.Int), entry.TxData, false)
+		_, used, failed, err := ApplyMessage(vmenv, msg, gp)
+		if err != nil {
+			// The entry could never be executed, drop it without any effects.
+			log.Debug("Dropped unexecutable scheduled transaction", "id", entry.ID, "err", err)
+			statedb.RevertToSnapshot(snapshot)
+			*gp = GasPool(gasLeft)
+			continue
+		}
+		var root []byte
+		if config.IsByzantium(header.Number) {
+			statedb.Finalise(true)
+		} else {
+			root = statedb.IntermediateRoot(config.IsEIP158(header.Number)).Bytes()
+		}
+		*usedGas += used
+
+		receipt := types.NewReceipt(root, failed, *usedGas)
+		receipt.TxHash = txHash
+		receipt.GasUsed = used
+		receipt.Logs = statedb.GetLogs(txHash)
+		receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+		receipts = append(receipts, receipt)
+	}
+}
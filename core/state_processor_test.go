@@ -0,0 +1,124 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/schedule"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/core/vm/umbrella"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that transactions emitted by SCHEDULE are queued in the state and run
+// at the start of the first block past their due time, identically when mined
+// and when imported, yielding receipts after those of the block's own. The
+// block gas limit is below the free gas limit, which must not stall the queue.
+func TestScheduledTransactions(t *testing.T) {
+	var (
+		key, _    = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr      = crypto.PubkeyToAddress(key.PublicKey)
+		scheduler = common.HexToAddress("0x1000000000000000000000000000000000000001")
+		receiver  = common.HexToAddress("0x1000000000000000000000000000000000000002")
+		db        = ethdb.NewMemDatabase()
+	)
+	// The scheduler queues a call to the receiver due 15 seconds later, which
+	// stores one at the receiver's first slot and logs.
+	schedulerCode := append([]byte{byte(vm.PUSH20)}, receiver.Bytes()...)
+	schedulerCode = append(schedulerCode,
+		byte(vm.PUSH1), 15,
+		byte(vm.TIMESTAMP),
+		byte(vm.ADD),
+		byte(vm.PUSH1), 0,
+		byte(vm.SCHEDULE),
+		byte(vm.STOP),
+	)
+	receiverCode := []byte{byte(vm.PUSH1), 1, byte(vm.PUSH1), 0, byte(vm.SSTORE), byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.LOG0), byte(vm.STOP)}
+
+	gspec := &Genesis{
+		Config:   params.TestChainConfig,
+		GasLimit: umbrella.DefaultFreeGasLimit / 2,
+		Alloc: GenesisAlloc{
+			addr:      {Balance: big.NewInt(1000000)},
+			scheduler: {Code: schedulerCode, Balance: new(big.Int)},
+			receiver:  {Code: receiverCode, Balance: new(big.Int)},
+		},
+	}
+	genesis := gspec.MustCommit(db)
+
+	signer := types.NewEIP155Signer(gspec.Config.ChainID)
+	blocks, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 3, func(i int, gen *BlockGen) {
+		if i == 0 || i == 2 {
+			tx, _ := types.SignTx(types.NewTransaction(gen.TxNonce(addr), scheduler, new(big.Int), 100000, nil, nil), signer, key)
+			gen.AddTx(tx)
+		}
+	})
+	// Import the chain into a fresh database, running the block processor.
+	diskdb := ethdb.NewMemDatabase()
+	gspec.MustCommit(diskdb)
+
+	blockchain, _ := NewBlockChain(diskdb, nil, gspec.Config, ethash.NewFaker(), vm.Config{})
+	defer blockchain.Stop()
+
+	for i, block := range blocks {
+		if _, err := blockchain.InsertChain(types.Blocks{block}); err != nil {
+			t.Fatalf("block %d: failed to insert: %v", i+1, err)
+		}
+		statedb, _ := blockchain.State()
+		queued, stored := schedule.Size(statedb), statedb.GetState(receiver, common.Hash{}).Big().Uint64()
+
+		// Blocks are 10 seconds apart, the first transaction is due by block 3,
+		// which queues the second one.
+		want := uint64(0)
+		if i == 2 {
+			want = 1
+		}
+		if queued != 1 || stored != want {
+			t.Fatalf("block %d: have %d queued and %d stored, want 1 and %d", i+1, queued, stored, want)
+		}
+	}
+	// The scheduled transaction's receipt precedes the one of the transaction
+	// of block 3, which queues another one, as it executes first.
+	receipts := rawdb.ReadReceipts(diskdb, blocks[2].Hash(), blocks[2].NumberU64())
+	if len(receipts) != 2 {
+		t.Fatalf("receipt count mismatch: have %d, want 2", len(receipts))
+	}
+	scheduled, queuing := receipts[0], receipts[1]
+	if want := schedule.TxHash(3, 0); scheduled.TxHash != want {
+		t.Errorf("scheduled receipt hash mismatch: have %x, want %x", scheduled.TxHash, want)
+	}
+	if want := blocks[2].Transactions()[0].Hash(); queuing.TxHash != want {
+		t.Errorf("transaction receipt hash mismatch: have %x, want %x", queuing.TxHash, want)
+	}
+	if scheduled.Status != types.ReceiptStatusSuccessful || len(scheduled.Logs) != 1 || scheduled.Logs[0].Address != receiver {
+		t.Errorf("scheduled receipt mismatch: status %d, logs %v", scheduled.Status, scheduled.Logs)
+	}
+	if scheduled.CumulativeGasUsed != scheduled.GasUsed || queuing.CumulativeGasUsed != blocks[2].GasUsed() {
+		t.Errorf("receipt gas mismatch: scheduled cumulative %d used %d, transaction cumulative %d, block %d", scheduled.CumulativeGasUsed, scheduled.GasUsed, queuing.CumulativeGasUsed, blocks[2].GasUsed())
+	}
+	if receipt, _, _, _ := rawdb.ReadReceipt(diskdb, queuing.TxHash); receipt == nil || receipt.TxHash != queuing.TxHash {
+		t.Errorf("transaction receipt not found by hash: %v", receipt)
+	}
+}
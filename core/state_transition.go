@@ -22,6 +22,7 @@ import (
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/params"
@@ -61,6 +62,7 @@ type StateTransition struct {
 	data       []byte
 	state      vm.StateDB
 	evm        *vm.EVM
+	freeGas    bool // Whether the callee paid for the gas by executing FREEGAS
 }
 
 // Message represents a message sent to a contract.
@@ -220,22 +222,14 @@ func (st *StateTransition) TransitionDb() (ret []byte, usedGas uint64, failed bo
 		return
 	}
 
-	// Get default gas limit and price from chain.
-	var (
-		defaultGasLimit = st.evm.Context.Umbrella.FreeGasLimit().Uint64()
-		currentGasLimit = st.msg.Gas()
-		currentGasPrice = st.gasPrice
-		isFreeGasTX     = false
-	)
+	isFreeGasTX := false
 
 	msg := st.msg
 	sender := vm.AccountRef(msg.From())
 	homestead := st.evm.ChainConfig().IsHomestead(st.evm.BlockNumber)
 	contractCreation := msg.To() == nil
-	contractCall := msg.To() != nil
-	zeroGasPrice := currentGasPrice.Cmp(big.NewInt(0)) == 0
 
-	if zeroGasPrice && currentGasLimit > defaultGasLimit && contractCall {
+	if IsFreeGasMessage(msg, st.evm.Context.Umbrella.FreeGasLimit().Uint64()) {
 		// FreeGas TX
 		isFreeGasTX = true
 		log.Debug("trying to call a freegas function", "err", nil)
@@ -290,14 +284,23 @@ func (st *StateTransition) TransitionDb() (ret []byte, usedGas uint64, failed bo
 		if vmerr == vm.ErrInsufficientBalance {
 			return nil, 0, false, vmerr
 		}
+		// Overflows halting the call fail it with their error as data, as
+		// those reverting do. Other failures return no data, not even the
+		// code of creations rejected after running.
+		if oe, ok := vmerr.(*types.OverflowError); ok {
+			ret = oe.Pack()
+		} else if vmerr != vm.ErrExecutionReverted {
+			ret = nil
+		}
 	}
 
 	st.applyRefundGasCounter()
 
 	if isFreeGasTX {
-		if evm.IsFreeGas() {
+		if FreeGasTriggered(evm, *msg.To()) {
 			log.Debug("trigger freegas function, refund remaining gas to contract", "err", nil)
 			st.refundGasToContract()
+			st.freeGas = true
 		} else {
 			log.Debug("freegas transaction call the non-freegas function", "err", errCallNonFreeGasFunctionWithZeroGasPrice)
 			st.revertBuyGasFromContract()
@@ -313,6 +316,29 @@ func (st *StateTransition) TransitionDb() (ret []byte, usedGas uint64, failed bo
 	return ret, st.gasUsed(), vmerr != nil, err
 }
 
+// FreeGas reports whether the callee paid for the gas of the message applied by
+// TransitionDb, by executing FREEGAS.
+func (st *StateTransition) FreeGas() bool {
+	return st.freeGas
+}
+
+// IsFreeGasMessage reports whether the callee of the message is asked to pay for
+// its gas: the message is zero priced, calls a contract and carries more gas
+// than the free gas limit.
+func IsFreeGasMessage(msg Message, freeGasLimit uint64) bool {
+	return msg.To() != nil && msg.GasPrice().Sign() == 0 && msg.Gas() > freeGasLimit
+}
+
+// FreeGasTriggered reports whether the contract at addr opted in to pay for the
+// transaction last executed by evm, by executing FREEGAS.
+func FreeGasTriggered(evm *vm.EVM, addr common.Address) bool {
+	if evm.ChainConfig().IsLityGas(evm.BlockNumber) {
+		// Only the callee itself may opt in to pay for the transaction.
+		return evm.StateDB.IsFreeGas(addr)
+	}
+	return evm.IsFreeGas()
+}
+
 func (st *StateTransition) applyRefundGasCounter() {
 	// Apply refund counter, capped to half of the used gas.
 	refund := st.gasUsed() / 2
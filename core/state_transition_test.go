@@ -0,0 +1,129 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/core/vm/umbrella"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that zero priced transactions above the free gas limit are paid for by
+// the called contract if it executes FREEGAS, and fail otherwise.
+func TestFreeGasTransactions(t *testing.T) {
+	var (
+		sender  = common.HexToAddress("0x1000000000000000000000000000000000000001")
+		freegas = common.HexToAddress("0x1000000000000000000000000000000000000002")
+		paid    = common.HexToAddress("0x1000000000000000000000000000000000000003")
+		funds   = big.NewInt(params.Ether)
+	)
+	apply := func(to common.Address, gas uint64, gasPrice *big.Int) (*state.StateDB, *vm.EVM, uint64, bool, error) {
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+		statedb.SetBalance(sender, funds)
+		statedb.SetCode(freegas, []byte{byte(vm.FREEGAS), byte(vm.STOP)})
+		statedb.SetBalance(freegas, funds)
+		statedb.SetCode(paid, []byte{byte(vm.STOP)})
+		statedb.SetBalance(paid, funds)
+
+		ctx := vm.Context{
+			CanTransfer: CanTransfer,
+			Transfer:    Transfer,
+			BlockNumber: big.NewInt(1),
+			GasLimit:    math.MaxUint64,
+			Umbrella:    umbrella.NewStandalone(nil),
+		}
+		evm := vm.NewEVM(ctx, statedb, params.TestChainConfig, vm.Config{})
+		msg := types.NewMessage(sender, &to, 0, new(big.Int), gas, gasPrice, nil, false)
+		_, used, failed, err := ApplyMessage(evm, msg, new(GasPool).AddGas(math.MaxUint64))
+		return statedb, evm, used, failed, err
+	}
+	limit := umbrella.DefaultFreeGasLimit
+
+	// The contract executing FREEGAS pays for the gas used.
+	statedb, evm, used, failed, err := apply(freegas, limit+1, new(big.Int))
+	if err != nil || failed {
+		t.Fatalf("freegas call failed: %v", err)
+	}
+	if !FreeGasTriggered(evm, freegas) {
+		t.Error("expected FREEGAS to be triggered")
+	}
+	cost := new(big.Int).Mul(new(big.Int).SetUint64(used), umbrella.DefaultGasPrice)
+	if have, want := statedb.GetBalance(freegas), new(big.Int).Sub(funds, cost); have.Cmp(want) != 0 {
+		t.Errorf("contract balance mismatch: have %v, want %v", have, want)
+	}
+	if have := statedb.GetBalance(sender); have.Cmp(funds) != 0 {
+		t.Errorf("sender balance mismatch: have %v, want %v", have, funds)
+	}
+	// A contract not executing FREEGAS doesn't pay, failing the transaction.
+	statedb, evm, used, failed, err = apply(paid, limit+1, new(big.Int))
+	if err != nil || !failed || used != 0 {
+		t.Fatalf("non-freegas call: have used %d, failed %v, err %v, want 0, true, nil", used, failed, err)
+	}
+	if FreeGasTriggered(evm, paid) {
+		t.Error("didn't expect FREEGAS to be triggered")
+	}
+	if have := statedb.GetBalance(paid); have.Cmp(funds) != 0 {
+		t.Errorf("contract balance mismatch: have %v, want %v", have, funds)
+	}
+	// Transactions up to the free gas limit, or priced, are paid by the sender.
+	for _, gasPrice := range []*big.Int{new(big.Int), big.NewInt(1)} {
+		gas := limit
+		if gasPrice.Sign() > 0 {
+			gas = limit + 1
+		}
+		statedb, _, used, failed, err = apply(freegas, gas, gasPrice)
+		if err != nil || failed {
+			t.Fatalf("price %v: call failed: %v", gasPrice, err)
+		}
+		cost := new(big.Int).Mul(new(big.Int).SetUint64(used), gasPrice)
+		if have, want := statedb.GetBalance(sender), new(big.Int).Sub(funds, cost); have.Cmp(want) != 0 {
+			t.Errorf("price %v: sender balance mismatch: have %v, want %v", gasPrice, have, want)
+		}
+		if have := statedb.GetBalance(freegas); have.Cmp(funds) != 0 {
+			t.Errorf("price %v: contract balance mismatch: have %v, want %v", gasPrice, have, funds)
+		}
+	}
+}
+
+func TestIsFreeGasMessage(t *testing.T) {
+	to := common.HexToAddress("0x01")
+	tests := []struct {
+		to       *common.Address
+		gas      uint64
+		gasPrice int64
+		freeGas  bool
+	}{
+		{&to, 101, 0, true},
+		{&to, 100, 0, false},
+		{&to, 101, 1, false},
+		{nil, 101, 0, false},
+	}
+	for i, tt := range tests {
+		msg := types.NewMessage(common.Address{}, tt.to, 0, new(big.Int), tt.gas, big.NewInt(tt.gasPrice), nil, false)
+		if have := IsFreeGasMessage(msg, 100); have != tt.freeGas {
+			t.Errorf("test %d: have %v, want %v", i, have, tt.freeGas)
+		}
+	}
+}
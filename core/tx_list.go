@@ -437,7 +437,8 @@ func (l *txPricedList) Removed() {
 
 // Cap finds all the transactions below the given price threshold, drops them
 // from the priced list and returs them for further removal from the entire pool.
-func (l *txPricedList) Cap(threshold *big.Int, local *accountSet) types.Transactions {
+// Transactions paid for by a contract are priced at sponsorPrice.
+func (l *txPricedList) Cap(threshold *big.Int, sponsorPrice *big.Int, local *accountSet) types.Transactions {
 	drop := make(types.Transactions, 0, 128) // Remote underpriced transactions to drop
 	save := make(types.Transactions, 0, 64)  // Local underpriced transactions to keep
 
@@ -453,8 +454,9 @@ func (l *txPricedList) Cap(threshold *big.Int, local *accountSet) types.Transact
 			save = append(save, tx)
 			break
 		}
-		// Non stale transaction found, discard unless local
-		if local.containsTx(tx) {
+		// Non stale transaction found, discard unless local or paid for by a
+		// contract at a price above the threshold
+		if local.containsTx(tx) || (l.all.IsSponsored(tx.Hash()) && sponsorPrice.Cmp(threshold) >= 0) {
 			save = append(save, tx)
 		} else {
 			drop = append(drop, tx)
@@ -17,6 +17,7 @@
 package core
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"math"
@@ -28,6 +29,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm/umbrella"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
@@ -76,6 +78,19 @@ var (
 	// than some meaningful limit a user might use. This is not a consensus error
 	// making the transaction invalid, rather a DOS protection.
 	ErrOversizedData = errors.New("oversized data")
+
+	// ErrNotSponsor is returned if a zero priced transaction asks an account
+	// without code to pay for its gas.
+	ErrNotSponsor = errors.New("sponsor is not a contract")
+
+	// ErrInsufficientSponsorFunds is returned if the balance of the contract asked
+	// to pay for a zero priced transaction doesn't cover gas * default gas price
+	// of all the transactions it pays for.
+	ErrInsufficientSponsorFunds = errors.New("insufficient sponsor funds for gas * default price")
+
+	// ErrSponsorLimit is returned if a contract is asked to pay for more zero
+	// priced transactions than the pool keeps per contract.
+	ErrSponsorLimit = errors.New("sponsored transaction limit reached")
 )
 
 var (
@@ -96,6 +111,10 @@ var (
 	queuedRateLimitCounter = metrics.NewRegisteredCounter("txpool/queued/ratelimit", nil) // Dropped due to rate limiting
 	queuedNofundsCounter   = metrics.NewRegisteredCounter("txpool/queued/nofunds", nil)   // Dropped due to out-of-funds
 
+	// Metrics for the sponsored transactions
+	sponsoredRateLimitCounter = metrics.NewRegisteredCounter("txpool/sponsored/ratelimit", nil) // Rejected due to rate limiting
+	sponsoredNofundsCounter   = metrics.NewRegisteredCounter("txpool/sponsored/nofunds", nil)   // Dropped due to out-of-funds sponsor
+
 	// General tx metrics
 	invalidTxCounter     = metrics.NewRegisteredCounter("txpool/invalid", nil)
 	underpricedTxCounter = metrics.NewRegisteredCounter("txpool/underpriced", nil)
@@ -117,6 +136,7 @@ type blockChain interface {
 	CurrentBlock() *types.Block
 	GetBlock(hash common.Hash, number uint64) *types.Block
 	StateAt(root common.Hash) (*state.StateDB, error)
+	Umbrella() umbrella.Umbrella
 
 	SubscribeChainHeadEvent(ch chan<- ChainHeadEvent) event.Subscription
 }
@@ -135,6 +155,8 @@ type TxPoolConfig struct {
 	AccountQueue uint64 // Maximum number of non-executable transaction slots permitted per account
 	GlobalQueue  uint64 // Maximum number of non-executable transaction slots for all accounts
 
+	SponsorSlots uint64 // Maximum number of zero priced transaction slots a contract pays for
+
 	Lifetime time.Duration // Maximum amount of time non-executable transaction are queued
 }
 
@@ -152,6 +174,8 @@ var DefaultTxPoolConfig = TxPoolConfig{
 	AccountQueue: 64,
 	GlobalQueue:  1024,
 
+	SponsorSlots: 16,
+
 	Lifetime: 3 * time.Hour,
 }
 
@@ -171,6 +195,10 @@ func (config *TxPoolConfig) sanitize() TxPoolConfig {
 		log.Warn("Sanitizing invalid txpool price bump", "provided", conf.PriceBump, "updated", DefaultTxPoolConfig.PriceBump)
 		conf.PriceBump = DefaultTxPoolConfig.PriceBump
 	}
+	if conf.SponsorSlots < 1 {
+		log.Warn("Sanitizing invalid txpool sponsor slots", "provided", conf.SponsorSlots, "updated", DefaultTxPoolConfig.SponsorSlots)
+		conf.SponsorSlots = DefaultTxPoolConfig.SponsorSlots
+	}
 	return conf
 }
 
@@ -197,6 +225,8 @@ type TxPool struct {
 	pendingState  *state.ManagedState // Pending state tracking virtual nonces
 	currentMaxGas uint64              // Current gas limit for transaction caps
 
+	standalone *umbrella.Standalone // Umbrella pricing sponsored transactions if the chain has none
+
 	locals  *accountSet // Set of local transaction to exempt from eviction rules
 	journal *txJournal  // Journal of local transaction to back up to disk
 
@@ -229,6 +259,7 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 		all:         newTxLookup(),
 		chainHeadCh: make(chan ChainHeadEvent, chainHeadChanSize),
 		gasPrice:    new(big.Int).SetUint64(config.PriceLimit),
+		standalone:  umbrella.NewStandalone(chainconfig.Umbrella),
 	}
 	pool.locals = newAccountSet(pool.signer)
 	pool.priced = newTxPricedList(pool.all)
@@ -414,6 +445,9 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 	senderCacher.recover(pool.signer, reinject)
 	pool.addTxsLocked(reinject, false)
 
+	// Drop the transactions their contracts can't pay for anymore
+	pool.evictSponsored()
+
 	// validate the pool of pending transactions, this will remove
 	// any transactions that have been included in the block or
 	// have been invalidated because of another transaction (e.g.
@@ -466,7 +500,7 @@ func (pool *TxPool) SetGasPrice(price *big.Int) {
 	defer pool.mu.Unlock()
 
 	pool.gasPrice = price
-	for _, tx := range pool.priced.Cap(price, pool.locals) {
+	for _, tx := range pool.priced.Cap(price, pool.umbrella().DefaultGasPrice(), pool.locals) {
 		pool.removeTx(tx.Hash(), false)
 	}
 	log.Info("Transaction pool price threshold updated", "price", price)
@@ -571,9 +605,11 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	if err != nil {
 		return ErrInvalidSender
 	}
-	// Drop non-local transactions under our own minimal accepted gas price
+	// Drop non-local transactions under our own minimal accepted gas price, unless
+	// a contract pays for them
 	local = local || pool.locals.contains(from) // account may be local even if the transaction arrived from the network
-	if !local && pool.gasPrice.Cmp(tx.GasPrice()) > 0 {
+	contract, sponsored := pool.sponsor(tx)
+	if !local && !sponsored && pool.gasPrice.Cmp(tx.GasPrice()) > 0 {
 		return ErrUnderpriced
 	}
 	// Ensure the transaction adheres to nonce ordering
@@ -585,6 +621,30 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	if pool.currentState.GetBalance(from).Cmp(tx.Cost()) < 0 {
 		return ErrInsufficientFunds
 	}
+	// Sponsoring contracts should have enough funds to buy the gas of all the
+	// transactions they pay for, and pay for a limited number of non-local ones
+	if sponsored {
+		if pool.currentState.GetCodeSize(contract) == 0 {
+			return ErrNotSponsor
+		}
+		// Replacing one of the contract's transactions releases its funds and slot
+		old := pool.pooled(from, tx.Nonce())
+		replaces := old != nil && pool.all.SponsoredBy(old.Hash(), contract)
+
+		cost := pool.sponsorCost(tx)
+		for _, pooled := range pool.all.SponsoredTxs(contract) {
+			if !replaces || pooled.Hash() != old.Hash() {
+				cost.Add(cost, pool.sponsorCost(pooled))
+			}
+		}
+		if pool.currentState.GetBalance(contract).Cmp(cost) < 0 {
+			return ErrInsufficientSponsorFunds
+		}
+		if !local && !replaces && uint64(pool.all.SponsoredCount(contract)) >= pool.config.SponsorSlots {
+			sponsoredRateLimitCounter.Inc(1)
+			return ErrSponsorLimit
+		}
+	}
 	intrGas, err := IntrinsicGas(tx.Data(), tx.To() == nil, pool.homestead)
 	if err != nil {
 		return err
@@ -595,6 +655,101 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	return nil
 }
 
+// umbrella returns the umbrella pricing the gas of sponsored transactions.
+func (pool *TxPool) umbrella() umbrella.Umbrella {
+	if u := pool.chain.Umbrella(); u != nil {
+		return u
+	}
+	return pool.standalone
+}
+
+// sponsor returns the contract asked to pay for the gas of a transaction, if
+// any. Like with FREEGAS, zero priced transactions calling a contract with more
+// gas than the free gas limit are paid for by the contract.
+func (pool *TxPool) sponsor(tx *types.Transaction) (common.Address, bool) {
+	if tx.To() == nil || tx.GasPrice().Sign() != 0 || tx.Gas() <= pool.umbrella().FreeGasLimit().Uint64() {
+		return common.Address{}, false
+	}
+	return *tx.To(), true
+}
+
+// sponsorCost returns the price the contract pays upfront for the gas of a
+// sponsored transaction.
+func (pool *TxPool) sponsorCost(tx *types.Transaction) *big.Int {
+	return new(big.Int).Mul(new(big.Int).SetUint64(tx.Gas()), pool.umbrella().DefaultGasPrice())
+}
+
+// sponsored marks a newly pooled transaction as paid for by its contract, if
+// it's a sponsored one.
+func (pool *TxPool) sponsored(tx *types.Transaction) {
+	if contract, ok := pool.sponsor(tx); ok {
+		pool.all.Sponsor(tx.Hash(), contract)
+	}
+}
+
+// pooled returns the pending or queued transaction of an account with the
+// given nonce, if any.
+func (pool *TxPool) pooled(addr common.Address, nonce uint64) *types.Transaction {
+	if list := pool.pending[addr]; list != nil {
+		if tx := list.txs.Get(nonce); tx != nil {
+			return tx
+		}
+	}
+	if list := pool.queue[addr]; list != nil {
+		return list.txs.Get(nonce)
+	}
+	return nil
+}
+
+// evictSponsored removes the sponsored transactions whose contract can no longer
+// afford to buy their gas along with that of its other transactions, moving any
+// subsequent pending transactions of their senders back to the future queue.
+// The funds of a contract go to the transactions of its senders in turns, each
+// sender's in nonce order. Once a sender's transaction is evicted, its later
+// ones paid for by the same contract are too, as they can't execute before it.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) evictSponsored() {
+	for contract, txs := range pool.all.Sponsored() {
+		// Group the transactions per sender, visiting the senders in a stable order
+		bySender := make(map[common.Address]types.Transactions)
+		for _, tx := range txs {
+			from, _ := types.Sender(pool.signer, tx) // already validated
+			bySender[from] = append(bySender[from], tx)
+		}
+		senders := make([]common.Address, 0, len(bySender))
+		for from, txs := range bySender {
+			sort.Sort(types.TxByNonce(txs))
+			senders = append(senders, from)
+		}
+		sort.Slice(senders, func(i, j int) bool { return bytes.Compare(senders[i][:], senders[j][:]) < 0 })
+
+		funds := new(big.Int).Set(pool.currentState.GetBalance(contract))
+		for depth := 0; len(bySender) > 0; depth++ {
+			for _, from := range senders {
+				txs, ok := bySender[from]
+				if !ok {
+					continue
+				}
+				if depth == len(txs) {
+					delete(bySender, from)
+					continue
+				}
+				if cost := pool.sponsorCost(txs[depth]); funds.Cmp(cost) >= 0 {
+					funds.Sub(funds, cost)
+					continue
+				}
+				for _, tx := range txs[depth:] {
+					log.Trace("Removed unsponsored transaction", "hash", tx.Hash(), "sponsor", contract)
+					pool.removeTx(tx.Hash(), true)
+					sponsoredNofundsCounter.Inc(1)
+				}
+				delete(bySender, from)
+			}
+		}
+	}
+}
+
 // add validates a transaction and inserts it into the non-executable queue for
 // later pending promotion and execution. If the transaction is a replacement for
 // an already pending or queued one, it overwrites the previous and returns this
@@ -649,6 +804,7 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 		}
 		pool.all.Add(tx)
 		pool.priced.Put(tx)
+		pool.sponsored(tx)
 		pool.journalTx(from, tx)
 
 		log.Trace("Pooled new executable transaction", "hash", hash, "from", from, "to", tx.To())
@@ -663,6 +819,7 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 	if err != nil {
 		return false, err
 	}
+	pool.sponsored(tx)
 	// Mark local addresses and journal local transactions
 	if local {
 		pool.locals.add(from)
@@ -1179,14 +1336,18 @@ func (as *accountSet) add(addr common.Address) {
 // peeking into the pool in TxPool.Get without having to acquire the widely scoped
 // TxPool.mu mutex.
 type txLookup struct {
-	all  map[common.Hash]*types.Transaction
-	lock sync.RWMutex
+	all       map[common.Hash]*types.Transaction
+	sponsors  map[common.Hash]common.Address                        // Contracts paying for the sponsored transactions
+	sponsored map[common.Address]map[common.Hash]*types.Transaction // Transactions sponsored by each contract
+	lock      sync.RWMutex
 }
 
 // newTxLookup returns a new txLookup structure.
 func newTxLookup() *txLookup {
 	return &txLookup{
-		all: make(map[common.Hash]*types.Transaction),
+		all:       make(map[common.Hash]*types.Transaction),
+		sponsors:  make(map[common.Hash]common.Address),
+		sponsored: make(map[common.Address]map[common.Hash]*types.Transaction),
 	}
 }
 
@@ -1232,4 +1393,81 @@ func (t *txLookup) Remove(hash common.Hash) {
 	defer t.lock.Unlock()
 
 	delete(t.all, hash)
+	if contract, ok := t.sponsors[hash]; ok {
+		delete(t.sponsors, hash)
+		if delete(t.sponsored[contract], hash); len(t.sponsored[contract]) == 0 {
+			delete(t.sponsored, contract)
+		}
+	}
+}
+
+// Sponsor marks a transaction in the lookup as paid for by a contract.
+func (t *txLookup) Sponsor(hash common.Hash, contract common.Address) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	tx, ok := t.all[hash]
+	if !ok {
+		return
+	}
+	if _, ok := t.sponsors[hash]; ok {
+		return
+	}
+	t.sponsors[hash] = contract
+	if t.sponsored[contract] == nil {
+		t.sponsored[contract] = make(map[common.Hash]*types.Transaction)
+	}
+	t.sponsored[contract][hash] = tx
+}
+
+// IsSponsored returns whether a transaction is paid for by a contract.
+func (t *txLookup) IsSponsored(hash common.Hash) bool {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	_, ok := t.sponsors[hash]
+	return ok
+}
+
+// SponsoredBy returns whether a transaction is paid for by the given contract.
+func (t *txLookup) SponsoredBy(hash common.Hash, contract common.Address) bool {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	sponsor, ok := t.sponsors[hash]
+	return ok && sponsor == contract
+}
+
+// SponsoredCount returns the number of transactions paid for by a contract.
+func (t *txLookup) SponsoredCount(contract common.Address) int {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return len(t.sponsored[contract])
+}
+
+// SponsoredTxs returns the transactions paid for by a contract.
+func (t *txLookup) SponsoredTxs(contract common.Address) types.Transactions {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	txs := make(types.Transactions, 0, len(t.sponsored[contract]))
+	for _, tx := range t.sponsored[contract] {
+		txs = append(txs, tx)
+	}
+	return txs
+}
+
+// Sponsored returns all sponsored transactions, grouped by the paying contract.
+func (t *txLookup) Sponsored() map[common.Address]types.Transactions {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	txs := make(map[common.Address]types.Transactions, len(t.sponsored))
+	for contract, sponsored := range t.sponsored {
+		for _, tx := range sponsored {
+			txs[contract] = append(txs[contract], tx)
+		}
+	}
+	return txs
 }
@@ -29,6 +29,8 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/core/vm/umbrella"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
@@ -64,6 +66,10 @@ func (bc *testBlockChain) StateAt(common.Hash) (*state.StateDB, error) {
 	return bc.statedb, nil
 }
 
+func (bc *testBlockChain) Umbrella() umbrella.Umbrella {
+	return nil
+}
+
 func (bc *testBlockChain) SubscribeChainHeadEvent(ch chan<- ChainHeadEvent) event.Subscription {
 	return bc.chainHeadFeed.Subscribe(ch)
 }
@@ -77,6 +83,11 @@ func pricedTransaction(nonce uint64, gaslimit uint64, gasprice *big.Int, key *ec
 	return tx
 }
 
+func sponsoredTransaction(nonce uint64, gaslimit uint64, contract common.Address, key *ecdsa.PrivateKey) *types.Transaction {
+	tx, _ := types.SignTx(types.NewTransaction(nonce, contract, new(big.Int), gaslimit, new(big.Int), nil), types.HomesteadSigner{}, key)
+	return tx
+}
+
 func setupTxPool() (*TxPool, *ecdsa.PrivateKey) {
 	statedb, _ := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
 	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
@@ -100,6 +111,22 @@ func validateTxPoolInternals(pool *TxPool) error {
 	if priced := pool.priced.items.Len() - pool.priced.stales; priced != pending+queued {
 		return fmt.Errorf("total priced transaction count %d != %d pending + %d queued", priced, pending, queued)
 	}
+	// Ensure the sponsored transactions are all tracked by the pool
+	sponsored := 0
+	for contract, txs := range pool.all.Sponsored() {
+		for _, tx := range txs {
+			if tx == nil {
+				return fmt.Errorf("sponsored transaction of %x missing from the pool", contract)
+			}
+		}
+		if count := pool.all.SponsoredCount(contract); count != len(txs) {
+			return fmt.Errorf("sponsored transaction count of %x mismatch: have %d, want %d", contract, count, len(txs))
+		}
+		sponsored += len(txs)
+	}
+	if sponsored != len(pool.all.sponsors) {
+		return fmt.Errorf("sponsored transaction count %d != %d tracked", sponsored, len(pool.all.sponsors))
+	}
 	// Ensure the next nonce to assign is the correct one
 	for addr, txs := range pool.pending {
 		// Find the last transaction
@@ -1736,6 +1763,232 @@ func TestTransactionStatusCheck(t *testing.T) {
 	}
 }
 
+// Tests that zero priced transactions above the free gas limit are validated
+// against the balance of the contract paying for them, not the sender's.
+func TestTransactionSponsoring(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	var (
+		contract = common.HexToAddress("0x1000000000000000000000000000000000000001")
+		account  = common.HexToAddress("0x1000000000000000000000000000000000000002")
+		gas      = umbrella.DefaultFreeGasLimit + 1
+		cost     = new(big.Int).Mul(new(big.Int).SetUint64(gas), umbrella.DefaultGasPrice)
+	)
+	pool.currentState.SetCode(contract, []byte{byte(vm.FREEGAS), byte(vm.STOP)})
+	pool.currentState.AddBalance(account, cost)
+
+	// Zero priced transactions up to the free gas limit are underpriced
+	if err := pool.AddRemote(sponsoredTransaction(0, gas-1, contract, key)); err != ErrUnderpriced {
+		t.Errorf("free transaction error mismatch: have %v, want %v", err, ErrUnderpriced)
+	}
+	// Above it, the called account must be a contract able to buy the gas
+	if err := pool.AddRemote(sponsoredTransaction(0, gas, account, key)); err != ErrNotSponsor {
+		t.Errorf("account sponsor error mismatch: have %v, want %v", err, ErrNotSponsor)
+	}
+	pool.currentState.AddBalance(contract, new(big.Int).Sub(cost, big.NewInt(1)))
+	if err := pool.AddRemote(sponsoredTransaction(0, gas, contract, key)); err != ErrInsufficientSponsorFunds {
+		t.Errorf("poor sponsor error mismatch: have %v, want %v", err, ErrInsufficientSponsorFunds)
+	}
+	pool.currentState.AddBalance(contract, big.NewInt(1))
+	if err := pool.AddRemote(sponsoredTransaction(0, gas, contract, key)); err != nil {
+		t.Errorf("failed to add sponsored transaction: %v", err)
+	}
+	pending, queued := pool.Stats()
+	if pending != 1 || queued != 0 {
+		t.Fatalf("pool stats mismatch: have %d pending %d queued, want 1 pending 0 queued", pending, queued)
+	}
+	if count := pool.all.SponsoredCount(contract); count != 1 {
+		t.Errorf("sponsored transaction count mismatch: have %d, want %d", count, 1)
+	}
+	// The contract must be able to buy the gas of all the transactions it pays for
+	if err := pool.AddRemote(sponsoredTransaction(1, gas, contract, key)); err != ErrInsufficientSponsorFunds {
+		t.Errorf("overspent sponsor error mismatch: have %v, want %v", err, ErrInsufficientSponsorFunds)
+	}
+	pool.currentState.AddBalance(contract, cost)
+	if err := pool.AddRemote(sponsoredTransaction(1, gas, contract, key)); err != nil {
+		t.Errorf("failed to add second sponsored transaction: %v", err)
+	}
+	// Raising the minimum price up to the sponsors' gas price keeps the sponsored
+	// transactions, raising it above drops them
+	pool.SetGasPrice(umbrella.DefaultGasPrice)
+	if pending, _ := pool.Stats(); pending != 2 {
+		t.Errorf("sponsored transactions dropped by repricing: have %d pending, want %d", pending, 2)
+	}
+	pool.SetGasPrice(new(big.Int).Add(umbrella.DefaultGasPrice, big.NewInt(1)))
+	if pending, queued := pool.Stats(); pending != 0 || queued != 0 {
+		t.Errorf("pool stats mismatch: have %d pending %d queued, want 0 pending 0 queued", pending, queued)
+	}
+	if count := pool.all.SponsoredCount(contract); count != 0 {
+		t.Errorf("sponsored transaction count mismatch: have %d, want %d", count, 0)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+// Tests that the number of transactions a contract pays for is limited, except
+// for local transactions and replacements.
+func TestTransactionSponsorLimiting(t *testing.T) {
+	t.Parallel()
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.SponsorSlots = 2
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	contract := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	pool.currentState.SetCode(contract, []byte{byte(vm.FREEGAS), byte(vm.STOP)})
+	pool.currentState.AddBalance(contract, big.NewInt(params.Ether))
+
+	keys := make([]*ecdsa.PrivateKey, 4)
+	for i := 0; i < len(keys); i++ {
+		keys[i], _ = crypto.GenerateKey()
+	}
+	gas := umbrella.DefaultFreeGasLimit + 1
+
+	// Fill the contract's slots with a pending and a queued transaction
+	for i, nonce := range []uint64{0, 1} {
+		if err := pool.AddRemote(sponsoredTransaction(nonce, gas, contract, keys[i])); err != nil {
+			t.Fatalf("sponsored transaction %d: failed to add: %v", i, err)
+		}
+	}
+	if err := pool.AddRemote(sponsoredTransaction(0, gas, contract, keys[2])); err != ErrSponsorLimit {
+		t.Errorf("sponsor limit error mismatch: have %v, want %v", err, ErrSponsorLimit)
+	}
+	// Replacements don't take a new slot, local transactions aren't limited
+	if err := pool.AddRemote(sponsoredTransaction(1, gas+1, contract, keys[1])); err != ErrReplaceUnderpriced {
+		t.Errorf("sponsored replacement error mismatch: have %v, want %v", err, ErrReplaceUnderpriced)
+	}
+	if err := pool.AddLocal(sponsoredTransaction(0, gas, contract, keys[3])); err != nil {
+		t.Errorf("failed to add local sponsored transaction: %v", err)
+	}
+	pending, queued := pool.Stats()
+	if pending != 2 || queued != 1 {
+		t.Fatalf("pool stats mismatch: have %d pending %d queued, want 2 pending 1 queued", pending, queued)
+	}
+	if count := pool.all.SponsoredCount(contract); count != 3 {
+		t.Errorf("sponsored transaction count mismatch: have %d, want %d", count, 3)
+	}
+	// Dropping a transaction frees its slot
+	pool.removeTx(pool.pending[crypto.PubkeyToAddress(keys[0].PublicKey)].Flatten()[0].Hash(), true)
+	if err := pool.AddRemote(sponsoredTransaction(0, gas, contract, keys[2])); err != ErrSponsorLimit {
+		t.Errorf("sponsor limit error mismatch: have %v, want %v", err, ErrSponsorLimit)
+	}
+	pool.removeTx(pool.queue[crypto.PubkeyToAddress(keys[1].PublicKey)].Flatten()[0].Hash(), true)
+	if err := pool.AddRemote(sponsoredTransaction(0, gas, contract, keys[2])); err != nil {
+		t.Errorf("failed to add sponsored transaction: %v", err)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+// Tests that sponsored transactions are evicted once the contract paying for
+// them can't afford their gas anymore, demoting any subsequent transactions.
+func TestTransactionSponsorEviction(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	var (
+		contract = common.HexToAddress("0x1000000000000000000000000000000000000001")
+		account  = crypto.PubkeyToAddress(key.PublicKey)
+		gas      = umbrella.DefaultFreeGasLimit + 1
+		cost     = new(big.Int).Mul(new(big.Int).SetUint64(gas), umbrella.DefaultGasPrice)
+	)
+	pool.currentState.SetCode(contract, []byte{byte(vm.FREEGAS), byte(vm.STOP)})
+	pool.currentState.AddBalance(contract, new(big.Int).Mul(cost, big.NewInt(3)))
+	pool.currentState.AddBalance(account, big.NewInt(1000000))
+
+	pool.AddRemotes(types.Transactions{
+		sponsoredTransaction(0, gas, contract, key),
+		transaction(1, 100000, key),
+		sponsoredTransaction(2, gas+100, contract, key),
+	})
+	if pending, _ := pool.Stats(); pending != 3 {
+		t.Fatalf("pending transactions mismatched: have %d, want %d", pending, 3)
+	}
+	// Once the contract can't buy the gas of all its transactions, the later ones
+	// are evicted even if it could afford each of them alone
+	costlier := new(big.Int).Mul(new(big.Int).SetUint64(gas+100), umbrella.DefaultGasPrice)
+	pool.currentState.SetBalance(contract, new(big.Int).Sub(new(big.Int).Add(cost, costlier), big.NewInt(1)))
+	pool.lockedReset(nil, nil)
+
+	pending, queued := pool.Stats()
+	if pending != 2 || queued != 0 {
+		t.Fatalf("pool stats mismatch: have %d pending %d queued, want 2 pending 0 queued", pending, queued)
+	}
+	// Evicting a sponsored transaction in the middle demotes the subsequent ones
+	pool.currentState.SetBalance(contract, new(big.Int))
+	pool.lockedReset(nil, nil)
+
+	pending, queued = pool.Stats()
+	if pending != 0 || queued != 1 {
+		t.Fatalf("pool stats mismatch: have %d pending %d queued, want 0 pending 1 queued", pending, queued)
+	}
+	if count := pool.all.SponsoredCount(contract); count != 0 {
+		t.Errorf("sponsored transaction count mismatch: have %d, want %d", count, 0)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+// Tests that the funds of a contract go to the sponsored transactions of each
+// sender in nonce order, evicting a sender's later transactions along with an
+// earlier one the contract can't afford anymore.
+func TestTransactionSponsorEvictionPerSender(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	var (
+		contract = common.HexToAddress("0x1000000000000000000000000000000000000001")
+		other, _ = crypto.GenerateKey()
+		gas      = umbrella.DefaultFreeGasLimit + 1
+		cost     = new(big.Int).Mul(new(big.Int).SetUint64(gas), umbrella.DefaultGasPrice)
+		costlier = new(big.Int).Mul(new(big.Int).SetUint64(gas+100), umbrella.DefaultGasPrice)
+	)
+	pool.currentState.SetCode(contract, []byte{byte(vm.FREEGAS), byte(vm.STOP)})
+	pool.currentState.AddBalance(contract, new(big.Int).Mul(costlier, big.NewInt(3)))
+
+	pool.AddRemotes(types.Transactions{
+		sponsoredTransaction(0, gas+100, contract, key),
+		sponsoredTransaction(1, gas, contract, key),
+		sponsoredTransaction(0, gas, contract, other),
+	})
+	if pending, _ := pool.Stats(); pending != 3 {
+		t.Fatalf("pending transactions mismatched: have %d, want %d", pending, 3)
+	}
+	// The contract can afford two cheap transactions, but not the first one of
+	// the sender of both of them
+	pool.currentState.SetBalance(contract, new(big.Int).Mul(cost, big.NewInt(2)))
+	pool.lockedReset(nil, nil)
+
+	pending, queued := pool.Stats()
+	if pending != 1 || queued != 0 {
+		t.Fatalf("pool stats mismatch: have %d pending %d queued, want 1 pending 0 queued", pending, queued)
+	}
+	if pool.pending[crypto.PubkeyToAddress(other.PublicKey)] == nil {
+		t.Error("transaction of the other sender evicted")
+	}
+	if count := pool.all.SponsoredCount(contract); count != 1 {
+		t.Errorf("sponsored transaction count mismatch: have %d, want %d", count, 1)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
 // Benchmarks the speed of validating the contents of the pending queue of the
 // transaction pool.
 func BenchmarkPendingDemotion100(b *testing.B)   { benchmarkPendingDemotion(b, 100) }
@@ -0,0 +1,89 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// OverflowSignature is the signature of the ABI error OverflowError packs to.
+const OverflowSignature = "LityOverflow(uint8,uint256,uint256[])"
+
+// OverflowSelector identifies packed overflow errors, like the selector of a
+// function call.
+var OverflowSelector = crypto.Keccak256([]byte(OverflowSignature))[:4]
+
+// OverflowError is the error of a Lity checked arithmetic operation whose
+// result doesn't fit its type. It is returned as the data of failed calls,
+// ABI encoded as a LityOverflow(uint8 opcode, uint256 pc, uint256[] operands)
+// error, so that callers can tell which operation overflowed and where.
+type OverflowError struct {
+	OpCode   byte       `json:"opcode"`
+	Pc       uint64     `json:"pc"`
+	Operands []*big.Int `json:"operands"` // Stack words, in two's complement for signed operations
+}
+
+func (e *OverflowError) Error() string {
+	return fmt.Sprintf("evm: arithmetic overflow (opcode 0x%x, pc %d, operands %v)", e.OpCode, e.Pc, e.Operands)
+}
+
+// Pack ABI encodes the error.
+func (e *OverflowError) Pack() []byte {
+	data := make([]byte, 4, 4+32*(4+len(e.Operands)))
+	copy(data, OverflowSelector)
+
+	data = append(data, math.PaddedBigBytes(big.NewInt(int64(e.OpCode)), 32)...)
+	data = append(data, math.PaddedBigBytes(new(big.Int).SetUint64(e.Pc), 32)...)
+	data = append(data, math.PaddedBigBytes(big.NewInt(3*32), 32)...)
+	data = append(data, math.PaddedBigBytes(big.NewInt(int64(len(e.Operands))), 32)...)
+	for _, operand := range e.Operands {
+		data = append(data, math.PaddedBigBytes(math.U256(new(big.Int).Set(operand)), 32)...)
+	}
+	return data
+}
+
+// UnpackOverflowError decodes the data of a failed call into the overflow
+// error it packs, or returns nil if the call didn't fail on an overflow.
+func UnpackOverflowError(data []byte) *OverflowError {
+	if len(data) < 4+4*32 || !bytes.Equal(data[:4], OverflowSelector) {
+		return nil
+	}
+	word := func(i int) *big.Int {
+		return new(big.Int).SetBytes(data[4+32*i : 4+32*(i+1)])
+	}
+	opcode, pc, offset, count := word(0), word(1), word(2), word(3)
+	if !opcode.IsUint64() || opcode.Uint64() > 0xff || !pc.IsUint64() || offset.Cmp(big.NewInt(3*32)) != 0 {
+		return nil
+	}
+	if !count.IsUint64() || count.Uint64() != uint64(len(data)-4-4*32)/32 || (len(data)-4)%32 != 0 {
+		return nil
+	}
+	e := &OverflowError{
+		OpCode:   byte(opcode.Uint64()),
+		Pc:       pc.Uint64(),
+		Operands: make([]*big.Int, count.Uint64()),
+	}
+	for i := range e.Operands {
+		e.Operands[i] = word(4 + i)
+	}
+	return e
+}
@@ -0,0 +1,74 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"bytes"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+func TestOverflowErrorPacking(t *testing.T) {
+	e := &OverflowError{OpCode: 0x01, Pc: 42, Operands: []*big.Int{math.MaxBig256, big.NewInt(1)}}
+	data := e.Pack()
+
+	want := common.FromHex("0x" +
+		"0000000000000000000000000000000000000000000000000000000000000001" +
+		"000000000000000000000000000000000000000000000000000000000000002a" +
+		"0000000000000000000000000000000000000000000000000000000000000060" +
+		"0000000000000000000000000000000000000000000000000000000000000002" +
+		"ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff" +
+		"0000000000000000000000000000000000000000000000000000000000000001")
+	if !bytes.Equal(data[:4], OverflowSelector) || !bytes.Equal(data[4:], want) {
+		t.Fatalf("packed error mismatch: have %x, want %x%x", data, OverflowSelector, want)
+	}
+	if have := UnpackOverflowError(data); !reflect.DeepEqual(have, e) {
+		t.Errorf("unpacked error mismatch: have %v, want %v", have, e)
+	}
+	// Data of other failures doesn't unpack
+	for _, data := range [][]byte{nil, data[:len(data)-1], data[:len(data)-32], append([]byte{0}, data[1:]...)} {
+		if have := UnpackOverflowError(data); have != nil {
+			t.Errorf("unpacked error from invalid data %x: %v", data, have)
+		}
+	}
+}
+
+// Tests that the data receipts failed with and whether the callee paid for the
+// gas are stored, and that receipts stored without them still decode.
+func TestReceiptRevertReasonStorage(t *testing.T) {
+	for _, reason := range [][]byte{nil, {0x01, 0x02}} {
+		for _, freeGas := range []bool{false, true} {
+			receipt := &Receipt{Status: ReceiptStatusFailed, Logs: []*Log{}, GasUsed: 1, RevertReason: reason, FreeGas: freeGas}
+			enc, err := rlp.EncodeToBytes((*ReceiptForStorage)(receipt))
+			if err != nil {
+				t.Fatalf("failed to encode receipt: %v", err)
+			}
+			var dec ReceiptForStorage
+			if err := rlp.DecodeBytes(enc, &dec); err != nil {
+				t.Fatalf("failed to decode receipt: %v", err)
+			}
+			if !bytes.Equal(dec.RevertReason, reason) || dec.FreeGas != freeGas || dec.GasUsed != 1 {
+				t.Errorf("decoded receipt mismatch: have %x/%v, want %x/%v", dec.RevertReason, dec.FreeGas, reason, freeGas)
+			}
+		}
+	}
+}
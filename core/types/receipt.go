@@ -55,6 +55,8 @@ type Receipt struct {
 	TxHash          common.Hash    `json:"transactionHash" gencodec:"required"`
 	ContractAddress common.Address `json:"contractAddress"`
 	GasUsed         uint64         `json:"gasUsed" gencodec:"required"`
+	RevertReason    []byte         `json:"revertReason,omitempty"` // Data the transaction reverted or halted on an overflow with, see OverflowError
+	FreeGas         bool           `json:"freeGas,omitempty"`      // Whether the callee paid for the gas of the transaction by executing FREEGAS
 }
 
 type receiptMarshaling struct {
@@ -62,6 +64,7 @@ type receiptMarshaling struct {
 	Status            hexutil.Uint64
 	CumulativeGasUsed hexutil.Uint64
 	GasUsed           hexutil.Uint64
+	RevertReason      hexutil.Bytes
 }
 
 // receiptRLP is the consensus encoding of a receipt.
@@ -80,6 +83,7 @@ type receiptStorageRLP struct {
 	ContractAddress   common.Address
 	Logs              []*LogForStorage
 	GasUsed           uint64
+	Extra             [][]byte `rlp:"tail"` // Revert reason then free gas flag, trailing empty ones omitted
 }
 
 // NewReceipt creates a barebone transaction receipt, copying the init fields.
@@ -140,7 +144,7 @@ func (r *Receipt) statusEncoding() []byte {
 // Size returns the approximate memory used by all internal contents. It is used
 // to approximate and limit the memory consumption of various caches.
 func (r *Receipt) Size() common.StorageSize {
-	size := common.StorageSize(unsafe.Sizeof(*r)) + common.StorageSize(len(r.PostState))
+	size := common.StorageSize(unsafe.Sizeof(*r)) + common.StorageSize(len(r.PostState)+len(r.RevertReason))
 
 	size += common.StorageSize(len(r.Logs)) * common.StorageSize(unsafe.Sizeof(Log{}))
 	for _, log := range r.Logs {
@@ -168,6 +172,12 @@ func (r *ReceiptForStorage) EncodeRLP(w io.Writer) error {
 	for i, log := range r.Logs {
 		enc.Logs[i] = (*LogForStorage)(log)
 	}
+	switch {
+	case r.FreeGas:
+		enc.Extra = [][]byte{r.RevertReason, {0x01}}
+	case len(r.RevertReason) > 0:
+		enc.Extra = [][]byte{r.RevertReason}
+	}
 	return rlp.Encode(w, enc)
 }
 
@@ -189,6 +199,12 @@ func (r *ReceiptForStorage) DecodeRLP(s *rlp.Stream) error {
 	}
 	// Assign the implementation fields
 	r.TxHash, r.ContractAddress, r.GasUsed = dec.TxHash, dec.ContractAddress, dec.GasUsed
+	if len(dec.Extra) > 0 && len(dec.Extra[0]) > 0 {
+		r.RevertReason = dec.Extra[0]
+	}
+	if len(dec.Extra) > 1 {
+		r.FreeGas = bytes.Equal(dec.Extra[1], []byte{0x01})
+	}
 	return nil
 }
 
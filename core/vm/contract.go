@@ -62,6 +62,7 @@ type Contract struct {
 	Args []byte
 
 	DelegateCall bool
+	IsCreate     bool // running the init code of a contract creation
 }
 
 // NewContract returns a new contract environment for the execution of EVM.
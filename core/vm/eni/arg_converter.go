@@ -6,11 +6,19 @@ package eni
 // fix_array: fix_array_start [0-9]+ type
 // dyn_array: dyn_array_start type
 // struct: struct_start type+ struct_end
-
-// TODO: bytes
+//
+// data format
+// data are packed in 32-byte words, value types take a single word each
+// address: right aligned 20-byte address
+// enum: unsigned integer in [0, 255]
+// bytes, string: length word followed by the content padded to 32 bytes
+// dyn_array: length word followed by the elements
+//
+// in JSON, addresses and bytes are 0x-prefixed hex strings and enums numbers
 
 import (
 	"bytes"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"math/big"
@@ -56,6 +64,8 @@ func (cvt *argConverter) parseType(typeInfo []byte, data []byte, json *bytes.Buf
 			typeInfo, data = cvt.parseStruct(typeInfo, data, json, memory)
 		} else if t == STRING {
 			typeInfo, data = cvt.parseString(typeInfo, data, json, memory)
+		} else if t == BYTES {
+			typeInfo, data = cvt.parseBytes(typeInfo, data, json, memory)
 		} else if t == STRINGPTR {
 			typeInfo, data = cvt.parseStringPtr(typeInfo, data, json, memory)
 		}
@@ -67,8 +77,7 @@ func (cvt *argConverter) parseType(typeInfo []byte, data []byte, json *bytes.Buf
 
 func (cvt *argConverter) parseString(typeInfo []byte, data []byte, json *bytes.Buffer, memory Memory) ([]byte, []byte) {
 	typeInfo = typeInfo[1:] // string
-	leng := new(big.Int).SetBytes(data[:32]).Int64()
-	data = data[32:]
+	leng, data := readLength(data)
 
 	var buffer bytes.Buffer
 	for i := int64(0); i < leng; i++ {
@@ -117,46 +126,63 @@ func (cvt *argConverter) parseStringPtr(typeInfo []byte, data []byte, json *byte
 	return typeInfo, data
 }
 
-// parsing int32 not finished
+func (cvt *argConverter) parseBytes(typeInfo []byte, data []byte, json *bytes.Buffer, memory Memory) ([]byte, []byte) {
+	typeInfo = typeInfo[1:] // bytes
+	leng, data := readLength(data)
+
+	json.WriteString("\"0x")
+	json.WriteString(hex.EncodeToString(data[:leng]))
+	json.WriteString("\"")
+	data = data[leng:]
+	if leng%32 > 0 {
+		data = data[32-leng%32:]
+	}
+	return typeInfo, data
+}
+
 func (cvt *argConverter) parseFixArray(typeInfo []byte, data []byte, json *bytes.Buffer, memory Memory) ([]byte, []byte) {
 	typeInfo = typeInfo[1:] // fix_array_start
-	json.WriteString("[")
-	leng := new(big.Int).SetBytes(typeInfo[:32]).Int64()
+	leng := new(big.Int).SetBytes(typeInfo[:32])
+	if !leng.IsInt64() || leng.Int64() > int64(len(data))/32 {
+		panic("encoding error - array length exceeds data")
+	}
 	typeInfo = typeInfo[32:]
+	return cvt.parseElements(typeInfo, leng.Int64(), data, json, memory)
+}
 
+// dynamic array
+func (cvt *argConverter) parseDynArray(typeInfo []byte, data []byte, json *bytes.Buffer, memory Memory) ([]byte, []byte) {
+	typeInfo = typeInfo[1:] // dyn_array_start
+	leng, data := readLength(data)
+	if leng > int64(len(data))/32 {
+		panic("encoding error - array length exceeds data")
+	}
+	return cvt.parseElements(typeInfo, leng, data, json, memory)
+}
+
+// parseElements parses leng array elements of the type at the head of typeInfo.
+func (cvt *argConverter) parseElements(typeInfo []byte, leng int64, data []byte, json *bytes.Buffer, memory Memory) ([]byte, []byte) {
+	json.WriteString("[")
 	for i := int64(0); i < leng; i++ {
-		if i == leng-1 {
-			typeInfo, data = cvt.parseType(typeInfo, data, json, memory)
-		} else {
+		if 0 < i {
 			json.WriteString(", ")
-			_, data = cvt.parseType(typeInfo, data, json, memory)
 		}
+		_, data = cvt.parseType(typeInfo, data, json, memory)
 	}
-
 	json.WriteString("]")
-	return typeInfo, data
-}
-
-// dynamic array
-func (cvt *argConverter) parseDynArray(typeInfo []byte, data []byte, json *bytes.Buffer, memory Memory) ([]byte, []byte) {
-	panic(fmt.Sprintf("dynamic array not implemented yet!"))
+	return skipType(typeInfo), data
 }
 
 func (cvt *argConverter) parseStruct(typeInfo []byte, data []byte, json *bytes.Buffer, memory Memory) ([]byte, []byte) {
 	typeInfo = typeInfo[1:] // struct_start
 	json.WriteString("[")
-	for i := 0; 0 < len(typeInfo); i++ {
-		t := typeInfo[0]
+	for i := 0; 0 < len(typeInfo) && typeInfo[0] != STRUCT_END; i++ {
 		if 0 < i {
 			json.WriteString(", ")
 		}
-		if t != STRUCT_END {
-			typeInfo, data = cvt.parseType(typeInfo, data, json, memory)
-		} else {
-			break
-		}
+		typeInfo, data = cvt.parseType(typeInfo, data, json, memory)
 	}
-	if typeInfo[0] != STRUCT_END {
+	if len(typeInfo) == 0 || typeInfo[0] != STRUCT_END {
 		panic("encoding error - expected struct_end token")
 	}
 	typeInfo = typeInfo[1:] // struct_end
@@ -164,7 +190,7 @@ func (cvt *argConverter) parseStruct(typeInfo []byte, data []byte, json *bytes.B
 	return typeInfo, data
 }
 
-// bool, int
+// bool, int, address, enum
 func (cvt *argConverter) parseValue(typeInfo []byte, data []byte, json *bytes.Buffer, memory Memory) ([]byte, []byte) {
 	t := typeInfo[0]
 	if t == BOOL {
@@ -197,6 +223,16 @@ func (cvt *argConverter) parseValue(typeInfo []byte, data []byte, json *bytes.Bu
 		n := new(big.Int)
 		n.SetBytes(data[:32]) // big endian
 		json.WriteString(n.String())
+	} else if t == ADDRESS {
+		json.WriteString("\"0x")
+		json.WriteString(hex.EncodeToString(data[12:32]))
+		json.WriteString("\"")
+	} else if t == ENUM {
+		n := new(big.Int).SetBytes(data[:32])
+		if n.BitLen() > 8 {
+			panic(fmt.Sprintf("encoding error - enum value out of range: %v", n))
+		}
+		json.WriteString(n.String())
 	} else {
 		panic(fmt.Sprintf("encoding error - unknown or not implemented type: %d", t))
 	}
@@ -204,3 +240,35 @@ func (cvt *argConverter) parseValue(typeInfo []byte, data []byte, json *bytes.Bu
 	data = data[32:]
 	return typeInfo, data
 }
+
+// readLength splits the length word off the head of data.
+func readLength(data []byte) (int64, []byte) {
+	leng := new(big.Int).SetBytes(data[:32])
+	if !leng.IsInt64() || leng.Int64() > int64(len(data)-32) {
+		panic("encoding error - length exceeds data")
+	}
+	return leng.Int64(), data[32:]
+}
+
+// skipType returns typeInfo past the type at its head.
+func skipType(typeInfo []byte) []byte {
+	t := typeInfo[0]
+	switch {
+	case t == FIX_ARRAY_START:
+		return skipType(typeInfo[33:])
+	case t == DYN_ARRAY_START:
+		return skipType(typeInfo[1:])
+	case t == STRUCT_START:
+		typeInfo = typeInfo[1:]
+		for len(typeInfo) > 0 && typeInfo[0] != STRUCT_END {
+			typeInfo = skipType(typeInfo)
+		}
+		if len(typeInfo) == 0 {
+			panic("encoding error - expected struct_end token")
+		}
+		return typeInfo[1:]
+	case t > STRINGPTR || t == STRUCT_END:
+		panic(fmt.Sprintf("encoding error - unknown or not implemented type: %d", t))
+	}
+	return typeInfo[1:]
+}
@@ -18,7 +18,7 @@ func ExampleConvertArguments_posInt() {
 	d = make([]byte, 70, 70)
 	f[0] = INT
 	d[31] = uint8(72) // 32-byte big endian
-	json, err := ConvertArguments(f, d)
+	json, err := ConvertArguments(f, d, nil)
 	printOrError(json, err)
 	// Output: [72]
 }
@@ -27,13 +27,13 @@ func ExampleConvertArguments_bool() {
 	f := [1]byte{BOOL}
 	var d [32]byte
 
-	json, err := ConvertArguments(f[:], d[:])
+	json, err := ConvertArguments(f[:], d[:], nil)
 	printOrError(json, err)
 
 	for i := 0; i < 32; i++ {
 		copy(d[:], make([]byte, 32, 32))
 		d[i] = uint8(72) // 32-byte big endian
-		json, err = ConvertArguments(f[:], d[:])
+		json, err = ConvertArguments(f[:], d[:], nil)
 		printOrError(json, err)
 	}
 
@@ -77,7 +77,7 @@ func ExampleConvertArguments_intBool() {
 	f := [2]byte{INT, BOOL}
 	var d [70]byte
 	d[31] = uint8(72) // 32-byte big endian
-	json, err := ConvertArguments(f[:], d[:])
+	json, err := ConvertArguments(f[:], d[:], nil)
 	printOrError(json, err)
 
 	// Output: [72,false]
@@ -91,7 +91,7 @@ func ExampleConvertArguments_negInt() {
 		d[i] = uint8(255)
 	}
 
-	json, err := ConvertArguments(f[:], d[:])
+	json, err := ConvertArguments(f[:], d[:], nil)
 
 	printOrError(json, err)
 	// Output: [-1]
@@ -108,7 +108,7 @@ func ExampleConvertArguments_string() {
 	strB := "abcdefghijklmnopqrstuvwxyzabcdefghijklmnopqrstuvwxyz"
 	copy(d[96:], []byte(strB))
 
-	json, err := ConvertArguments(f[:], d[:])
+	json, err := ConvertArguments(f[:], d[:], nil)
 
 	printOrError(json, err)
 	// Output: ["abc","abcdefghijklmnopqrstuvwxyzabcdefghijklmnopqrstuvwx"]
@@ -121,7 +121,7 @@ func ExampleConvertArguments_escapedString() {
 	strA := "abc\"d\\e"
 	copy(d[32:], []byte(strA))
 
-	json, _ := ConvertArguments(f[:], d[:])
+	json, _ := ConvertArguments(f[:], d[:], nil)
 	fmt.Println(json)
 
 	// Output: ["abc\"d\\e"]
@@ -134,12 +134,64 @@ func ExampleConvertArguments_controlEscapedString() {
 	strA := "abc\"d\\\b\x00e"
 	copy(d[32:], []byte(strA))
 
-	json, _ := ConvertArguments(f[:], d[:])
+	json, _ := ConvertArguments(f[:], d[:], nil)
 	fmt.Println(json)
 
 	// Output: ["abc\"d\\\u0008\u0000e"]
 }
 
+func ExampleConvertArguments_dynArray() {
+	f := [2]byte{DYN_ARRAY_START, UINT}
+	var d [96]byte
+	d[31] = uint8(2) // array length
+	d[63] = uint8(7)
+	d[95] = uint8(9)
+
+	json, err := ConvertArguments(f[:], d[:], nil)
+	printOrError(json, err)
+	// Output: [[7, 9]]
+}
+
+// a struct holding a dynamic array of strings and an enum
+func ExampleConvertArguments_nestedDynArray() {
+	f := [6]byte{STRUCT_START, DYN_ARRAY_START, STRING, ENUM, STRUCT_END, BOOL}
+	var d [224]byte
+	d[31] = uint8(2) // array length
+	d[63] = uint8(1)
+	d[64] = 'a'
+	d[127] = uint8(2)
+	copy(d[128:], "bc")
+	d[191] = uint8(3)
+	d[223] = uint8(1)
+
+	json, err := ConvertArguments(f[:], d[:], nil)
+	printOrError(json, err)
+	// Output: [[["a", "bc"], 3],true]
+}
+
+func ExampleConvertArguments_addressBytes() {
+	f := [2]byte{ADDRESS, BYTES}
+	var d [96]byte
+	d[12], d[31] = 0xca, 0xfe
+	d[63] = uint8(3) // bytes length
+	copy(d[64:], []byte{0x01, 0x02, 0x03})
+
+	json, err := ConvertArguments(f[:], d[:], nil)
+	printOrError(json, err)
+	// Output: ["0xca000000000000000000000000000000000000fe","0x010203"]
+}
+
+// a dynamic array longer than the data
+func ExampleConvertArguments_errorDynArrayLength() {
+	f := [2]byte{DYN_ARRAY_START, UINT}
+	var d [64]byte
+	d[0] = uint8(1) // array length
+
+	json, err := ConvertArguments(f[:], d[:], nil)
+	printOrError(json, err)
+	// Output: Argument Parser Error: encoding error - length exceeds data
+}
+
 // encoding grammaer error
 // This happens when Lity byte code generates wrong encoding
 func ExampleConvertArguments_errorEncoding1() {
@@ -149,7 +201,7 @@ func ExampleConvertArguments_errorEncoding1() {
 		d[i] = uint8(255)
 	}
 
-	json, err := ConvertArguments(f[:], d[:])
+	json, err := ConvertArguments(f[:], d[:], nil)
 
 	printOrError(json, err)
 	// Output: Argument Parser Error: encoding error - unknown or not implemented type: 155
@@ -163,10 +215,10 @@ func ExampleConvertArguments_errorEncoding2() {
 	for i := 0; i < 32; i++ {
 		d[i] = uint8(255)
 	}
-	json, err := ConvertArguments(f[:], d[:])
+	json, err := ConvertArguments(f[:], d[:], nil)
 
 	printOrError(json, err)
-	// Output: Argument Parser Error: runtime error: index out of range
+	// Output: Argument Parser Error: encoding error - expected struct_end token
 }
 
 // data length mismatches ENI type encoding
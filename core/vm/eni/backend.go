@@ -0,0 +1,121 @@
+package eni
+
+import (
+	"errors"
+	"sync"
+)
+
+// ENIBackend resolves and executes the ENI functions invoked by an EVM. An
+// invocation consists of InitENI resolving the function, followed by Gas and
+// ExecuteENI. A backend holds the invocation in flight, so it serves a single
+// EVM only.
+type ENIBackend interface {
+	// InitENI prepares the named function to be called with the JSON encoded
+	// arguments.
+	InitENI(eniFunction string, argsText string) error
+
+	// Gas returns the gas cost of the prepared invocation.
+	Gas() (uint64, error)
+
+	// ExecuteENI runs the prepared invocation, returning its JSON encoded
+	// return value.
+	ExecuteENI() (string, error)
+}
+
+// Provider creates the ENI backend of every EVM. Unlike backends, a provider is
+// safe for concurrent use.
+type Provider interface {
+	// NewBackend returns a backend resolving functions at the given block
+	// number.
+	NewBackend(number uint64) ENIBackend
+}
+
+// GasFunc computes the gas cost of an ENI function from its JSON arguments.
+type GasFunc func(argsText string) (uint64, error)
+
+// RunFunc executes an ENI function on its JSON arguments.
+type RunFunc func(argsText string) (string, error)
+
+type goFunction struct {
+	gas GasFunc
+	run RunFunc
+}
+
+// goFunctions is the set of functions shared by a GoBackend and the backends
+// it provides.
+type goFunctions struct {
+	lock  sync.RWMutex
+	funcs map[string]goFunction
+}
+
+// GoBackend is an ENI backend running functions implemented in Go within the
+// node process. It needs neither cgo nor the native libraries, which makes it
+// suitable for tests and non-Linux builds.
+//
+// A GoBackend is also a Provider: every EVM configured with it gets a backend
+// of its own, sharing the registered functions but not the invocation state.
+// Functions may be registered at any time.
+type GoBackend struct {
+	funcs *goFunctions
+
+	opName   string
+	op       *goFunction
+	argsText string
+}
+
+// NewGoBackend creates an ENI backend without any functions.
+func NewGoBackend() *GoBackend {
+	return &GoBackend{funcs: &goFunctions{funcs: make(map[string]goFunction)}}
+}
+
+// Register makes the named ENI function available, replacing any function
+// previously registered under the same name.
+func (b *GoBackend) Register(name string, gas GasFunc, run RunFunc) {
+	b.funcs.lock.Lock()
+	defer b.funcs.lock.Unlock()
+
+	b.funcs.funcs[name] = goFunction{gas: gas, run: run}
+}
+
+// NewBackend implements Provider, returning a backend with the functions of b
+// and an invocation state of its own. Go functions don't depend on the block.
+func (b *GoBackend) NewBackend(number uint64) ENIBackend {
+	return &GoBackend{funcs: b.funcs}
+}
+
+// InitENI implements ENIBackend, resolving a registered function.
+func (b *GoBackend) InitENI(eniFunction string, argsText string) error {
+	b.funcs.lock.RLock()
+	fn, ok := b.funcs.funcs[eniFunction]
+	b.funcs.lock.RUnlock()
+
+	if !ok {
+		return errors.New("ENI " + eniFunction + " not registered")
+	}
+	b.opName, b.op, b.argsText = eniFunction, &fn, argsText
+	return nil
+}
+
+// Gas implements ENIBackend.
+func (b *GoBackend) Gas() (uint64, error) {
+	if b.op == nil {
+		return 0, errors.New("ENI function not initialized")
+	}
+	gas, err := b.op.gas(b.argsText)
+	if err != nil {
+		return gas, errors.New("ENI " + b.opName + " gas error, msg = " + err.Error())
+	}
+	return gas, nil
+}
+
+// ExecuteENI implements ENIBackend.
+func (b *GoBackend) ExecuteENI() (string, error) {
+	if b.op == nil {
+		return "", errors.New("ENI function not initialized")
+	}
+	ret, err := b.op.run(b.argsText)
+	if err != nil {
+		return ret, errors.New("ENI " + b.opName + " run error, msg = " + err.Error())
+	}
+	return ret, nil
+}
@@ -0,0 +1,143 @@
+// +build cgo
+
+package eni
+
+/*
+#cgo LDFLAGS: -ldl
+#include <dlfcn.h>
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"sync"
+	"unsafe"
+
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+var (
+	cacheHitMeter   = metrics.NewRegisteredMeter("eni/cache/hit", nil)
+	cacheMissMeter  = metrics.NewRegisteredMeter("eni/cache/miss", nil)
+	cacheEvictMeter = metrics.NewRegisteredMeter("eni/cache/evict", nil)
+)
+
+// libHandles is the process wide cache of opened ENI libraries.
+var libHandles = newHandleCache()
+
+// libHandle is an opened dynamic library along with the symbols resolved from
+// it so far. A handle is closed once it is evicted from the cache and no ENI
+// invocation references it anymore.
+type libHandle struct {
+	path    string
+	handle  unsafe.Pointer
+	symbols map[string]unsafe.Pointer
+
+	refs    int  // number of ENI invocations using the handle
+	evicted bool // whether the handle was dropped from the cache
+}
+
+// handleCache keeps dynamic libraries open across ENI invocations, so that
+// every library is loaded and every symbol resolved only once.
+type handleCache struct {
+	mu   sync.Mutex
+	libs map[string]*libHandle // opened libraries keyed by path
+}
+
+func newHandleCache() *handleCache {
+	return &handleCache{libs: make(map[string]*libHandle)}
+}
+
+// acquire returns the gas and run functions of the ENI operation exported by
+// the library at path, opening the library if it is not cached yet. The
+// returned handle must be released once the functions are no longer needed.
+func (c *handleCache) acquire(path, opName string) (*libHandle, unsafe.Pointer, unsafe.Pointer, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	lib, ok := c.libs[path]
+	if !ok {
+		cpath := C.CString(path)
+		defer C.free(unsafe.Pointer(cpath))
+
+		// Bind all symbols upfront, lazy binding would run the dynamic linker
+		// within the instruction budget of whichever invocation first calls a
+		// function of the library.
+		handle := C.dlopen(cpath, C.RTLD_NOW)
+		if handle == nil {
+			return nil, nil, nil, errors.New("dlopen failed: " + path + "\nError: " + C.GoString(C.dlerror()))
+		}
+		lib = &libHandle{path: path, handle: handle, symbols: make(map[string]unsafe.Pointer)}
+		c.libs[path] = lib
+	}
+	gasFunc, gasHit, err := lib.symbol(opName + "_gas")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	runFunc, runHit, err := lib.symbol(opName + "_run")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if gasHit && runHit {
+		cacheHitMeter.Mark(1)
+	} else {
+		cacheMissMeter.Mark(1)
+	}
+	lib.refs++
+	return lib, gasFunc, runFunc, nil
+}
+
+// release drops a reference to the handle, closing it if it was evicted.
+func (c *handleCache) release(lib *libHandle) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	lib.refs--
+	if lib.evicted && lib.refs == 0 {
+		lib.close()
+	}
+}
+
+// evict drops the libraries at the given paths from the cache, e.g. because
+// they were moved or replaced by an upgrade. Handles still in use are closed
+// once their last reference is released.
+func (c *handleCache) evict(paths ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, path := range paths {
+		lib, ok := c.libs[path]
+		if !ok {
+			continue
+		}
+		delete(c.libs, path)
+		cacheEvictMeter.Mark(1)
+
+		lib.evicted = true
+		if lib.refs == 0 {
+			lib.close()
+		}
+	}
+}
+
+// symbol resolves the named symbol, reporting whether it was cached already.
+func (lib *libHandle) symbol(name string) (unsafe.Pointer, bool, error) {
+	if fn, ok := lib.symbols[name]; ok {
+		return fn, true, nil
+	}
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+
+	fn := C.dlsym(lib.handle, cname)
+	if fn == nil {
+		return nil, false, errors.New("dlsym failed: " + name)
+	}
+	lib.symbols[name] = fn
+	return fn, false, nil
+}
+
+func (lib *libHandle) close() {
+	C.dlclose(lib.handle)
+	lib.handle, lib.symbols = nil, nil
+}
@@ -0,0 +1,64 @@
+// +build cgo
+
+package eni
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Tests that libraries are opened once and only closed after eviction when
+// the last invocation using them is done.
+func TestHandleCache(t *testing.T) {
+	dir := newTestLibPath(t)
+	defer os.RemoveAll(dir)
+
+	buildLibrary(t, dir, "eni_cache_v1.0.0.so", "foo", "bar")
+	path := filepath.Join(dir, "eni_cache_v1.0.0.so")
+
+	cache := newHandleCache()
+	first, gas, run, err := cache.acquire(path, "foo")
+	if err != nil {
+		t.Fatalf("failed to open library: %v", err)
+	}
+	if gas == nil || run == nil {
+		t.Fatal("functions not resolved")
+	}
+	second, gas2, run2, err := cache.acquire(path, "foo")
+	if err != nil {
+		t.Fatalf("failed to reuse library: %v", err)
+	}
+	if first != second || gas != gas2 || run != run2 {
+		t.Fatal("library opened twice")
+	}
+	if len(first.symbols) != 2 {
+		t.Errorf("resolved symbols mismatch: have %d, want 2", len(first.symbols))
+	}
+	if _, _, _, err := cache.acquire(path, "missing"); err == nil {
+		t.Error("missing function resolved")
+	}
+	cache.release(second)
+
+	// Evicted handles stay usable until released.
+	cache.evict(path)
+	if first.handle == nil {
+		t.Fatal("handle in use closed")
+	}
+	if _, ok := cache.libs[path]; ok {
+		t.Fatal("evicted library still cached")
+	}
+	cache.release(first)
+	if first.handle != nil {
+		t.Fatal("released handle not closed")
+	}
+	// The library is reopened on next use.
+	third, _, _, err := cache.acquire(path, "bar")
+	if err != nil {
+		t.Fatalf("failed to reopen library: %v", err)
+	}
+	if third == first {
+		t.Fatal("closed handle reused")
+	}
+	cache.release(third)
+}
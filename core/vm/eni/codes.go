@@ -118,6 +118,7 @@ var ComplexType = map[byte]bool{
 	DYN_ARRAY_START: true,
 	STRUCT_START:    true,
 	STRING:          true,
+	BYTES:           true,
 	STRINGPTR:       true,
 }
 
@@ -0,0 +1,126 @@
+package eni
+
+import (
+	"bytes"
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/math"
+)
+
+// randomData generates canonically encoded data for the type at the head of
+// typeInfo, returning the remaining type information.
+func randomData(rnd *rand.Rand, typeInfo []byte, data *bytes.Buffer) []byte {
+	word := func(n *big.Int) { data.Write(math.PaddedBigBytes(n, 32)) }
+	blob := func(b []byte) {
+		word(big.NewInt(int64(len(b))))
+		data.Write(b)
+		if len(b)%32 > 0 {
+			data.Write(make([]byte, 32-len(b)%32))
+		}
+	}
+	t := typeInfo[0]
+	switch {
+	case t == BOOL:
+		word(big.NewInt(rnd.Int63n(2)))
+	case t == ENUM:
+		word(big.NewInt(rnd.Int63n(256)))
+	case t == ADDRESS:
+		b := make([]byte, 20)
+		rnd.Read(b)
+		word(new(big.Int).SetBytes(b))
+	case IsUint(t):
+		b := make([]byte, rnd.Intn(33))
+		rnd.Read(b)
+		word(new(big.Int).SetBytes(b))
+	case IsSint(t):
+		n := big.NewInt(rnd.Int63())
+		if rnd.Intn(2) == 0 {
+			n.Neg(n)
+		}
+		word(math.U256(n))
+	case t == STRING:
+		b := make([]byte, rnd.Intn(70))
+		for i := range b {
+			b[i] = byte(rnd.Intn(0x80))
+		}
+		blob(b)
+	case t == BYTES:
+		b := make([]byte, rnd.Intn(70))
+		rnd.Read(b)
+		blob(b)
+	case t == FIX_ARRAY_START:
+		leng := new(big.Int).SetBytes(typeInfo[1:33]).Int64()
+		for i := int64(0); i < leng; i++ {
+			randomData(rnd, typeInfo[33:], data)
+		}
+		return skipType(typeInfo)
+	case t == DYN_ARRAY_START:
+		leng := rnd.Intn(4)
+		word(big.NewInt(int64(leng)))
+		for i := 0; i < leng; i++ {
+			randomData(rnd, typeInfo[1:], data)
+		}
+		return skipType(typeInfo)
+	case t == STRUCT_START:
+		typeInfo = typeInfo[1:]
+		for typeInfo[0] != STRUCT_END {
+			typeInfo = randomData(rnd, typeInfo, data)
+		}
+		return typeInfo[1:]
+	}
+	return typeInfo[1:]
+}
+
+func fixArray(leng byte, elem ...byte) []byte {
+	t := make([]byte, 33)
+	t[0], t[32] = FIX_ARRAY_START, leng
+	return append(t, elem...)
+}
+
+// Tests that converting arguments to JSON and back as a return value yields
+// the original data for every type.
+func TestConverterRoundTrip(t *testing.T) {
+	types := [][]byte{
+		{BOOL},
+		{INT},
+		{INT8, UINT256},
+		{UINT},
+		{BYTE32},
+		{ADDRESS},
+		{ENUM},
+		{STRING},
+		{BYTES},
+		{DYN_ARRAY_START, UINT},
+		{DYN_ARRAY_START, STRING},
+		{DYN_ARRAY_START, BYTES},
+		{DYN_ARRAY_START, DYN_ARRAY_START, INT},
+		{STRUCT_START, ADDRESS, DYN_ARRAY_START, ENUM, STRUCT_END},
+		{DYN_ARRAY_START, STRUCT_START, BYTES, DYN_ARRAY_START, BOOL, STRUCT_END},
+		{STRUCT_START, STRUCT_START, STRING, STRUCT_END, INT, STRUCT_END, ADDRESS},
+		fixArray(3, DYN_ARRAY_START, ADDRESS),
+		append([]byte{DYN_ARRAY_START}, fixArray(2, STRING)...),
+		fixArray(0, STRUCT_START, BYTES, STRUCT_END),
+	}
+	rnd := rand.New(rand.NewSource(1))
+	for i, typeInfo := range types {
+		for j := 0; j < 100; j++ {
+			var data bytes.Buffer
+			for rest := typeInfo; len(rest) > 0; {
+				rest = randomData(rnd, rest, &data)
+			}
+			json, err := ConvertArguments(typeInfo, data.Bytes(), nil)
+			if err != nil {
+				t.Fatalf("type %d: failed to convert arguments %x: %v", i, data.Bytes(), err)
+			}
+			ret, err := ConvertReturnValue(typeInfo, json)
+			if err != nil {
+				t.Fatalf("type %d: failed to convert return value %s: %v", i, json, err)
+			}
+			if !bytes.Equal(ret, data.Bytes()) {
+				t.Fatalf("type %d: round trip mismatch for %s\nhave %x\nwant %x", i, json, ret, data.Bytes())
+			}
+		}
+	}
+}
@@ -1,7 +1,9 @@
+// +build cgo
+
 package eni
 
 /*
-#cgo CFLAGS: -Werror
+#cgo CFLAGS: -Werror -D_GNU_SOURCE
 #cgo LDFLAGS: -ldl -I${SRCDIR}/core/vm/eni
 #include <dlfcn.h>
 #include <stdint.h>
@@ -13,53 +15,71 @@ package eni
 import "C"
 
 import (
-	"debug/elf"
 	"errors"
-	"fmt"
-	"os"
-	"path/filepath"
+	"math"
 	"runtime"
+	"time"
 	"unsafe"
 
-	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
 )
 
+// ENI is the native ENI backend, running functions exported by the dynamic
+// libraries in the ENI library path in a forked process.
 type ENI struct {
-	// functions & dynamic libraries mapping used by ENI
-	functions map[string]string
-	opName    string
-	gasFunc   unsafe.Pointer
-	runFunc   unsafe.Pointer
-	argsText  string // JSON
-	retText   string // JSON
+	// block number the ENI functions are resolved at
+	number   uint64
+	library  *Library // library the functions are resolved in, instead of the registry
+	opName   string
+	lib      *libHandle // cached library the functions belong to
+	gasFunc  unsafe.Pointer
+	runFunc  unsafe.Pointer
+	gas      uint64 // gas charged by the gas function, bounding the run function
+	argsText string // JSON
+	retText  string // JSON
+}
+
+// NewENI returns an ENI handler resolving functions against the libraries
+// canonical at the given block number.
+func NewENI(number uint64) *ENI {
+	return &ENI{number: number}
 }
 
-func NewENI() *ENI {
-	return &ENI{}
+// NewLibraryENI returns an ENI handler resolving functions in the given library
+// only, whether it is installed in the library path or not.
+func NewLibraryENI(lib *Library) *ENI {
+	return &ENI{library: lib}
 }
 
 func (eni *ENI) InitENI(eniFunction string, argsText string) (err error) {
-	// Get functions & dynamic libraries mappings
-	eni.functions, err = getEniFunctions()
-	if err != nil {
-		return err
+	if runtime.GOOS != "linux" {
+		return errors.New("currently ENI is only supported on Linux")
 	}
-
-	dynamicLibName := eni.functions[eniFunction+"_gas"]
-	// Load dynamic library.
-	handler := C.dlopen(C.CString(dynamicLibName), C.RTLD_LAZY)
-	if handler == nil {
-		return errors.New("dlopen failed: " + dynamicLibName + "\nError: " + C.GoString(C.dlerror()))
+	lib := eni.library
+	if lib == nil {
+		// Find the library version canonical at the current block.
+		registry, err := DefaultRegistry()
+		if err != nil {
+			return err
+		}
+		if lib, err = registry.Lookup(eniFunction+"_gas", eni.number); err != nil {
+			return err
+		}
+		if runLib, err := registry.Lookup(eniFunction+"_run", eni.number); err != nil {
+			return err
+		} else if runLib != lib {
+			return errors.New("ENI " + eniFunction + " gas and run functions are exported by different libraries")
+		}
 	}
+	// Release the library of a previous invocation that did not complete.
+	eni.release()
 
-	eni.gasFunc = C.dlsym(handler, C.CString(eniFunction+"_gas"))
-	if eni.gasFunc == nil {
-		return errors.New("dlsym failed: " + eniFunction + "_gas")
-	}
-	eni.runFunc = C.dlsym(handler, C.CString(eniFunction+"_run"))
-	if eni.runFunc == nil {
-		return errors.New("dlsym failed: " + eniFunction + "_run")
+	handle, gasFunc, runFunc, err := libHandles.acquire(lib.Path, eniFunction)
+	if err != nil {
+		return err
 	}
+	eni.lib, eni.gasFunc, eni.runFunc = handle, gasFunc, runFunc
 	eni.opName = eniFunction
 	eni.argsText = argsText
 
@@ -69,78 +89,122 @@ func (eni *ENI) InitENI(eniFunction string, argsText string) (err error) {
 // Gas returns gas of current ENI operation
 // a process is forked to achieve fault tolerance
 func (eni *ENI) Gas() (uint64, error) {
-	status := C.int(C.ENI_FAILURE)
-	gas := uint64(C.fork_gas(eni.gasFunc, C.CString(eni.argsText), &status))
-	if int(status) != C.ENI_SUCCESS {
-		errMsg := C.eni_error_msg(status)
-		fmt.Printf("ENI error: %s\n", C.GoString(errMsg))
-		return gas, errors.New("ENI " + eni.opName + " gas error, msg = " + C.GoString(errMsg))
+	gas, err := forkGas(eni.gasFunc, eni.argsText, limits{
+		instructions: params.ENIGasInstructions,
+		memory:       params.ENIMemoryLimit,
+		timeout:      Timeout(),
+	})
+	if err != nil {
+		err.Op, err.Phase = eni.opName, "gas"
+		log.Debug("ENI gas function failed", "op", eni.opName, "code", err.Code, "local", err.Local, "err", err.Msg)
+		return gas, err
 	}
+	eni.gas = gas
 	return gas, nil
 }
 
 // ExecuteENI executes current ENI operation
 // a process is forked to achieve fault tolerance
 func (eni *ENI) ExecuteENI() (string, error) {
-	// Run ENI function.
-	status := C.int(C.ENI_FAILURE)
-	retCString := C.fork_run(eni.runFunc, C.CString(eni.argsText), &status)
-	defer C.free(unsafe.Pointer(retCString))
-	retGoString := C.GoString(retCString)
+	defer eni.release()
 
-	if int(status) != C.ENI_SUCCESS {
-		errMsg := C.eni_error_msg(status)
-		fmt.Printf("ENI error: %s\n", C.GoString(errMsg))
-		return retGoString, errors.New("ENI " + eni.opName + " run error, msg = " + C.GoString(errMsg))
+	ret, err := forkRun(eni.runFunc, eni.argsText, runLimits(eni.gas))
+	if err != nil {
+		err.Op, err.Phase = eni.opName, "run"
+		log.Debug("ENI run function failed", "op", eni.opName, "code", err.Code, "local", err.Local, "err", err.Msg)
+		return ret, err
 	}
-	return retGoString, nil
+	return ret, nil
 }
 
-func getEniFunctions() (map[string]string, error) {
-	if runtime.GOOS != "linux" {
-		return nil, errors.New("currently ENI is only supported on Linux")
-	}
+// Fault codes of interest outside the C code, see fork_call.h.
+const (
+	codeFailure     = int(C.ENI_FAILURE)
+	codeTimeout     = int(C.ENI_TLE)
+	codeLimitFail   = int(C.ENI_LIMIT_FAIL)
+	codeKilled      = int(C.ENI_KILLED)
+	codeSegfault    = int(C.ENI_SEGFAULT)
+	codeNullResult  = int(C.ENI_NULL_RESULT)
+	codeOutOfGas    = int(C.ENI_OUT_OF_GAS)
+	codeOutputLimit = int(C.ENI_OUTPUT_LIMIT)
+)
+
+// limits bounds the resources of a forked ENI invocation.
+type limits struct {
+	instructions uint64 // 0 = unbounded
+	memory       uint64
+	output       uint64        // 0 = unbounded
+	timeout      time.Duration // 0 = never
+}
 
-	// Get dynamic library path.
-	libPath := filepath.Join(node.DefaultDataDir(), "eni", "lib")
-	if val, ok := os.LookupEnv("ENI_LIBRARY_PATH"); ok {
-		libPath = val
+// runLimits returns the limits of a run function which charged the given gas.
+// It may execute a number of instructions proportional to the gas, whatever
+// the speed of the node.
+func runLimits(gas uint64) limits {
+	instructions := gas * params.ENIInstructionsPerGas
+	if gas > math.MaxUint64/params.ENIInstructionsPerGas {
+		instructions = math.MaxUint64
 	}
-	var dynamicLibs []string
-	fileinfo, err := os.Stat(libPath)
-	if err != nil {
-		return nil, err
+	return limits{
+		instructions: instructions,
+		memory:       params.ENIMemoryLimit,
+		output:       params.ENIOutputLimit,
+		timeout:      Timeout(),
 	}
-	if !fileinfo.Mode().IsDir() {
-		return nil, errors.New("can't find dynamic library path: " + libPath)
+}
+
+func (l limits) c() C.eni_limits {
+	return C.eni_limits{
+		instructions: C.uint64_t(l.instructions),
+		memory:       C.uint64_t(l.memory),
+		output:       C.uint64_t(l.output),
+		timeout_ms:   C.uint64_t(l.timeout / time.Millisecond),
 	}
+}
 
-	// Get all dynamic libraries from library path.
-	filepath.Walk(libPath, func(path string, fileinfo os.FileInfo, err error) error {
-		if fileinfo.Mode().IsRegular() && filepath.Ext(path) == ".so" {
-			dynamicLibs = append(dynamicLibs, path)
-		}
-		return nil
-	})
+// forkGas runs an ENI gas function in a forked process.
+func forkGas(fn unsafe.Pointer, argsText string, l limits) (uint64, *Fault) {
+	argsCString := C.CString(argsText)
+	defer C.free(unsafe.Pointer(argsCString))
 
-	// Build mapping for functions and libraries
-	functions := make(map[string]string)
-	for _, lib := range dynamicLibs {
-		elf, err := elf.Open(lib)
-		if err != nil {
-			return nil, err
-		}
+	cl := l.c()
+	status := C.int(C.ENI_FAILURE)
+	gas := uint64(C.fork_gas(fn, argsCString, &cl, &status))
+	if int(status) != C.ENI_SUCCESS {
+		return gas, newFault(status)
+	}
+	return gas, nil
+}
 
-		symbols, err := elf.DynamicSymbols()
-		if err != nil {
-			return nil, err
-		}
+// forkRun runs an ENI run function in a forked process.
+func forkRun(fn unsafe.Pointer, argsText string, l limits) (string, *Fault) {
+	argsCString := C.CString(argsText)
+	defer C.free(unsafe.Pointer(argsCString))
 
-		for _, symbol := range symbols {
-			// TODO: assert ( `symbol.Name` does not exist in `functions` )
-			functions[symbol.Name] = lib
-		}
+	cl := l.c()
+	status := C.int(C.ENI_FAILURE)
+	retCString := C.fork_run(fn, argsCString, &cl, &status)
+	defer C.free(unsafe.Pointer(retCString))
+	retGoString := C.GoString(retCString)
+
+	if int(status) != C.ENI_SUCCESS {
+		return retGoString, newFault(status)
 	}
+	return retGoString, nil
+}
 
-	return functions, nil
+func newFault(status C.int) *Fault {
+	return &Fault{
+		Code:  int(status),
+		Msg:   C.GoString(C.eni_error_msg(status)),
+		Local: !bool(C.is_libeni_fault(status)),
+	}
+}
+
+// release hands the library of the current operation back to the cache.
+func (eni *ENI) release() {
+	if eni.lib != nil {
+		libHandles.release(eni.lib)
+		eni.lib, eni.gasFunc, eni.runFunc = nil, nil, nil
+	}
 }
@@ -0,0 +1,42 @@
+// +build !cgo
+
+package eni
+
+import "errors"
+
+var errNoCgo = errors.New("native ENI libraries are not supported without cgo")
+
+// ENI is the native ENI backend. Without cgo no native library can be loaded,
+// so every invocation fails.
+type ENI struct{}
+
+// NewENI returns an ENI handler resolving functions against the libraries
+// canonical at the given block number.
+func NewENI(number uint64) *ENI {
+	return &ENI{}
+}
+
+// NewLibraryENI returns an ENI handler resolving functions in the given library
+// only, whether it is installed in the library path or not.
+func NewLibraryENI(lib *Library) *ENI {
+	return &ENI{}
+}
+
+func (eni *ENI) InitENI(eniFunction string, argsText string) error {
+	return errNoCgo
+}
+
+func (eni *ENI) Gas() (uint64, error) {
+	return 0, errNoCgo
+}
+
+func (eni *ENI) ExecuteENI() (string, error) {
+	return "", errNoCgo
+}
+
+// libHandles has nothing to cache without cgo.
+var libHandles handleCache
+
+type handleCache struct{}
+
+func (c *handleCache) evict(paths ...string) {}
@@ -0,0 +1,181 @@
+// +build cgo
+
+package eni
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// limitsLibrary holds ENI functions misbehaving in various ways.
+const limitsLibrary = `
+#include <stdint.h>
+#include <stdlib.h>
+#include <string.h>
+
+int64_t* gas(char* a) { int64_t* g = malloc(sizeof(int64_t)); *g = 1; return g; }
+#define ENI_GAS(fn) int64_t* fn##_gas(char* a) { return gas(a); }
+ENI_GAS(big) ENI_GAS(count) ENI_GAS(hog) ENI_GAS(spin) ENI_GAS(trap)
+
+char big[2 * 1024 * 1024];
+char* big_run(char* a) {
+	memset(big, '1', sizeof(big) - 1);
+	return big;
+}
+char* count_run(char* a) {
+	for (volatile int i = 0; i < 1000; i++) {
+	}
+	return a;
+}
+char* hog_run(char* a) {
+	char* mem = malloc(1024 * 1024 * 1024);
+	if (mem == NULL) {
+		return NULL;
+	}
+	memset(mem, 1, 1024 * 1024 * 1024);
+	return a;
+}
+char* spin_run(char* a) {
+	for (volatile uint64_t i = 0; ; i++) {
+	}
+	return a;
+}
+char* trap_run(char* a) {
+	__builtin_trap();
+	return a;
+}
+`
+
+// buildLimitsLibrary compiles limitsLibrary and resolves the named run
+// functions.
+func buildLimitsLibrary(t *testing.T, functions ...string) (map[string]unsafe.Pointer, func()) {
+	cc, err := exec.LookPath("cc")
+	if err != nil {
+		t.Skip("C compiler not available")
+	}
+	dir, err := ioutil.TempDir("", "eni-limits")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srcPath, libPath := filepath.Join(dir, "limits.c"), filepath.Join(dir, "limits.so")
+	if err := ioutil.WriteFile(srcPath, []byte(limitsLibrary), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command(cc, "-shared", "-fPIC", "-o", libPath, srcPath).CombinedOutput(); err != nil {
+		t.Fatalf("failed to build library: %v\n%s", err, out)
+	}
+	cache := newHandleCache()
+	funcs := make(map[string]unsafe.Pointer)
+	for _, fn := range functions {
+		lib, _, run, err := cache.acquire(libPath, fn)
+		if err != nil {
+			t.Fatalf("failed to resolve %s: %v", fn, err)
+		}
+		cache.release(lib) // kept open until evicted
+		funcs[fn] = run
+	}
+	return funcs, func() {
+		cache.evict(libPath)
+		os.RemoveAll(dir)
+	}
+}
+
+// Tests that exceeding a deterministic limit fails the invocation alike on
+// every node, while hitting the wall clock timeout is a local fault.
+func TestForkLimits(t *testing.T) {
+	funcs, cleanup := buildLimitsLibrary(t, "big", "hog", "spin", "trap")
+	defer cleanup()
+
+	tests := []struct {
+		fn     string
+		limits limits
+		codes  []int
+		local  bool
+	}{
+		{"big", limits{memory: 1 << 26, output: 1 << 20}, []int{codeOutputLimit}, false},
+		{"hog", limits{memory: 1 << 26}, []int{codeNullResult, codeSegfault, codeKilled}, false},
+		{"spin", limits{memory: 1 << 26, timeout: 100 * time.Millisecond}, []int{codeTimeout}, true},
+		{"spin", limits{instructions: 100000, memory: 1 << 26}, []int{codeOutOfGas}, false},
+		{"trap", limits{memory: 1 << 26}, []int{codeFailure}, true}, // signals without a dedicated code
+		{"trap", limits{instructions: 100000, memory: 1 << 26}, []int{codeFailure}, true},
+	}
+	for _, tt := range tests {
+		_, err := forkRun(funcs[tt.fn], "[]", tt.limits)
+		if err == nil {
+			t.Errorf("%s: invocation succeeded", tt.fn)
+			continue
+		}
+		if !containsCode(tt.codes, err.Code) {
+			t.Errorf("%s: error code mismatch: have %d (%s), want one of %v", tt.fn, err.Code, err.Msg, tt.codes)
+		}
+		if err.Local != tt.local {
+			t.Errorf("%s: local fault mismatch: have %v, want %v", tt.fn, err.Local, tt.local)
+		}
+		if IsLocalFault(err) != tt.local {
+			t.Errorf("%s: IsLocalFault mismatch: have %v, want %v", tt.fn, IsLocalFault(err), tt.local)
+		}
+	}
+}
+
+// Tests that a run function may execute exactly the instructions of its budget,
+// whatever the speed of the node, and runs out of gas past them.
+func TestForkInstructionBudget(t *testing.T) {
+	if have, want := runLimits(1000).instructions, 1000*params.ENIInstructionsPerGas; have != want {
+		t.Errorf("budget mismatch: have %d, want %d", have, want)
+	}
+	if have, want := runLimits(math.MaxUint64).instructions, uint64(math.MaxUint64); have != want {
+		t.Errorf("budget mismatch with excessive gas: have %d, want %d", have, want)
+	}
+	funcs, cleanup := buildLimitsLibrary(t, "count", "spin")
+	defer cleanup()
+
+	run := func(fn string, instructions uint64) *Fault {
+		_, err := forkRun(funcs[fn], "[]", limits{instructions: instructions, memory: 1 << 26, timeout: 10 * time.Second})
+		return err
+	}
+	// Find the instructions count executes by bisecting its budget.
+	lo, hi := uint64(1), uint64(1000000)
+	for lo < hi {
+		mid := (lo + hi) / 2
+		switch err := run("count", mid); {
+		case err == nil:
+			hi = mid
+		case err.Code == codeOutOfGas:
+			lo = mid + 1
+		default:
+			t.Fatalf("budget %d: unexpected error: %v", mid, err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		if err := run("count", lo); err != nil {
+			t.Fatalf("run %d: failed within the budget of %d instructions: %v", i, lo, err)
+		}
+		if err := run("count", lo-1); err == nil || err.Code != codeOutOfGas {
+			t.Fatalf("run %d: error mismatch for the budget of %d instructions: have %v, want out of gas", i, lo-1, err)
+		}
+	}
+	err := run("spin", 100000)
+	if err == nil || err.Code != codeOutOfGas {
+		t.Fatalf("error mismatch: have %v, want out of gas", err)
+	}
+	if err.Local {
+		t.Fatal("running out of gas reported as local fault")
+	}
+}
+
+func containsCode(codes []int, code int) bool {
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eni
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// DefaultTimeout is the default wall clock time after which a native ENI
+// invocation is considered stuck.
+const DefaultTimeout = time.Minute
+
+// timeout is the node wide safety net timeout, in nanoseconds.
+var timeout = int64(DefaultTimeout)
+
+// SetTimeout sets the wall clock time after which native ENI invocations are
+// killed, zero disabling the timeout. The instruction budget derived from the
+// gas of an invocation is expected to end it much earlier, so the timeout is a
+// safety net which results in a local fault halting the node rather than in a
+// failed transaction.
+func SetTimeout(d time.Duration) {
+	atomic.StoreInt64(&timeout, int64(d))
+}
+
+// Timeout returns the wall clock time after which native ENI invocations are
+// killed.
+func Timeout() time.Duration {
+	return time.Duration(atomic.LoadInt64(&timeout))
+}
+
+// Fault is the error of a failed native ENI invocation.
+type Fault struct {
+	Code  int    // ENI error code, see fork_call.h, zero if the operation didn't run
+	Op    string // name of the ENI operation
+	Phase string // "lookup", "gas" or "run"
+	Msg   string
+
+	// Local is set for faults of the local node, such as running out of file
+	// descriptors, missing a library or hitting the timeout, which another node
+	// executing the same invocation need not run into. Faults of the ENI operation itself, like a
+	// segmentation fault or an exceeded limit, are the same on every node.
+	Local bool
+}
+
+func (f *Fault) Error() string {
+	return "ENI " + f.Op + " " + f.Phase + " error, msg = " + f.Msg
+}
+
+// IsLocalFault reports whether err is a fault of the local node, which must not
+// be allowed to decide the outcome of a transaction.
+func IsLocalFault(err error) bool {
+	fault, ok := err.(*Fault)
+	return ok && fault.Local
+}
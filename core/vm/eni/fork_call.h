@@ -11,31 +11,37 @@
 #include <fcntl.h>
 #include <time.h>
 #include <sys/prctl.h>
+#include <sys/ptrace.h>
+#include <sys/resource.h>
 #include <sys/syscall.h>
 #include <sys/types.h>
 #include <sys/wait.h>
 #include <sys/epoll.h>
 #include <sys/timerfd.h>
+#include <sys/user.h>
 #include <linux/seccomp.h>
 
-// TODO: Maybe we need to take different measure for two kinds of errors:
-//   1. go-ethereum's fault. ex. no enough fd
-//   2. libeni's fault.
-// And we have different ways to handle them. (ex. kill geth v.s mark the tx as invalid)
-#define ENI_ERROR_CODES(X)                                                    \
-    X(0,    ENI_SUCCESS,       "Success")                                     \
-    X(11,   ENI_FAILURE,       "An unclassified occurred")                    \
-    X(12,   ENI_RESOURCE_BUSY, "Failed to perform some syscalls")             \
-    X(13,   ENI_SECCOMP_FAIL,  "Failed to create sandbox for safe execution") \
-    X(21,   ENI_TLE,           "Execution timeout")                           \
-    X(22,   ENI_KILLED,        "ENI operation got killed")                    \
-    X(23,   ENI_SEGFAULT,      "ENI operation segmentation fault")            \
-    X(24,   ENI_NULL_RESULT,   "ENI operation returns NULL")                  \
+// Codes below 20 are faults of the local node, e.g. it ran out of file
+// descriptors or its safety net timeout expired. They must not fail the
+// transaction, as other nodes may well execute it.
+// Codes from 20 on are faults of the ENI operation itself, which every node
+// runs into alike.
+#define ENI_ERROR_CODES(X)                                                             \
+    X(0,    ENI_SUCCESS,       "Success")                                              \
+    X(11,   ENI_FAILURE,       "An unclassified occurred")                             \
+    X(12,   ENI_RESOURCE_BUSY, "Failed to perform some syscalls")                      \
+    X(13,   ENI_SECCOMP_FAIL,  "Failed to create sandbox for safe execution")          \
+    X(14,   ENI_TLE,           "Execution timeout")                                    \
+    X(15,   ENI_LIMIT_FAIL,    "Failed to enforce resource limits")                    \
+    X(22,   ENI_KILLED,        "ENI operation got killed")                             \
+    X(23,   ENI_SEGFAULT,      "ENI operation segmentation fault")                     \
+    X(24,   ENI_NULL_RESULT,   "ENI operation returns NULL")                           \
+    X(25,   ENI_OUT_OF_GAS,    "ENI operation exceeded its instruction budget")        \
+    X(26,   ENI_OUTPUT_LIMIT,  "ENI operation result exceeds the output limit")        \
 
 #define ENI_ERR_ENUM(ID, NAME, DESC) NAME = ID,
 #define ENI_ERR_TEXT(ID, NAME, TEXT) case ID: return TEXT;
 
-// TODO: Need further refinement about how to classify errors
 bool is_libeni_fault(int code) {
     return code >= 20;
 }
@@ -80,13 +86,47 @@ eni_return_data eni_run_executor(eni_function f, char* arg) {
     return ((func_run)f)(arg);
 }
 
-eni_return_data fork_call(eni_result_length_finder, eni_executor, eni_function f, char* args_text, int *status);
-eni_return_data wait_and_read_from_child(int pid, int pfd, int* eni_status);
-int eni_fork_child(eni_result_length_finder, eni_executor, eni_function f, char* args_text, int pfd);
+// Resources an ENI operation may use. Apart from the timeout, which is a per
+// node safety net, the limits are part of consensus: exceeding them fails the
+// operation the same way on every node.
+typedef struct {
+    uint64_t instructions; // instructions the operation may execute, 0 = unbounded
+    uint64_t memory;       // address space the operation may allocate
+    uint64_t output;       // size of the result, 0 = unbounded
+    uint64_t timeout_ms;   // wall clock time after which the operation is killed, 0 = never
+} eni_limits;
+
+#if defined(__x86_64__) || defined(__i386__)
+// eni_budget_mark is called right before and after the ENI operation in the
+// child, trapping into the parent tracing it, which counts the instructions in
+// between by single-stepping the child. Unlike a timer or a hardware counter,
+// the count is exact and the same on every node, independent of its speed.
+// The mark traps with ud2 rather than a syscall, which the sandbox forbids.
+__asm__(
+    ".text\n"
+    ".type eni_budget_mark, @function\n"
+    "eni_budget_mark:\n"
+    "    ud2\n"
+    "    ret\n"
+);
+void eni_budget_mark(void);
+
+#define ENI_BUDGET_MARK_LEN 2 // length of the ud2 instruction
+#if defined(__x86_64__)
+#define ENI_REG_IP(regs) ((regs).rip)
+#else
+#define ENI_REG_IP(regs) ((regs).eip)
+#endif
+#endif
+
+eni_return_data fork_call(eni_result_length_finder, eni_executor, eni_function f, char* args_text, eni_limits* limits, int *status);
+eni_return_data wait_and_read_from_child(int pid, int pfd, eni_limits* limits, int* eni_status);
+int eni_fork_child(eni_result_length_finder, eni_executor, eni_function f, char* args_text, eni_limits* limits, int pfd);
+int eni_meter_child(int pid, eni_limits* limits, int* child_status, bool* reaped);
 
 // f should be op_gas()
-uint64_t fork_gas(void* f, char *argsText, int* status) {
-    uint64_t* ret = fork_call(gas_result_length, eni_gas_executor, (eni_function)f, argsText, status);
+uint64_t fork_gas(void* f, char *argsText, eni_limits* limits, int* status) {
+    uint64_t* ret = fork_call(gas_result_length, eni_gas_executor, (eni_function)f, argsText, limits, status);
     if (ret == NULL) return 0;
     uint64_t val = *ret;
     free(ret);
@@ -94,8 +134,8 @@ uint64_t fork_gas(void* f, char *argsText, int* status) {
 }
 
 // f should be op_run()
-char* fork_run(void* f, char *argsText, int* status){
-    return (char*) fork_call(run_result_length, eni_run_executor, (eni_function)f, argsText, status);
+char* fork_run(void* f, char *argsText, eni_limits* limits, int* status){
+    return (char*) fork_call(run_result_length, eni_run_executor, (eni_function)f, argsText, limits, status);
 }
 
 eni_return_data fork_call(
@@ -103,6 +143,7 @@ eni_return_data fork_call(
     eni_executor exe,
     eni_function f,
     char* args_text,
+    eni_limits* limits,
     int *status
 )
 {
@@ -121,7 +162,7 @@ eni_return_data fork_call(
     }
 
     int pid;
-    if ((pid = eni_fork_child(get_result_len, exe, f, args_text, pfd[1])) < 0) {
+    if ((pid = eni_fork_child(get_result_len, exe, f, args_text, limits, pfd[1])) < 0) {
         *status = ENI_FAILURE; // failed to fork a child
         close(pfd[0]);
         close(pfd[1]);
@@ -129,18 +170,39 @@ eni_return_data fork_call(
     }
     close(pfd[1]);
 
+    // The child writes its result only once it ran the operation within its
+    // instruction budget, or died trying.
+    int child_status;
+    bool reaped = false;
+    if (limits->instructions > 0) {
+        int eni_meter_status = eni_meter_child(pid, limits, &child_status, &reaped);
+        if (eni_meter_status != ENI_SUCCESS) {
+            if (!reaped) {
+                kill(pid, SIGKILL);
+                waitpid(pid, NULL, 0);
+            }
+            close(pfd[0]);
+            *status = eni_meter_status;
+            return NULL;
+        }
+    }
+
     int eni_read_status = ENI_SUCCESS;
-    eni_return_data child_exe_result = wait_and_read_from_child(pid, pfd[0], &eni_read_status);
+    eni_return_data child_exe_result = wait_and_read_from_child(pid, pfd[0], limits, &eni_read_status);
     close(pfd[0]);
 
     if (!child_exe_result) {
         assert(eni_read_status != ENI_SUCCESS);
+        // reap the child, which may still be running if reading its result failed
+        if (!reaped) {
+            kill(pid, SIGKILL);
+            waitpid(pid, NULL, 0);
+        }
         *status = eni_read_status;
         return NULL;
     }
 
-    int child_status;
-    while (true) {
+    while (!reaped) {
         pid_t waitpid_result = waitpid(pid, &child_status, WNOHANG);
         if (waitpid_result == -1) {
             *status = ENI_FAILURE;
@@ -153,7 +215,7 @@ eni_return_data fork_call(
             fprintf(stderr, "ENI Warning: Child not fully terminated yet, retrying...\n");
         }
         else {
-            break;
+            reaped = true;
         }
     }
 
@@ -211,14 +273,111 @@ int set_up_sandbox(int pipefd) {
     return 0;
 }
 
-// create a fd that will be avaliable to be read after 3 seconds
-int create_eni_timerfd() {
+// Caps the address space of the calling process at its current size plus
+// `memory` bytes. The current size is inherited from the node, so only the
+// allowance on top of it is the same on every node.
+int set_memory_limit(uint64_t memory) {
+    int fd = open("/proc/self/statm", O_RDONLY);
+    if (fd == -1)
+        return ENI_LIMIT_FAIL;
+    char buf[64];
+    memset(buf, 0, sizeof(buf));
+    int nread = read(fd, buf, sizeof(buf)-1);
+    close(fd);
+    if (nread <= 0)
+        return ENI_LIMIT_FAIL;
+
+    uint64_t pages = strtoull(buf, NULL, 10);
+    struct rlimit limit;
+    limit.rlim_cur = limit.rlim_max = pages * sysconf(_SC_PAGESIZE) + memory;
+    if (setrlimit(RLIMIT_AS, &limit) == -1)
+        return ENI_LIMIT_FAIL;
+    return 0;
+}
+
+#ifdef ENI_BUDGET_MARK_LEN
+// Reports whether the stopped child trapped at eni_budget_mark, and if so lets
+// it continue past the trap.
+// @return 1 if it did, 0 if it didn't, -1 if its registers are inaccessible
+int eni_pass_budget_mark(int pid) {
+    struct user_regs_struct regs;
+    if (ptrace(PTRACE_GETREGS, pid, NULL, &regs) == -1)
+        return -1;
+    if (ENI_REG_IP(regs) != (uintptr_t)eni_budget_mark)
+        return 0;
+    ENI_REG_IP(regs) += ENI_BUDGET_MARK_LEN;
+    if (ptrace(PTRACE_SETREGS, pid, NULL, &regs) == -1)
+        return -1;
+    return 1;
+}
+
+uint64_t eni_elapsed_ms(struct timespec* start) {
+    struct timespec now;
+    clock_gettime(CLOCK_MONOTONIC, &now);
+    return (now.tv_sec - start->tv_sec) * 1000 + (now.tv_nsec - start->tv_nsec) / 1000000;
+}
+
+// Single-steps the traced child from its first budget mark to its second, and
+// detaches from it there, or as soon as it receives a signal of its own, which
+// is delivered to it untraced. If the child terminates meanwhile, it is reaped
+// and its wait status stored in `child_status`.
+// @return ENI_OUT_OF_GAS if the child executed more instructions than its
+// budget, which it is left stopped at
+int eni_meter_child(int pid, eni_limits* limits, int* child_status, bool* reaped) {
+    struct timespec start;
+    clock_gettime(CLOCK_MONOTONIC, &start);
+
+    uint64_t executed = 0;
+    bool metering = false;
+    while (true) {
+        int wstatus;
+        if (waitpid(pid, &wstatus, 0) == -1)
+            return ENI_FAILURE;
+        if (WIFEXITED(wstatus) || WIFSIGNALED(wstatus)) {
+            *child_status = wstatus;
+            *reaped = true;
+            return ENI_SUCCESS;
+        }
+        int sig = WSTOPSIG(wstatus);
+        if (sig == SIGTRAP && metering) {
+            // single-stepped one instruction
+            if (++executed > limits->instructions)
+                return ENI_OUT_OF_GAS;
+            if (limits->timeout_ms > 0 && executed % 4096 == 0 && eni_elapsed_ms(&start) >= limits->timeout_ms)
+                return ENI_TLE;
+        }
+        else {
+            int mark = sig == SIGILL ? eni_pass_budget_mark(pid) : 0;
+            if (mark == -1)
+                return ENI_LIMIT_FAIL;
+            if (mark == 0 || metering) {
+                // the operation is done or faulted by itself
+                if (ptrace(PTRACE_DETACH, pid, NULL, (void*)(long)(mark ? 0 : sig)) == -1)
+                    return ENI_LIMIT_FAIL;
+                return ENI_SUCCESS;
+            }
+            metering = true;
+        }
+        if (ptrace(PTRACE_SINGLESTEP, pid, NULL, NULL) == -1)
+            return ENI_LIMIT_FAIL;
+    }
+}
+#else
+// Instruction budgets are only implemented for x86.
+int eni_meter_child(int pid, eni_limits* limits, int* child_status, bool* reaped) {
+    return ENI_LIMIT_FAIL;
+}
+#endif
+
+// create a fd that will be avaliable to be read after the given timeout
+int create_eni_timerfd(uint64_t timeout_ms) {
     int tfd = timerfd_create(CLOCK_MONOTONIC, 0);
     if (tfd == -1)
         return -1;
     struct itimerspec timeout_value;
     memset(&timeout_value, 0, sizeof(timeout_value));
-    timeout_value.it_value.tv_sec = 3;
+    timeout_value.it_value.tv_sec = timeout_ms / 1000;
+    timeout_value.it_value.tv_nsec = (timeout_ms % 1000) * 1000000;
     if (timerfd_settime(tfd, 0, &timeout_value, NULL) == -1)
         return -1;
     return tfd;
@@ -228,13 +387,13 @@ int create_eni_timerfd() {
 // @param eni_status When the returned pointer is NULL, `eni_status` will be set to corresponding error code.
 // This function will keep trying to read from `pfd`,
 // until timeout reached or `pfd` reached EOF (EOF implies another end of the pipe was closed)
-eni_return_data wait_and_read_from_child(int pid, int pfd, int* eni_status) {
+eni_return_data wait_and_read_from_child(int pid, int pfd, eni_limits* limits, int* eni_status) {
     int ret_len = 0, ret_cap = 32;
     eni_return_data ret = malloc(ret_cap);
     if (!ret) goto unclassified_error;
 
     int tfd = -1, epfd = -1;
-    if ((tfd = create_eni_timerfd()) == -1)
+    if (limits->timeout_ms > 0 && (tfd = create_eni_timerfd(limits->timeout_ms)) == -1)
         goto unclassified_error;
     if ((epfd = epoll_create1(0)) == -1)
         goto unclassified_error;
@@ -247,11 +406,13 @@ eni_return_data wait_and_read_from_child(int pid, int pfd, int* eni_status) {
     if (epoll_ctl(epfd, EPOLL_CTL_ADD, pfd, &epev))
         goto unclassified_error;
 
-    memset(&epev, 0, sizeof(struct epoll_event));
-    epev.events = EPOLLIN;
-    epev.data.fd = tfd;
-    if (epoll_ctl(epfd, EPOLL_CTL_ADD, tfd, &epev))
-        goto unclassified_error;
+    if (tfd != -1) {
+        memset(&epev, 0, sizeof(struct epoll_event));
+        epev.events = EPOLLIN;
+        epev.data.fd = tfd;
+        if (epoll_ctl(epfd, EPOLL_CTL_ADD, tfd, &epev))
+            goto unclassified_error;
+    }
 
 /*
   Success
@@ -263,7 +424,7 @@ eni_return_data wait_and_read_from_child(int pid, int pfd, int* eni_status) {
          |    ^               +---+------+--+
          |    |                   |      |
      EOF |    +-------------------+      | tfd triggered
-         |        pfd triggered          |
+         |        pfd triggered          | (if a timeout is set)
          v                               v
      return ret;                      ENI_TLE
 */
@@ -279,6 +440,11 @@ eni_return_data wait_and_read_from_child(int pid, int pfd, int* eni_status) {
         int nread = read(pfd, ret + ret_len, ret_cap - ret_len);
         if (nread > 0) { // Success
             ret_len += nread;
+            if (limits->output > 0 && ret_len > limits->output) {
+                kill(pid, SIGKILL); // it is this functions's caller's responsibility to waitpid
+                *eni_status = ENI_OUTPUT_LIMIT;
+                goto error;
+            }
             continue;
         }
         else if (nread == 0) { // EOF
@@ -318,6 +484,7 @@ int eni_fork_child(
     eni_executor exe,
     eni_function f,
     char* args_text,
+    eni_limits* limits,
     int pfd
 )
 {
@@ -326,16 +493,38 @@ int eni_fork_child(
 
     // child process' code starts from here
 
+    // The node's signal handlers are inherited and would run into the sandbox,
+    // restore the default actions so that the child dies of its own signals.
+    int sig;
+    for (sig = 1; sig < NSIG; sig++)
+        signal(sig, SIG_DFL); // fails for SIGKILL and SIGSTOP, which are fine
+    sigset_t all;
+    sigfillset(&all);
+    sigprocmask(SIG_UNBLOCK, &all, NULL);
+
     int errnum;
+    if ((errnum = set_memory_limit(limits->memory)) != ENI_SUCCESS)
+        exit(errnum);
+    bool metered = limits->instructions > 0;
+    if (metered && ptrace(PTRACE_TRACEME, 0, NULL, NULL) == -1)
+        exit(ENI_LIMIT_FAIL);
     if ((errnum = set_up_sandbox(pfd)) != ENI_SUCCESS)
         exit(errnum);
+#ifdef ENI_BUDGET_MARK_LEN
+    if (metered)
+        eni_budget_mark();
+    void* result = exe(f, args_text);
+    if (metered)
+        eni_budget_mark();
+#else
     void* result = exe(f, args_text);
+#endif
     if (!result)
         syscall(SYS_exit, ENI_NULL_RESULT);
     int tot_write = 0;
     int len = get_result_len(result);
     while (tot_write < len) {
-        int nwrite = write(pfd, result, len-tot_write);
+        int nwrite = write(pfd, (char*)result + tot_write, len-tot_write);
         if (nwrite <= 0)
             syscall(SYS_exit, ENI_RESOURCE_BUSY);
         tot_write += nwrite;
@@ -5,23 +5,35 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ethereum/go-ethereum/node"
 )
 
 type OTAInfo struct {
-	LibName  string
-	Version  string   // The format of version should be vX.Y.Z where X, Y, Z are all integers. E.g. v1.0.0, v3.2.0
-	Url      []string // URL to retrieve the library file
-	Checksum string   // SHA512 checksum to check the health of the library
+	LibName  string   `json:"libName"`
+	Version  string   `json:"version"`  // The format of version should be vX.Y.Z where X, Y, Z are all integers. E.g. v1.0.0, v3.2.0
+	Url      []string `json:"url"`      // URL to retrieve the library file
+	Checksum string   `json:"checksum"` // SHA512 checksum to check the health of the library
 }
 
+// downloadTimeout bounds the download of a single library, so that a stalled
+// mirror can't block the upgrade service from shutting down.
+const downloadTimeout = 5 * time.Minute
+
+var downloadClient = &http.Client{Timeout: downloadTimeout}
+
+// libReg matches library file names of the form name_vX.Y.Z.so.
+var libReg = regexp.MustCompile(`\A[A-Za-z][A-Za-z_]*[A-Za-z]_v\d+\.\d+\.\d+\.so\z`)
+
 type OTAInstance struct {
 	enableInfos    map[string]OTAInfo
 	libPath        string
@@ -65,8 +77,8 @@ func initializeLibDir() {
 		return
 	}
 
-	os.Mkdir(filepath.Join(libPath, "staging"), 0644)
-	os.Mkdir(filepath.Join(libPath, "retired"), 0644)
+	os.Mkdir(filepath.Join(libPath, "staging"), 0755)
+	os.Mkdir(filepath.Join(libPath, "retired"), 0755)
 }
 
 func NewVersion() *Version {
@@ -150,20 +162,10 @@ func (ota *OTAInstance) IsValidNewLib(info OTAInfo) (bool, error) {
 
 // Verify downloaded staging libraries.
 func (ota *OTAInstance) verify(info OTAInfo) (err error) {
-	libFile, err := os.Open(filepath.Join(
-		ota.stagingLibPath,
-		generateFileName(info)))
+	checksum, err := Checksum(filepath.Join(ota.stagingLibPath, generateFileName(info)))
 	if err != nil {
 		return err
 	}
-	defer libFile.Close()
-
-	hasher := sha512.New()
-	if _, err := io.Copy(hasher, libFile); err != nil {
-		return err
-	}
-
-	checksum := fmt.Sprintf("%x", hasher.Sum(nil))
 	if checksum != info.Checksum {
 		os.Remove(filepath.Join(
 			ota.stagingLibPath,
@@ -173,6 +175,38 @@ func (ota *OTAInstance) verify(info OTAInfo) (err error) {
 	return nil
 }
 
+// Checksum returns the SHA512 checksum of the library at path, as announced in
+// the OTAInfo of its upgrade.
+func Checksum(path string) (string, error) {
+	libFile, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer libFile.Close()
+
+	hasher := sha512.New()
+	if _, err := io.Copy(hasher, libFile); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", hasher.Sum(nil)), nil
+}
+
+// NewOTAInfo describes the library at path, downloadable from the given URLs,
+// for an over-the-air upgrade.
+func NewOTAInfo(path string, urls []string) (OTAInfo, error) {
+	info, ok := parseFileName(filepath.Base(path))
+	if !ok {
+		return OTAInfo{}, fmt.Errorf("invalid ENI library file name %s, want name_vX.Y.Z.so", filepath.Base(path))
+	}
+	checksum, err := Checksum(path)
+	if err != nil {
+		return OTAInfo{}, err
+	}
+	info.Url = append(info.Url, urls...)
+	info.Checksum = checksum
+	return info, nil
+}
+
 // Register staging libraries to lib.
 func (ota *OTAInstance) Register(info OTAInfo) (err error) {
 	// If there is an old version, move it to retired folder.
@@ -200,6 +234,39 @@ func (ota *OTAInstance) Register(info OTAInfo) (err error) {
 	return nil
 }
 
+// Enable moves a staged library into lib, retiring the version it replaces,
+// and reloads the registry. The libraries are linked into their new folder
+// before the registry is reloaded and removed from the old one only after,
+// so that a lookup never resolves to a library that was moved away.
+func (ota *OTAInstance) Enable(info OTAInfo, registry *Registry) error {
+	var moves [][2]string
+	if originInfo, exist := ota.enableInfos[info.LibName]; exist {
+		moves = append(moves, [2]string{
+			filepath.Join(ota.libPath, generateFileName(originInfo)),
+			filepath.Join(ota.retiredLibPath, generateFileName(originInfo)),
+		})
+	}
+	moves = append(moves, [2]string{
+		filepath.Join(ota.stagingLibPath, generateFileName(info)),
+		filepath.Join(ota.libPath, generateFileName(info)),
+	})
+	for i, move := range moves {
+		if err := os.Link(move[0], move[1]); err != nil {
+			for _, done := range moves[:i] {
+				os.Remove(done[1])
+			}
+			return err
+		}
+	}
+	ota.enableInfos[info.LibName] = info
+
+	err := registry.Reload()
+	for _, move := range moves {
+		os.Remove(move[0])
+	}
+	return err
+}
+
 // Remove unused libraries from lib, staging, and retired folder
 func (ota *OTAInstance) Destroy(info OTAInfo) (err error) {
 	err = removeLibrary(ota.libPath, info)
@@ -278,7 +345,7 @@ func (ota *OTAInstance) downloadFromUrl(fileName, url string) (err error) {
 	}
 	defer output.Close()
 
-	response, err := http.Get(url)
+	response, err := downloadClient.Get(url)
 	if err != nil {
 		os.Remove(fileName)
 		return
@@ -296,39 +363,84 @@ func (ota *OTAInstance) downloadFromUrl(fileName, url string) (err error) {
 
 // Load existed libraries from ENI library path.
 func (ota *OTAInstance) loadExistedLib() {
-	var dynamicLibs []string
+	for _, info := range listLibraries(ota.libPath) {
+		// Load local libraries into enable info list.
+		ota.enableInfos[info.LibName] = info
+	}
+}
+
+// EnabledLibraries returns the libraries currently enabled in the library path.
+func (ota *OTAInstance) EnabledLibraries() []OTAInfo {
+	infos := make([]OTAInfo, 0, len(ota.enableInfos))
+	for _, info := range ota.enableInfos {
+		infos = append(infos, info)
+	}
+	sortLibraries(infos)
+	return infos
+}
+
+// StagingLibraries returns the libraries downloaded but not yet registered.
+func (ota *OTAInstance) StagingLibraries() []OTAInfo {
+	return listLibraries(ota.stagingLibPath)
+}
 
-	// Get all dynamic libraries from library path.
-	filepath.Walk(ota.libPath, func(path string, fileinfo os.FileInfo, err error) error {
-		if fileinfo.IsDir() && (path == ota.retiredLibPath || path == ota.stagingLibPath) {
-			return filepath.SkipDir
+// RetiredLibraries returns the libraries replaced by a newer version.
+func (ota *OTAInstance) RetiredLibraries() []OTAInfo {
+	return listLibraries(ota.retiredLibPath)
+}
+
+// listLibraries returns the well-named libraries placed directly in dir,
+// sorted by name and version.
+func listLibraries(dir string) []OTAInfo {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var infos []OTAInfo
+	for _, file := range files {
+		if !file.Mode().IsRegular() {
+			continue
 		}
-		if fileinfo.Mode().IsRegular() && filepath.Ext(path) == ".so" {
-			dynamicLibs = append(dynamicLibs, fileinfo.Name())
+		if info, ok := parseFileName(file.Name()); ok {
+			infos = append(infos, info)
 		}
-		return nil
-	})
+	}
+	sortLibraries(infos)
+	return infos
+}
 
-	// Library name format examples:
-	//	reverse_v1.0.0.so
-	//	eni_rsa_v1.5.9.so
-	//	eni_scrypt_v9.10.11.so
-	libReg := regexp.MustCompile(`\A[A-Za-z][A-Za-z_]*[A-Za-z]_v\d+\.\d+\.\d+\.so\z`)
-	for _, lib := range dynamicLibs {
-		if libReg.MatchString(lib) {
-			// Parse LibName and Version from the file name.
-			libSlice := strings.Split(lib, "_")
-			libName := strings.Join(libSlice[:len(libSlice)-1], "_")
-			versionSlice := strings.Split(libSlice[len(libSlice)-1], ".")
-			version := strings.Join(versionSlice[:len(versionSlice)-1], ".")
-			info := OTAInfo{
-				LibName:  libName,
-				Version:  version,
-				Url:      []string{},
-				Checksum: "",
-			}
-			// Load local libraries into enable info list.
-			ota.enableInfos[info.LibName] = info
-		}
+// parseFileName extracts LibName and Version from a library file name.
+//
+// Library name format examples:
+//
+//	reverse_v1.0.0.so
+//	eni_rsa_v1.5.9.so
+//	eni_scrypt_v9.10.11.so
+func parseFileName(fileName string) (OTAInfo, bool) {
+	if !libReg.MatchString(fileName) {
+		return OTAInfo{}, false
 	}
+	libSlice := strings.Split(fileName, "_")
+	libName := strings.Join(libSlice[:len(libSlice)-1], "_")
+	versionSlice := strings.Split(libSlice[len(libSlice)-1], ".")
+	version := strings.Join(versionSlice[:len(versionSlice)-1], ".")
+	return OTAInfo{
+		LibName:  libName,
+		Version:  version,
+		Url:      []string{},
+		Checksum: "",
+	}, true
+}
+
+// sortLibraries orders infos by LibName and then by ascending Version.
+func sortLibraries(infos []OTAInfo) {
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].LibName != infos[j].LibName {
+			return infos[i].LibName < infos[j].LibName
+		}
+		vi, vj := NewVersion(), NewVersion()
+		vi.BuildFromString(infos[i].Version)
+		vj.BuildFromString(infos[j].Version)
+		return vi.Compare(*vj) < 0
+	})
 }
@@ -0,0 +1,559 @@
+package eni
+
+import (
+	"debug/elf"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// manifestName is the file under the ENI library path recording from which
+// block each library version is canonical.
+const manifestName = "versions.json"
+
+var (
+	errUnknownFunction = errors.New("ENI function not found")
+	errScheduleOrder   = errors.New("ENI activation must follow the last scheduled one")
+)
+
+// Library is a single version of an ENI dynamic library.
+type Library struct {
+	Name    string
+	Version string
+	Path    string
+	Symbols []string // exported ENI symbols, i.e. *_gas and *_run functions
+}
+
+// Activation records the block from which a library version is canonical.
+type Activation struct {
+	Version string `json:"version"`
+	Block   uint64 `json:"block"`
+}
+
+// Registry resolves ENI functions to the library version canonical at a given
+// block number, so that every node executes, and replays, a block against the
+// same native code regardless of which versions are installed locally.
+//
+// The activation schedule is read from the manifest in the library path.
+// Libraries without any scheduled activation are never canonical, installing a
+// library locally doesn't change the code any block executes.
+type Registry struct {
+	libPath    string
+	manifestMu sync.Mutex // serialises manifest updates
+
+	mu       sync.RWMutex
+	schedule map[string][]Activation        // activations per library, ordered by block
+	libs     map[string]*Library            // every known library keyed by file name
+	tables   map[uint64]map[string]*Library // symbol tables keyed by epoch start block
+	missing  map[uint64][]string            // canonical libraries not installed, keyed by epoch start block
+}
+
+var (
+	defaultRegistry   *Registry
+	defaultRegistryMu sync.Mutex
+)
+
+// Activator enables the library versions due at a block before any of its
+// transactions execute.
+type Activator interface {
+	// Activate enables the library versions due at the given block number. It
+	// fails if a version due isn't available locally, in which case the block
+	// must not be processed.
+	Activate(number uint64) error
+
+	// Ready reports whether the library versions due at the given block number
+	// are available locally, without enabling them.
+	Ready(number uint64) error
+}
+
+var (
+	activators   = make(map[int]Activator)
+	activatorID  int
+	activatorsMu sync.RWMutex
+)
+
+// SubscribeActivation registers an activator run by Activate for every block
+// about to be imported. The returned function removes it again.
+func SubscribeActivation(a Activator) func() {
+	activatorsMu.Lock()
+	defer activatorsMu.Unlock()
+
+	id := activatorID
+	activatorID++
+	activators[id] = a
+
+	return func() {
+		activatorsMu.Lock()
+		defer activatorsMu.Unlock()
+		delete(activators, id)
+	}
+}
+
+// Activate runs the subscribed activators for the block about to be imported,
+// so that upgrades due at number are in place before the block executes. The
+// first activator failure is returned.
+func Activate(number uint64) error {
+	activatorsMu.RLock()
+	defer activatorsMu.RUnlock()
+
+	var err error
+	for _, a := range activators {
+		if aerr := a.Activate(number); aerr != nil && err == nil {
+			err = aerr
+		}
+	}
+	return err
+}
+
+// Ready reports whether the upgrades due at number are available locally, so
+// that a block of that number may be built.
+func Ready(number uint64) error {
+	activatorsMu.RLock()
+	defer activatorsMu.RUnlock()
+
+	for _, a := range activators {
+		if err := a.Ready(number); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DefaultRegistry returns the process wide registry over the ENI library path.
+func DefaultRegistry() (*Registry, error) {
+	defaultRegistryMu.Lock()
+	defer defaultRegistryMu.Unlock()
+
+	libPath, err := getLibPath()
+	if err != nil {
+		return nil, err
+	}
+	if defaultRegistry != nil && defaultRegistry.libPath == libPath {
+		return defaultRegistry, nil
+	}
+	registry, err := NewRegistry(libPath)
+	if err != nil {
+		return nil, err
+	}
+	defaultRegistry = registry
+	return registry, nil
+}
+
+// NewRegistry creates a registry over the libraries in libPath, including the
+// staging and retired subdirectories.
+func NewRegistry(libPath string) (*Registry, error) {
+	r := &Registry{libPath: libPath}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload rescans the library path and the activation manifest. A library
+// exporting a symbol already exported by another library canonical at the same
+// height is left out of the symbol table of that height.
+func (r *Registry) Reload() error {
+	r.manifestMu.Lock()
+	defer r.manifestMu.Unlock()
+
+	schedule, err := readManifest(filepath.Join(r.libPath, manifestName))
+	if err != nil {
+		return err
+	}
+	_, err = r.load(schedule)
+	return err
+}
+
+// load rebuilds the library and symbol tables for the given schedule. Within
+// an epoch, the library activated first keeps the symbols it exports, and any
+// library activated later exporting one of them is rejected as a whole. The
+// rejected libraries are returned.
+func (r *Registry) load(schedule map[string][]Activation) ([]*symbolConflict, error) {
+	libs := make(map[string]*Library)
+	// Later directories take precedence over earlier ones. A library being
+	// enabled or retired is present in both its old and its new directory,
+	// the new one wins.
+	for _, dir := range []string{
+		filepath.Join(r.libPath, "staging"),
+		r.libPath,
+		filepath.Join(r.libPath, "retired"),
+	} {
+		for _, info := range listLibraries(dir) {
+			libs[generateFileName(info)] = &Library{
+				Name:    info.LibName,
+				Version: info.Version,
+				Path:    filepath.Join(dir, generateFileName(info)),
+			}
+		}
+	}
+	// Build the symbol table of every epoch, rejecting duplicates.
+	var (
+		tables    = make(map[uint64]map[string]*Library)
+		missing   = make(map[uint64][]string)
+		conflicts []*symbolConflict
+	)
+	for _, start := range epochs(schedule) {
+		table := make(map[string]*Library)
+		for _, name := range activationOrder(schedule, start) {
+			version, _ := canonical(schedule[name], start)
+			file := generateFileName(OTAInfo{LibName: name, Version: version})
+			lib := libs[file]
+			if lib == nil {
+				// Not installed locally, lookups in this epoch fail as a
+				// local fault, other nodes may well have the library.
+				missing[start] = append(missing[start], file)
+				continue
+			}
+			if lib.Symbols == nil {
+				symbols, err := readSymbols(lib.Path)
+				if err != nil {
+					return nil, err
+				}
+				lib.Symbols = symbols
+			}
+			var conflict *symbolConflict
+			for _, symbol := range lib.Symbols {
+				if other, ok := table[symbol]; ok {
+					conflict = &symbolConflict{symbol: symbol, lib: lib, other: other, block: start}
+					break
+				}
+			}
+			if conflict != nil {
+				log.Warn("Rejected conflicting ENI library", "err", conflict)
+				conflicts = append(conflicts, conflict)
+				continue
+			}
+			for _, symbol := range lib.Symbols {
+				table[symbol] = lib
+			}
+		}
+		tables[start] = table
+	}
+
+	r.mu.Lock()
+	var stale []string
+	for file, lib := range r.libs {
+		if cur, ok := libs[file]; !ok || cur.Path != lib.Path {
+			stale = append(stale, lib.Path)
+		}
+	}
+	r.schedule, r.libs, r.tables, r.missing = schedule, libs, tables, missing
+	r.mu.Unlock()
+
+	// Close libraries moved or removed by an upgrade.
+	libHandles.evict(stale...)
+	return conflicts, nil
+}
+
+// symbolConflict is a library rejected from an epoch for exporting a symbol
+// of another library canonical in the same epoch.
+type symbolConflict struct {
+	symbol     string
+	lib, other *Library
+	block      uint64
+}
+
+func (c *symbolConflict) Error() string {
+	return fmt.Sprintf("ENI symbol %s of %s_%s already exported by %s_%s at block %d",
+		c.symbol, c.lib.Name, c.lib.Version, c.other.Name, c.other.Version, c.block)
+}
+
+// Schedule makes the given library version canonical from the given block on
+// and persists the activation in the manifest. Scheduling the same activation
+// twice is a no-op. An activation making the library conflict with another one
+// is refused.
+func (r *Registry) Schedule(name, version string, block uint64) error {
+	r.manifestMu.Lock()
+	defer r.manifestMu.Unlock()
+
+	manifestPath := filepath.Join(r.libPath, manifestName)
+	schedule, err := readManifest(manifestPath)
+	if err != nil {
+		return err
+	}
+	acts := schedule[name]
+	if len(acts) > 0 {
+		last := acts[len(acts)-1]
+		if last.Version == version && last.Block == block {
+			return nil
+		}
+		if last.Block >= block {
+			return errScheduleOrder
+		}
+	}
+	updated := copySchedule(schedule)
+	updated[name] = append(updated[name], Activation{Version: version, Block: block})
+
+	conflicts, err := r.load(updated)
+	if err != nil {
+		return err
+	}
+	for _, conflict := range conflicts {
+		if conflict.lib.Name == name && conflict.lib.Version == version {
+			// Restore the tables of the schedule in force.
+			r.load(schedule)
+			return conflict
+		}
+	}
+	return writeManifest(manifestPath, updated)
+}
+
+// Unschedule drops a pending activation of the given library version.
+func (r *Registry) Unschedule(name, version string, block uint64) error {
+	r.manifestMu.Lock()
+	defer r.manifestMu.Unlock()
+
+	manifestPath := filepath.Join(r.libPath, manifestName)
+	schedule, err := readManifest(manifestPath)
+	if err != nil {
+		return err
+	}
+	var acts []Activation
+	for _, act := range schedule[name] {
+		if act.Version != version || act.Block != block {
+			acts = append(acts, act)
+		}
+	}
+	if len(acts) == 0 {
+		delete(schedule, name)
+	} else {
+		schedule[name] = acts
+	}
+	if _, err := r.load(schedule); err != nil {
+		return err
+	}
+	return writeManifest(manifestPath, schedule)
+}
+
+// Activations returns the activation schedule of the named library.
+func (r *Registry) Activations(name string) []Activation {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]Activation(nil), r.schedule[name]...)
+}
+
+// Canonical returns the version of the named library in use at block number.
+func (r *Registry) Canonical(name string, number uint64) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return canonical(r.schedule[name], number)
+}
+
+// Lookup returns the library exporting symbol at block number. A symbol not
+// found while a library canonical at number isn't installed locally is a local
+// fault, as the missing library may export it.
+func (r *Registry) Lookup(symbol string, number uint64) (*Library, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	start := epochStart(r.schedule, number)
+	if lib, ok := r.tables[start][symbol]; ok {
+		return lib, nil
+	}
+	if missing := r.missing[start]; len(missing) > 0 {
+		return nil, &Fault{
+			Op:    symbol,
+			Phase: "lookup",
+			Msg:   fmt.Sprintf("%s not installed at block %d", strings.Join(missing, ", "), number),
+			Local: true,
+		}
+	}
+	return nil, fmt.Errorf("%v: %s at block %d", errUnknownFunction, symbol, number)
+}
+
+// Libraries returns the libraries canonical at block number.
+func (r *Registry) Libraries(number uint64) []*Library {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var libs []*Library
+	for _, name := range sortedNames(r.schedule) {
+		if version, ok := canonical(r.schedule[name], number); ok {
+			if lib := r.libs[generateFileName(OTAInfo{LibName: name, Version: version})]; lib != nil {
+				libs = append(libs, lib)
+			}
+		}
+	}
+	return libs
+}
+
+// OpenLibrary reads the ENI library at path, which must be named after the
+// library and its version like the libraries in the library path are.
+func OpenLibrary(path string) (*Library, error) {
+	info, ok := parseFileName(filepath.Base(path))
+	if !ok {
+		return nil, fmt.Errorf("invalid ENI library file name %s, want name_vX.Y.Z.so", filepath.Base(path))
+	}
+	symbols, err := readSymbols(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Library{Name: info.LibName, Version: info.Version, Path: path, Symbols: symbols}, nil
+}
+
+// Functions splits the symbols of the library into the ENI functions it
+// exports, those with both a gas and a run function, and the symbols missing
+// their counterpart. Both are sorted.
+func (lib *Library) Functions() (functions []string, unpaired []string) {
+	exported := make(map[string]bool, len(lib.Symbols))
+	for _, symbol := range lib.Symbols {
+		exported[symbol] = true
+	}
+	for _, symbol := range lib.Symbols {
+		name := strings.TrimSuffix(strings.TrimSuffix(symbol, "_gas"), "_run")
+		switch {
+		case !exported[name+"_gas"] || !exported[name+"_run"]:
+			unpaired = append(unpaired, symbol)
+		case strings.HasSuffix(symbol, "_gas"):
+			functions = append(functions, name)
+		}
+	}
+	sort.Strings(functions)
+	sort.Strings(unpaired)
+	return functions, unpaired
+}
+
+// canonical returns the version activated last at or before number.
+func canonical(acts []Activation, number uint64) (string, bool) {
+	if act, ok := activation(acts, number); ok {
+		return act.Version, true
+	}
+	return "", false
+}
+
+// activation returns the activation last at or before number.
+func activation(acts []Activation, number uint64) (Activation, bool) {
+	for i := len(acts) - 1; i >= 0; i-- {
+		if acts[i].Block <= number {
+			return acts[i], true
+		}
+	}
+	return Activation{}, false
+}
+
+// activationOrder returns the libraries canonical at number, ordered by the
+// activation of their canonical version and then by name.
+func activationOrder(schedule map[string][]Activation, number uint64) []string {
+	var (
+		names  []string
+		blocks = make(map[string]uint64)
+	)
+	for _, name := range sortedNames(schedule) {
+		if act, ok := activation(schedule[name], number); ok {
+			names = append(names, name)
+			blocks[name] = act.Block
+		}
+	}
+	sort.SliceStable(names, func(i, j int) bool { return blocks[names[i]] < blocks[names[j]] })
+	return names
+}
+
+// epochs returns the distinct activation blocks of a schedule in ascending
+// order. The symbol table only changes at these heights.
+func epochs(schedule map[string][]Activation) []uint64 {
+	seen := map[uint64]bool{0: true}
+	starts := []uint64{0}
+	for _, acts := range schedule {
+		for _, act := range acts {
+			if !seen[act.Block] {
+				seen[act.Block] = true
+				starts = append(starts, act.Block)
+			}
+		}
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+	return starts
+}
+
+// epochStart returns the start of the epoch containing number.
+func epochStart(schedule map[string][]Activation, number uint64) uint64 {
+	start := uint64(0)
+	for _, acts := range schedule {
+		for _, act := range acts {
+			if act.Block <= number && act.Block > start {
+				start = act.Block
+			}
+		}
+	}
+	return start
+}
+
+func sortedNames(schedule map[string][]Activation) []string {
+	names := make([]string, 0, len(schedule))
+	for name := range schedule {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func copySchedule(schedule map[string][]Activation) map[string][]Activation {
+	cpy := make(map[string][]Activation, len(schedule))
+	for name, acts := range schedule {
+		cpy[name] = append([]Activation(nil), acts...)
+	}
+	return cpy
+}
+
+// readSymbols returns the ENI functions defined by a dynamic library.
+func readSymbols(path string) ([]string, error) {
+	file, err := elf.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	symbols, err := file.DynamicSymbols()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, symbol := range symbols {
+		if symbol.Section == elf.SHN_UNDEF || elf.ST_TYPE(symbol.Info) != elf.STT_FUNC {
+			continue // imported or not a function
+		}
+		if strings.HasSuffix(symbol.Name, "_gas") || strings.HasSuffix(symbol.Name, "_run") {
+			names = append(names, symbol.Name)
+		}
+	}
+	return names, nil
+}
+
+func readManifest(path string) (map[string][]Activation, error) {
+	schedule := make(map[string][]Activation)
+	blob, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return schedule, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(blob, &schedule); err != nil {
+		return nil, fmt.Errorf("invalid ENI manifest %s: %v", path, err)
+	}
+	for name, acts := range schedule {
+		sort.SliceStable(acts, func(i, j int) bool { return acts[i].Block < acts[j].Block })
+		schedule[name] = acts
+	}
+	return schedule, nil
+}
+
+func writeManifest(path string, schedule map[string][]Activation) error {
+	blob, err := json.MarshalIndent(schedule, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path+".new", blob, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".new", path)
+}
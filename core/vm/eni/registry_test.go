@@ -0,0 +1,247 @@
+package eni
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// buildLibrary compiles a shared library exporting an ENI function for each
+// of the given names into dir/file.
+func buildLibrary(t *testing.T, dir, file string, functions ...string) {
+	cc, err := exec.LookPath("cc")
+	if err != nil {
+		t.Skip("C compiler not available")
+	}
+	var src strings.Builder
+	src.WriteString("#include <stdint.h>\n#include <stdlib.h>\n")
+	for _, fn := range functions {
+		fmt.Fprintf(&src, "int64_t* %s_gas(char* a) { int64_t* g = malloc(sizeof(int64_t)); *g = 1; return g; }\n", fn)
+		fmt.Fprintf(&src, "char* %s_run(char* a) { return a; }\n", fn)
+	}
+	srcPath := filepath.Join(dir, file+".c")
+	if err := ioutil.WriteFile(srcPath, []byte(src.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(srcPath)
+
+	if out, err := exec.Command(cc, "-shared", "-fPIC", "-o", filepath.Join(dir, file), srcPath).CombinedOutput(); err != nil {
+		t.Fatalf("failed to build %s: %v\n%s", file, err, out)
+	}
+}
+
+func newTestLibPath(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "eni-registry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sub := range []string{"staging", "retired"} {
+		if err := os.Mkdir(filepath.Join(dir, sub), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// Tests that functions resolve to the library version canonical at the
+// requested height, including versions still waiting in staging.
+func TestRegistryVersionPinning(t *testing.T) {
+	dir := newTestLibPath(t)
+	defer os.RemoveAll(dir)
+
+	buildLibrary(t, dir, "eni_crypto_v1.0.0.so", "sha", "aes")
+	buildLibrary(t, filepath.Join(dir, "staging"), "eni_crypto_v1.1.0.so", "sha", "aes", "rsa")
+
+	registry, err := NewRegistry(dir)
+	if err != nil {
+		t.Fatalf("failed to create registry: %v", err)
+	}
+	// Installed but unscheduled libraries must not resolve.
+	if version, ok := registry.Canonical("eni_crypto", 100); ok {
+		t.Fatalf("unscheduled library canonical in version %s", version)
+	}
+	if lib, err := registry.Lookup("sha_gas", 100); err == nil {
+		t.Fatalf("unscheduled library resolved to %s", lib.Version)
+	}
+	if err := registry.Schedule("eni_crypto", "v1.0.0", 0); err != nil {
+		t.Fatalf("failed to schedule library: %v", err)
+	}
+	if err := registry.Schedule("eni_crypto", "v1.1.0", 50); err != nil {
+		t.Fatalf("failed to schedule upgrade: %v", err)
+	}
+	tests := []struct {
+		symbol  string
+		number  uint64
+		version string
+	}{
+		{"sha_gas", 0, "v1.0.0"},
+		{"sha_run", 49, "v1.0.0"},
+		{"sha_gas", 50, "v1.1.0"},
+		{"rsa_run", 1000, "v1.1.0"},
+		{"rsa_gas", 49, ""},
+		{"missing_gas", 1000, ""},
+	}
+	for i, tt := range tests {
+		lib, err := registry.Lookup(tt.symbol, tt.number)
+		switch {
+		case tt.version == "" && err == nil:
+			t.Errorf("test %d: %s at %d resolved to %s, want failure", i, tt.symbol, tt.number, lib.Version)
+		case tt.version != "" && err != nil:
+			t.Errorf("test %d: %s at %d failed: %v", i, tt.symbol, tt.number, err)
+		case tt.version != "" && lib.Version != tt.version:
+			t.Errorf("test %d: %s at %d resolved to %s, want %s", i, tt.symbol, tt.number, lib.Version, tt.version)
+		}
+	}
+	// The schedule must survive a restart.
+	reloaded, err := NewRegistry(dir)
+	if err != nil {
+		t.Fatalf("failed to reload registry: %v", err)
+	}
+	if version, _ := reloaded.Canonical("eni_crypto", 49); version != "v1.0.0" {
+		t.Errorf("reloaded version at 49: have %s, want v1.0.0", version)
+	}
+	if version, _ := reloaded.Canonical("eni_crypto", 50); version != "v1.1.0" {
+		t.Errorf("reloaded version at 50: have %s, want v1.1.0", version)
+	}
+	if err := reloaded.Schedule("eni_crypto", "v1.2.0", 50); err != errScheduleOrder {
+		t.Errorf("out of order activation: have %v, want %v", err, errScheduleOrder)
+	}
+	if err := reloaded.Unschedule("eni_crypto", "v1.1.0", 50); err != nil {
+		t.Fatalf("failed to unschedule: %v", err)
+	}
+	if version, _ := reloaded.Canonical("eni_crypto", 1000); version != "v1.0.0" {
+		t.Errorf("version after unschedule: have %s, want v1.0.0", version)
+	}
+}
+
+// Tests that lookups failing while a canonical library isn't installed locally
+// are local faults, and that they are ordinary failures otherwise.
+func TestRegistryMissingLibrary(t *testing.T) {
+	dir := newTestLibPath(t)
+	defer os.RemoveAll(dir)
+
+	buildLibrary(t, dir, "eni_crypto_v1.0.0.so", "sha")
+
+	registry, err := NewRegistry(dir)
+	if err != nil {
+		t.Fatalf("failed to create registry: %v", err)
+	}
+	if err := registry.Schedule("eni_crypto", "v1.0.0", 0); err != nil {
+		t.Fatalf("failed to schedule library: %v", err)
+	}
+	if err := registry.Schedule("eni_crypto", "v1.1.0", 50); err != nil {
+		t.Fatalf("failed to schedule missing upgrade: %v", err)
+	}
+	tests := []struct {
+		symbol string
+		number uint64
+		local  bool
+	}{
+		{"rsa_gas", 49, false},
+		{"rsa_gas", 50, true},
+		{"sha_gas", 50, true},
+	}
+	for i, tt := range tests {
+		_, err := registry.Lookup(tt.symbol, tt.number)
+		if err == nil {
+			t.Fatalf("test %d: %s at %d resolved", i, tt.symbol, tt.number)
+		}
+		if IsLocalFault(err) != tt.local {
+			t.Errorf("test %d: %s at %d local fault mismatch: have %v, want %v", i, tt.symbol, tt.number, IsLocalFault(err), tt.local)
+		}
+	}
+}
+
+// Tests that enabling a staged library never leaves the registry pointing to
+// a moved library.
+func TestRegistryEnable(t *testing.T) {
+	dir := newTestLibPath(t)
+	defer os.RemoveAll(dir)
+	os.Setenv("ENI_LIBRARY_PATH", dir)
+
+	buildLibrary(t, dir, "eni_crypto_v1.0.0.so", "sha")
+	buildLibrary(t, filepath.Join(dir, "staging"), "eni_crypto_v1.1.0.so", "sha")
+
+	registry, err := NewRegistry(dir)
+	if err != nil {
+		t.Fatalf("failed to create registry: %v", err)
+	}
+	if err := registry.Schedule("eni_crypto", "v1.0.0", 0); err != nil {
+		t.Fatalf("failed to schedule library: %v", err)
+	}
+	if err := registry.Schedule("eni_crypto", "v1.1.0", 10); err != nil {
+		t.Fatalf("failed to schedule upgrade: %v", err)
+	}
+	ota := NewOTAInstance()
+	if err := ota.Enable(OTAInfo{LibName: "eni_crypto", Version: "v1.1.0"}, registry); err != nil {
+		t.Fatalf("failed to enable library: %v", err)
+	}
+	for _, tt := range []struct {
+		number uint64
+		path   string
+	}{
+		{9, filepath.Join(dir, "retired", "eni_crypto_v1.0.0.so")},
+		{10, filepath.Join(dir, "eni_crypto_v1.1.0.so")},
+	} {
+		lib, err := registry.Lookup("sha_run", tt.number)
+		if err != nil {
+			t.Fatalf("lookup at %d failed: %v", tt.number, err)
+		}
+		if lib.Path != tt.path {
+			t.Errorf("path at %d: have %s, want %s", tt.number, lib.Path, tt.path)
+		}
+		if _, err := os.Stat(lib.Path); err != nil {
+			t.Errorf("library at %d missing: %v", tt.number, err)
+		}
+	}
+	for _, moved := range []string{filepath.Join(dir, "eni_crypto_v1.0.0.so"), filepath.Join(dir, "staging", "eni_crypto_v1.1.0.so")} {
+		if _, err := os.Stat(moved); !os.IsNotExist(err) {
+			t.Errorf("library %s not moved away", moved)
+		}
+	}
+}
+
+// Tests that a library exporting a symbol of another library canonical at the
+// same height is rejected, without affecting the other libraries.
+func TestRegistryDuplicateSymbols(t *testing.T) {
+	dir := newTestLibPath(t)
+	defer os.RemoveAll(dir)
+
+	buildLibrary(t, dir, "eni_foo_v1.0.0.so", "hash")
+	buildLibrary(t, filepath.Join(dir, "staging"), "eni_bar_v1.0.0.so", "hash", "mac")
+
+	registry, err := NewRegistry(dir)
+	if err != nil {
+		t.Fatalf("failed to create registry: %v", err)
+	}
+	if err := registry.Schedule("eni_foo", "v1.0.0", 0); err != nil {
+		t.Fatalf("failed to schedule library: %v", err)
+	}
+	if err := registry.Schedule("eni_bar", "v1.0.0", 10); err == nil {
+		t.Fatal("conflicting library scheduled")
+	}
+	if lib, err := registry.Lookup("hash_gas", 10); err != nil || lib.Name != "eni_foo" {
+		t.Fatalf("failed schedule altered resolution: %v %v", lib, err)
+	}
+	// A conflicting manifest only rejects the library activated last.
+	manifest := map[string][]Activation{
+		"eni_foo": {{Version: "v1.0.0", Block: 0}},
+		"eni_bar": {{Version: "v1.0.0", Block: 10}},
+	}
+	if err := writeManifest(filepath.Join(dir, manifestName), manifest); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Reload(); err != nil {
+		t.Fatalf("registry failed to load conflicting manifest: %v", err)
+	}
+	if lib, err := registry.Lookup("hash_run", 20); err != nil || lib.Name != "eni_foo" {
+		t.Errorf("conflicting symbol resolution: %v %v", lib, err)
+	}
+	if lib, err := registry.Lookup("mac_run", 20); err == nil {
+		t.Errorf("symbol of rejected library resolved to %s", lib.Name)
+	}
+}
@@ -2,6 +2,7 @@ package eni
 
 import (
 	"bytes"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"math/big"
@@ -16,7 +17,7 @@ func ConvertReturnValue(typeInfo []byte, jsonStr string) (ret []byte, err error)
 	var cvt *retConverter
 	defer func() {
 		if r := recover(); r != nil {
-			err = errors.New("Return Parser Error: " + r.(string))
+			err = errors.New(fmt.Sprint("Return Parser Error: ", r))
 		}
 	}()
 	json := []byte(jsonStr)
@@ -47,6 +48,10 @@ func (cvt *retConverter) parseType(typeInfo []byte, data *bytes.Buffer, json []b
 			typeInfo, json = cvt.parseStruct(typeInfo, data, json)
 		} else if t == STRING {
 			typeInfo, json = cvt.parseString(typeInfo, data, json)
+		} else if t == BYTES {
+			typeInfo, json = cvt.parseBytes(typeInfo, data, json)
+		} else {
+			panic(fmt.Sprintf("encoding error - type cannot be returned: %d", t))
 		}
 	} else { // value type
 		typeInfo, json = cvt.parseValue(typeInfo, data, json)
@@ -79,46 +84,76 @@ func (cvt *retConverter) parseString(typeInfo []byte, data *bytes.Buffer, json [
 	return typeInfo, json
 }
 
+func (cvt *retConverter) parseBytes(typeInfo []byte, data *bytes.Buffer, json []byte) ([]byte, []byte) {
+	typeInfo = typeInfo[1:] // bytes
+	b := cvt.parseHex(&json)
+	length := int64(len(b))
+
+	data.Write(math.PaddedBigBytes(big.NewInt(length), 32))
+	data.Write(b)
+	if length%32 > 0 {
+		data.Write(make([]byte, 32-length%32))
+	}
+	return typeInfo, json
+}
+
 func (cvt *retConverter) parseDynArray(typeInfo []byte, data *bytes.Buffer, json []byte) ([]byte, []byte) {
-	// TODO
-	panic("dynamic array not implemented yet!")
+	typeInfo = typeInfo[1:] // dyn_array_start
+	cvt.skipWS(&json)
+	cvt.expect(&json, '[')
+
+	// The length precedes the elements, so buffer them while counting.
+	var elems bytes.Buffer
+	length := int64(0)
+	cvt.skipWS(&json)
+	for json[0] != ']' {
+		if length > 0 {
+			cvt.expect(&json, ',')
+		}
+		_, json = cvt.parseType(typeInfo, &elems, json)
+		cvt.skipWS(&json)
+		length++
+	}
+	cvt.expect(&json, ']')
+
+	data.Write(math.PaddedBigBytes(big.NewInt(length), 32))
+	data.Write(elems.Bytes())
+	return skipType(typeInfo), json
 }
 
 func (cvt *retConverter) parseFixArray(typeInfo []byte, data *bytes.Buffer, json []byte) ([]byte, []byte) {
 	typeInfo = typeInfo[1:] // fix_array_start
 	cvt.skipWS(&json)
 	cvt.expect(&json, '[')
-	leng := new(big.Int).SetBytes(typeInfo[:32]).Int64()
+	leng := new(big.Int).SetBytes(typeInfo[:32])
+	if !leng.IsInt64() || leng.Int64() > int64(len(json)) {
+		panic("encoding error - array length exceeds data")
+	}
 	typeInfo = typeInfo[32:]
 
-	for i := int64(0); i < leng; i++ {
+	for i := int64(0); i < leng.Int64(); i++ {
 		if i > 0 {
 			cvt.skipWS(&json)
 			cvt.expect(&json, ',')
 		}
-		if i == leng-1 {
-			typeInfo, json = cvt.parseType(typeInfo, data, json)
-		} else {
-			_, json = cvt.parseType(typeInfo, data, json)
-		}
+		_, json = cvt.parseType(typeInfo, data, json)
 	}
 
 	cvt.skipWS(&json)
 	cvt.expect(&json, ']')
-	return typeInfo, json
+	return skipType(typeInfo), json
 }
 
 func (cvt *retConverter) parseStruct(typeInfo []byte, data *bytes.Buffer, json []byte) ([]byte, []byte) {
+	typeInfo = typeInfo[1:] // struct_start
 	cvt.skipWS(&json)
 	cvt.expect(&json, '[')
-	for i := 0; ; i++ {
-		t := typeInfo[0]
-		cvt.skipWS(&json)
-		cvt.expect(&json, ',')
-		if t != STRUCT_END {
-			typeInfo, json = cvt.parseType(typeInfo, data, json)
-			break
+	for i := 0; typeInfo[0] != STRUCT_END; i++ {
+		if i > 0 {
+			cvt.skipWS(&json)
+			cvt.expect(&json, ',')
 		}
+		typeInfo, json = cvt.parseType(typeInfo, data, json)
 	}
 	typeInfo = typeInfo[1:] // struct_end
 	cvt.skipWS(&json)
@@ -160,15 +195,8 @@ func (cvt *retConverter) parseValue(typeInfo []byte, data *bytes.Buffer, json []
 		// two's complement
 		n := new(big.Int)
 		n.SetString(string(ojson[0:i]), 10)
-		b := math.PaddedBigBytes(n, 32)
-		for i := 0; i < 32; i++ {
-			b[i] ^= byte(255)
-		}
-		n.SetBytes(b)
-		n.Add(n, big.NewInt(int64(1)))
-		b = math.PaddedBigBytes(n, 32)
-		data.Write(b)
-	} else if IsUint(t) { // unsigned integer
+		data.Write(math.PaddedBigBytes(math.U256(n), 32))
+	} else if IsUint(t) || t == ENUM { // unsigned integer
 		i := 0
 		ojson := json
 		for cvt.haveDigit(&json) {
@@ -179,8 +207,18 @@ func (cvt *retConverter) parseValue(typeInfo []byte, data *bytes.Buffer, json []
 		}
 		var n big.Int
 		n.SetString(string(ojson[0:i]), 10)
+		if t == ENUM && n.BitLen() > 8 {
+			panic(fmt.Sprintf("enum value out of range: %v", &n))
+		}
 		b := math.PaddedBigBytes(&n, 32)
 		data.Write(b)
+	} else if t == ADDRESS {
+		b := cvt.parseHex(&json)
+		if len(b) != 20 {
+			panic(fmt.Sprintf("expected 20-byte address, found %d bytes", len(b)))
+		}
+		data.Write(make([]byte, 12))
+		data.Write(b)
 	} else {
 		panic(fmt.Sprintf("encoding error - unknown or not implemented type: %d", t))
 	}
@@ -188,6 +226,24 @@ func (cvt *retConverter) parseValue(typeInfo []byte, data *bytes.Buffer, json []
 	return typeInfo, json
 }
 
+// parseHex parses a 0x-prefixed hex string.
+func (cvt *retConverter) parseHex(json *[]byte) []byte {
+	cvt.skipWS(json)
+	cvt.expect(json, '"')
+	cvt.expect(json, '0')
+	cvt.expect(json, 'x')
+	end := bytes.IndexByte(*json, '"')
+	if end < 0 {
+		panic("unterminated hex string")
+	}
+	b, err := hex.DecodeString(string((*json)[:end]))
+	if err != nil {
+		panic(fmt.Sprintf("invalid hex string: %v", err))
+	}
+	*json = (*json)[end+1:]
+	return b
+}
+
 func (cvt *retConverter) parseEscape(json *[]byte) (ch byte) {
 	if (*json)[1] == '\\' || (*json)[1] == '"' {
 		ch = (*json)[1]
@@ -66,6 +66,38 @@ func ExampleConvertReturnValue_fixArray() {
 	// Output: [255 255 255 255 255 255 255 255 255 255 255 255 255 255 255 255 255 255 255 255 255 255 255 255 255 255 255 255 255 255 255 133]
 }
 
+func ExampleConvertReturnValue_dynArray() {
+	f := [2]byte{DYN_ARRAY_START, INT}
+	d, err := ConvertReturnValue(f[:], "[[-1, 2]]")
+
+	retPrintOrError(d, err)
+	// Output: [0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 2 255 255 255 255 255 255 255 255 255 255 255 255 255 255 255 255 255 255 255 255 255 255 255 255 255 255 255 255 255 255 255 255 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 2]
+}
+
+func ExampleConvertReturnValue_bytes() {
+	f := [1]byte{BYTES}
+	d, err := ConvertReturnValue(f[:], "[\"0x0a0b\"]")
+
+	retPrintOrError(d, err)
+	// Output: [0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 2 10 11 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0]
+}
+
+func ExampleConvertReturnValue_errorAddress() {
+	f := [1]byte{ADDRESS}
+	d, err := ConvertReturnValue(f[:], "[\"0x0a0b\"]")
+
+	retPrintOrError(d, err)
+	// Output: Return Parser Error: expected 20-byte address, found 2 bytes
+}
+
+func ExampleConvertReturnValue_errorEnum() {
+	f := [1]byte{ENUM}
+	d, err := ConvertReturnValue(f[:], "[256]")
+
+	retPrintOrError(d, err)
+	// Output: Return Parser Error: enum value out of range: 256
+}
+
 func ExampleConvertReturnValue_error1() {
 	f := [1]byte{INT}
 	d, err := ConvertReturnValue(f[:], "-123")
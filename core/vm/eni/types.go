@@ -0,0 +1,98 @@
+package eni
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// TypeName returns the Solidity name of a token of the type format, e.g.
+// "uint8" for UINT8. Tokens delimiting arrays and structs have no name.
+func TypeName(code byte) string {
+	switch {
+	case code == BOOL:
+		return "bool"
+	case code == ADDRESS:
+		return "address"
+	case code == BYTES:
+		return "bytes"
+	case code == ENUM:
+		return "enum"
+	case code == STRING:
+		return "string"
+	case code == STRINGPTR:
+		return "stringptr"
+	case code == INT:
+		return "int"
+	case INT8 <= code && code <= INT256:
+		return "int" + strconv.Itoa(8*int(code-INT8+1))
+	case code == UINT:
+		return "uint"
+	case UINT8 <= code && code <= UINT256:
+		return "uint" + strconv.Itoa(8*int(code-UINT8+1))
+	case BYTE1 <= code && code <= BYTE32:
+		return "bytes" + strconv.Itoa(int(code-BYTE1+1))
+	}
+	return ""
+}
+
+// TypeCode is the inverse of TypeName, it returns the token of a value, string
+// or bytes type.
+func TypeCode(name string) (byte, bool) {
+	for code := byte(BOOL); code <= STRINGPTR; code++ {
+		if n := TypeName(code); n != "" && n == name {
+			return code, true
+		}
+	}
+	return 0, false
+}
+
+// DescribeTypes decodes type information into the Solidity names of the
+// types, with arrays as "T[n]" or "T[]" and structs as "(T1,T2)".
+func DescribeTypes(typeInfo []byte) (types []string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			types, err = nil, errors.New(fmt.Sprint("Type Parser Error: ", r))
+		}
+	}()
+	for len(typeInfo) > 0 {
+		var name string
+		name, typeInfo = describeType(typeInfo)
+		types = append(types, name)
+	}
+	return types, nil
+}
+
+// describeType names the type at the head of typeInfo and returns typeInfo
+// past it.
+func describeType(typeInfo []byte) (string, []byte) {
+	switch t := typeInfo[0]; t {
+	case FIX_ARRAY_START:
+		leng := new(big.Int).SetBytes(typeInfo[1:33])
+		elem, rest := describeType(typeInfo[33:])
+		return fmt.Sprintf("%s[%v]", elem, leng), rest
+	case DYN_ARRAY_START:
+		elem, rest := describeType(typeInfo[1:])
+		return elem + "[]", rest
+	case STRUCT_START:
+		var fields []string
+		typeInfo = typeInfo[1:]
+		for len(typeInfo) > 0 && typeInfo[0] != STRUCT_END {
+			var field string
+			field, typeInfo = describeType(typeInfo)
+			fields = append(fields, field)
+		}
+		if len(typeInfo) == 0 {
+			panic("encoding error - expected struct_end token")
+		}
+		return "(" + strings.Join(fields, ",") + ")", typeInfo[1:]
+	default:
+		name := TypeName(t)
+		if name == "" {
+			panic(fmt.Sprintf("encoding error - unknown or not implemented type: %d", t))
+		}
+		return name, typeInfo[1:]
+	}
+}
@@ -0,0 +1,57 @@
+package eni
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTypeNames(t *testing.T) {
+	names := map[byte]string{
+		BOOL: "bool", ADDRESS: "address", STRING: "string", INT: "int", INT8: "int8",
+		INT256: "int256", UINT: "uint", UINT64: "uint64", UINT256: "uint256", BYTE1: "bytes1",
+		BYTE32: "bytes32", FIX_ARRAY_START: "", STRUCT_END: "",
+	}
+	for code, name := range names {
+		if have := TypeName(code); have != name {
+			t.Errorf("%d: name mismatch: have %q, want %q", code, have, name)
+		}
+		if name == "" {
+			continue
+		}
+		if have, ok := TypeCode(name); !ok || have != code {
+			t.Errorf("%s: code mismatch: have %d, want %d", name, have, code)
+		}
+	}
+	if _, ok := TypeCode("uint7"); ok {
+		t.Error("expected unknown type name to be rejected")
+	}
+}
+
+func TestDescribeTypes(t *testing.T) {
+	fixArray := append([]byte{FIX_ARRAY_START}, make([]byte, 32)...)
+	fixArray[32] = 3
+	fixArray = append(fixArray, UINT8)
+
+	tests := []struct {
+		info  []byte
+		types []string
+	}{
+		{[]byte{UINT, STRING, BOOL}, []string{"uint", "string", "bool"}},
+		{fixArray, []string{"uint8[3]"}},
+		{[]byte{DYN_ARRAY_START, DYN_ARRAY_START, ADDRESS}, []string{"address[][]"}},
+		{[]byte{STRUCT_START, INT, DYN_ARRAY_START, BYTE4, STRUCT_END, BYTES}, []string{"(int,bytes4[])", "bytes"}},
+	}
+	for i, tt := range tests {
+		types, err := DescribeTypes(tt.info)
+		if err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+		} else if !reflect.DeepEqual(types, tt.types) {
+			t.Errorf("test %d: types mismatch: have %v, want %v", i, types, tt.types)
+		}
+	}
+	for i, info := range [][]byte{{STRUCT_START, INT}, {FIX_ARRAY_START, 1}, {STRINGPTR + 1}} {
+		if _, err := DescribeTypes(info); err == nil {
+			t.Errorf("malformed %d: expected error", i)
+		}
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/schedule"
 	"github.com/ethereum/go-ethereum/core/vm/eni"
 	"github.com/ethereum/go-ethereum/core/vm/umbrella"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -41,6 +42,9 @@ type (
 	// GetHashFunc returns the nth block hash in the blockchain
 	// and is used by the BLOCKHASH EVM op code.
 	GetHashFunc func(uint64) common.Hash
+	// GetValidatorsFunc returns the validator set of the nth block
+	// and is used by the ISVALIDATOR EVM op code.
+	GetValidatorsFunc func(uint64) umbrella.ValidatorSet
 )
 
 // run runs the given contract and takes care of running precompiles with a fallback to the byte code interpreter.
@@ -53,6 +57,9 @@ func run(evm *EVM, contract *Contract, input []byte) ([]byte, error) {
 		if p := precompiles[*contract.CodeAddr]; p != nil {
 			return RunPrecompiledContract(p, input, contract)
 		}
+		if *contract.CodeAddr == schedule.QueueAddress && evm.ChainConfig().IsSchedule(evm.BlockNumber) {
+			return runScheduleQueue(evm, contract, input)
+		}
 	}
 	for _, interpreter := range evm.interpreters {
 		if interpreter.CanRun(contract.Code) {
@@ -70,6 +77,25 @@ func run(evm *EVM, contract *Contract, input []byte) ([]byte, error) {
 	return nil, ErrNoCompatibleInterpreter
 }
 
+// runScheduleQueue services calls into the schedule queue account, which only
+// accepts cancellations of pending entries by their sender or receiver.
+func runScheduleQueue(evm *EVM, contract *Contract, input []byte) ([]byte, error) {
+	id, ok := schedule.ParseCancelInput(input)
+	if !ok || contract.Address() != schedule.QueueAddress || contract.Value().Sign() != 0 {
+		return nil, ErrExecutionReverted
+	}
+	if !contract.UseGas(params.ScheduleCancelGas) {
+		return nil, ErrOutOfGas
+	}
+	if evm.interpreter.IsReadOnly() {
+		return nil, errWriteProtection
+	}
+	if err := schedule.Cancel(evm.StateDB, id, contract.Caller()); err != nil {
+		return nil, ErrExecutionReverted
+	}
+	return nil, nil
+}
+
 // Context provides the EVM with auxiliary information. Once provided
 // it shouldn't be modified.
 type Context struct {
@@ -80,6 +106,8 @@ type Context struct {
 	Transfer TransferFunc
 	// GetHash returns the hash corresponding to n
 	GetHash GetHashFunc
+	// GetValidators returns the validator set of the block n
+	GetValidators GetValidatorsFunc
 
 	// Message information
 	Origin   common.Address // Provides information for ORIGIN
@@ -91,6 +119,7 @@ type Context struct {
 	BlockNumber *big.Int       // Provides information for NUMBER
 	Time        *big.Int       // Provides information for TIME
 	Difficulty  *big.Int       // Provides information for DIFFICULTY
+	Beacon      common.Hash    // Provides the randomness beacon mixed into RAND, see core.BlockBeacon
 
 	// Travis database helper component
 	Umbrella umbrella.Umbrella
@@ -128,7 +157,9 @@ type EVM struct {
 	// NOTE: must be set atomically
 	abort int32
 	// ethereum native interface handler
-	eni *eni.ENI
+	eni eni.ENIBackend
+	// eniCall is the ENI invocation in flight, recorded for the tracer
+	eniCall *ENILog
 	// umbrella is a hendler to communacate with Travis database
 	umbrella umbrella.Umbrella
 	// callGasTemp holds the gas available for the current call. This is needed because the
@@ -142,9 +173,39 @@ type EVM struct {
 	randomNumberCounter uint64
 }
 
+// umbrellaValidatorsFn returns a GetValidatorsFunc asking the umbrella for the
+// validator sets, indexing each of them once.
+func umbrellaValidatorsFn(umb umbrella.Umbrella) GetValidatorsFunc {
+	var cache map[uint64]umbrella.ValidatorSet
+
+	return func(n uint64) umbrella.ValidatorSet {
+		if set, ok := cache[n]; ok {
+			return set
+		}
+		if cache == nil {
+			cache = make(map[uint64]umbrella.ValidatorSet)
+		}
+		set := umbrella.NewValidatorSet(umb.GetValidatorsAt(new(big.Int).SetUint64(n)))
+		cache[n] = set
+		return set
+	}
+}
+
 // NewEVM returns a new EVM. The returned EVM is not thread safe and should
 // only ever be used *once*.
 func NewEVM(ctx Context, statedb StateDB, chainConfig *params.ChainConfig, vmConfig Config) *EVM {
+	// ENI functions are resolved against the libraries canonical at this block.
+	var number uint64
+	if ctx.BlockNumber != nil {
+		number = ctx.BlockNumber.Uint64()
+	}
+	// Outside of Travis, the Lity opcodes are served by the built-in umbrella.
+	if ctx.Umbrella == nil {
+		ctx.Umbrella = umbrella.NewStandalone(chainConfig.Umbrella)
+	}
+	if ctx.GetValidators == nil {
+		ctx.GetValidators = umbrellaValidatorsFn(ctx.Umbrella)
+	}
 	evm := &EVM{
 		Context:             ctx,
 		StateDB:             statedb,
@@ -152,13 +213,20 @@ func NewEVM(ctx Context, statedb StateDB, chainConfig *params.ChainConfig, vmCon
 		chainConfig:         chainConfig,
 		chainRules:          chainConfig.Rules(ctx.BlockNumber),
 		interpreters:        make([]Interpreter, 1),
-		eni:                 eni.NewENI(),
 		umbrella:            ctx.Umbrella,
 		freegas:             0,
 		randomNumberCounter: 0,
 	}
 
-	if len(os.Getenv("EVMC_PATH")) != 0 {
+	// Every EVM gets a backend of its own, backends hold the invocation in
+	// flight.
+	if vmConfig.ENI != nil {
+		evm.eni = vmConfig.ENI.NewBackend(number)
+	} else {
+		evm.eni = eni.NewENI(number)
+	}
+
+	if len(os.Getenv("EVMC_PATH")) != 0 && evmcSupports(evm.chainRules) {
 		evm.interpreters[0] = NewEVMC(evm)
 	} else {
 		evm.interpreters[0] = NewEVMInterpreter(evm, vmConfig)
@@ -191,6 +259,27 @@ func (evm *EVM) IncreaseRandomNumberCounter() uint64 {
 	return evm.randomNumberCounter
 }
 
+// ENI returns the backend serving the ENI invocations of this EVM.
+func (evm *EVM) ENI() eni.ENIBackend {
+	return evm.eni
+}
+
+// traceENI hands the ENI invocation in flight over to the tracer, if tracing.
+func (evm *EVM) traceENI(ret string, err error) {
+	if !evm.vmConfig.Debug || evm.eniCall == nil {
+		return
+	}
+	call := evm.eniCall
+	evm.eniCall = nil
+
+	if err != nil {
+		call.Error = err.Error()
+	} else {
+		call.Return = ret
+	}
+	evm.vmConfig.Tracer.CaptureENI(evm, call)
+}
+
 // Cancel cancels any running EVM operation. This may be called concurrently and
 // it's safe to be called multiple times.
 func (evm *EVM) Cancel() {
@@ -263,7 +352,7 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 	// when we're in homestead this also counts for code storage gas errors.
 	if err != nil {
 		evm.StateDB.RevertToSnapshot(snapshot)
-		if err != errExecutionReverted && err != errENIExecutionError {
+		if err != ErrExecutionReverted && err != errENIExecutionError {
 			contract.UseGas(contract.Gas)
 		}
 	}
@@ -304,7 +393,7 @@ func (evm *EVM) CallCode(caller ContractRef, addr common.Address, input []byte,
 	ret, err = run(evm, contract, input)
 	if err != nil {
 		evm.StateDB.RevertToSnapshot(snapshot)
-		if err != errExecutionReverted {
+		if err != ErrExecutionReverted {
 			contract.UseGas(contract.Gas)
 		}
 	}
@@ -337,7 +426,7 @@ func (evm *EVM) DelegateCall(caller ContractRef, addr common.Address, input []by
 	ret, err = run(evm, contract, input)
 	if err != nil {
 		evm.StateDB.RevertToSnapshot(snapshot)
-		if err != errExecutionReverted {
+		if err != ErrExecutionReverted {
 			contract.UseGas(contract.Gas)
 		}
 	}
@@ -380,7 +469,7 @@ func (evm *EVM) StaticCall(caller ContractRef, addr common.Address, input []byte
 	ret, err = run(evm, contract, input)
 	if err != nil {
 		evm.StateDB.RevertToSnapshot(snapshot)
-		if err != errExecutionReverted {
+		if err != ErrExecutionReverted {
 			contract.UseGas(contract.Gas)
 		}
 	}
@@ -420,6 +509,7 @@ func (evm *EVM) Create(caller ContractRef, code []byte, gas uint64, value *big.I
 	// only.
 	contract := NewContract(caller, AccountRef(contractAddr), value, gas)
 	contract.SetCallCode(&contractAddr, crypto.Keccak256Hash(code), code)
+	contract.IsCreate = true
 
 	if evm.vmConfig.NoRecursion && evm.depth > 0 {
 		return nil, contractAddr, gas, nil
@@ -452,7 +542,7 @@ func (evm *EVM) Create(caller ContractRef, code []byte, gas uint64, value *big.I
 	// when we're in homestead this also counts for code storage gas errors.
 	if maxCodeSizeExceeded || (err != nil && (evm.ChainConfig().IsHomestead(evm.BlockNumber) || err != ErrCodeStoreOutOfGas)) {
 		evm.StateDB.RevertToSnapshot(snapshot)
-		if err != errExecutionReverted && err != errENIExecutionError {
+		if err != ErrExecutionReverted && err != errENIExecutionError {
 			contract.UseGas(contract.Gas)
 		}
 	}
@@ -23,9 +23,11 @@ import (
 	"strings"
 	"sync"
 
-	"github.com/ethereum/evmc/bindings/go/evmc"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm/eni"
+	"github.com/ethereum/go-ethereum/core/vm/evmc"
+	"github.com/ethereum/go-ethereum/core/vm/umbrella"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/params"
@@ -40,6 +42,8 @@ type EVMC struct {
 var (
 	createMu     sync.Mutex
 	evmcInstance *evmc.Instance
+	evmcLity     bool // whether the VM supports the Lity extension
+	evmcLityWarn sync.Once
 )
 
 func createVM() *evmc.Instance {
@@ -71,18 +75,35 @@ func createVM() *evmc.Instance {
 				}
 			}
 		}
+		evmcLity = evmcInstance.EnableLity()
+		log.Info("EVMC VM Lity extension negotiated", "supported", evmcLity)
 	}
 	return evmcInstance
 }
 
+// evmcSupports reports whether the EVMC VM can execute under the given rules.
+// The Lity instructions are only served by VMs supporting the Lity extension,
+// the built-in interpreter is used in place of any other VM.
+func evmcSupports(rules params.Rules) bool {
+	createVM()
+	if rules.IsLity && !evmcLity {
+		evmcLityWarn.Do(func() {
+			log.Warn("EVMC VM lacks the Lity extension, using the built-in interpreter")
+		})
+		return false
+	}
+	return true
+}
+
 func NewEVMC(env *EVM) *EVMC {
 	return &EVMC{createVM(), env, false}
 }
 
-// Implements evmc.HostContext interface.
+// Implements evmc.LityHostContext interface.
 type HostContext struct {
 	env      *EVM
 	contract *Contract
+	readOnly bool
 }
 
 func (host *HostContext) AccountExists(addr common.Address) bool {
@@ -217,7 +238,7 @@ func (host *HostContext) Call(kind evmc.CallKind,
 		if !isHomestead && err == ErrCodeStoreOutOfGas {
 			err = nil
 		}
-		if err == errExecutionReverted {
+		if err == ErrExecutionReverted {
 			// Assign return buffer from REVERT.
 			// TODO: Bad API design: return data buffer and the code is returned in the same place. In worst case
 			//       the code is returned also when there is not enough funds to deploy the code.
@@ -226,7 +247,7 @@ func (host *HostContext) Call(kind evmc.CallKind,
 	}
 
 	// Map errors.
-	if err == errExecutionReverted {
+	if err == ErrExecutionReverted {
 		err = evmc.Revert
 	} else if err != nil {
 		err = evmc.Failure
@@ -236,8 +257,109 @@ func (host *HostContext) Call(kind evmc.CallKind,
 	return output, gasLeft, createAddr, err
 }
 
+// ENI runs an ENI operation the way opENI does, charging its gas beforehand.
+func (host *HostContext) ENI(function common.Hash, argsType []byte, argsData []byte, memory []byte, retType []byte,
+	gas int64) (output []byte, gasLeft int64, err error) {
+
+	env := host.env
+	mem := NewMemory()
+	mem.store = memory
+
+	funcName := strings.Trim(string(function.Big().Bytes()), "\x00")
+	argsText, err := eni.ConvertArguments(argsType, argsData, mem)
+	if err != nil {
+		return nil, 0, evmc.Failure
+	}
+	if err := env.eni.InitENI(funcName, argsText); err != nil {
+		haltOnLocalENIFault(err)
+		return nil, 0, evmc.Failure
+	}
+	cost, err := gasENI(env.ChainConfig().GasTable(env.BlockNumber), env, host.contract, nil, nil, 0)
+	if err != nil {
+		return nil, 0, evmc.Failure
+	}
+	if cost > uint64(gas) {
+		return nil, 0, evmc.OutOfGas
+	}
+	retText, err := env.eni.ExecuteENI()
+	if err != nil {
+		haltOnLocalENIFault(err)
+		return nil, 0, evmc.Failure
+	}
+	if retText == "" {
+		return nil, 0, evmc.Failure
+	}
+	output, err = eni.ConvertReturnValue(retType, retText)
+	if err != nil {
+		return nil, 0, evmc.Failure
+	}
+	return output, gas - int64(cost), nil
+}
+
+func (host *HostContext) Schedule(sender common.Address, receiver common.Address, unixtime common.Hash, txData common.Hash,
+	gas int64) (gasCost int64, err error) {
+
+	env := host.env
+	cost := GasFastestStep
+	if env.ChainConfig().IsLityGas(env.BlockNumber) {
+		cost = scheduleGas(txData.Big().Bytes())
+	}
+	if cost > uint64(gas) {
+		return 0, evmc.OutOfGas
+	}
+	scheduleTx := umbrella.ScheduleTx{
+		Sender:   sender,
+		Receiver: receiver,
+		TxData:   txData.Big().Bytes(),
+		Unixtime: unixtime.Big().Uint64(),
+	}
+	walkGas, err := emitSchedule(env, scheduleTx, host.readOnly, uint64(gas)-cost)
+	switch err {
+	case nil:
+		return int64(cost + walkGas), nil
+	case ErrOutOfGas:
+		return 0, evmc.OutOfGas
+	case errWriteProtection:
+		return int64(cost), evmc.StaticModeViolation
+	default:
+		return int64(cost), evmc.Failure
+	}
+}
+
+func (host *HostContext) IsValidator(addr common.Address) bool {
+	return isValidator(host.env, addr)
+}
+
+func (host *HostContext) FreeGas(addr common.Address, gas int64) (gasCost int64, err error) {
+	env := host.env
+	cost, err := gasFreeGas(env.ChainConfig().GasTable(env.BlockNumber), env, host.contract, nil, nil, 0)
+	if err != nil {
+		return 0, evmc.Failure
+	}
+	if cost > uint64(gas) {
+		return 0, evmc.OutOfGas
+	}
+	freeGas(env, addr)
+	return int64(cost), nil
+}
+
+func (host *HostContext) Rand(addr common.Address, gas int64) (number common.Hash, gasCost int64, err error) {
+	env := host.env
+	cost, err := gasRand(env.ChainConfig().GasTable(env.BlockNumber), env, host.contract, nil, nil, 0)
+	if err != nil {
+		return common.Hash{}, 0, evmc.Failure
+	}
+	if cost > uint64(gas) {
+		return common.Hash{}, 0, evmc.OutOfGas
+	}
+	return common.BytesToHash(randomNumber(env, addr)), int64(cost), nil
+}
+
 func getRevision(env *EVM) evmc.Revision {
 	n := env.BlockNumber
+	if env.ChainConfig().IsConstantinople(n) {
+		return evmc.Constantinople
+	}
 	if env.ChainConfig().IsByzantium(n) {
 		return evmc.Byzantium
 	}
@@ -253,6 +375,18 @@ func getRevision(env *EVM) evmc.Revision {
 	return evmc.Frontier
 }
 
+// getCallKind returns the kind of the message running the contract.
+func getCallKind(contract *Contract) evmc.CallKind {
+	switch {
+	case contract.IsCreate:
+		return evmc.Create
+	case contract.DelegateCall:
+		return evmc.DelegateCall
+	default:
+		return evmc.Call
+	}
+}
+
 func (evm *EVMC) Run(contract *Contract, input []byte) (ret []byte, err error) {
 	evm.env.depth++
 	defer func() { evm.env.depth-- }()
@@ -262,15 +396,8 @@ func (evm *EVMC) Run(contract *Contract, input []byte) (ret []byte, err error) {
 		return nil, nil
 	}
 
-	// FIXME: Fix the type of evmc.Call in evmc package.
-	var kind evmc.CallKind = evmc.Call
-	if evm.env.StateDB.GetCodeSize(contract.Address()) == 0 {
-		// Guess if this is a CREATE.
-		kind = evmc.Create
-	}
-
 	output, gasLeft, err := evm.instance.Execute(
-		&HostContext{evm.env, contract},
+		&HostContext{evm.env, contract, evm.readOnly},
 		getRevision(evm.env),
 		contract.Address(),
 		contract.Caller(),
@@ -279,14 +406,15 @@ func (evm *EVMC) Run(contract *Contract, input []byte) (ret []byte, err error) {
 		crypto.Keccak256Hash(contract.Code),
 		int64(contract.Gas),
 		evm.env.depth-1,
-		kind,
+		getCallKind(contract),
 		evm.readOnly,
+		evm.env.ChainConfig().IsLity(evm.env.BlockNumber),
 		contract.Code)
 
 	contract.Gas = uint64(gasLeft)
 
 	if err == evmc.Revert {
-		err = errExecutionReverted
+		err = ErrExecutionReverted
 	} else if evmcError, ok := err.(evmc.Error); ok && evmcError.IsInternalError() {
 		panic(fmt.Sprintf("EVMC VM internal error: %s", evmcError.Error()))
 	}
@@ -0,0 +1,13 @@
+// EVMC: Ethereum Client-VM Connector API.
+// Copyright 2018 Pawel Bylica.
+// Licensed under the MIT License. See the LICENSE file.
+
+// Package evmc contains the Go bindings of EVMC, the Ethereum Client-VM
+// Connector API, extended with the Lity host interface declared in lity.h.
+//
+// The bindings are those of github.com/ethereum/evmc at revision
+// 924288755cd2258f3f0b67aff8313ebd36946788. The Lity extension changes the
+// host function table and the signature of Instance.Execute, which can't be
+// layered on top of the upstream package, so the extended bindings live here
+// instead of being vendored. The C sources other than host.c are unchanged.
+package evmc
@@ -11,6 +11,7 @@ package evmc
 #include <evmc/evmc.h>
 #include <evmc/helpers.h>
 #include <evmc/loader.h>
+#include <evmc/lity.h>
 
 #include <stdlib.h>
 #include <string.h>
@@ -29,7 +30,7 @@ struct extended_context
 	int64_t index;
 };
 
-extern const struct evmc_context_fn_table evmc_go_fn_table;
+extern const struct evmc_lity_context_fn_table evmc_go_fn_table;
 
 static struct evmc_result execute_wrapper(struct evmc_instance* instance, int64_t context_index, enum evmc_revision rev,
 	const struct evmc_address* destination, const struct evmc_address* sender, const struct evmc_uint256be* value,
@@ -51,7 +52,7 @@ static struct evmc_result execute_wrapper(struct evmc_instance* instance, int64_
 		flags,
 	};
 
-	struct extended_context ctx = {{&evmc_go_fn_table}, context_index};
+	struct extended_context ctx = {{&evmc_go_fn_table.base}, context_index};
 	return instance->execute(instance, &ctx.context, rev, &msg, code, code_size);
 }
 */
@@ -124,8 +125,11 @@ func (err Error) Error() string {
 }
 
 const (
-	Failure = Error(C.EVMC_FAILURE)
-	Revert  = Error(C.EVMC_REVERT)
+	Failure  = Error(C.EVMC_FAILURE)
+	Revert   = Error(C.EVMC_REVERT)
+	OutOfGas = Error(C.EVMC_OUT_OF_GAS)
+
+	StaticModeViolation = Error(C.EVMC_STATIC_MODE_VIOLATION)
 )
 
 type Revision int32
@@ -188,14 +192,26 @@ func (instance *Instance) SetOption(name string, value string) (err error) {
 	return err
 }
 
+// EnableLity negotiates the Lity extension with the VM, reporting whether the
+// VM supports it. See lity.h.
+func (instance *Instance) EnableLity() bool {
+	return instance.SetOption(LityOption, "on") == nil
+}
+
+// Execute runs the code of a message. Setting lity enables the Lity rules, it
+// requires the VM to support the Lity extension and ctx to implement
+// LityHostContext.
 func (instance *Instance) Execute(ctx HostContext, rev Revision,
 	destination common.Address, sender common.Address, value common.Hash, input []byte, codeHash common.Hash, gas int64,
-	depth int, kind CallKind, static bool, code []byte) (output []byte, gasLeft int64, err error) {
+	depth int, kind CallKind, static bool, lity bool, code []byte) (output []byte, gasLeft int64, err error) {
 
 	flags := C.uint32_t(0)
 	if static {
 		flags |= C.EVMC_STATIC
 	}
+	if lity {
+		flags |= C.EVMC_LITY
+	}
 
 	ctxId := addHostContext(ctx)
 	// FIXME: Clarify passing by pointer vs passing by value.
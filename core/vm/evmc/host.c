@@ -0,0 +1,38 @@
+/* EVMC: Ethereum Client-VM Connector API.
+ * Copyright 2018 Pawel Bylica.
+ * Licensed under the MIT License. See the LICENSE file.
+ */
+
+#include "_cgo_export.h"
+
+#include <evmc/lity.h>
+
+#include <stdlib.h>
+
+__attribute__((visibility("hidden"))) const struct evmc_lity_context_fn_table evmc_go_fn_table = {
+    {
+        (evmc_account_exists_fn)accountExists,
+        (evmc_get_storage_fn)getStorage,
+        (evmc_set_storage_fn)setStorage,
+        (evmc_get_balance_fn)getBalance,
+        (evmc_get_code_size_fn)getCodeSize,
+        (evmc_get_code_hash_fn)getCodeHash,
+        (evmc_copy_code_fn)copyCode,
+        (evmc_selfdestruct_fn)selfdestruct,
+        (evmc_call_fn)call,
+        (evmc_get_tx_context_fn)getTxContext,
+        (evmc_get_block_hash_fn)getBlockHash,
+        (evmc_emit_log_fn)emitLog,
+    },
+    (evmc_lity_eni_fn)lityENI,
+    (evmc_lity_schedule_fn)litySchedule,
+    (evmc_lity_is_validator_fn)lityIsValidator,
+    (evmc_lity_free_gas_fn)lityFreeGas,
+    (evmc_lity_rand_fn)lityRand,
+};
+
+__attribute__((visibility("hidden"))) void evmc_go_free_result_output(
+    const struct evmc_result* result)
+{
+    free((void*)result->output_data);
+}
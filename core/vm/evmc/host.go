@@ -236,3 +236,95 @@ func call(pResult *C.struct_evmc_result, pCtx unsafe.Pointer, msg *C.struct_evmc
 
 	*pResult = result
 }
+
+// LityOption is the option a VM accepts to declare support of the Lity
+// extension, see lity.h.
+const LityOption = "lity"
+
+// LityHostContext is the HostContext of a Host servicing the Lity instructions.
+type LityHostContext interface {
+	HostContext
+	ENI(function common.Hash, argsType []byte, argsData []byte, memory []byte, retType []byte, gas int64) (output []byte, gasLeft int64, err error)
+	Schedule(sender common.Address, receiver common.Address, unixtime common.Hash, txData common.Hash, gas int64) (gasCost int64, err error)
+	IsValidator(addr common.Address) bool
+	FreeGas(addr common.Address, gas int64) (gasCost int64, err error)
+	Rand(addr common.Address, gas int64) (number common.Hash, gasCost int64, err error)
+}
+
+func getLityHostContext(pCtx unsafe.Pointer) LityHostContext {
+	idx := int((*C.struct_extended_context)(pCtx).index)
+	ctx, ok := getHostContext(idx).(LityHostContext)
+	if !ok {
+		panic("evmc: Lity callback on a host without Lity support")
+	}
+	return ctx
+}
+
+//export lityENI
+func lityENI(pResult *C.struct_evmc_result, pCtx unsafe.Pointer, pFunction *C.struct_evmc_uint256be,
+	pArgsType *C.uint8_t, argsTypeSize C.size_t, pArgsData *C.uint8_t, argsDataSize C.size_t,
+	pMemory *C.uint8_t, memorySize C.size_t, pRetType *C.uint8_t, retTypeSize C.size_t, gas C.int64_t) {
+	ctx := getLityHostContext(pCtx)
+
+	output, gasLeft, err := ctx.ENI(goHash(*pFunction), goByteSlice(pArgsType, argsTypeSize), goByteSlice(pArgsData, argsDataSize),
+		goByteSlice(pMemory, memorySize), goByteSlice(pRetType, retTypeSize), int64(gas))
+
+	result := C.struct_evmc_result{}
+	if err != nil {
+		result.status_code = C.enum_evmc_status_code(err.(Error))
+	}
+	result.gas_left = C.int64_t(gasLeft)
+
+	if len(output) > 0 {
+		cOutput := C.CBytes(output)
+		result.output_data = (*C.uint8_t)(cOutput)
+		result.output_size = C.size_t(len(output))
+		result.release = (C.evmc_release_result_fn)(C.evmc_go_free_result_output)
+	}
+	*pResult = result
+}
+
+//export litySchedule
+func litySchedule(pCtx unsafe.Pointer, pSender *C.struct_evmc_address, pReceiver *C.struct_evmc_address,
+	pUnixtime *C.struct_evmc_uint256be, pTxData *C.struct_evmc_uint256be, gas C.int64_t, pGasCost *C.int64_t) C.enum_evmc_status_code {
+	ctx := getLityHostContext(pCtx)
+
+	gasCost, err := ctx.Schedule(goAddress(*pSender), goAddress(*pReceiver), goHash(*pUnixtime), goHash(*pTxData), int64(gas))
+	*pGasCost = C.int64_t(gasCost)
+	return lityStatus(err)
+}
+
+//export lityIsValidator
+func lityIsValidator(pCtx unsafe.Pointer, pAddr *C.struct_evmc_address) C.int {
+	ctx := getLityHostContext(pCtx)
+	if ctx.IsValidator(goAddress(*pAddr)) {
+		return 1
+	}
+	return 0
+}
+
+//export lityFreeGas
+func lityFreeGas(pCtx unsafe.Pointer, pAddr *C.struct_evmc_address, gas C.int64_t, pGasCost *C.int64_t) C.enum_evmc_status_code {
+	ctx := getLityHostContext(pCtx)
+
+	gasCost, err := ctx.FreeGas(goAddress(*pAddr), int64(gas))
+	*pGasCost = C.int64_t(gasCost)
+	return lityStatus(err)
+}
+
+//export lityRand
+func lityRand(pResult *C.struct_evmc_uint256be, pCtx unsafe.Pointer, pAddr *C.struct_evmc_address, gas C.int64_t, pGasCost *C.int64_t) C.enum_evmc_status_code {
+	ctx := getLityHostContext(pCtx)
+
+	number, gasCost, err := ctx.Rand(goAddress(*pAddr), int64(gas))
+	*pResult = evmcUint256be(number)
+	*pGasCost = C.int64_t(gasCost)
+	return lityStatus(err)
+}
+
+func lityStatus(err error) C.enum_evmc_status_code {
+	if err != nil {
+		return C.enum_evmc_status_code(err.(Error))
+	}
+	return C.EVMC_SUCCESS
+}
@@ -0,0 +1,167 @@
+/* EVMC: Ethereum Client-VM Connector API.
+ * Copyright 2018 Pawel Bylica.
+ * Licensed under the MIT License. See the LICENSE file.
+ */
+
+/**
+ * EVMC Lity extension
+ *
+ * Host callbacks servicing the instructions Lity adds to the EVM: ENI,
+ * SCHEDULE, ISVALIDATOR, FREEGAS and RAND.
+ *
+ * Negotiation: a VM supporting the extension accepts the ::EVMC_LITY_OPTION
+ * option with the value "on". The Host then sets ::EVMC_LITY in the flags of
+ * every message executed under the Lity rules. For such messages the VM MUST
+ *  - service the Lity instructions through the callbacks below,
+ *  - fail ADD, SUB and MUL on unsigned overflow,
+ *  - push the block timestamp for NUMBER.
+ * The Host never sets ::EVMC_LITY for a VM which did not accept the option.
+ *
+ * The callbacks are reached through the context: when ::EVMC_LITY is set, the
+ * context function table is the base of a ::evmc_lity_context_fn_table.
+ *
+ * Callbacks charging gas are passed the gas available to the operation and
+ * price it according to the rules of the current block. If the gas does not
+ * cover the cost they fail with ::EVMC_OUT_OF_GAS without any effect,
+ * otherwise the VM MUST deduct the returned cost from the gas left.
+ *
+ * @defgroup lity EVMC Lity extension
+ * @{
+ */
+#pragma once
+
+#include <evmc/evmc.h>
+
+/** The option a VM accepts to declare support of the Lity extension. */
+#define EVMC_LITY_OPTION "lity"
+
+/** The ::evmc_message flag enabling the Lity rules. */
+enum evmc_lity_flags
+{
+    EVMC_LITY = 2 /**< Lity rules, see ::EVMC_LITY_OPTION. */
+};
+
+/**
+ * ENI callback function.
+ *
+ *  Runs a native function, charging its gas before running it.
+ *
+ *  @param[out] result          The ENI encoded result in output_data and the
+ *                              gas left after charging the operation. The VM
+ *                              lays the result out in memory after the
+ *                              arguments, as the built-in interpreter does.
+ *  @param      context         The Host execution context.
+ *  @param      function        The function name, as pushed on the stack.
+ *  @param      args_type       The ENI type information of the arguments.
+ *  @param      args_type_size  The size of the type information.
+ *  @param      args_data       The ENI encoded arguments.
+ *  @param      args_data_size  The size of the encoded arguments.
+ *  @param      memory          The memory of the calling frame, which the
+ *                              dynamically sized arguments point into.
+ *  @param      memory_size     The size of the memory.
+ *  @param      ret_type        The ENI type information of the result.
+ *  @param      ret_type_size   The size of the type information.
+ *  @param      gas             The gas available to the operation.
+ */
+typedef void (*evmc_lity_eni_fn)(struct evmc_result* result,
+                                 struct evmc_context* context,
+                                 const struct evmc_uint256be* function,
+                                 const uint8_t* args_type,
+                                 size_t args_type_size,
+                                 const uint8_t* args_data,
+                                 size_t args_data_size,
+                                 const uint8_t* memory,
+                                 size_t memory_size,
+                                 const uint8_t* ret_type,
+                                 size_t ret_type_size,
+                                 int64_t gas);
+
+/**
+ * Schedule callback function.
+ *
+ *  Queues a transaction to be sent once the given time is reached.
+ *
+ *  @param      context   The Host execution context.
+ *  @param      sender    The caller of the contract executing SCHEDULE.
+ *  @param      receiver  The receiver of the scheduled transaction.
+ *  @param      unixtime  The time the transaction falls due.
+ *  @param      tx_data   The transaction data, as pushed on the stack.
+ *  @param      gas       The gas available to the operation.
+ *  @param[out] gas_cost  The gas cost of the operation.
+ *  @return               ::EVMC_SUCCESS or the failure of the operation.
+ */
+typedef enum evmc_status_code (*evmc_lity_schedule_fn)(struct evmc_context* context,
+                                                       const struct evmc_address* sender,
+                                                       const struct evmc_address* receiver,
+                                                       const struct evmc_uint256be* unixtime,
+                                                       const struct evmc_uint256be* tx_data,
+                                                       int64_t gas,
+                                                       int64_t* gas_cost);
+
+/**
+ * Check validator callback function.
+ *
+ *  @param context  The Host execution context.
+ *  @param address  The address to look up.
+ *  @return         1 if the address is a validator of the block, 0 otherwise.
+ */
+typedef int (*evmc_lity_is_validator_fn)(struct evmc_context* context,
+                                         const struct evmc_address* address);
+
+/**
+ * Free gas callback function.
+ *
+ *  Marks the executing contract as paying for the gas of the transaction.
+ *
+ *  @param      context   The Host execution context.
+ *  @param      address   The address of the contract executing FREEGAS.
+ *  @param      gas       The gas available to the operation.
+ *  @param[out] gas_cost  The gas cost of the operation.
+ *  @return               ::EVMC_SUCCESS or the failure of the operation.
+ */
+typedef enum evmc_status_code (*evmc_lity_free_gas_fn)(struct evmc_context* context,
+                                                       const struct evmc_address* address,
+                                                       int64_t gas,
+                                                       int64_t* gas_cost);
+
+/**
+ * Random number callback function.
+ *
+ *  @param[out] result    The random number.
+ *  @param      context   The Host execution context.
+ *  @param      address   The address of the contract executing RAND.
+ *  @param      gas       The gas available to the operation.
+ *  @param[out] gas_cost  The gas cost of the operation.
+ *  @return               ::EVMC_SUCCESS or the failure of the operation.
+ */
+typedef enum evmc_status_code (*evmc_lity_rand_fn)(struct evmc_uint256be* result,
+                                                   struct evmc_context* context,
+                                                   const struct evmc_address* address,
+                                                   int64_t gas,
+                                                   int64_t* gas_cost);
+
+/**
+ * The context interface of the Lity extension.
+ */
+struct evmc_lity_context_fn_table
+{
+    /** The standard callbacks, the context function table points here. */
+    struct evmc_context_fn_table base;
+
+    /** ENI callback function. */
+    evmc_lity_eni_fn eni;
+
+    /** Schedule callback function. */
+    evmc_lity_schedule_fn schedule;
+
+    /** Check validator callback function. */
+    evmc_lity_is_validator_fn is_validator;
+
+    /** Free gas callback function. */
+    evmc_lity_free_gas_fn free_gas;
+
+    /** Random number callback function. */
+    evmc_lity_rand_fn rand;
+};
+
+/** @} */
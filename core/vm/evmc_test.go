@@ -0,0 +1,141 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/schedule"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/vm/evmc"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func newLityHostEnv(t *testing.T) (*EVM, *Contract) {
+	statedb, err := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := Context{
+		BlockNumber: big.NewInt(1),
+		Time:        big.NewInt(1000),
+		Difficulty:  big.NewInt(1),
+		Origin:      common.HexToAddress("0x01"),
+	}
+	env := NewEVM(ctx, statedb, params.TestChainConfig, Config{})
+	contract := NewContract(AccountRef(common.HexToAddress("0x02")), AccountRef(common.HexToAddress("0x03")), new(big.Int), 0)
+	return env, contract
+}
+
+// Tests that EVMC messages are sent with the kind of the call running them.
+func TestEVMCCallKind(t *testing.T) {
+	contract := NewContract(AccountRef(common.Address{}), AccountRef(common.Address{}), new(big.Int), 0)
+	if kind := getCallKind(contract); kind != evmc.Call {
+		t.Errorf("call kind mismatch: have %v, want %v", kind, evmc.Call)
+	}
+	contract.IsCreate = true
+	if kind := getCallKind(contract); kind != evmc.Create {
+		t.Errorf("create kind mismatch: have %v, want %v", kind, evmc.Create)
+	}
+	contract = NewContract(contract, AccountRef(common.Address{}), new(big.Int), 0).AsDelegate()
+	if kind := getCallKind(contract); kind != evmc.DelegateCall {
+		t.Errorf("delegate call kind mismatch: have %v, want %v", kind, evmc.DelegateCall)
+	}
+}
+
+// Tests that the Lity host callbacks derive the same random numbers as RAND,
+// and only once their gas is paid.
+func TestEVMCLityRand(t *testing.T) {
+	hostEnv, contract := newLityHostEnv(t)
+	interpEnv, _ := newLityHostEnv(t)
+	host := &HostContext{hostEnv, contract, false}
+
+	if _, _, err := host.Rand(contract.Address(), int64(params.RandGas-1)); err != evmc.OutOfGas {
+		t.Fatalf("error mismatch: have %v, want %v", err, evmc.OutOfGas)
+	}
+	for i := 0; i < 2; i++ {
+		number, cost, err := host.Rand(contract.Address(), int64(params.RandGas))
+		if err != nil {
+			t.Fatalf("rand %d failed: %v", i, err)
+		}
+		if cost != int64(params.RandGas) {
+			t.Errorf("rand %d cost mismatch: have %d, want %d", i, cost, params.RandGas)
+		}
+		if want := common.BytesToHash(randomNumber(interpEnv, contract.Address())); number != want {
+			t.Errorf("rand %d mismatch: have %x, want %x", i, number, want)
+		}
+	}
+}
+
+// Tests that scheduled transactions are queued by the host callback unless
+// the gas or the call frame forbid it.
+func TestEVMCLitySchedule(t *testing.T) {
+	env, contract := newLityHostEnv(t)
+	var (
+		receiver = common.HexToAddress("0x04")
+		unixtime = common.BigToHash(big.NewInt(2000))
+		txData   = common.BigToHash(big.NewInt(0xabcdef))
+		cost     = int64(params.ScheduleGas + (2*common.AddressLength+8+3)*params.ScheduleDataGas)
+	)
+	host := &HostContext{env, contract, true}
+	if _, err := host.Schedule(contract.Caller(), receiver, unixtime, txData, cost); err != evmc.StaticModeViolation {
+		t.Fatalf("static error mismatch: have %v, want %v", err, evmc.StaticModeViolation)
+	}
+	host.readOnly = false
+	if _, err := host.Schedule(contract.Caller(), receiver, unixtime, txData, cost-1); err != evmc.OutOfGas {
+		t.Fatalf("gas error mismatch: have %v, want %v", err, evmc.OutOfGas)
+	}
+	if size := schedule.Size(env.StateDB); size != 0 {
+		t.Fatalf("failed schedules queued: %d", size)
+	}
+	have, err := host.Schedule(contract.Caller(), receiver, unixtime, txData, cost)
+	if err != nil {
+		t.Fatalf("schedule failed: %v", err)
+	}
+	if have != cost {
+		t.Errorf("cost mismatch: have %d, want %d", have, cost)
+	}
+	entry := schedule.Head(env.StateDB)
+	if entry == nil {
+		t.Fatal("schedule not queued")
+	}
+	if entry.Sender != contract.Caller() || entry.Receiver != receiver || entry.Unixtime != 2000 {
+		t.Errorf("entry mismatch: have %+v", entry)
+	}
+}
+
+// Tests that contracts opt in to pay for the gas through the host callback.
+func TestEVMCLityFreeGas(t *testing.T) {
+	env, contract := newLityHostEnv(t)
+	host := &HostContext{env, contract, false}
+
+	if _, err := host.FreeGas(contract.Address(), int64(params.FreeGasGas-1)); err != evmc.OutOfGas {
+		t.Fatalf("error mismatch: have %v, want %v", err, evmc.OutOfGas)
+	}
+	if env.StateDB.IsFreeGas(contract.Address()) {
+		t.Fatal("opted in without paying")
+	}
+	if _, err := host.FreeGas(contract.Address(), int64(params.FreeGasGas)); err != nil {
+		t.Fatalf("free gas failed: %v", err)
+	}
+	if !env.StateDB.IsFreeGas(contract.Address()) {
+		t.Fatal("not opted in")
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+const (
+	maxU256 = "115792089237316195423570985008687907853269984665640564039457584007913129639935" // 2^256-1
+	maxS256 = "57896044618658097711785492504343953926634992332820282019728792003956564819967"  // 2^255-1
+	minS256 = "-57896044618658097711785492504343953926634992332820282019728792003956564819968" // -2^255
+)
+
+// fixedPointTest is a fixed point operation on x and y with n decimals, and its
+// expected result, or the empty string if the operation fails.
+type fixedPointTest struct {
+	x, y, n string
+	want    string
+}
+
+// fixedPointConformance are the results of the fixed point instructions
+// specified by Lity: quotients are truncated toward zero, results must fit the
+// operand type and operands have at most 80 decimals.
+var fixedPointConformance = map[OpCode][]fixedPointTest{
+	FMUL: {
+		{"150", "225", "2", "337"},
+		{"0", maxU256, "80", "0"},
+		{maxU256, "1", "0", maxU256},
+		{maxU256, "10", "1", maxU256},
+		{maxU256, "2", "0", ""},
+		{maxU256, maxU256, "77", ""},
+		{"1", "1", "80", "0"},
+		{"1", "1", "81", ""},
+		{"1", "1", maxU256, ""},
+	},
+	SFMUL: {
+		{"150", "225", "2", "337"},
+		{"-150", "225", "2", "-337"},
+		{"150", "-225", "2", "-337"},
+		{"-150", "-225", "2", "337"},
+		{"-1", "1", "1", "0"},
+		{minS256, "1", "0", minS256},
+		{minS256, "10", "1", minS256},
+		{minS256, "-1", "0", ""},
+		{maxS256, "-1", "0", "-" + maxS256},
+		{maxS256, "2", "0", ""},
+		{minS256, "2", "0", ""},
+		{"-1", "-1", "81", ""},
+	},
+	FDIV: {
+		{"1", "3", "18", "333333333333333333"},
+		{"2", "3", "0", "0"},
+		{maxU256, "1", "0", maxU256},
+		{maxU256, "10", "1", maxU256},
+		{maxU256, "1", "1", ""},
+		{"1", "0", "0", ""},
+		{"1", "1", "81", ""},
+	},
+	SFDIV: {
+		{"1", "3", "2", "33"},
+		{"-1", "3", "2", "-33"},
+		{"1", "-3", "2", "-33"},
+		{"-1", "-3", "2", "33"},
+		{"-100", "-3", "0", "33"},
+		{minS256, "1", "0", minS256},
+		{minS256, "10", "1", minS256},
+		{minS256, "-1", "0", ""},
+		{maxS256, "-1", "0", "-" + maxS256},
+		{maxS256, "1", "1", ""},
+		{"1", "0", "0", ""},
+		{"-1", "1", "81", ""},
+	},
+}
+
+// word parses a decimal number into its two's complement stack word.
+func word(t *testing.T, s string) *big.Int {
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		t.Fatalf("invalid number %q", s)
+	}
+	return math.U256(n)
+}
+
+// runFixedPoint executes a fixed point instruction on the given operands.
+func runFixedPoint(t *testing.T, config *params.ChainConfig, op OpCode, test fixedPointTest) (*big.Int, error) {
+	var (
+		env            = NewEVM(Context{BlockNumber: big.NewInt(1)}, nil, config, Config{})
+		stack          = newstack()
+		pc             = uint64(0)
+		evmInterpreter = NewEVMInterpreter(env, env.vmConfig)
+	)
+	env.interpreter = evmInterpreter
+	evmInterpreter.intPool = poolOfIntPools.get()
+	defer poolOfIntPools.put(evmInterpreter.intPool)
+
+	stack.push(word(t, test.n))
+	stack.push(word(t, test.y))
+	stack.push(word(t, test.x))
+	if _, err := evmInterpreter.cfg.JumpTable[op].execute(&pc, evmInterpreter, nil, nil, stack); err != nil {
+		return nil, err
+	}
+	return stack.pop(), nil
+}
+
+func TestFixedPointConformance(t *testing.T) {
+	for op, tests := range fixedPointConformance {
+		for i, test := range tests {
+			have, err := runFixedPoint(t, params.TestChainConfig, op, test)
+			switch {
+			case test.want == "" && err == nil:
+				t.Errorf("%v test %d: expected error, have %v", op, i, have)
+			case test.want != "" && err != nil:
+				t.Errorf("%v test %d: unexpected error: %v", op, i, err)
+			case test.want != "" && have.Cmp(word(t, test.want)) != 0:
+				t.Errorf("%v test %d: result mismatch: have %v, want %v", op, i, math.S256(have), test.want)
+			}
+		}
+	}
+}
+
+// Tests that before the FixedPoint fork the quotients are Euclidean and the
+// decimals are unbounded.
+func TestFixedPointLegacy(t *testing.T) {
+	config := *params.TestChainConfig
+	config.FixedPointBlock = big.NewInt(2)
+
+	for op, test := range map[OpCode]fixedPointTest{
+		SFMUL: {"-150", "225", "2", "-338"},
+		SFDIV: {"-100", "-3", "0", "34"},
+		FMUL:  {"1", "1", "81", "0"},
+		FDIV:  {"0", "1", "81", "0"},
+	} {
+		have, err := runFixedPoint(t, &config, op, test)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", op, err)
+		} else if have.Cmp(word(t, test.want)) != 0 {
+			t.Errorf("%v: result mismatch: have %v, want %v", op, math.S256(have), test.want)
+		}
+	}
+}
+
+func TestGasFixedPoint(t *testing.T) {
+	legacy := *params.TestChainConfig
+	legacy.FixedPointBlock = nil
+
+	tests := []struct {
+		config *params.ChainConfig
+		n      *big.Int
+		gas    uint64
+	}{
+		{params.TestChainConfig, big.NewInt(0), GasMidStep},
+		{params.TestChainConfig, big.NewInt(18), GasMidStep + 18*params.FixedPointGas},
+		{params.TestChainConfig, big.NewInt(80), GasMidStep + 80*params.FixedPointGas},
+		{params.TestChainConfig, big.NewInt(81), GasMidStep},
+		{params.TestChainConfig, math.MaxBig256, GasMidStep},
+		{&legacy, big.NewInt(80), GasMidStep},
+	}
+	for i, test := range tests {
+		env := NewEVM(Context{BlockNumber: big.NewInt(1)}, nil, test.config, Config{})
+		stack := newstack()
+		stack.push(test.n)
+		stack.push(big.NewInt(1))
+		stack.push(big.NewInt(1))
+
+		gas, err := gasFixedPoint(env.ChainConfig().GasTable(env.BlockNumber), env, nil, stack, nil, 0)
+		if err != nil {
+			t.Fatalf("test %d: unexpected error: %v", i, err)
+		}
+		if gas != test.gas {
+			t.Errorf("test %d: gas mismatch: have %d, want %d", i, gas, test.gas)
+		}
+	}
+}
@@ -463,7 +463,56 @@ func gasDup(gt params.GasTable, evm *EVM, contract *Contract, stack *Stack, mem
 func gasENI(gt params.GasTable, evm *EVM, contract *Contract, stack *Stack, mem *Memory, memorySize uint64) (uint64, error) {
 	gas, err := evm.eni.Gas()
 	if err != nil {
+		haltOnLocalENIFault(err)
+		evm.traceENI("", err)
 		return 0, err
 	}
+	if evm.eniCall != nil {
+		evm.eniCall.Gas = gas
+	}
 	return 400 + gas, nil
 }
+
+func gasSchedule(gt params.GasTable, evm *EVM, contract *Contract, stack *Stack, mem *Memory, memorySize uint64) (uint64, error) {
+	if !evm.ChainConfig().IsLityGas(evm.BlockNumber) {
+		return GasFastestStep, nil
+	}
+	return scheduleGas(stack.Back(0).Bytes()), nil
+}
+
+// schedulePayloadSize is the size of a scheduled transaction stored in the
+// queue without its data: the sender, the receiver and the due time.
+const schedulePayloadSize = 2*common.AddressLength + 8
+
+// scheduleGas returns the gas charged past the LityGas fork for scheduling a
+// transaction carrying the given data, every byte of the queued payload being
+// charged for.
+func scheduleGas(txData []byte) uint64 {
+	return params.ScheduleGas + (schedulePayloadSize+uint64(len(txData)))*params.ScheduleDataGas
+}
+
+func gasFreeGas(gt params.GasTable, evm *EVM, contract *Contract, stack *Stack, mem *Memory, memorySize uint64) (uint64, error) {
+	if !evm.ChainConfig().IsLityGas(evm.BlockNumber) {
+		return GasFastestStep, nil
+	}
+	return params.FreeGasGas, nil
+}
+
+func gasFixedPoint(gt params.GasTable, evm *EVM, contract *Contract, stack *Stack, mem *Memory, memorySize uint64) (uint64, error) {
+	if !evm.ChainConfig().IsFixedPoint(evm.BlockNumber) {
+		return GasMidStep, nil
+	}
+	// Scaling by 10^n costs more with n, out of range decimals fail on execution.
+	n := stack.Back(2)
+	if !n.IsUint64() || n.Uint64() > params.MaxFixedPointDecimals {
+		return GasMidStep, nil
+	}
+	return GasMidStep + n.Uint64()*params.FixedPointGas, nil
+}
+
+func gasRand(gt params.GasTable, evm *EVM, contract *Contract, stack *Stack, mem *Memory, memorySize uint64) (uint64, error) {
+	if !evm.ChainConfig().IsLityGas(evm.BlockNumber) {
+		return GasFastStep, nil
+	}
+	return params.RandGas, nil
+}
@@ -25,6 +25,7 @@ func (s StructLog) MarshalJSON() ([]byte, error) {
 		Storage     map[common.Hash]common.Hash `json:"-"`
 		Depth       int                         `json:"depth"`
 		Err         error                       `json:"-"`
+		ENI         *ENILog                     `json:"eni,omitempty"`
 		OpName      string                      `json:"opName"`
 		ErrorString string                      `json:"error"`
 	}
@@ -44,6 +45,7 @@ func (s StructLog) MarshalJSON() ([]byte, error) {
 	enc.Storage = s.Storage
 	enc.Depth = s.Depth
 	enc.Err = s.Err
+	enc.ENI = s.ENI
 	enc.OpName = s.OpName()
 	enc.ErrorString = s.ErrorString()
 	return json.Marshal(&enc)
@@ -61,6 +63,7 @@ func (s *StructLog) UnmarshalJSON(input []byte) error {
 		Storage    map[common.Hash]common.Hash `json:"-"`
 		Depth      *int                        `json:"depth"`
 		Err        error                       `json:"-"`
+		ENI        *ENILog                     `json:"eni,omitempty"`
 	}
 	var dec StructLog
 	if err := json.Unmarshal(input, &dec); err != nil {
@@ -99,5 +102,8 @@ func (s *StructLog) UnmarshalJSON(input []byte) error {
 	if dec.Err != nil {
 		s.Err = dec.Err
 	}
+	if dec.ENI != nil {
+		s.ENI = dec.ENI
+	}
 	return nil
 }
@@ -17,7 +17,6 @@
 package vm
 
 import (
-	"bytes"
 	"encoding/binary"
 	"errors"
 	"fmt"
@@ -25,10 +24,12 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/schedule"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm/eni"
 	"github.com/ethereum/go-ethereum/core/vm/umbrella"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/params"
 )
 
@@ -37,9 +38,9 @@ var (
 	tt255                    = math.BigPow(2, 255)
 	errWriteProtection       = errors.New("evm: write protection")
 	errReturnDataOutOfBounds = errors.New("evm: return data out of bounds")
-	errExecutionReverted     = errors.New("evm: execution reverted")
 	errMaxCodeSizeExceeded   = errors.New("evm: max code size exceeded")
 	errENIExecutionError     = errors.New("evm: eni execution error")
+	errFixedPointDecimals    = errors.New("evm: fixed point decimals out of range")
 )
 
 func opAdd(pc *uint64, interpreter *EVMInterpreter, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
@@ -531,6 +532,13 @@ func opTimestamp(pc *uint64, interpreter *EVMInterpreter, contract *Contract, me
 }
 
 func opNumber(pc *uint64, interpreter *EVMInterpreter, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
+	stack.push(math.U256(interpreter.intPool.get().Set(interpreter.evm.BlockNumber)))
+	return nil, nil
+}
+
+// opLityNumber reports the block timestamp in place of the block number, as
+// Lity chains always have.
+func opLityNumber(pc *uint64, interpreter *EVMInterpreter, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
 	stack.push(math.U256(interpreter.intPool.get().Set(interpreter.evm.Time)))
 	return nil, nil
 }
@@ -665,7 +673,7 @@ func opCreate(pc *uint64, interpreter *EVMInterpreter, contract *Contract, memor
 	contract.Gas += returnGas
 	interpreter.intPool.put(value, offset, size)
 
-	if suberr == errExecutionReverted {
+	if suberr == ErrExecutionReverted {
 		return res, nil
 	}
 	return nil, nil
@@ -691,7 +699,7 @@ func opCall(pc *uint64, interpreter *EVMInterpreter, contract *Contract, memory
 	} else {
 		stack.push(interpreter.intPool.get().SetUint64(1))
 	}
-	if err == nil || err == errExecutionReverted {
+	if err == nil || err == ErrExecutionReverted {
 		memory.Set(retOffset.Uint64(), retSize.Uint64(), ret)
 	}
 	contract.Gas += returnGas
@@ -720,7 +728,7 @@ func opCallCode(pc *uint64, interpreter *EVMInterpreter, contract *Contract, mem
 	} else {
 		stack.push(interpreter.intPool.get().SetUint64(1))
 	}
-	if err == nil || err == errExecutionReverted {
+	if err == nil || err == ErrExecutionReverted {
 		memory.Set(retOffset.Uint64(), retSize.Uint64(), ret)
 	}
 	contract.Gas += returnGas
@@ -745,7 +753,7 @@ func opDelegateCall(pc *uint64, interpreter *EVMInterpreter, contract *Contract,
 	} else {
 		stack.push(interpreter.intPool.get().SetUint64(1))
 	}
-	if err == nil || err == errExecutionReverted {
+	if err == nil || err == ErrExecutionReverted {
 		memory.Set(retOffset.Uint64(), retSize.Uint64(), ret)
 	}
 	contract.Gas += returnGas
@@ -770,7 +778,7 @@ func opStaticCall(pc *uint64, interpreter *EVMInterpreter, contract *Contract, m
 	} else {
 		stack.push(interpreter.intPool.get().SetUint64(1))
 	}
-	if err == nil || err == errExecutionReverted {
+	if err == nil || err == ErrExecutionReverted {
 		memory.Set(retOffset.Uint64(), retSize.Uint64(), ret)
 	}
 	contract.Gas += returnGas
@@ -781,46 +789,77 @@ func opStaticCall(pc *uint64, interpreter *EVMInterpreter, contract *Contract, m
 
 func opIsvalidator(pc *uint64, interpreter *EVMInterpreter, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
 	addr := stack.peek()
-	fromAddr := common.BigToAddress(addr)
-	isValidator := uint64(0)
-	validators := interpreter.evm.Umbrella.GetValidators()
-	for i := range validators {
-		if bytes.Equal(validators[i].Bytes(), fromAddr.Bytes()) {
-			isValidator = uint64(1)
-			break
-		}
+	if isValidator(interpreter.evm, common.BigToAddress(addr)) {
+		addr.SetUint64(1)
+	} else {
+		addr.SetUint64(0)
 	}
-	addr.SetUint64(isValidator)
 	return nil, nil
 }
 
+// isValidator reports whether addr is a validator of the current block. The
+// set is the one of the executing block rather than the live one, so that old
+// blocks replay alike.
+func isValidator(evm *EVM, addr common.Address) bool {
+	return evm.GetValidators(evm.BlockNumber.Uint64()).Contains(addr)
+}
+
 func opFreeGas(pc *uint64, interpreter *EVMInterpreter, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
-	interpreter.evm.SetFreeGas(true)
+	freeGas(interpreter.evm, contract.Address())
 	return nil, nil
 }
 
+// freeGas makes the contract at addr pay for the gas of the transaction.
+func freeGas(evm *EVM, addr common.Address) {
+	// Opting in is journaled per contract, so that it reverts with the frame
+	// and a nested call cannot opt in on behalf of the transaction's callee.
+	if evm.ChainConfig().IsLityGas(evm.BlockNumber) {
+		evm.StateDB.SetFreeGas(addr)
+		return
+	}
+	evm.SetFreeGas(true)
+}
+
 func opRand(pc *uint64, interpreter *EVMInterpreter, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
-	nonce := interpreter.evm.StateDB.GetNonce(interpreter.evm.Origin)
+	stack.push(new(big.Int).SetBytes(randomNumber(interpreter.evm, contract.Address())))
+	return nil, nil
+}
+
+// randomNumber derives the next random number of the contract at addr.
+func randomNumber(evm *EVM, addr common.Address) []byte {
+	nonce := evm.StateDB.GetNonce(evm.Origin)
 	nonceBuf := make([]byte, binary.MaxVarintLen64)
 	nonceLen := binary.PutUvarint(nonceBuf, nonce)
 
-	codeHash := interpreter.evm.StateDB.GetCodeHash(contract.Address())
-	randomSeed := interpreter.evm.Difficulty.Bytes()
-
-	offset := interpreter.evm.IncreaseRandomNumberCounter()
+	// The counter lives in the EVM rather than in the state, so it deliberately
+	// survives reverts: a reverted frame cannot replay the numbers it observed.
+	offset := evm.IncreaseRandomNumberCounter()
 	offsetBuf := make([]byte, binary.MaxVarintLen64)
 	offsetLen := binary.PutUvarint(offsetBuf, offset)
+
+	if evm.ChainConfig().IsRandBeacon(evm.BlockNumber) {
+		// random number = Keccak256(
+		/// beacon, committed to by the block's proposer
+		/// origin and nonce, from the current transaction
+		/// address, of the contract executing RAND
+		/// offset, random number counter)
+		return crypto.Keccak256(evm.Beacon[:], evm.Origin[:], nonceBuf[:nonceLen], addr.Bytes(), offsetBuf[:offsetLen])
+	}
+	codeHash := evm.StateDB.GetCodeHash(addr)
+	randomSeed := evm.Difficulty.Bytes()
 	// random number = Keccak256(
 	/// randomSeed, from the current block header
 	/// nonce, from the current transaction
 	/// codeHash, contract's code hash from StateDB
 	/// offset, random number counter)
-	hash := crypto.Keccak256(randomSeed, nonceBuf[:nonceLen], codeHash[:], offsetBuf[:offsetLen])
-	stack.push(new(big.Int).SetBytes(hash))
-	return nil, nil
+	return crypto.Keccak256(randomSeed, nonceBuf[:nonceLen], codeHash[:], offsetBuf[:offsetLen])
 }
 
 func opSchedule(pc *uint64, interpreter *EVMInterpreter, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
+	// Before the LityGas fork the receiver isn't covered by stack validation.
+	if err := stack.require(3); err != nil {
+		return nil, err
+	}
 	txData, unixtime, receiver := stack.pop(), stack.pop(), stack.pop()
 	scheduleTx := umbrella.ScheduleTx{
 		Sender:   contract.CallerAddress,
@@ -828,10 +867,53 @@ func opSchedule(pc *uint64, interpreter *EVMInterpreter, contract *Contract, mem
 		TxData:   txData.Bytes(),
 		Unixtime: unixtime.Uint64(),
 	}
-	interpreter.evm.Umbrella.EmitScheduleTx(scheduleTx)
+	walkGas, err := emitSchedule(interpreter.evm, scheduleTx, interpreter.readOnly, contract.Gas)
+	if err != nil {
+		return nil, err
+	}
+	contract.UseGas(walkGas)
 	return nil, nil
 }
 
+// emitSchedule queues a transaction emitted by SCHEDULE. Inserting it into the
+// state queue ahead of pending transactions falling due later costs gas for
+// every transaction walked past, which is returned. The walk fails with
+// ErrOutOfGas if it would cost more than the gas available.
+func emitSchedule(evm *EVM, scheduleTx umbrella.ScheduleTx, readOnly bool, gas uint64) (uint64, error) {
+	// Past the schedule fork the queue is kept in the state, so that emissions
+	// revert with the call frame and are covered by the state root.
+	if evm.ChainConfig().IsSchedule(evm.BlockNumber) {
+		if readOnly {
+			return 0, errWriteProtection
+		}
+		_, steps, err := schedule.Push(evm.StateDB, scheduleTx, gas/params.ScheduleWalkGas)
+		if err == schedule.ErrWalkLimit {
+			return 0, ErrOutOfGas
+		}
+		return steps * params.ScheduleWalkGas, err
+	}
+	// Otherwise the emission is journaled and handed over to the umbrella
+	// only once the transaction succeeds.
+	if evm.ChainConfig().IsLityGas(evm.BlockNumber) {
+		if readOnly {
+			return 0, errWriteProtection
+		}
+		evm.StateDB.AddScheduleTx(scheduleTx)
+		return 0, nil
+	}
+	evm.Umbrella.EmitScheduleTx(scheduleTx)
+	return 0, nil
+}
+
+// haltOnLocalENIFault stops the node if an ENI invocation failed for a reason
+// local to this node. Failing the transaction instead would make the node
+// disagree with the rest of the network on the resulting state.
+func haltOnLocalENIFault(err error) {
+	if eni.IsLocalFault(err) {
+		log.Crit("Local fault executing ENI operation", "err", err)
+	}
+}
+
 func opENI(pc *uint64, interpreter *EVMInterpreter, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
 	_ = stack.pop()
 	typeOffset, dataOffset := stack.pop().Int64(), stack.pop().Int64()
@@ -844,7 +926,9 @@ func opENI(pc *uint64, interpreter *EVMInterpreter, contract *Contract, memory *
 
 	// We already initialized ENI environment so we only need to call it here.
 	retText, err := interpreter.evm.eni.ExecuteENI()
+	interpreter.evm.traceENI(retText, err)
 	if err != nil {
+		haltOnLocalENIFault(err)
 		return nil, err
 	}
 	if retText == "" {
@@ -913,16 +997,28 @@ func opSuicide(pc *uint64, interpreter *EVMInterpreter, contract *Contract, memo
 	return nil, nil
 }
 
+// overflow fails the checked arithmetic operation op at pc, whose result from
+// the given stack words doesn't fit. Since the OverflowRevert fork the
+// operation reverts with the ABI encoded error as data, refunding the gas left,
+// before it halts exceptionally with the error.
+func overflow(interpreter *EVMInterpreter, pc uint64, op OpCode, operands ...*big.Int) ([]byte, error) {
+	err := &types.OverflowError{OpCode: byte(op), Pc: pc, Operands: operands}
+	if interpreter.evm.ChainConfig().IsOverflowRevert(interpreter.evm.BlockNumber) {
+		return err.Pack(), ErrExecutionReverted
+	}
+	return nil, err
+}
+
 // Signed add with overflow checking
 func opSadd(pc *uint64, interpreter *EVMInterpreter, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
-	x, y := stack.pop(), stack.pop()
-	x = math.S256(x)
-	y = math.S256(y)
+	a, b := stack.pop(), stack.pop()
+	x := math.S256(a)
+	y := math.S256(b)
 
 	z := big.NewInt(0)
 	z.Add(x, y)
 	if !math.InS256(z) {
-		return nil, errors.New("SADD overflow")
+		return overflow(interpreter, *pc, SADD, a, b)
 	}
 
 	stack.push(math.SignAbsTo256Twos(z))
@@ -937,7 +1033,7 @@ func opUadd(pc *uint64, interpreter *EVMInterpreter, contract *Contract, memory
 	z.Add(x, y)
 
 	if !math.InU256(z) {
-		return nil, errors.New("UADD overflow")
+		return overflow(interpreter, *pc, ADD, x, y)
 	}
 
 	stack.push(math.U256(z))
@@ -947,14 +1043,14 @@ func opUadd(pc *uint64, interpreter *EVMInterpreter, contract *Contract, memory
 
 // Signed sub with overflow checking
 func opSsub(pc *uint64, interpreter *EVMInterpreter, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
-	x, y := stack.pop(), stack.pop()
-	x = math.S256(x)
-	y = math.S256(y)
+	a, b := stack.pop(), stack.pop()
+	x := math.S256(a)
+	y := math.S256(b)
 
 	z := big.NewInt(0)
 	z.Sub(x, y)
 	if !math.InS256(z) {
-		return nil, errors.New("SSUB overflow")
+		return overflow(interpreter, *pc, SSUB, a, b)
 	}
 
 	stack.push(math.SignAbsTo256Twos(z))
@@ -969,7 +1065,7 @@ func opUsub(pc *uint64, interpreter *EVMInterpreter, contract *Contract, memory
 	z.Sub(x, y)
 
 	if !math.InU256(z) {
-		return nil, errors.New("USUB overflow")
+		return overflow(interpreter, *pc, SUB, x, y)
 	}
 
 	stack.push(math.U256(z))
@@ -979,14 +1075,14 @@ func opUsub(pc *uint64, interpreter *EVMInterpreter, contract *Contract, memory
 
 // Signed mul with overflow checking
 func opSmul(pc *uint64, interpreter *EVMInterpreter, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
-	x, y := stack.pop(), stack.pop()
-	x = math.S256(x)
-	y = math.S256(y)
+	a, b := stack.pop(), stack.pop()
+	x := math.S256(a)
+	y := math.S256(b)
 
 	z := big.NewInt(0)
 	z.Mul(x, y)
 	if !math.InS256(z) {
-		return nil, errors.New("SMUL overflow")
+		return overflow(interpreter, *pc, SMUL, a, b)
 	}
 
 	stack.push(math.SignAbsTo256Twos(z))
@@ -1001,7 +1097,7 @@ func opUmul(pc *uint64, interpreter *EVMInterpreter, contract *Contract, memory
 	z.Mul(x, y)
 
 	if !math.InU256(z) {
-		return nil, errors.New("UMUL overflow")
+		return overflow(interpreter, *pc, MUL, x, y)
 	}
 
 	stack.push(math.U256(z))
@@ -1009,16 +1105,40 @@ func opUmul(pc *uint64, interpreter *EVMInterpreter, contract *Contract, memory
 	return nil, nil
 }
 
+// fixedPointScale returns 10^n, the scale of fixed point numbers with n
+// decimals. Since the FixedPoint fork the decimals are bounded, so that scaling
+// can't be made arbitrarily expensive.
+func fixedPointScale(evm *EVM, n *big.Int) (*big.Int, error) {
+	if evm.ChainConfig().IsFixedPoint(evm.BlockNumber) && (!n.IsUint64() || n.Uint64() > params.MaxFixedPointDecimals) {
+		return nil, errFixedPointDecimals
+	}
+	return new(big.Int).Exp(big.NewInt(10), n, nil), nil
+}
+
+// fixedPointQuo sets z to the quotient x/y of fixed point operations and
+// returns z. Since the FixedPoint fork the quotient is truncated toward zero as
+// the Lity specification requires, before it was the Euclidean one, rounding
+// negative quotients of positive divisors down.
+func fixedPointQuo(evm *EVM, z, x, y *big.Int) *big.Int {
+	if evm.ChainConfig().IsFixedPoint(evm.BlockNumber) {
+		return z.Quo(x, y)
+	}
+	return z.Div(x, y)
+}
+
 // Unsigned fixed point number mul with overflow checking
 func opFmul(pc *uint64, interpreter *EVMInterpreter, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
 	x, y, n := stack.pop(), stack.pop(), stack.pop()
+	scale, err := fixedPointScale(interpreter.evm, n)
+	if err != nil {
+		return nil, err
+	}
 	z := big.NewInt(0)
-	base10 := big.NewInt(10)
 	z.Mul(x, y)
-	z.Div(z, base10.Exp(base10, n, nil))
+	fixedPointQuo(interpreter.evm, z, z, scale)
 
 	if !math.InU256(z) {
-		return nil, errors.New("FMUL overflow")
+		return overflow(interpreter, *pc, FMUL, x, y, n)
 	}
 
 	stack.push(math.U256(z))
@@ -1028,16 +1148,19 @@ func opFmul(pc *uint64, interpreter *EVMInterpreter, contract *Contract, memory
 
 // Signed fixed point number mul with overflow checking
 func opSfmul(pc *uint64, interpreter *EVMInterpreter, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
-	x, y, n := stack.pop(), stack.pop(), stack.pop()
-	x = math.S256(x)
-	y = math.S256(y)
+	a, b, n := stack.pop(), stack.pop(), stack.pop()
+	x := math.S256(a)
+	y := math.S256(b)
+	scale, err := fixedPointScale(interpreter.evm, n)
+	if err != nil {
+		return nil, err
+	}
 
 	z := big.NewInt(0)
-	base10 := big.NewInt(10)
 	z.Mul(x, y)
-	z.Div(z, base10.Exp(base10, n, nil))
+	fixedPointQuo(interpreter.evm, z, z, scale)
 	if !math.InS256(z) {
-		return nil, errors.New("SFMUL overflow")
+		return overflow(interpreter, *pc, SFMUL, a, b, n)
 	}
 
 	stack.push(math.SignAbsTo256Twos(z))
@@ -1048,13 +1171,19 @@ func opSfmul(pc *uint64, interpreter *EVMInterpreter, contract *Contract, memory
 // Unsigned fixed point number div with overflow checking
 func opFdiv(pc *uint64, interpreter *EVMInterpreter, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
 	x, y, n := stack.pop(), stack.pop(), stack.pop()
+	if y.Sign() == 0 {
+		return nil, errors.New("FDIV division by zero")
+	}
+	scale, err := fixedPointScale(interpreter.evm, n)
+	if err != nil {
+		return nil, err
+	}
 	z := big.NewInt(0)
-	base10 := big.NewInt(10)
-	z.Mul(x, base10.Exp(base10, n, nil))
-	z.Div(z, y)
+	z.Mul(x, scale)
+	fixedPointQuo(interpreter.evm, z, z, y)
 
 	if !math.InU256(z) {
-		return nil, errors.New("FDIV overflow")
+		return overflow(interpreter, *pc, FDIV, x, y, n)
 	}
 
 	stack.push(math.U256(z))
@@ -1064,16 +1193,22 @@ func opFdiv(pc *uint64, interpreter *EVMInterpreter, contract *Contract, memory
 
 // Signed fixed point number div with overflow checking
 func opSfdiv(pc *uint64, interpreter *EVMInterpreter, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
-	x, y, n := stack.pop(), stack.pop(), stack.pop()
-	x = math.S256(x)
-	y = math.S256(y)
+	a, b, n := stack.pop(), stack.pop(), stack.pop()
+	x := math.S256(a)
+	y := math.S256(b)
+	if y.Sign() == 0 {
+		return nil, errors.New("SFDIV division by zero")
+	}
+	scale, err := fixedPointScale(interpreter.evm, n)
+	if err != nil {
+		return nil, err
+	}
 
 	z := big.NewInt(0)
-	base10 := big.NewInt(10)
-	z.Mul(x, base10.Exp(base10, n, nil))
-	z.Div(z, y)
+	z.Mul(x, scale)
+	fixedPointQuo(interpreter.evm, z, z, y)
 	if !math.InS256(z) {
-		return nil, errors.New("SFDIV overflow")
+		return overflow(interpreter, *pc, SFDIV, a, b, n)
 	}
 
 	stack.push(math.SignAbsTo256Twos(z))
@@ -21,6 +21,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm/umbrella"
 )
 
 // StateDB is an EVM database for full state querying.
@@ -61,6 +62,14 @@ type StateDB interface {
 	AddLog(*types.Log)
 	AddPreimage(common.Hash, []byte)
 
+	// AddScheduleTx records a transaction emitted by SCHEDULE, reverting along
+	// with the call frame emitting it.
+	AddScheduleTx(umbrella.ScheduleTx)
+	// SetFreeGas records that the contract opted in to pay for the current
+	// transaction, reverting along with the call frame opting in.
+	SetFreeGas(common.Address)
+	IsFreeGas(common.Address) bool
+
 	ForEachStorage(common.Address, func(common.Hash, common.Hash) bool)
 }
 
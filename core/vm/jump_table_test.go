@@ -0,0 +1,69 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that chains without the Lity fork run the stock Ethereum instruction
+// sets, and that the fork layers the Lity instructions on top of them.
+func TestLityInstructionSets(t *testing.T) {
+	lityOps := []OpCode{ENI, SADD, SSUB, SMUL, ISVALIDATOR, FMUL, SFMUL, FDIV, SFDIV, SCHEDULE, FREEGAS, RAND}
+	stockOps := map[OpCode]executionFunc{ADD: opAdd, SUB: opSub, MUL: opMul, NUMBER: opNumber}
+	lityExecs := map[OpCode]executionFunc{ADD: opUadd, SUB: opUsub, MUL: opUmul, NUMBER: opLityNumber}
+
+	sameFunc := func(a, b executionFunc) bool {
+		return reflect.ValueOf(a).Pointer() == reflect.ValueOf(b).Pointer()
+	}
+	jumpTable := func(config *params.ChainConfig, number int64) [256]operation {
+		evm := NewEVM(Context{BlockNumber: big.NewInt(number)}, nil, config, Config{})
+		return NewEVMInterpreter(evm, Config{}).cfg.JumpTable
+	}
+	stock := *params.MainnetChainConfig
+	lity := stock
+	lity.LityBlock = big.NewInt(0)
+
+	for _, number := range []int64{0, 1150000, 4370000} {
+		table := jumpTable(&stock, number)
+		for _, op := range lityOps {
+			if table[op].valid {
+				t.Errorf("block %d: %v valid without the Lity fork", number, op)
+			}
+		}
+		for op, exec := range stockOps {
+			if !sameFunc(table[op].execute, exec) {
+				t.Errorf("block %d: %v doesn't run its stock implementation without the Lity fork", number, op)
+			}
+		}
+		table = jumpTable(&lity, number)
+		for _, op := range lityOps {
+			if !table[op].valid {
+				t.Errorf("block %d: %v invalid with the Lity fork", number, op)
+			}
+		}
+		for op, exec := range lityExecs {
+			if !sameFunc(table[op].execute, exec) {
+				t.Errorf("block %d: %v doesn't run its Lity implementation with the Lity fork", number, op)
+			}
+		}
+	}
+}
@@ -66,6 +66,7 @@ type StructLog struct {
 	Storage    map[common.Hash]common.Hash `json:"-"`
 	Depth      int                         `json:"depth"`
 	Err        error                       `json:"-"`
+	ENI        *ENILog                     `json:"eni,omitempty"`
 }
 
 // overrides for gencodec
@@ -91,15 +92,26 @@ func (s *StructLog) ErrorString() string {
 	return ""
 }
 
+// ENILog records a native function invoked by the ENI opcode.
+type ENILog struct {
+	Function string `json:"function"`         // name of the ENI function
+	Args     string `json:"args"`             // JSON encoded arguments
+	Gas      uint64 `json:"gas"`              // gas charged by the function
+	Return   string `json:"return,omitempty"` // JSON encoded return value
+	Error    string `json:"error,omitempty"`
+}
+
 // Tracer is used to collect execution traces from an EVM transaction
 // execution. CaptureState is called for each step of the VM with the
-// current VM state.
+// current VM state. CaptureENI is called once the native function of an ENI
+// step returned or failed, env.ENI() being the backend that ran it.
 // Note that reference types are actual VM data structures; make copies
 // if you need to retain them beyond the current call.
 type Tracer interface {
 	CaptureStart(from common.Address, to common.Address, call bool, input []byte, gas uint64, value *big.Int) error
 	CaptureState(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error
 	CaptureFault(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error
+	CaptureENI(env *EVM, call *ENILog) error
 	CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error) error
 }
 
@@ -115,6 +127,8 @@ type StructLogger struct {
 	changedValues map[common.Address]Storage
 	output        []byte
 	err           error
+
+	eni *ENILog // ENI call failed before its step was logged
 }
 
 // NewStructLogger returns a new logger
@@ -177,7 +191,10 @@ func (l *StructLogger) CaptureState(env *EVM, pc uint64, op OpCode, gas, cost ui
 		storage = l.changedValues[contract.Address()].Copy()
 	}
 	// create a new snaptshot of the EVM.
-	log := StructLog{pc, op, gas, cost, mem, memory.Len(), stck, storage, depth, err}
+	log := StructLog{pc, op, gas, cost, mem, memory.Len(), stck, storage, depth, err, nil}
+	if op == ENI {
+		log.ENI, l.eni = l.eni, nil
+	}
 
 	l.logs = append(l.logs, log)
 	return nil
@@ -189,6 +206,19 @@ func (l *StructLogger) CaptureFault(env *EVM, pc uint64, op OpCode, gas, cost ui
 	return nil
 }
 
+// CaptureENI implements the Tracer interface, attaching the native call to the
+// log of its ENI step.
+func (l *StructLogger) CaptureENI(env *EVM, call *ENILog) error {
+	// An ENI function failing to compute its gas fails the step before it is
+	// logged, hold on to the call until then.
+	if n := len(l.logs); n > 0 && l.logs[n-1].Op == ENI && l.logs[n-1].ENI == nil && l.logs[n-1].Err == nil {
+		l.logs[n-1].ENI = call
+		return nil
+	}
+	l.eni = call
+	return nil
+}
+
 // CaptureEnd is called after the call finishes to finalize the tracing.
 func (l *StructLogger) CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error) error {
 	l.output = output
@@ -220,6 +250,15 @@ func WriteTrace(writer io.Writer, logs []StructLog) {
 		}
 		fmt.Fprintln(writer)
 
+		if log.ENI != nil {
+			fmt.Fprintf(writer, "ENI: %s(%s) gas=%v", log.ENI.Function, log.ENI.Args, log.ENI.Gas)
+			if log.ENI.Error != "" {
+				fmt.Fprintf(writer, " ERROR: %v\n", log.ENI.Error)
+			} else {
+				fmt.Fprintf(writer, " return=%s\n", log.ENI.Return)
+			}
+		}
+
 		if len(log.Stack) > 0 {
 			fmt.Fprintln(writer, "Stack:")
 			for i := len(log.Stack) - 1; i >= 0; i-- {
@@ -33,8 +33,10 @@ func NewEnv(cfg *Config) *vm.EVM {
 		BlockNumber: cfg.BlockNumber,
 		Time:        cfg.Time,
 		Difficulty:  cfg.Difficulty,
+		Beacon:      cfg.Beacon,
 		GasLimit:    cfg.GasLimit,
 		GasPrice:    cfg.GasPrice,
+		Umbrella:    cfg.Umbrella,
 	}
 
 	return vm.NewEVM(context, cfg.State, cfg.ChainConfig, cfg.EVMConfig)
@@ -24,6 +24,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/core/vm/umbrella"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/params"
@@ -34,6 +35,7 @@ import (
 type Config struct {
 	ChainConfig *params.ChainConfig
 	Difficulty  *big.Int
+	Beacon      common.Hash
 	Origin      common.Address
 	Coinbase    common.Address
 	BlockNumber *big.Int
@@ -46,6 +48,7 @@ type Config struct {
 
 	State     *state.StateDB
 	GetHashFn func(n uint64) common.Hash
+	Umbrella  umbrella.Umbrella
 }
 
 // sets defaults on the config
@@ -59,6 +62,7 @@ func setDefaults(cfg *Config) {
 			EIP150Block:    new(big.Int),
 			EIP155Block:    new(big.Int),
 			EIP158Block:    new(big.Int),
+			LityBlock:      new(big.Int),
 		}
 	}
 
@@ -85,6 +89,9 @@ func setDefaults(cfg *Config) {
 			return common.BytesToHash(crypto.Keccak256([]byte(new(big.Int).SetUint64(n).String())))
 		}
 	}
+	if cfg.Umbrella == nil {
+		cfg.Umbrella = umbrella.NewStandalone(cfg.ChainConfig.Umbrella)
+	}
 }
 
 // Execute executes the code using the input as call data during the execution.
@@ -17,15 +17,25 @@
 package runtime
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"math/big"
+	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/asm"
 	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/core/vm/eni"
+	"github.com/ethereum/go-ethereum/core/vm/umbrella"
 	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
 )
 
 func TestDefaults(t *testing.T) {
@@ -116,6 +126,346 @@ func TestCall(t *testing.T) {
 	}
 }
 
+// eniCallCode returns code calling the named ENI function with the arguments
+// (3, 4) and returning its uint result, with the types at 0x80 and the data at
+// 0x100.
+func eniCallCode(function string) []byte {
+	code := []byte{
+		byte(vm.PUSH1), 2, byte(vm.PUSH1), 0x80, byte(vm.MSTORE), // argument types length
+		byte(vm.PUSH1), eni.UINT, byte(vm.PUSH1), 0xa0, byte(vm.MSTORE8),
+		byte(vm.PUSH1), eni.UINT, byte(vm.PUSH1), 0xa1, byte(vm.MSTORE8),
+		byte(vm.PUSH1), 1, byte(vm.PUSH1), 0xc0, byte(vm.MSTORE), // return types length
+		byte(vm.PUSH1), eni.UINT, byte(vm.PUSH1), 0xe0, byte(vm.MSTORE8),
+		byte(vm.PUSH1), 64, byte(vm.PUSH2), 0x01, 0x00, byte(vm.MSTORE), // argument data length
+		byte(vm.PUSH1), 3, byte(vm.PUSH2), 0x01, 0x20, byte(vm.MSTORE),
+		byte(vm.PUSH1), 4, byte(vm.PUSH2), 0x01, 0x40, byte(vm.MSTORE),
+		byte(vm.PUSH2), 0x01, 0x00, // data offset
+		byte(vm.PUSH1), 0x80, // type offset
+		byte(vm.PUSH1) + byte(len(function)-1),
+	}
+	code = append(code, function...)
+	return append(code,
+		byte(vm.ENI),
+		byte(vm.PUSH1), 32,
+		byte(vm.SWAP1),
+		byte(vm.RETURN),
+	)
+}
+
+func newAddBackend() *eni.GoBackend {
+	backend := eni.NewGoBackend()
+	backend.Register("add", func(args string) (uint64, error) {
+		return 10, nil
+	}, func(args string) (string, error) {
+		var ops []uint64
+		if err := json.Unmarshal([]byte(args), &ops); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("[%d]", ops[0]+ops[1]), nil
+	})
+	return backend
+}
+
+func TestENIGoBackend(t *testing.T) {
+	backend := newAddBackend()
+	cfg := &Config{EVMConfig: vm.Config{ENI: backend}}
+	ret, _, err := Execute(eniCallCode("add"), nil, cfg)
+	if err != nil {
+		t.Fatal("didn't expect error", err)
+	}
+	if num := new(big.Int).SetBytes(ret); num.Cmp(big.NewInt(7)) != 0 {
+		t.Error("Expected 7, got", num)
+	}
+	// Unknown functions must fail the execution.
+	if _, _, err := Execute(eniCallCode("adx"), nil, &Config{EVMConfig: vm.Config{ENI: backend}}); err == nil {
+		t.Error("expected unregistered function to fail")
+	}
+}
+
+// Tests that EVMs configured with the same Go backend don't share the state of
+// their invocations in flight.
+func TestENIGoBackendPerEVM(t *testing.T) {
+	backend := newAddBackend()
+	backend.Register("neg", func(args string) (uint64, error) {
+		return 20, nil
+	}, func(args string) (string, error) {
+		return "[0]", nil
+	})
+	cfg := vm.Config{ENI: backend}
+	first := vm.NewEVM(vm.Context{BlockNumber: big.NewInt(0)}, nil, params.TestChainConfig, cfg)
+	second := vm.NewEVM(vm.Context{BlockNumber: big.NewInt(0)}, nil, params.TestChainConfig, cfg)
+	if first.ENI() == second.ENI() {
+		t.Fatal("EVMs share their ENI backend")
+	}
+	if err := first.ENI().InitENI("add", "[3,4]"); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.ENI().InitENI("neg", "[1]"); err != nil {
+		t.Fatal(err)
+	}
+	if gas, err := first.ENI().Gas(); err != nil || gas != 10 {
+		t.Errorf("gas of first EVM: have %d (%v), want 10", gas, err)
+	}
+	if ret, err := first.ENI().ExecuteENI(); err != nil || ret != "[7]" {
+		t.Errorf("return of first EVM: have %s (%v), want [7]", ret, err)
+	}
+}
+
+// Tests that ENI calls assembled by the macro of core/asm execute.
+func TestENIMacro(t *testing.T) {
+	c := asm.NewCompiler(false)
+	c.Feed(asm.Lex("add.asm", []byte("eni_call \"add\" uint 3 uint8 4 returns uint\npush 32\nswap1\nreturn\n"), false))
+	bin, errs := c.Compile()
+	if len(errs) > 0 {
+		t.Fatal("failed to compile:", errs)
+	}
+	ret, _, err := Execute(common.Hex2Bytes(bin), nil, &Config{EVMConfig: vm.Config{ENI: newAddBackend()}})
+	if err != nil {
+		t.Fatal("didn't expect error", err)
+	}
+	if num := new(big.Int).SetBytes(ret); num.Cmp(big.NewInt(7)) != 0 {
+		t.Error("Expected 7, got", num)
+	}
+}
+
+// Tests that the native calls of ENI steps are attached to their logs.
+func TestENITracing(t *testing.T) {
+	backend := newAddBackend()
+	backend.Register("fail", func(args string) (uint64, error) {
+		return 0, errors.New("no gas for you")
+	}, nil)
+
+	trace := func(function string) *vm.StructLog {
+		logger := vm.NewStructLogger(nil)
+		Execute(eniCallCode(function), nil, &Config{EVMConfig: vm.Config{ENI: backend, Debug: true, Tracer: logger}})
+		for i, log := range logger.StructLogs() {
+			if log.Op == vm.ENI {
+				return &logger.StructLogs()[i]
+			}
+		}
+		t.Fatalf("%s: ENI step not logged", function)
+		return nil
+	}
+	log := trace("add")
+	want := vm.ENILog{Function: "add", Args: "[3,4]", Gas: 10, Return: "[7]"}
+	if log.ENI == nil || *log.ENI != want {
+		t.Errorf("ENI log mismatch: have %+v, want %+v", log.ENI, want)
+	}
+	log = trace("fail")
+	if log.Err == nil {
+		t.Error("expected failing ENI step")
+	}
+	if log.ENI == nil || log.ENI.Function != "fail" || !strings.Contains(log.ENI.Error, "no gas for you") {
+		t.Errorf("failed ENI log mismatch: have %+v", log.ENI)
+	}
+}
+
+func TestStandaloneUmbrella(t *testing.T) {
+	validator := common.HexToAddress("0x0102030405060708090a0b0c0d0e0f1011121314")
+	isValidator := func(addr common.Address, cfg *Config) uint64 {
+		code := append([]byte{byte(vm.PUSH20)}, addr.Bytes()...)
+		code = append(code,
+			byte(vm.ISVALIDATOR),
+			byte(vm.PUSH1), 0,
+			byte(vm.MSTORE),
+			byte(vm.PUSH1), 32,
+			byte(vm.PUSH1), 0,
+			byte(vm.RETURN),
+		)
+		ret, _, err := Execute(code, nil, cfg)
+		if err != nil {
+			t.Fatal("didn't expect error", err)
+		}
+		return new(big.Int).SetBytes(ret).Uint64()
+	}
+	// Without any umbrella configured, the validator set is empty.
+	if isValidator(validator, nil) != 0 {
+		t.Error("expected empty validator set")
+	}
+	chainConfig := *params.TestChainConfig
+	chainConfig.Umbrella = &params.UmbrellaConfig{Validators: []common.Address{validator}}
+	if isValidator(validator, &Config{ChainConfig: &chainConfig}) != 1 {
+		t.Error("expected configured validator")
+	}
+	if isValidator(common.Address{1}, &Config{ChainConfig: &chainConfig}) != 0 {
+		t.Error("didn't expect unknown validator")
+	}
+}
+
+// callCode returns the code calling the given address without any value or data.
+func callCode(addr common.Address) []byte {
+	code := []byte{
+		byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0,
+		byte(vm.PUSH20),
+	}
+	code = append(code, addr.Bytes()...)
+	return append(code, byte(vm.GAS), byte(vm.CALL), byte(vm.POP))
+}
+
+func TestFreeGasJournal(t *testing.T) {
+	var (
+		optin    = common.HexToAddress("0x0b")
+		reverted = common.HexToAddress("0x0c")
+		caller   = common.BytesToAddress([]byte("contract"))
+	)
+	chainConfig := *params.TestChainConfig
+	cfg := &Config{ChainConfig: &chainConfig}
+	cfg.State, _ = state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+	cfg.State.SetCode(optin, []byte{byte(vm.FREEGAS), byte(vm.STOP)})
+	cfg.State.SetCode(reverted, []byte{byte(vm.FREEGAS), byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.REVERT)})
+
+	code := append(callCode(optin), callCode(reverted)...)
+	if _, _, err := Execute(code, nil, cfg); err != nil {
+		t.Fatal("didn't expect error", err)
+	}
+	// Opting in is attributed to the contract executing FREEGAS only, and
+	// is discarded if its frame reverts.
+	if !cfg.State.IsFreeGas(optin) {
+		t.Error("expected callee to opt in")
+	}
+	if cfg.State.IsFreeGas(reverted) {
+		t.Error("expected reverted opt in to be discarded")
+	}
+	if cfg.State.IsFreeGas(caller) {
+		t.Error("didn't expect caller to opt in through its callee")
+	}
+}
+
+func TestScheduleGas(t *testing.T) {
+	receiver := common.HexToAddress("0x0a")
+	code := append([]byte{byte(vm.PUSH20)}, receiver.Bytes()...)
+	code = append(code, byte(vm.PUSH1), 100, byte(vm.PUSH2), 0xbe, 0xef, byte(vm.SCHEDULE))
+
+	gasUsed := func(chainConfig *params.ChainConfig, code []byte) (uint64, *Config, error) {
+		cfg := &Config{ChainConfig: chainConfig, GasLimit: 100000, Umbrella: umbrella.NewStandalone(nil)}
+		cfg.State, _ = state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+		cfg.State.SetCode(common.Address{1}, code)
+
+		_, leftOverGas, err := Call(common.Address{1}, nil, cfg)
+		return cfg.GasLimit - leftOverGas, cfg, err
+	}
+	legacyConfig := *params.TestChainConfig
+	legacyConfig.ScheduleBlock, legacyConfig.LityGasBlock = nil, nil
+	lityConfig := *params.TestChainConfig
+	lityConfig.ScheduleBlock = nil
+
+	legacy, _, err := gasUsed(&legacyConfig, code)
+	if err != nil {
+		t.Fatal("didn't expect error", err)
+	}
+	lity, cfg, err := gasUsed(&lityConfig, code)
+	if err != nil {
+		t.Fatal("didn't expect error", err)
+	}
+	if want := legacy - vm.GasFastestStep + params.ScheduleGas + (2*common.AddressLength+8+2)*params.ScheduleDataGas; lity != want {
+		t.Errorf("gas mismatch: have %d, want %d", lity, want)
+	}
+	// The emission waits for the transaction to succeed.
+	if txs := cfg.State.GetScheduleTxs(common.Hash{}); len(txs) != 1 || txs[0].Receiver != receiver {
+		t.Errorf("schedule not journaled: %v", txs)
+	}
+	if _, cfg, err = gasUsed(&lityConfig, append(code, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.REVERT))); err == nil {
+		t.Fatal("expected revert")
+	}
+	if txs := cfg.State.GetScheduleTxs(common.Hash{}); len(txs) != 0 {
+		t.Errorf("reverted schedule not discarded: %v", txs)
+	}
+	// A missing receiver fails the call on either side of the fork.
+	short := []byte{byte(vm.PUSH1), 100, byte(vm.PUSH2), 0xbe, 0xef, byte(vm.SCHEDULE)}
+	for _, config := range []*params.ChainConfig{&legacyConfig, &lityConfig} {
+		if _, _, err := gasUsed(config, short); err == nil {
+			t.Errorf("expected stack underflow with LityGas block %v", config.LityGasBlock)
+		}
+	}
+}
+
+// Tests RAND against known vectors of the legacy and the beacon derivations.
+func TestRandBeacon(t *testing.T) {
+	code := []byte{
+		byte(vm.RAND), byte(vm.PUSH1), 0, byte(vm.MSTORE),
+		byte(vm.RAND), byte(vm.PUSH1), 32, byte(vm.MSTORE),
+		byte(vm.PUSH1), 64, byte(vm.PUSH1), 0, byte(vm.RETURN),
+	}
+	legacyConfig := *params.TestChainConfig
+	legacyConfig.RandBeaconBlock = nil
+
+	tests := []struct {
+		config *params.ChainConfig
+		beacon common.Hash
+		origin common.Address
+		want   [2]string
+	}{
+		// Keccak256(difficulty, nonce, code hash, counter)
+		{&legacyConfig, common.Hash{}, common.Address{}, [2]string{
+			"14553c5900f8dbc30f21f06d599dca7e963fd7a7a4f92132e1cef3ef5ca0dfb8",
+			"5432bf4a9950efcd3a7486c0a9311b9d7591289b0092c7bac3ae964e88fb5d2e",
+		}},
+		{&legacyConfig, common.Hash{1}, common.Address{}, [2]string{
+			"14553c5900f8dbc30f21f06d599dca7e963fd7a7a4f92132e1cef3ef5ca0dfb8",
+			"5432bf4a9950efcd3a7486c0a9311b9d7591289b0092c7bac3ae964e88fb5d2e",
+		}},
+		// Keccak256(beacon, origin, nonce, address, counter)
+		{params.TestChainConfig, common.Hash{1}, common.Address{}, [2]string{
+			"5377418dfb5f1cd3905f8d5abafab267064bbd8d04c2741ccadf08d41af2c89a",
+			"6eefa585e443c25c51d05b5b0bf9210247874dcb8712d1caa4b4714c1e153c1f",
+		}},
+		{params.TestChainConfig, common.Hash{2}, common.Address{}, [2]string{
+			"2bc7ece689740e43c18dd94165eeadebcc969e139ec81a03c6936414fc927cbd",
+			"260d018535d9ff9153a1e719b1759d617b875cc0e0e4b8e493e8f7da6c652578",
+		}},
+		{params.TestChainConfig, common.Hash{1}, common.Address{1}, [2]string{
+			"2fee2bcdbc5a40095c7bce3f7cdad578edc2c12e04f9dd8476c5502b49fb3dcd",
+			"f38d802025187e7734dec5f354a569213ace3a4975a16bad17f1d44abc09c542",
+		}},
+	}
+	for i, test := range tests {
+		cfg := &Config{ChainConfig: test.config, Difficulty: big.NewInt(131072), Beacon: test.beacon, Origin: test.origin}
+		ret, _, err := Execute(code, nil, cfg)
+		if err != nil {
+			t.Fatalf("test %d: didn't expect error: %v", i, err)
+		}
+		for j, want := range test.want {
+			if have := common.Bytes2Hex(ret[32*j : 32*(j+1)]); have != want {
+				t.Errorf("test %d: random number %d mismatch: have %s, want %s", i, j, have, want)
+			}
+		}
+	}
+}
+
+func TestOverflowRevert(t *testing.T) {
+	code := append([]byte{byte(vm.PUSH1), 1, byte(vm.PUSH32)}, math.PaddedBigBytes(math.MaxBig256, 32)...)
+	code = append(code, byte(vm.ADD), byte(vm.STOP))
+	want := &types.OverflowError{OpCode: byte(vm.ADD), Pc: 35, Operands: []*big.Int{math.MaxBig256, big.NewInt(1)}}
+
+	call := func(chainConfig *params.ChainConfig) ([]byte, uint64, error) {
+		cfg := &Config{ChainConfig: chainConfig, GasLimit: 100000}
+		cfg.State, _ = state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+		cfg.State.SetCode(common.Address{1}, code)
+		return Call(common.Address{1}, nil, cfg)
+	}
+	// By default overflows halt exceptionally, consuming all gas.
+	_, leftOverGas, err := call(params.TestChainConfig)
+	if have, ok := err.(*types.OverflowError); !ok || !reflect.DeepEqual(have, want) {
+		t.Errorf("overflow error mismatch: have %v, want %v", err, want)
+	}
+	if leftOverGas != 0 {
+		t.Errorf("halting overflow left %d gas", leftOverGas)
+	}
+	// Overflows may revert instead, with the ABI encoded error as data.
+	revertConfig := *params.TestChainConfig
+	revertConfig.OverflowRevertBlock = big.NewInt(0)
+	ret, leftOverGas, err := call(&revertConfig)
+	if err == nil || err.Error() != "evm: execution reverted" {
+		t.Errorf("expected revert, have %v", err)
+	}
+	if leftOverGas == 0 {
+		t.Error("reverting overflow consumed all gas")
+	}
+	if have := types.UnpackOverflowError(ret); !reflect.DeepEqual(have, want) {
+		t.Errorf("revert data mismatch: have %v, want %v", have, want)
+	}
+}
+
 func BenchmarkCall(b *testing.B) {
 	var definition = `[{"constant":true,"inputs":[],"name":"seller","outputs":[{"name":"","type":"address"}],"type":"function"},{"constant":false,"inputs":[],"name":"abort","outputs":[],"type":"function"},{"constant":true,"inputs":[],"name":"value","outputs":[{"name":"","type":"uint256"}],"type":"function"},{"constant":false,"inputs":[],"name":"refund","outputs":[],"type":"function"},{"constant":true,"inputs":[],"name":"buyer","outputs":[{"name":"","type":"address"}],"type":"function"},{"constant":false,"inputs":[],"name":"confirmReceived","outputs":[],"type":"function"},{"constant":true,"inputs":[],"name":"state","outputs":[{"name":"","type":"uint8"}],"type":"function"},{"constant":false,"inputs":[],"name":"confirmPurchase","outputs":[],"type":"function"},{"inputs":[],"type":"constructor"},{"anonymous":false,"inputs":[],"name":"Aborted","type":"event"},{"anonymous":false,"inputs":[],"name":"PurchaseConfirmed","type":"event"},{"anonymous":false,"inputs":[],"name":"ItemReceived","type":"event"},{"anonymous":false,"inputs":[],"name":"Refunded","type":"event"}]`
 
@@ -22,23 +22,31 @@ import (
 	"github.com/ethereum/go-ethereum/params"
 )
 
-func makeStackFunc(pop, push int) stackValidationFunc {
-	return func(stack *Stack) error {
-		if err := stack.require(pop); err != nil {
-			return err
-		}
-
-		if stack.len()+push-pop > int(params.StackLimit) {
-			return fmt.Errorf("stack limit reached %d (%d)", stack.len(), params.StackLimit)
-		}
-		return nil
+// stackValidation validates the stack (size) for an operation popping pop
+// items off the stack and pushing push items onto it.
+type stackValidation struct {
+	pop, push int
+}
+
+func (v stackValidation) validate(stack *Stack) error {
+	if err := stack.require(v.pop); err != nil {
+		return err
+	}
+
+	if stack.len()+v.push-v.pop > int(params.StackLimit) {
+		return fmt.Errorf("stack limit reached %d (%d)", stack.len(), params.StackLimit)
 	}
+	return nil
+}
+
+func makeStack(pop, push int) stackValidation {
+	return stackValidation{pop, push}
 }
 
-func makeDupStackFunc(n int) stackValidationFunc {
-	return makeStackFunc(n, n+1)
+func makeDupStack(n int) stackValidation {
+	return makeStack(n, n+1)
 }
 
-func makeSwapStackFunc(n int) stackValidationFunc {
-	return makeStackFunc(n, n)
+func makeSwapStack(n int) stackValidation {
+	return makeStack(n, n)
 }
@@ -0,0 +1,102 @@
+package umbrella
+
+import (
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+var (
+	// DefaultGasPrice is the gas price charged to contracts paying for
+	// freegas transactions unless configured otherwise.
+	DefaultGasPrice = big.NewInt(2 * params.Shannon)
+
+	// DefaultFreeGasLimit is the gas limit above which zero priced
+	// transactions are freegas ones unless configured otherwise.
+	DefaultFreeGasLimit uint64 = 500000
+)
+
+// Standalone is an umbrella for chains not run by Travis. The validator set
+// and gas parameters are static, taken from the chain configuration, and
+// scheduled transactions are queued in memory.
+type Standalone struct {
+	validators   []common.Address
+	gasPrice     *big.Int
+	freeGasLimit *big.Int
+
+	lock  sync.Mutex
+	queue []ScheduleTx // scheduled transactions ordered by due time
+	now   func() time.Time
+}
+
+// NewStandalone creates an umbrella from the given configuration, which may be
+// nil to use the defaults.
+func NewStandalone(config *params.UmbrellaConfig) *Standalone {
+	u := &Standalone{
+		gasPrice:     DefaultGasPrice,
+		freeGasLimit: new(big.Int).SetUint64(DefaultFreeGasLimit),
+		now:          time.Now,
+	}
+	if config != nil {
+		u.validators = append([]common.Address(nil), config.Validators...)
+		if config.DefaultGasPrice != nil {
+			u.gasPrice = config.DefaultGasPrice
+		}
+		if config.FreeGasLimit != 0 {
+			u.freeGasLimit = new(big.Int).SetUint64(config.FreeGasLimit)
+		}
+	}
+	return u
+}
+
+// GetValidators implements Umbrella, returning the static validator set.
+func (u *Standalone) GetValidators() []common.Address {
+	return append([]common.Address(nil), u.validators...)
+}
+
+// GetValidatorsAt implements Umbrella, returning the static validator set
+// whatever the block.
+func (u *Standalone) GetValidatorsAt(number *big.Int) []common.Address {
+	return u.GetValidators()
+}
+
+// EmitScheduleTx implements Umbrella, queueing the transaction until due.
+func (u *Standalone) EmitScheduleTx(tx ScheduleTx) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	i := sort.Search(len(u.queue), func(i int) bool { return u.queue[i].Unixtime > tx.Unixtime })
+	u.queue = append(u.queue, ScheduleTx{})
+	copy(u.queue[i+1:], u.queue[i:])
+	u.queue[i] = tx
+}
+
+// GetDueTxs implements Umbrella, removing and returning every queued
+// transaction whose time has come.
+func (u *Standalone) GetDueTxs() []ScheduleTx {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	now := uint64(u.now().Unix())
+	n := sort.Search(len(u.queue), func(i int) bool { return u.queue[i].Unixtime > now })
+	if n == 0 {
+		return nil
+	}
+	due := append([]ScheduleTx(nil), u.queue[:n]...)
+	u.queue = u.queue[n:]
+	return due
+}
+
+// DefaultGasPrice implements Umbrella.
+func (u *Standalone) DefaultGasPrice() *big.Int {
+	return new(big.Int).Set(u.gasPrice)
+}
+
+// FreeGasLimit implements Umbrella.
+func (u *Standalone) FreeGasLimit() *big.Int {
+	return new(big.Int).Set(u.freeGasLimit)
+}
@@ -0,0 +1,52 @@
+package umbrella
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestStandaloneDefaults(t *testing.T) {
+	u := NewStandalone(nil)
+	if u.DefaultGasPrice().Cmp(DefaultGasPrice) != 0 {
+		t.Errorf("default gas price mismatch: have %v, want %v", u.DefaultGasPrice(), DefaultGasPrice)
+	}
+	if u.FreeGasLimit().Uint64() != DefaultFreeGasLimit {
+		t.Errorf("free gas limit mismatch: have %v, want %v", u.FreeGasLimit(), DefaultFreeGasLimit)
+	}
+	if len(u.GetValidators()) != 0 {
+		t.Errorf("unexpected validators: %v", u.GetValidators())
+	}
+	u = NewStandalone(&params.UmbrellaConfig{
+		Validators:      []common.Address{{1}},
+		DefaultGasPrice: big.NewInt(7),
+		FreeGasLimit:    100,
+	})
+	if u.DefaultGasPrice().Int64() != 7 || u.FreeGasLimit().Int64() != 100 || len(u.GetValidators()) != 1 {
+		t.Errorf("configuration not applied: %v %v %v", u.DefaultGasPrice(), u.FreeGasLimit(), u.GetValidators())
+	}
+}
+
+func TestStandaloneSchedule(t *testing.T) {
+	u := NewStandalone(nil)
+	now := time.Unix(1000, 0)
+	u.now = func() time.Time { return now }
+
+	for _, at := range []uint64{1500, 900, 1000, 2000} {
+		u.EmitScheduleTx(ScheduleTx{Unixtime: at})
+	}
+	due := u.GetDueTxs()
+	if len(due) != 2 || due[0].Unixtime != 900 || due[1].Unixtime != 1000 {
+		t.Fatalf("due transactions mismatch: %v", due)
+	}
+	if due := u.GetDueTxs(); len(due) != 0 {
+		t.Fatalf("due transactions returned twice: %v", due)
+	}
+	now = time.Unix(1800, 0)
+	if due := u.GetDueTxs(); len(due) != 1 || due[0].Unixtime != 1500 {
+		t.Fatalf("due transactions mismatch: %v", due)
+	}
+}
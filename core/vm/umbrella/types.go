@@ -10,3 +10,21 @@ type ScheduleTx struct {
 	TxData   []byte
 	Unixtime uint64
 }
+
+// ValidatorSet is a validator set indexed for constant time membership tests.
+type ValidatorSet map[common.Address]struct{}
+
+// NewValidatorSet indexes the given validators.
+func NewValidatorSet(validators []common.Address) ValidatorSet {
+	set := make(ValidatorSet, len(validators))
+	for _, addr := range validators {
+		set[addr] = struct{}{}
+	}
+	return set
+}
+
+// Contains reports whether addr is in the set.
+func (set ValidatorSet) Contains(addr common.Address) bool {
+	_, ok := set[addr]
+	return ok
+}
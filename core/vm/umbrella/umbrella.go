@@ -8,9 +8,21 @@ import (
 
 type Umbrella interface {
 	GetValidators() []common.Address
+	// GetValidatorsAt returns the validator set of the given block, which
+	// must not change once the block is executed.
+	GetValidatorsAt(number *big.Int) []common.Address
 	EmitScheduleTx(ScheduleTx)
 	GetDueTxs() []ScheduleTx
 	// schedule(this.A(a, b), timestamp);
 	DefaultGasPrice() *big.Int
 	FreeGasLimit() *big.Int
 }
+
+// Beacon is implemented by umbrellas providing a randomness beacon, the VRF or
+// commit-reveal output each block's proposer commits to, verified by the
+// umbrella's consensus engine. It is mixed into the numbers RAND derives, so
+// that they cannot be computed before the block. Blocks past the RandBeacon
+// fork are rejected if the beacon is zero.
+type Beacon interface {
+	GetBeacon(number *big.Int) common.Hash
+}
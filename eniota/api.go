@@ -0,0 +1,122 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eniota
+
+import (
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/vm/eni"
+)
+
+// Libraries lists the ENI libraries known to the node, grouped by state.
+type Libraries struct {
+	Enabled []eni.OTAInfo `json:"enabled"`
+	Staging []eni.OTAInfo `json:"staging"`
+	Retired []eni.OTAInfo `json:"retired"`
+	Pending []Upgrade     `json:"pending"`
+}
+
+// LibraryStatus describes every known version of a single ENI library.
+type LibraryStatus struct {
+	Name    string   `json:"name"`
+	Enabled string   `json:"enabled,omitempty"` // Version currently in use, if any
+	Staging []string `json:"staging"`
+	Retired []string `json:"retired"`
+	Pending *Upgrade `json:"pending,omitempty"`
+
+	Activations []eni.Activation `json:"activations"` // Blocks from which each version is canonical
+}
+
+// PublicENIAPI provides an API to inspect the ENI libraries of the node.
+type PublicENIAPI struct {
+	s *Service
+}
+
+// NewPublicENIAPI creates a new ENI library inspection API.
+func NewPublicENIAPI(s *Service) *PublicENIAPI {
+	return &PublicENIAPI{s}
+}
+
+// ListLibraries returns the enabled, staging and retired library versions
+// along with the scheduled upgrades.
+func (api *PublicENIAPI) ListLibraries() Libraries {
+	api.s.mu.Lock()
+	libs := Libraries{
+		Enabled: api.s.ota.EnabledLibraries(),
+		Staging: api.s.ota.StagingLibraries(),
+		Retired: api.s.ota.RetiredLibraries(),
+	}
+	api.s.mu.Unlock()
+
+	libs.Pending = api.s.Pending()
+	return libs
+}
+
+// LibraryStatus returns the known versions of the named library.
+func (api *PublicENIAPI) LibraryStatus(name string) LibraryStatus {
+	libs := api.ListLibraries()
+
+	status := LibraryStatus{Name: name, Staging: []string{}, Retired: []string{}}
+	for _, info := range libs.Enabled {
+		if info.LibName == name {
+			status.Enabled = info.Version
+		}
+	}
+	for _, info := range libs.Staging {
+		if info.LibName == name {
+			status.Staging = append(status.Staging, info.Version)
+		}
+	}
+	for _, info := range libs.Retired {
+		if info.LibName == name {
+			status.Retired = append(status.Retired, info.Version)
+		}
+	}
+	for i := range libs.Pending {
+		if libs.Pending[i].Info.LibName == name {
+			status.Pending = &libs.Pending[i]
+		}
+	}
+	status.Activations = api.s.registry.Activations(name)
+	return status
+}
+
+// PrivateAdminAPI is the collection of ENI upgrade administrative methods.
+type PrivateAdminAPI struct {
+	s *Service
+}
+
+// NewPrivateAdminAPI creates a new ENI upgrade administrative API.
+func NewPrivateAdminAPI(s *Service) *PrivateAdminAPI {
+	return &PrivateAdminAPI{s}
+}
+
+// EniUpgrade schedules a library upgrade, enabling the new version starting
+// from the given block.
+func (api *PrivateAdminAPI) EniUpgrade(info eni.OTAInfo, block hexutil.Uint64) (bool, error) {
+	if err := api.s.Schedule(info, uint64(block)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// EniCancelUpgrade drops the upgrade scheduled for the named library.
+func (api *PrivateAdminAPI) EniCancelUpgrade(name string) (bool, error) {
+	if err := api.s.Cancel(name); err != nil {
+		return false, err
+	}
+	return true, nil
+}
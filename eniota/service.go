@@ -0,0 +1,468 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package eniota implements the over-the-air ENI library upgrade service.
+//
+// Upgrades are announced either by a registry contract or by the
+// admin_eniUpgrade RPC. Each announcement carries the library manifest and the
+// block height at which the new version becomes enabled. The library is
+// downloaded and verified into the staging directory right away, and moved
+// into the ENI library path right before the block at the activation height is
+// processed.
+package eniota
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm/eni"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// chainEventChanSize is the size of channel listening to ChainEvent.
+	chainEventChanSize = 10
+
+	// registryABI is the interface of the upgrade announcements emitted by the
+	// registry contract.
+	registryABI = `[{"anonymous":false,"inputs":[{"indexed":false,"name":"name","type":"string"},{"indexed":false,"name":"version","type":"string"},{"indexed":false,"name":"checksum","type":"string"},{"indexed":false,"name":"url","type":"string"},{"indexed":false,"name":"activation","type":"uint256"}],"name":"LibraryUpgrade","type":"event"}]`
+)
+
+var (
+	errNoLibraryPath    = errors.New("ENI library path not found")
+	errInvalidLibrary   = errors.New("library is not a valid upgrade of the enabled version")
+	errActivationPassed = errors.New("activation block already passed")
+	errUpgradeNotFound  = errors.New("no pending upgrade for library")
+	errUpgradeNotStaged = errors.New("ENI upgrade not staged at activation height")
+)
+
+var (
+	registryContractABI  abi.ABI
+	registryUpgradeTopic common.Hash
+)
+
+func init() {
+	parsed, err := abi.JSON(strings.NewReader(registryABI))
+	if err != nil {
+		panic(err)
+	}
+	registryContractABI = parsed
+	registryUpgradeTopic = parsed.Events["LibraryUpgrade"].Id()
+}
+
+// announcement is the payload of a LibraryUpgrade registry event. The url
+// field holds the download mirrors separated by whitespace.
+type announcement struct {
+	Name       string
+	Version    string
+	Checksum   string
+	Url        string
+	Activation *big.Int
+}
+
+// Config are the configuration parameters of the ENI upgrade service.
+type Config struct {
+	// Registry is the contract announcing library upgrades. The zero address
+	// disables the registry watcher, leaving admin_eniUpgrade as the only way
+	// to schedule an upgrade.
+	Registry common.Address `toml:",omitempty"`
+
+	// Journal is the file scheduled upgrades are persisted to, so that they
+	// survive a node restart.
+	Journal string `toml:",omitempty"`
+}
+
+// blockChain is the subset of core.BlockChain the service depends on.
+type blockChain interface {
+	CurrentBlock() *types.Block
+	SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subscription
+}
+
+// Upgrade is a library version scheduled to be enabled at a given block.
+type Upgrade struct {
+	Info   eni.OTAInfo `json:"info"`
+	Block  uint64      `json:"block"`  // Height of the first block executed with the new version
+	Staged bool        `json:"staged"` // Whether the library is downloaded and verified
+	Error  string      `json:"error,omitempty"`
+}
+
+// Service is the ENI upgrade daemon. It stages announced libraries and
+// enables them at their activation height.
+type Service struct {
+	config   Config
+	chain    blockChain
+	ota      *eni.OTAInstance
+	registry *eni.Registry
+
+	mu      sync.Mutex
+	pending map[string]*Upgrade // Scheduled upgrades keyed by library name
+
+	unsubscribe func() // Detaches the service from block processing
+	quit        chan struct{}
+	wg          sync.WaitGroup
+}
+
+// New creates an ENI upgrade service watching the given chain.
+func New(config Config, chain blockChain) (*Service, error) {
+	ota := eni.NewOTAInstance()
+	if ota == nil {
+		return nil, errNoLibraryPath
+	}
+	registry, err := eni.DefaultRegistry()
+	if err != nil {
+		return nil, err
+	}
+	s := &Service{
+		config:   config,
+		chain:    chain,
+		ota:      ota,
+		registry: registry,
+		pending:  make(map[string]*Upgrade),
+		quit:     make(chan struct{}),
+	}
+	if err := s.loadJournal(); err != nil {
+		log.Warn("Failed to load ENI upgrade journal", "err", err)
+	}
+	return s, nil
+}
+
+// Protocols implements node.Service, returning the P2P network protocols used
+// by the upgrade service (nil as it doesn't use the devp2p overlay network).
+func (s *Service) Protocols() []p2p.Protocol { return nil }
+
+// APIs implements node.Service, returning the RPC API endpoints provided by the
+// upgrade service.
+func (s *Service) APIs() []rpc.API {
+	return []rpc.API{
+		{
+			Namespace: "eni",
+			Version:   "1.0",
+			Service:   NewPublicENIAPI(s),
+			Public:    true,
+		}, {
+			Namespace: "admin",
+			Version:   "1.0",
+			Service:   NewPrivateAdminAPI(s),
+		},
+	}
+}
+
+// Start implements node.Service, starting the upgrade daemon.
+func (s *Service) Start(server *p2p.Server) error {
+	s.mu.Lock()
+	for _, upgrade := range s.pending {
+		if !upgrade.Staged {
+			s.stage(upgrade)
+		}
+	}
+	s.mu.Unlock()
+
+	// Catch up with upgrades that became due while the node was offline, the
+	// rest is enabled by block import right before the activation block.
+	if head := s.chain.CurrentBlock(); head != nil {
+		if err := s.Activate(head.NumberU64()); err != nil {
+			log.Error("ENI upgrade overdue, blocks are refused until it is staged", "err", err)
+		}
+	}
+	s.unsubscribe = eni.SubscribeActivation(s)
+
+	s.wg.Add(1)
+	go s.loop()
+
+	log.Info("ENI upgrade service started", "registry", s.config.Registry)
+	return nil
+}
+
+// Stop implements node.Service, terminating the upgrade daemon.
+func (s *Service) Stop() error {
+	if s.unsubscribe != nil {
+		s.unsubscribe()
+	}
+	close(s.quit)
+	s.wg.Wait()
+
+	log.Info("ENI upgrade service stopped")
+	return nil
+}
+
+// loop waits for newly imported blocks, picking up registry announcements.
+func (s *Service) loop() {
+	defer s.wg.Done()
+
+	chainCh := make(chan core.ChainEvent, chainEventChanSize)
+	chainSub := s.chain.SubscribeChainEvent(chainCh)
+	defer chainSub.Unsubscribe()
+
+	for {
+		select {
+		case ev := <-chainCh:
+			for _, l := range ev.Logs {
+				s.handleLog(l)
+			}
+
+		case <-chainSub.Err():
+			return
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// handleLog schedules the upgrade announced by a registry log, if any.
+func (s *Service) handleLog(l *types.Log) {
+	if s.config.Registry == (common.Address{}) || l.Address != s.config.Registry || l.Removed {
+		return
+	}
+	if len(l.Topics) == 0 || l.Topics[0] != registryUpgradeTopic {
+		return
+	}
+	info, block, err := parseAnnouncement(l.Data)
+	if err != nil {
+		log.Warn("Invalid ENI upgrade announcement", "tx", l.TxHash, "err", err)
+		return
+	}
+	if err := s.Schedule(info, block); err != nil {
+		log.Warn("Rejected ENI upgrade announcement", "library", info.LibName, "version", info.Version, "err", err)
+	}
+}
+
+// Schedule registers a library upgrade to be enabled at the given block and
+// starts downloading it into the staging directory.
+func (s *Service) Schedule(info eni.OTAInfo, block uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if head := s.chain.CurrentBlock(); head != nil && block <= head.NumberU64() {
+		return errActivationPassed
+	}
+	valid, err := s.ota.IsValidNewLib(info)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return errInvalidLibrary
+	}
+	if old, ok := s.pending[info.LibName]; ok {
+		log.Info("Replacing scheduled ENI upgrade", "library", info.LibName, "old", old.Info.Version, "new", info.Version)
+	}
+	upgrade := &Upgrade{Info: info, Block: block}
+	s.pending[info.LibName] = upgrade
+	s.stage(upgrade)
+
+	log.Info("Scheduled ENI upgrade", "library", info.LibName, "version", info.Version, "block", block)
+	return s.saveJournal()
+}
+
+// Cancel drops the upgrade scheduled for the given library.
+func (s *Service) Cancel(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	upgrade, ok := s.pending[name]
+	if !ok {
+		return errUpgradeNotFound
+	}
+	if head := s.chain.CurrentBlock(); head != nil && upgrade.Block <= head.NumberU64() {
+		return errActivationPassed
+	}
+	if upgrade.Staged {
+		if err := s.registry.Unschedule(name, upgrade.Info.Version, upgrade.Block); err != nil {
+			return err
+		}
+	}
+	delete(s.pending, name)
+	return s.saveJournal()
+}
+
+// stage downloads and verifies an upgrade in the background. The caller must
+// hold s.mu.
+func (s *Service) stage(upgrade *Upgrade) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		err := s.ota.DownloadInfo(upgrade.Info)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.pending[upgrade.Info.LibName] != upgrade {
+			return // superseded or cancelled meanwhile
+		}
+		if err == nil {
+			// Pin the new version to its activation height, blocks before
+			// it keep resolving to the previous version.
+			err = s.registry.Schedule(upgrade.Info.LibName, upgrade.Info.Version, upgrade.Block)
+		}
+		if err != nil {
+			upgrade.Error = err.Error()
+			log.Error("Failed to stage ENI library", "library", upgrade.Info.LibName, "version", upgrade.Info.Version, "err", err)
+		} else {
+			upgrade.Staged, upgrade.Error = true, ""
+			log.Info("Staged ENI library", "library", upgrade.Info.LibName, "version", upgrade.Info.Version)
+		}
+		s.saveJournal()
+	}()
+}
+
+// Activate enables every staged upgrade due at or before the given block. It
+// runs synchronously from block import, before the block executes. The
+// registry already resolves the new version from its activation height on
+// while it sits in staging; enabling merely moves the library in place and
+// retires the old version.
+//
+// An upgrade due but not staged yet fails the activation: the registry doesn't
+// know the new version, so the block would execute against the old one, unlike
+// on the upgraded nodes. The block is refused until the download succeeds.
+func (s *Service) Activate(number uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var (
+		changed bool
+		failure error
+	)
+	for name, upgrade := range s.pending {
+		if upgrade.Block > number {
+			continue
+		}
+		if !upgrade.Staged {
+			log.Error("ENI library not staged at activation height", "library", name, "version", upgrade.Info.Version, "block", upgrade.Block, "err", upgrade.Error)
+			if failure == nil {
+				failure = fmt.Errorf("%v: %s %s at block %d", errUpgradeNotStaged, name, upgrade.Info.Version, upgrade.Block)
+			}
+			continue
+		}
+		if err := s.ota.Enable(upgrade.Info, s.registry); err != nil {
+			upgrade.Error = err.Error()
+			log.Error("Failed to enable ENI library", "library", name, "version", upgrade.Info.Version, "err", err)
+			continue
+		}
+		delete(s.pending, name)
+		changed = true
+		log.Info("Enabled ENI library", "library", name, "version", upgrade.Info.Version, "block", upgrade.Block)
+	}
+	if changed {
+		s.saveJournal()
+	}
+	return failure
+}
+
+// Ready reports whether every upgrade due at or before the given block is
+// staged, without enabling any.
+func (s *Service) Ready(number uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for name, upgrade := range s.pending {
+		if upgrade.Block <= number && !upgrade.Staged {
+			return fmt.Errorf("%v: %s %s at block %d", errUpgradeNotStaged, name, upgrade.Info.Version, upgrade.Block)
+		}
+	}
+	return nil
+}
+
+// Pending returns the scheduled upgrades ordered by activation height.
+func (s *Service) Pending() []Upgrade {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	upgrades := make([]Upgrade, 0, len(s.pending))
+	for _, upgrade := range s.pending {
+		upgrades = append(upgrades, *upgrade)
+	}
+	sort.Slice(upgrades, func(i, j int) bool {
+		if upgrades[i].Block != upgrades[j].Block {
+			return upgrades[i].Block < upgrades[j].Block
+		}
+		return upgrades[i].Info.LibName < upgrades[j].Info.LibName
+	})
+	return upgrades
+}
+
+// loadJournal restores the upgrades scheduled before the last shutdown.
+func (s *Service) loadJournal() error {
+	if s.config.Journal == "" {
+		return nil
+	}
+	blob, err := ioutil.ReadFile(s.config.Journal)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var upgrades []*Upgrade
+	if err := json.Unmarshal(blob, &upgrades); err != nil {
+		return err
+	}
+	for _, upgrade := range upgrades {
+		upgrade.Staged = false // re-verified on start
+		s.pending[upgrade.Info.LibName] = upgrade
+	}
+	return nil
+}
+
+// saveJournal persists the scheduled upgrades. The caller must hold s.mu.
+func (s *Service) saveJournal() error {
+	if s.config.Journal == "" {
+		return nil
+	}
+	upgrades := make([]*Upgrade, 0, len(s.pending))
+	for _, upgrade := range s.pending {
+		upgrades = append(upgrades, upgrade)
+	}
+	blob, err := json.MarshalIndent(upgrades, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.config.Journal + ".new"
+	if err := ioutil.WriteFile(tmp, blob, 0644); err != nil {
+		log.Warn("Failed to write ENI upgrade journal", "err", err)
+		return err
+	}
+	return os.Rename(tmp, s.config.Journal)
+}
+
+// parseAnnouncement decodes the payload of a LibraryUpgrade registry event.
+func parseAnnouncement(data []byte) (eni.OTAInfo, uint64, error) {
+	var out announcement
+	if err := registryContractABI.Unpack(&out, "LibraryUpgrade", data); err != nil {
+		return eni.OTAInfo{}, 0, err
+	}
+	if out.Activation == nil || !out.Activation.IsUint64() {
+		return eni.OTAInfo{}, 0, fmt.Errorf("invalid activation block %v", out.Activation)
+	}
+	info := eni.OTAInfo{
+		LibName:  out.Name,
+		Version:  out.Version,
+		Url:      strings.Fields(out.Url),
+		Checksum: out.Checksum,
+	}
+	return info, out.Activation.Uint64(), nil
+}
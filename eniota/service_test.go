@@ -0,0 +1,264 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eniota
+
+import (
+	"crypto/sha512"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm/eni"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// testChain is a fake block chain feeding chain events to the service.
+type testChain struct {
+	head *types.Block
+	feed event.Feed
+}
+
+func (c *testChain) CurrentBlock() *types.Block { return c.head }
+
+func (c *testChain) SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subscription {
+	return c.feed.Subscribe(ch)
+}
+
+// insert advances the fake chain by one block carrying the given logs,
+// running the ENI activations ahead of the block like block processing does.
+// The block is refused if the activations fail.
+func (c *testChain) insert(logs ...*types.Log) error {
+	number := new(big.Int).Add(c.head.Number(), common.Big1)
+	if err := eni.Activate(number.Uint64()); err != nil {
+		return err
+	}
+	c.head = types.NewBlockWithHeader(&types.Header{Number: number})
+	c.feed.Send(core.ChainEvent{Block: c.head, Hash: c.head.Hash(), Logs: logs})
+	return nil
+}
+
+// newTestService creates an upgrade service over a temporary library path and
+// a file server hosting the given library content.
+func newTestService(t *testing.T, config Config, lib []byte) (*Service, *testChain, string, *httptest.Server) {
+	dir, err := ioutil.TempDir("", "eniota")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("ENI_LIBRARY_PATH", dir)
+
+	chain := &testChain{head: types.NewBlockWithHeader(&types.Header{Number: big.NewInt(0)})}
+	s, err := New(config, chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(lib)
+	}))
+	return s, chain, dir, server
+}
+
+// testLibrary compiles a shared library exporting a single ENI function and
+// returns its content.
+func testLibrary(t *testing.T, function string) []byte {
+	cc, err := exec.LookPath("cc")
+	if err != nil {
+		t.Skip("C compiler not available")
+	}
+	dir, err := ioutil.TempDir("", "eniota-lib")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := fmt.Sprintf("#include <stdint.h>\nint64_t* %s_gas(char* a) { return 0; }\nchar* %s_run(char* a) { return a; }\n", function, function)
+	if err := ioutil.WriteFile(filepath.Join(dir, "lib.c"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command(cc, "-shared", "-fPIC", "-o", filepath.Join(dir, "lib.so"), filepath.Join(dir, "lib.c")).CombinedOutput(); err != nil {
+		t.Fatalf("failed to build library: %v\n%s", err, out)
+	}
+	lib, err := ioutil.ReadFile(filepath.Join(dir, "lib.so"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lib
+}
+
+func testInfo(version string, lib []byte, url string) eni.OTAInfo {
+	return eni.OTAInfo{
+		LibName:  "eni_test",
+		Version:  version,
+		Url:      []string{url},
+		Checksum: fmt.Sprintf("%x", sha512.Sum512(lib)),
+	}
+}
+
+// waitFor polls cond until it holds or the test times out.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	for i := 0; i < 100; i++ {
+		if cond() {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("timeout waiting for %s", what)
+}
+
+// Tests that a scheduled upgrade is staged immediately and only enabled when
+// the block at the activation height is processed.
+func TestScheduledActivation(t *testing.T) {
+	lib := testLibrary(t, "scheduled")
+	s, chain, dir, server := newTestService(t, Config{}, lib)
+	defer os.RemoveAll(dir)
+	defer server.Close()
+
+	s.Start(nil)
+	defer s.Stop()
+
+	api := NewPublicENIAPI(s)
+	if err := s.Schedule(testInfo("v1.0.0", lib, server.URL), 3); err != nil {
+		t.Fatalf("failed to schedule upgrade: %v", err)
+	}
+	waitFor(t, "staging", func() bool {
+		status := api.LibraryStatus("eni_test")
+		return status.Pending != nil && status.Pending.Staged
+	})
+	if status := api.LibraryStatus("eni_test"); status.Enabled != "" || len(status.Staging) != 1 {
+		t.Fatalf("library enabled before activation: %+v", status)
+	}
+	chain.insert() // block 1
+	chain.insert() // block 2, activation at 3 still ahead
+	if status := api.LibraryStatus("eni_test"); status.Enabled != "" {
+		t.Fatalf("library enabled too early: %+v", status)
+	}
+	chain.insert() // block 3, library must be in place before it executes
+	if status := api.LibraryStatus("eni_test"); status.Enabled != "v1.0.0" {
+		t.Fatalf("library not enabled at activation: %+v", status)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "eni_test_v1.0.0.so")); err != nil {
+		t.Fatalf("library not moved into place: %v", err)
+	}
+	if err := s.Schedule(testInfo("v1.0.0", lib, server.URL), 10); err != errInvalidLibrary {
+		t.Fatalf("re-scheduling same version: have %v, want %v", err, errInvalidLibrary)
+	}
+	if err := s.Schedule(testInfo("v1.1.0", lib, server.URL), 3); err != errActivationPassed {
+		t.Fatalf("scheduling in the past: have %v, want %v", err, errActivationPassed)
+	}
+}
+
+// Tests that the block at the activation height of an upgrade which couldn't be
+// staged is refused rather than executed against the old library version.
+func TestUnstagedActivation(t *testing.T) {
+	lib := testLibrary(t, "unstaged")
+	s, chain, dir, server := newTestService(t, Config{}, lib)
+	defer os.RemoveAll(dir)
+	defer server.Close()
+
+	s.Start(nil)
+	defer s.Stop()
+
+	info := testInfo("v1.0.0", lib, server.URL)
+	info.Checksum = fmt.Sprintf("%x", sha512.Sum512(nil)) // download fails verification
+	if err := s.Schedule(info, 2); err != nil {
+		t.Fatalf("failed to schedule upgrade: %v", err)
+	}
+	waitFor(t, "staging failure", func() bool {
+		pending := s.Pending()
+		return len(pending) == 1 && pending[0].Error != ""
+	})
+	if err := chain.insert(); err != nil {
+		t.Fatalf("block before activation refused: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := chain.insert(); err == nil {
+			t.Fatalf("block at activation height accepted without the library")
+		}
+	}
+	if number := chain.head.NumberU64(); number != 1 {
+		t.Fatalf("chain head mismatch: have %d, want 1", number)
+	}
+}
+
+// Tests that upgrades announced by the registry contract are picked up, while
+// announcements by other contracts are ignored.
+func TestRegistryAnnouncement(t *testing.T) {
+	lib := testLibrary(t, "announced")
+	registry := common.HexToAddress("0x0101010101010101010101010101010101010101")
+	s, chain, dir, server := newTestService(t, Config{Registry: registry}, lib)
+	defer os.RemoveAll(dir)
+	defer server.Close()
+
+	s.Start(nil)
+	defer s.Stop()
+
+	info := testInfo("v2.0.0", lib, server.URL)
+	data, err := registryContractABI.Events["LibraryUpgrade"].Inputs.Pack(info.LibName, info.Version, info.Checksum, server.URL, big.NewInt(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain.insert(&types.Log{Address: common.Address{0x02}, Topics: []common.Hash{registryUpgradeTopic}, Data: data})
+	time.Sleep(50 * time.Millisecond)
+	if pending := s.Pending(); len(pending) != 0 {
+		t.Fatalf("foreign announcement accepted: %+v", pending)
+	}
+	chain.insert(&types.Log{Address: registry, Topics: []common.Hash{registryUpgradeTopic}, Data: data})
+	waitFor(t, "announcement", func() bool {
+		pending := s.Pending()
+		return len(pending) == 1 && pending[0].Block == 5 && pending[0].Info.Version == "v2.0.0"
+	})
+}
+
+// Tests that scheduled upgrades survive a service restart.
+func TestUpgradeJournal(t *testing.T) {
+	lib := testLibrary(t, "journaled")
+	journal, err := ioutil.TempFile("", "eniota-journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	journal.Close()
+	os.Remove(journal.Name())
+	defer os.Remove(journal.Name())
+
+	s, chain, dir, server := newTestService(t, Config{Journal: journal.Name()}, lib)
+	defer os.RemoveAll(dir)
+	defer server.Close()
+
+	if err := s.Schedule(testInfo("v1.2.3", lib, server.URL), 7); err != nil {
+		t.Fatalf("failed to schedule upgrade: %v", err)
+	}
+	s.Start(nil)
+	s.Stop()
+
+	restarted, err := New(Config{Journal: journal.Name()}, chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending := restarted.Pending()
+	if len(pending) != 1 || pending[0].Block != 7 || pending[0].Info.Version != "v1.2.3" {
+		t.Fatalf("journal not restored: %+v", pending)
+	}
+}
@@ -190,6 +190,7 @@ func (api *PrivateDebugAPI) traceChain(ctx context.Context, start, end *types.Bl
 			// Fetch and execute the next block trace tasks
 			for task := range tasks {
 				signer := types.MakeSigner(api.config, task.block.Number())
+				core.ApplyDueSchedules(api.config, api.eth.blockchain, nil, new(core.GasPool).AddGas(task.block.GasLimit()), task.statedb, task.block.Header(), new(uint64), vm.Config{})
 
 				// Trace all the transactions contained within
 				for i, tx := range task.block.Transactions() {
@@ -439,6 +440,9 @@ func (api *PrivateDebugAPI) traceBlock(ctx context.Context, block *types.Block,
 			}
 		}()
 	}
+	// Execute the scheduled transactions fallen due, which precede the block's own
+	core.ApplyDueSchedules(api.config, api.eth.blockchain, nil, new(core.GasPool).AddGas(block.GasLimit()), statedb, block.Header(), new(uint64), vm.Config{})
+
 	// Feed the transactions into the tracers and return
 	var failed error
 	for i, tx := range txs {
@@ -630,8 +634,9 @@ func (api *PrivateDebugAPI) computeTxEnv(blockHash common.Hash, txIndex int, ree
 	if err != nil {
 		return nil, vm.Context{}, nil, err
 	}
-	// Recompute transactions up to the target index.
+	// Recompute the due scheduled transactions and the ones up to the target index.
 	signer := types.MakeSigner(api.config, block.Number())
+	core.ApplyDueSchedules(api.config, api.eth.blockchain, nil, new(core.GasPool).AddGas(block.GasLimit()), statedb, block.Header(), new(uint64), vm.Config{})
 
 	for idx, tx := range block.Transactions() {
 		// Assemble the transaction call message and return if the requested offset
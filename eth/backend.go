@@ -21,6 +21,7 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"path/filepath"
 	"runtime"
 	"sync"
 	"sync/atomic"
@@ -108,11 +109,11 @@ func New(ctx *node.ServiceContext, config *Config, pending miner.Pending) (*Ethe
 	if !config.SyncMode.IsValid() {
 		return nil, fmt.Errorf("invalid sync mode %d", config.SyncMode)
 	}
-	chainDb, err := CreateDB(ctx, config, "chaindata")
+	chainDb, err := createChainDB(ctx, config)
 	if err != nil {
 		return nil, err
 	}
-	chainConfig, genesisHash, genesisErr := core.SetupGenesisBlock(chainDb, config.Genesis)
+	chainConfig, genesisHash, genesisErr := core.SetupGenesisBlockWithOverride(chainDb, config.Genesis, config.OverrideLity)
 	if _, ok := genesisErr.(*params.ConfigCompatError); genesisErr != nil && !ok {
 		return nil, genesisErr
 	}
@@ -144,7 +145,7 @@ func New(ctx *node.ServiceContext, config *Config, pending miner.Pending) (*Ethe
 	}
 	var (
 		vmConfig    = vm.Config{EnablePreimageRecording: config.EnablePreimageRecording}
-		cacheConfig = &core.CacheConfig{Disabled: config.NoPruning, TrieNodeLimit: config.TrieCache, TrieTimeLimit: config.TrieTimeout}
+		cacheConfig = &core.CacheConfig{Disabled: config.NoPruning, TrieNodeLimit: config.TrieCache, TrieTimeLimit: config.TrieTimeout, SnapshotLimit: config.SnapshotCache}
 	)
 	eth.blockchain, err = core.NewBlockChain(chainDb, cacheConfig, eth.chainConfig, eth.engine, vmConfig)
 	if err != nil {
@@ -213,6 +214,56 @@ func CreateDB(ctx *node.ServiceContext, config *Config, name string) (ethdb.Data
 	return db, nil
 }
 
+// createChainDB creates the full node chain database, moving the blocks older
+// than the freezer threshold to the ancient store unless it is disabled.
+func createChainDB(ctx *node.ServiceContext, config *Config) (ethdb.Database, error) {
+	if config.DatabaseFreezerThreshold == 0 {
+		return CreateDB(ctx, config, "chaindata")
+	}
+	db, err := OpenDatabaseWithFreezer(ctx, "chaindata", config.DatabaseCache, config.DatabaseHandles, config.DatabaseFreezer, config.DatabaseFreezerThreshold)
+	if err != nil {
+		return nil, err
+	}
+	if db, ok := rawdb.KeyValueStore(db).(*ethdb.LDBDatabase); ok {
+		db.Meter("eth/db/chaindata/")
+	}
+	return db, nil
+}
+
+// DatabaseOpener opens the databases of a node's data directory, implemented by
+// both node.Node and node.ServiceContext.
+type DatabaseOpener interface {
+	OpenDatabase(name string, cache, handles int) (ethdb.Database, error)
+	ResolvePath(path string) string
+}
+
+// OpenDatabaseWithFreezer opens an existing database with the given name (or
+// creates one if no previous can be found) from within the node's data directory,
+// also attaching a chain freezer to it that moves ancient chain data from the
+// database to immutable append-only files. The freezer path is resolved in the
+// data directory if relative and defaults to the "ancient" directory inside the
+// database. If the node is an ephemeral one, a memory database is returned.
+func OpenDatabaseWithFreezer(stack DatabaseOpener, name string, cache, handles int, freezer string, threshold uint64) (ethdb.Database, error) {
+	db, err := stack.OpenDatabase(name, cache, handles)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := db.(*ethdb.LDBDatabase); !ok {
+		return db, nil
+	}
+	if freezer == "" {
+		freezer = filepath.Join(stack.ResolvePath(name), "ancient")
+	} else {
+		freezer = stack.ResolvePath(freezer)
+	}
+	frdb, err := rawdb.NewDatabaseWithFreezer(db, freezer, threshold)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return frdb, nil
+}
+
 // CreateConsensusEngine creates the required type of consensus engine instance for an Ethereum service
 func CreateConsensusEngine(ctx *node.ServiceContext, config *ethash.Config, chainConfig *params.ChainConfig, db ethdb.Database) consensus.Engine {
 	// If proof-of-authority is requested, set it up
@@ -88,11 +88,14 @@ type Config struct {
 	LightPeers int `toml:",omitempty"` // Maximum number of LES client peers
 
 	// Database options
-	SkipBcVersionCheck bool `toml:"-"`
-	DatabaseHandles    int  `toml:"-"`
-	DatabaseCache      int
-	TrieCache          int
-	TrieTimeout        time.Duration
+	SkipBcVersionCheck       bool `toml:"-"`
+	DatabaseHandles          int  `toml:"-"`
+	DatabaseCache            int
+	DatabaseFreezer          string // Ancient chain segment directory, inside the chain database if empty
+	DatabaseFreezerThreshold uint64 // Number of recent blocks kept out of the ancient store, 0 disables it (must stay enabled once blocks were frozen)
+	TrieCache                int
+	TrieTimeout              time.Duration
+	SnapshotCache            int // Megabytes of memory caching the state snapshot, 0 disables it
 
 	// Mining-related options
 	Etherbase    common.Address `toml:",omitempty"`
@@ -114,6 +117,10 @@ type Config struct {
 
 	// Miscellaneous options
 	DocRoot string `toml:"-"`
+
+	// Lity fork block override, for chains that ran the Lity opcodes before the
+	// fork block was introduced
+	OverrideLity *big.Int `toml:",omitempty"`
 }
 
 type configMarshaling struct {
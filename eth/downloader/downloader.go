@@ -111,6 +111,7 @@ type Downloader struct {
 
 	lightchain LightChain
 	blockchain BlockChain
+	snapSyncer SnapSyncer // Optional bulk state retriever to run before the trie node sync
 
 	// Callbacks
 	dropPeer peerDropFn // Drops a peer for misbehaving
@@ -197,6 +198,14 @@ type BlockChain interface {
 	InsertReceiptChain(types.Blocks, []types.Receipts) (int, error)
 }
 
+// SnapSyncer encapsulates a bulk state retriever run ahead of the trie node sync,
+// leaving only the remaining gaps of the state to be healed node by node.
+type SnapSyncer interface {
+	// Sync retrieves as much of the state of a root as the available peers can
+	// serve, returning early if the cancel channel is closed.
+	Sync(root common.Hash, cancel chan struct{}) error
+}
+
 // New creates a new downloader to fetch hashes and blocks from remote peers.
 func New(mode SyncMode, stateDb ethdb.Database, mux *event.TypeMux, chain BlockChain, lightchain LightChain, dropPeer peerDropFn) *Downloader {
 	if lightchain == nil {
@@ -233,6 +242,12 @@ func New(mode SyncMode, stateDb ethdb.Database, mux *event.TypeMux, chain BlockC
 	return dl
 }
 
+// SetSnapSyncer sets the bulk state retriever to run before the trie node sync
+// of fast synchronisation. It must be called before any sync is started.
+func (d *Downloader) SetSnapSyncer(syncer SnapSyncer) {
+	d.snapSyncer = syncer
+}
+
 // Progress retrieves the synchronisation boundaries, specifically the origin
 // block where synchronisation started at (may have failed/suspended); the block
 // or header sync is currently at; and the latest known block which the sync targets.
@@ -212,7 +212,8 @@ func (d *Downloader) runStateSync(s *stateSync) *stateSync {
 // stateSync schedules requests for downloading a particular state trie defined
 // by a given state root.
 type stateSync struct {
-	d *Downloader // Downloader instance to access and manage current peerset
+	d    *Downloader // Downloader instance to access and manage current peerset
+	root common.Hash // State root currently being synced
 
 	sched  *trie.Sync                 // State trie sync scheduler defining the tasks
 	keccak hash.Hash                  // Keccak256 hasher to verify deliveries with
@@ -239,6 +240,7 @@ type stateTask struct {
 func newStateSync(d *Downloader, root common.Hash) *stateSync {
 	return &stateSync{
 		d:       d,
+		root:    root,
 		sched:   state.NewStateSync(root, d.stateDB),
 		keccak:  sha3.NewKeccak256(),
 		tasks:   make(map[common.Hash]*stateTask),
@@ -252,6 +254,19 @@ func newStateSync(d *Downloader, root common.Hash) *stateSync {
 // it finishes, and finally notifying any goroutines waiting for the loop to
 // finish.
 func (s *stateSync) run() {
+	// Retrieve the bulk of the state in ranges if possible, healing the rest
+	if s.d.snapSyncer != nil {
+		if err := s.d.snapSyncer.Sync(s.root, s.cancel); err != nil {
+			select {
+			case <-s.cancel:
+				err = errCancelStateFetch
+			default:
+			}
+			s.err = err
+			close(s.done)
+			return
+		}
+	}
 	s.err = s.loop()
 	close(s.done)
 }
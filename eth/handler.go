@@ -33,6 +33,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/eth/fetcher"
+	"github.com/ethereum/go-ethereum/eth/snap"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/log"
@@ -75,6 +76,7 @@ type ProtocolManager struct {
 	maxPeers    int
 
 	downloader *downloader.Downloader
+	snapSyncer *snap.Syncer
 	fetcher    *fetcher.Fetcher
 	peers      *peerSet
 
@@ -162,8 +164,32 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 	if len(manager.SubProtocols) == 0 {
 		return nil, errIncompatibleConfig
 	}
+	// Retrieve the state in ranges alongside the primary protocol if fast syncing,
+	// and serve it if the state snapshot is maintained
+	if mode == downloader.FastSync || blockchain.Snapshots() != nil {
+		manager.snapSyncer = snap.NewSyncer(chaindb)
+		for i, version := range snap.ProtocolVersions {
+			version := version // Closure for the run
+			manager.SubProtocols = append(manager.SubProtocols, p2p.Protocol{
+				Name:    snap.ProtocolName,
+				Version: version,
+				Length:  snap.ProtocolLengths[i],
+				Run: func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+					select {
+					case <-manager.quitSync:
+						return p2p.DiscQuitting
+					default:
+					}
+					return snap.Handle(blockchain.StateCache().TrieDB(), blockchain.Snapshots(), manager.snapSyncer, snap.NewPeer(version, p, rw))
+				},
+			})
+		}
+	}
 	// Construct the different synchronisation mechanisms
 	manager.downloader = downloader.New(mode, chaindb, manager.eventMux, blockchain, nil, manager.removePeer)
+	if mode == downloader.FastSync {
+		manager.downloader.SetSnapSyncer(manager.snapSyncer)
+	}
 
 	validator := func(header *types.Header) error {
 		return engine.VerifyHeader(blockchain, header, true)
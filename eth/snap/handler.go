@@ -0,0 +1,276 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snap
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
+	"github.com/ethereum/go-ethereum/light"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+const (
+	// softResponseLimit is the target maximum size of replies to data retrievals.
+	softResponseLimit = 2 * 1024 * 1024
+
+	// maxCodeLookups is the maximum number of bytecodes to serve. This number is
+	// there to limit the number of disk lookups.
+	maxCodeLookups = 1024
+
+	// maxStorageLookups is the maximum number of accounts whose storage to serve.
+	// This number is there to limit the number of disk lookups.
+	maxStorageLookups = 1024
+)
+
+// Handle is the callback invoked to manage the life cycle of a snap peer. It
+// serves the state requests of the peer from the state snapshot, proving them
+// with the tries of the trie database, and delivers its replies to the syncer.
+// Without a snapshot, only contract codes are served. When this function
+// terminates, the peer is disconnected.
+func Handle(triedb *trie.Database, snaps *snapshot.Tree, syncer *Syncer, peer *Peer) error {
+	if err := syncer.Register(peer); err != nil {
+		peer.Log().Error("Snapshot peer registration failed", "err", err)
+		return err
+	}
+	defer syncer.Unregister(peer.id)
+
+	for {
+		if err := handleMessage(triedb, snaps, syncer, peer); err != nil {
+			peer.Log().Debug("Snapshot message handling failed", "err", err)
+			return err
+		}
+	}
+}
+
+// handleMessage is invoked whenever an inbound message is received from a remote
+// peer. The remote connection is torn down upon returning any error.
+func handleMessage(triedb *trie.Database, snaps *snapshot.Tree, syncer *Syncer, peer *Peer) error {
+	// Read the next message from the remote peer, and ensure it's fully consumed
+	msg, err := peer.rw.ReadMsg()
+	if err != nil {
+		return err
+	}
+	if msg.Size > ProtocolMaxMsgSize {
+		return fmt.Errorf("%v: %v > %v", errMsgTooLarge, msg.Size, ProtocolMaxMsgSize)
+	}
+	defer msg.Discard()
+
+	// Handle the message depending on its contents
+	switch msg.Code {
+	case GetAccountRangeMsg:
+		var req getAccountRangeData
+		if err := msg.Decode(&req); err != nil {
+			return fmt.Errorf("%v: msg %v: %v", errDecode, msg, err)
+		}
+		return peer.sendAccountRange(serviceAccountRange(triedb, snaps, &req))
+
+	case AccountRangeMsg:
+		var res accountRangeData
+		if err := msg.Decode(&res); err != nil {
+			return fmt.Errorf("%v: msg %v: %v", errDecode, msg, err)
+		}
+		return syncer.deliverResponse(&response{id: res.ID, peer: peer.id, accounts: res.Accounts, proof: res.Proof})
+
+	case GetStorageRangesMsg:
+		var req getStorageRangesData
+		if err := msg.Decode(&req); err != nil {
+			return fmt.Errorf("%v: msg %v: %v", errDecode, msg, err)
+		}
+		return peer.sendStorageRanges(serviceStorageRanges(triedb, snaps, &req))
+
+	case StorageRangesMsg:
+		var res storageRangesData
+		if err := msg.Decode(&res); err != nil {
+			return fmt.Errorf("%v: msg %v: %v", errDecode, msg, err)
+		}
+		return syncer.deliverResponse(&response{id: res.ID, peer: peer.id, slots: res.Slots, proof: res.Proof})
+
+	case GetByteCodesMsg:
+		var req getByteCodesData
+		if err := msg.Decode(&req); err != nil {
+			return fmt.Errorf("%v: msg %v: %v", errDecode, msg, err)
+		}
+		return peer.sendByteCodes(serviceByteCodes(triedb, &req))
+
+	case ByteCodesMsg:
+		var res byteCodesData
+		if err := msg.Decode(&res); err != nil {
+			return fmt.Errorf("%v: msg %v: %v", errDecode, msg, err)
+		}
+		return syncer.deliverResponse(&response{id: res.ID, peer: peer.id, codes: res.Codes})
+
+	default:
+		return fmt.Errorf("%v: %v", errInvalidMsgCode, msg.Code)
+	}
+}
+
+// serviceAccountRange assembles the response to an account range query from the
+// snapshot. The range is returned empty without a proof if the snapshot or the
+// trie of the requested state is unavailable.
+func serviceAccountRange(triedb *trie.Database, snaps *snapshot.Tree, req *getAccountRangeData) *accountRangeData {
+	if req.Bytes > softResponseLimit {
+		req.Bytes = softResponseLimit
+	}
+	res := &accountRangeData{ID: req.ID}
+	if snaps == nil {
+		return res
+	}
+	tr, err := trie.New(req.Root, triedb)
+	if err != nil {
+		return res
+	}
+	it, err := snaps.AccountIterator(req.Root, req.Origin)
+	if err != nil {
+		return res
+	}
+	defer it.Release()
+
+	// Gather the accounts up to and including the first one beyond the limit,
+	// or until the size allowance is reached
+	var (
+		accounts []*accountData
+		size     uint64
+	)
+	for it.Next() {
+		hash := it.Hash()
+		accounts = append(accounts, &accountData{Hash: hash, Body: common.CopyBytes(it.Value())})
+
+		size += uint64(common.HashLength + len(it.Value()))
+		if bytes.Compare(hash[:], req.Limit[:]) >= 0 || size >= req.Bytes {
+			break
+		}
+	}
+	if it.Error() != nil {
+		return res
+	}
+	// Prove the edges of the range, the origin and the last account
+	var proof light.NodeList
+	if err := tr.Prove(req.Origin[:], 0, &proof); err != nil {
+		return res
+	}
+	if len(accounts) > 0 {
+		if err := tr.Prove(accounts[len(accounts)-1].Hash[:], 0, &proof); err != nil {
+			return res
+		}
+	}
+	res.Accounts = accounts
+	for _, node := range proof {
+		res.Proof = append(res.Proof, node)
+	}
+	return res
+}
+
+// serviceStorageRanges assembles the response to a storage ranges query from
+// the snapshot. Only the last range is proven, if it doesn't contain the
+// complete storage of the account.
+func serviceStorageRanges(triedb *trie.Database, snaps *snapshot.Tree, req *getStorageRangesData) *storageRangesData {
+	if req.Bytes > softResponseLimit {
+		req.Bytes = softResponseLimit
+	}
+	res := &storageRangesData{ID: req.ID}
+	if snaps == nil {
+		return res
+	}
+	snap := snaps.Snapshot(req.Root)
+	if snap == nil {
+		return res
+	}
+	var size uint64
+	for i, account := range req.Accounts {
+		if size >= req.Bytes || i >= maxStorageLookups {
+			break
+		}
+		// The origin only applies to the first account
+		var origin common.Hash
+		if i == 0 {
+			origin = req.Origin
+		}
+		acc, err := snap.Account(account)
+		if err != nil || acc == nil {
+			break
+		}
+		it, err := snaps.StorageIterator(req.Root, account, origin)
+		if err != nil {
+			break
+		}
+		// Gather the storage slots until the size allowance is reached
+		var (
+			slots []*storageData
+			abort bool
+		)
+		for it.Next() {
+			if size >= req.Bytes {
+				abort = true
+				break
+			}
+			slots = append(slots, &storageData{Hash: it.Hash(), Body: common.CopyBytes(it.Value())})
+			size += uint64(common.HashLength + len(it.Value()))
+		}
+		err = it.Error()
+		it.Release()
+		if err != nil {
+			break
+		}
+		res.Slots = append(res.Slots, slots)
+
+		// If the storage range is partial, prove its edges and stop
+		if origin != (common.Hash{}) || abort {
+			stTrie, err := trie.New(acc.Root, triedb)
+			if err != nil {
+				return &storageRangesData{ID: req.ID}
+			}
+			var proof light.NodeList
+			if err := stTrie.Prove(origin[:], 0, &proof); err != nil {
+				return &storageRangesData{ID: req.ID}
+			}
+			if len(slots) > 0 {
+				if err := stTrie.Prove(slots[len(slots)-1].Hash[:], 0, &proof); err != nil {
+					return &storageRangesData{ID: req.ID}
+				}
+			}
+			for _, node := range proof {
+				res.Proof = append(res.Proof, node)
+			}
+			break
+		}
+	}
+	return res
+}
+
+// serviceByteCodes assembles the response to a bytecode query, skipping any code
+// not found.
+func serviceByteCodes(triedb *trie.Database, req *getByteCodesData) *byteCodesData {
+	if req.Bytes > softResponseLimit {
+		req.Bytes = softResponseLimit
+	}
+	res := &byteCodesData{ID: req.ID}
+
+	var size uint64
+	for i, hash := range req.Hashes {
+		if size >= req.Bytes || i >= maxCodeLookups {
+			break
+		}
+		if code, err := triedb.Node(hash); err == nil {
+			res.Codes = append(res.Codes, code)
+			size += uint64(len(code))
+		}
+	}
+	return res
+}
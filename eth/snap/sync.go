@@ -0,0 +1,710 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snap
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/light"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+const (
+	// maxRequestSize is the maximum number of bytes to request from a remote peer.
+	maxRequestSize = 512 * 1024
+
+	// maxCodeRequestCount is the maximum number of bytecode blobs to request in a
+	// single query.
+	maxCodeRequestCount = 64
+
+	// maxStorageSetRequestCount is the maximum number of contracts to request the
+	// storage of in a single query.
+	maxStorageSetRequestCount = 64
+
+	// accountConcurrency is the number of intervals the account trie is split into
+	// to retrieve them concurrently. The intervals are aligned to the branches of
+	// the root node.
+	accountConcurrency = 16
+
+	// requestTimeout is the maximum time a peer is allowed to spend on serving a
+	// single network request.
+	requestTimeout = 10 * time.Second
+
+	// logInterval is the frequency of the sync progress reports.
+	logInterval = 8 * time.Second
+)
+
+var (
+	// emptyRoot is the known root hash of an empty trie.
+	emptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+
+	// emptyCode is the known hash of the empty EVM bytecode.
+	emptyCode = crypto.Keccak256Hash(nil)
+)
+
+var (
+	errCancelled         = errors.New("sync cancelled")
+	errAlreadyRegistered = errors.New("peer is already registered")
+	errNotRegistered     = errors.New("peer is not registered")
+)
+
+// accountTask represents an interval of the account trie, retrieved in order and
+// inserted into a trie of its own. As the interval is aligned to a branch of the
+// root node, the nodes below the branch are the same as of the full trie.
+type accountTask struct {
+	next common.Hash // Next account to sync in this interval
+	last common.Hash // Last account to sync in this interval
+	trie *trie.Trie  // Trie the accounts of the interval are inserted into
+
+	req  *request         // Pending retrieval of the next accounts, nil if idle
+	res  *accountResponse // Accounts waiting for their storage and code to be committed
+	pend int              // Number of storage and code retrievals pending for the accounts
+	done bool             // Flag whether the interval was fully retrieved
+}
+
+// accountResponse is a verified range of accounts, committed into the trie of its
+// interval once the storage and code of all the accounts are available.
+type accountResponse struct {
+	hashes []common.Hash // Hashes of the retrieved accounts
+	bodies [][]byte      // Bodies of the retrieved accounts in the trie encoding
+	cont   bool          // Whether the interval continues after the accounts
+}
+
+// storageTask represents the retrieval of the storage trie of an account.
+type storageTask struct {
+	owner   *accountTask // Interval whose accounts wait for the storage
+	account common.Hash  // Hash of the account owning the storage
+	root    common.Hash  // Storage root the slots are verified against
+	next    common.Hash  // Next slot to retrieve, non-zero if partially retrieved
+	trie    *trie.Trie   // Trie the slots are inserted into
+}
+
+// codeTask represents the retrieval of a contract code, shared by all accounts
+// running the same code.
+type codeTask struct {
+	owners []*accountTask // Intervals whose accounts wait for the code
+	req    *request       // Pending retrieval of the code, nil if idle
+}
+
+// request is a retrieval in flight to a remote peer. Exactly one of the task
+// fields is set, depending on the type of the request.
+type request struct {
+	id      uint64      // Request ID to match up responses with
+	peer    string      // Peer the request was sent to
+	timeout *time.Timer // Timer to fire when the peer doesn't respond in time
+
+	account  *accountTask   // Account interval being retrieved
+	storages []*storageTask // Storage tries being retrieved
+	codes    []common.Hash  // Contract codes being retrieved
+}
+
+// response is the reply of a remote peer, delivered to the sync loop.
+type response struct {
+	id   uint64 // ID of the request this is a response for
+	peer string // Peer that sent the response
+
+	accounts []*accountData   // Accounts of an account range response
+	slots    [][]*storageData // Storage slots of a storage ranges response
+	codes    [][]byte         // Contract codes of a bytecode response
+	proof    [][]byte         // Proof of the edges of a range response
+}
+
+// Syncer retrieves the state of a root in bulk, as ranges of the account and
+// storage tries verified by their edge proofs, inserting them into tries of their
+// own. As peers only serve recent states, the retrieved ranges may belong to
+// different roots and some might be missing altogether. The resulting state is
+// thus expected to be healed by a trie node sync of the final root, which will
+// only retrieve the nodes not yet available.
+//
+// Retrieved nodes are only written to the database if the storage and code of all
+// the accounts below them were written before, as the trie node sync assumes that
+// everything below an existing node is available.
+type Syncer struct {
+	db     ethdb.Database // Database to store the retrieved state into
+	triedb *trie.Database // Trie database the retrieved tries are committed through
+
+	root     common.Hash               // Current state root being synced
+	tasks    []*accountTask            // Account intervals, retaining progress across runs
+	storage  []*storageTask            // Storage retrievals waiting to be assigned
+	codes    map[common.Hash]*codeTask // Contract code retrievals, assigned or not
+	requests map[uint64]*request       // Retrievals currently in flight
+	busy     map[string]struct{}       // Peers with a retrieval in flight
+	useless  map[string]struct{}       // Peers not serving the current root, or faulty
+
+	accountSynced, slotSynced, codeSynced uint64    // Number of state entries retrieved
+	logged                                time.Time // Timestamp of the last progress report
+
+	peers   map[string]*Peer // Peers speaking the snap protocol
+	update  chan struct{}    // Notification channel for peer arrivals and departures
+	deliver chan *response   // Delivery channel multiplexing peer responses
+	timeout chan *request    // Notification channel for timed out requests
+	quit    chan struct{}    // Termination channel of the running sync, nil if idle
+	lock    sync.RWMutex     // Protects the peer set and the sync lifecycle
+}
+
+// NewSyncer creates a state range syncer storing into the given database.
+func NewSyncer(db ethdb.Database) *Syncer {
+	return &Syncer{
+		db:      db,
+		triedb:  trie.NewDatabase(db),
+		codes:   make(map[common.Hash]*codeTask),
+		peers:   make(map[string]*Peer),
+		update:  make(chan struct{}, 1),
+		deliver: make(chan *response),
+		timeout: make(chan *request),
+	}
+}
+
+// Register injects a new peer into the set of peers the state is retrieved from.
+func (s *Syncer) Register(peer *Peer) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.peers[peer.id]; ok {
+		return errAlreadyRegistered
+	}
+	s.peers[peer.id] = peer
+	s.notify()
+	return nil
+}
+
+// Unregister removes a peer from the set of peers the state is retrieved from.
+func (s *Syncer) Unregister(id string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.peers[id]; !ok {
+		return errNotRegistered
+	}
+	delete(s.peers, id)
+	s.notify()
+	return nil
+}
+
+// notify signals the sync loop that the peer set changed. The caller must hold
+// the lock.
+func (s *Syncer) notify() {
+	select {
+	case s.update <- struct{}{}:
+	default:
+	}
+}
+
+// deliverResponse injects a response of a remote peer into the running sync.
+func (s *Syncer) deliverResponse(res *response) error {
+	s.lock.RLock()
+	quit := s.quit
+	s.lock.RUnlock()
+
+	if quit == nil {
+		return nil // Stale responses of a finished sync are dropped
+	}
+	select {
+	case s.deliver <- res:
+	case <-quit:
+	}
+	return nil
+}
+
+// Sync retrieves the state of the given root until all the account intervals are
+// done, the cancel channel is closed, or no peer is left to serve the remaining
+// state. The progress of the intervals is retained for the next invocation. An
+// error is only returned if the sync was cancelled or failed to write the state.
+func (s *Syncer) Sync(root common.Hash, cancel chan struct{}) error {
+	s.lock.Lock()
+	if s.quit != nil {
+		s.lock.Unlock()
+		return errors.New("sync already running")
+	}
+	quit := make(chan struct{})
+	s.quit = quit
+
+	s.root = root
+	if s.tasks == nil {
+		s.tasks = s.newAccountTasks()
+	}
+	s.requests = make(map[uint64]*request)
+	s.busy = make(map[string]struct{})
+	s.useless = make(map[string]struct{})
+	s.lock.Unlock()
+
+	defer func() {
+		s.reset()
+
+		s.lock.Lock()
+		close(quit)
+		s.quit = nil
+		s.lock.Unlock()
+	}()
+	log.Info("Starting state range sync", "root", root)
+	s.logged = time.Now()
+
+	for {
+		if s.complete() {
+			log.Info("Finished state range sync", "root", root, "accounts", s.accountSynced, "slots", s.slotSynced, "codes", s.codeSynced)
+			return nil
+		}
+		s.assignTasks(root, quit)
+		if len(s.requests) == 0 {
+			log.Info("Suspended state range sync, no peers to serve the state", "root", root, "accounts", s.accountSynced, "slots", s.slotSynced, "codes", s.codeSynced)
+			return nil
+		}
+		select {
+		case <-s.update:
+			// Peer set changed, revert the requests of departed peers
+			s.lock.RLock()
+			for id, req := range s.requests {
+				if _, ok := s.peers[req.peer]; !ok {
+					req.timeout.Stop()
+					delete(s.requests, id)
+					delete(s.busy, req.peer)
+					s.revert(req)
+				}
+			}
+			s.lock.RUnlock()
+
+		case <-cancel:
+			return errCancelled
+
+		case res := <-s.deliver:
+			req := s.requests[res.id]
+			if req == nil || req.peer != res.peer {
+				log.Debug("Unrequested state range response", "peer", res.peer, "reqid", res.id)
+				continue
+			}
+			req.timeout.Stop()
+			delete(s.requests, res.id)
+			delete(s.busy, req.peer)
+
+			var err error
+			switch {
+			case req.account != nil:
+				err = s.processAccounts(req, res)
+			case req.storages != nil:
+				err = s.processStorage(req, res)
+			default:
+				err = s.processCodes(req, res)
+			}
+			if err != nil {
+				return err
+			}
+			if time.Since(s.logged) > logInterval {
+				log.Info("Syncing state ranges", "accounts", s.accountSynced, "slots", s.slotSynced, "codes", s.codeSynced)
+				s.logged = time.Now()
+			}
+
+		case req := <-s.timeout:
+			// Skip the timeout if the request was already answered
+			if s.requests[req.id] != req {
+				continue
+			}
+			log.Debug("State range request timed out", "peer", req.peer, "reqid", req.id)
+			delete(s.requests, req.id)
+			delete(s.busy, req.peer)
+			s.revert(req)
+		}
+	}
+}
+
+// newAccountTasks splits the account trie into the intervals retrieved
+// concurrently.
+func (s *Syncer) newAccountTasks() []*accountTask {
+	tasks := make([]*accountTask, accountConcurrency)
+	for i := range tasks {
+		var next, last common.Hash
+		next[0] = byte(i * 256 / accountConcurrency)
+		last[0] = byte((i+1)*256/accountConcurrency - 1)
+		for j := 1; j < common.HashLength; j++ {
+			last[j] = 0xff
+		}
+		tr, _ := trie.New(common.Hash{}, s.triedb)
+		tasks[i] = &accountTask{next: next, last: last, trie: tr}
+	}
+	return tasks
+}
+
+// complete returns whether all the account intervals were retrieved.
+func (s *Syncer) complete() bool {
+	for _, task := range s.tasks {
+		if !task.done {
+			return false
+		}
+	}
+	return true
+}
+
+// reset drops all the retrievals of a finished sync run, along with the accounts
+// not yet committed. The committed progress of the intervals is retained.
+func (s *Syncer) reset() {
+	for _, req := range s.requests {
+		req.timeout.Stop()
+	}
+	s.requests = nil
+	for _, task := range s.tasks {
+		task.req, task.res, task.pend = nil, nil, 0
+	}
+	s.storage = nil
+	s.codes = make(map[common.Hash]*codeTask)
+}
+
+// revert returns the tasks of a failed request to the retrieval queues.
+func (s *Syncer) revert(req *request) {
+	switch {
+	case req.account != nil:
+		req.account.req = nil
+	case req.storages != nil:
+		s.storage = append(req.storages, s.storage...)
+	default:
+		for _, hash := range req.codes {
+			if task := s.codes[hash]; task != nil && task.req == req {
+				task.req = nil
+			}
+		}
+	}
+}
+
+// assignTasks sends a retrieval to every idle peer serving the current root,
+// preferring the code and storage blocking account intervals over new accounts.
+func (s *Syncer) assignTasks(root common.Hash, quit chan struct{}) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	for id, peer := range s.peers {
+		if _, ok := s.busy[id]; ok {
+			continue
+		}
+		if _, ok := s.useless[id]; ok {
+			continue
+		}
+		req := &request{id: rand.Uint64(), peer: id}
+
+		var err error
+		switch {
+		case s.fillCodes(req):
+			err = peer.RequestByteCodes(req.id, req.codes, maxRequestSize)
+
+		case len(s.storage) > 0:
+			// Partially retrieved storage is requested alone, continuing from where
+			// it was left off, other storage is retrieved in batches
+			var origin common.Hash
+			if s.storage[0].next != (common.Hash{}) {
+				req.storages, s.storage, origin = s.storage[:1:1], s.storage[1:], s.storage[0].next
+			} else {
+				for len(s.storage) > 0 && len(req.storages) < maxStorageSetRequestCount && s.storage[0].next == (common.Hash{}) {
+					req.storages, s.storage = append(req.storages, s.storage[0]), s.storage[1:]
+				}
+			}
+			accounts := make([]common.Hash, len(req.storages))
+			for i, task := range req.storages {
+				accounts[i] = task.account
+			}
+			err = peer.RequestStorageRanges(req.id, root, accounts, origin, maxRequestSize)
+
+		default:
+			for _, task := range s.tasks {
+				if !task.done && task.req == nil && task.res == nil {
+					task.req, req.account = req, task
+					break
+				}
+			}
+			if req.account == nil {
+				continue
+			}
+			err = peer.RequestAccountRange(req.id, root, req.account.next, req.account.last, maxRequestSize)
+		}
+		if err != nil {
+			peer.Log().Debug("Failed to request state range", "err", err)
+			s.useless[id] = struct{}{}
+			s.revert(req)
+			continue
+		}
+		req.timeout = time.AfterFunc(requestTimeout, func() {
+			select {
+			case s.timeout <- req:
+			case <-quit:
+			}
+		})
+		s.requests[req.id] = req
+		s.busy[id] = struct{}{}
+	}
+}
+
+// fillCodes assigns the idle contract code retrievals to a request, reporting
+// whether there were any.
+func (s *Syncer) fillCodes(req *request) bool {
+	for hash, task := range s.codes {
+		if len(req.codes) >= maxCodeRequestCount {
+			break
+		}
+		if task.req == nil {
+			task.req, req.codes = req, append(req.codes, hash)
+		}
+	}
+	return len(req.codes) > 0
+}
+
+// processAccounts verifies a range of accounts and schedules the retrieval of
+// their storage and code, committing them right away if nothing is missing.
+func (s *Syncer) processAccounts(req *request, res *response) error {
+	task := req.account
+	task.req = nil
+
+	// An empty response without a proof means the peer doesn't have the state
+	if len(res.accounts) == 0 && len(res.proof) == 0 {
+		s.useless[res.peer] = struct{}{}
+		return nil
+	}
+	keys := make([][]byte, len(res.accounts))
+	bodies := make([][]byte, len(res.accounts))
+	for i, account := range res.accounts {
+		keys[i], bodies[i] = common.CopyBytes(account.Hash[:]), account.Body
+	}
+	var last []byte
+	if len(keys) > 0 {
+		last = keys[len(keys)-1]
+	}
+	cont, err := trie.VerifyRangeProof(s.root, task.next[:], last, keys, bodies, proofSet(res.proof))
+	if err != nil {
+		log.Debug("Invalid account range", "peer", res.peer, "err", err)
+		s.useless[res.peer] = struct{}{}
+		return nil
+	}
+	// Drop the accounts beyond the interval, which are only there for the proof
+	hashes := make([]common.Hash, 0, len(keys))
+	for i, key := range keys {
+		if bytes.Compare(key, task.last[:]) > 0 {
+			bodies, cont = bodies[:i], false
+			break
+		}
+		hashes = append(hashes, common.BytesToHash(key))
+	}
+	// Schedule the retrieval of the storage and code not yet available
+	accounts := make([]state.Account, len(bodies))
+	for i, body := range bodies {
+		if err := rlp.DecodeBytes(body, &accounts[i]); err != nil {
+			log.Debug("Invalid account in range", "peer", res.peer, "err", err)
+			s.useless[res.peer] = struct{}{}
+			return nil
+		}
+	}
+	task.res = &accountResponse{hashes: hashes, bodies: bodies, cont: cont}
+	for i, acc := range accounts {
+		if acc.Root != emptyRoot {
+			if ok, _ := s.db.Has(acc.Root[:]); !ok {
+				s.storage = append(s.storage, &storageTask{owner: task, account: hashes[i], root: acc.Root})
+				task.pend++
+			}
+		}
+		if hash := common.BytesToHash(acc.CodeHash); hash != emptyCode {
+			if ok, _ := s.db.Has(hash[:]); !ok {
+				if s.codes[hash] == nil {
+					s.codes[hash] = new(codeTask)
+				}
+				s.codes[hash].owners = append(s.codes[hash].owners, task)
+				task.pend++
+			}
+		}
+	}
+	s.accountSynced += uint64(len(hashes))
+	if task.pend == 0 {
+		return s.commitAccounts(task)
+	}
+	return nil
+}
+
+// processStorage verifies the storage ranges of a batch of accounts, committing
+// the completed storage tries and rescheduling the rest.
+func (s *Syncer) processStorage(req *request, res *response) error {
+	// An empty response without a proof means the peer doesn't have the state
+	if (len(res.slots) == 0 && len(res.proof) == 0) || len(res.slots) > len(req.storages) {
+		s.useless[res.peer] = struct{}{}
+		s.revert(req)
+		return nil
+	}
+	var done []*storageTask
+	for i, slots := range res.slots {
+		task := req.storages[i]
+
+		keys := make([][]byte, len(slots))
+		values := make([][]byte, len(slots))
+		for j, slot := range slots {
+			keys[j], values[j] = common.CopyBytes(slot.Hash[:]), slot.Body
+		}
+		// Only the last range is proven, all others must be complete
+		var (
+			proof       trie.DatabaseReader
+			first, last []byte
+		)
+		if i == len(res.slots)-1 && len(res.proof) > 0 {
+			proof, first = proofSet(res.proof), task.next[:]
+			if len(keys) > 0 {
+				last = keys[len(keys)-1]
+			}
+		}
+		cont, err := trie.VerifyRangeProof(task.root, first, last, keys, values, proof)
+		if err != nil {
+			log.Debug("Invalid storage range", "peer", res.peer, "err", err)
+			s.useless[res.peer] = struct{}{}
+			s.storage = append(req.storages[i:], s.storage...)
+			break
+		}
+		if task.trie == nil {
+			task.trie, _ = trie.New(common.Hash{}, s.triedb)
+		}
+		for j, key := range keys {
+			task.trie.Update(key, values[j])
+		}
+		s.slotSynced += uint64(len(keys))
+
+		root, err := s.commitTrie(task.trie)
+		if err != nil {
+			return err
+		}
+		if cont {
+			// Storage partially retrieved, continue it with priority
+			task.next = common.BytesToHash(increaseKey(common.CopyBytes(last)))
+			s.storage = append([]*storageTask{task}, s.storage...)
+			continue
+		}
+		if root != task.root {
+			log.Error("Storage trie mismatch after verified retrieval", "account", task.account, "have", root, "want", task.root)
+		}
+		done = append(done, task)
+	}
+	// Reschedule the storage not delivered
+	if len(res.slots) < len(req.storages) {
+		if _, ok := s.useless[res.peer]; !ok {
+			s.storage = append(req.storages[len(res.slots):], s.storage...)
+		}
+	}
+	for _, task := range done {
+		if err := s.resolve(task.owner); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// processCodes verifies and writes a batch of contract codes, rescheduling the
+// codes not delivered.
+func (s *Syncer) processCodes(req *request, res *response) error {
+	if len(res.codes) == 0 {
+		s.useless[res.peer] = struct{}{}
+	}
+	var (
+		batch = s.db.NewBatch()
+		done  []*codeTask
+	)
+	for _, code := range res.codes {
+		hash := crypto.Keccak256Hash(code)
+		task := s.codes[hash]
+		if task == nil || task.req != req {
+			continue
+		}
+		if err := batch.Put(hash[:], code); err != nil {
+			return err
+		}
+		delete(s.codes, hash)
+		done = append(done, task)
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	s.codeSynced += uint64(len(done))
+
+	// Reschedule the codes not delivered and resolve the waiting accounts
+	s.revert(req)
+	for _, task := range done {
+		for _, owner := range task.owners {
+			if err := s.resolve(owner); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resolve marks a storage or code retrieval of an account interval as done,
+// committing its accounts if nothing else is missing.
+func (s *Syncer) resolve(task *accountTask) error {
+	if task.res == nil {
+		return nil // Accounts dropped since the retrieval was scheduled
+	}
+	if task.pend--; task.pend > 0 {
+		return nil
+	}
+	return s.commitAccounts(task)
+}
+
+// commitAccounts inserts the retrieved accounts of an interval into its trie and
+// writes it into the database, advancing the interval.
+func (s *Syncer) commitAccounts(task *accountTask) error {
+	res := task.res
+	for i, hash := range res.hashes {
+		task.trie.Update(hash[:], res.bodies[i])
+	}
+	if _, err := s.commitTrie(task.trie); err != nil {
+		return err
+	}
+	task.res = nil
+	if !res.cont {
+		task.done = true
+		return nil
+	}
+	last := res.hashes[len(res.hashes)-1]
+	task.next = common.BytesToHash(increaseKey(common.CopyBytes(last[:])))
+	return nil
+}
+
+// commitTrie writes the nodes of a trie into the database.
+func (s *Syncer) commitTrie(tr *trie.Trie) (common.Hash, error) {
+	root, err := tr.Commit(nil)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return root, s.triedb.Commit(root, false)
+}
+
+// proofSet converts the nodes of a proof into a database verifying it against.
+func proofSet(proof [][]byte) trie.DatabaseReader {
+	nodes := make(light.NodeList, len(proof))
+	for i, node := range proof {
+		nodes[i] = node
+	}
+	return nodes.NodeSet()
+}
+
+// increaseKey returns the key incremented by one, in place.
+func increaseKey(key []byte) []byte {
+	for i := len(key) - 1; i >= 0; i-- {
+		key[i]++
+		if key[i] != 0x0 {
+			break
+		}
+	}
+	return key
+}
@@ -0,0 +1,230 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snap
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// makeTestState creates a state of the given number of accounts, every fifth one
+// a contract with a few storage slots, and a last contract whose storage exceeds
+// a single response, along with its snapshot.
+func makeTestState(t *testing.T, accounts int) (state.Database, *snapshot.Tree, common.Hash) {
+	diskdb := ethdb.NewMemDatabase()
+	db := state.NewDatabase(diskdb)
+	statedb, _ := state.New(common.Hash{}, db)
+
+	for i := 0; i < accounts; i++ {
+		addr := common.BigToAddress(big.NewInt(int64(i + 1)))
+		statedb.SetBalance(addr, big.NewInt(int64(i+1)))
+		statedb.SetNonce(addr, uint64(i))
+
+		if i%5 == 0 {
+			statedb.SetCode(addr, []byte{byte(i), byte(i >> 8), 0x01})
+			for j := 0; j < i%7+1; j++ {
+				statedb.SetState(addr, common.BigToHash(big.NewInt(int64(j+1))), common.BigToHash(big.NewInt(int64(i*j+1))))
+			}
+		}
+	}
+	whale := common.BigToAddress(big.NewInt(0xbeef))
+	statedb.SetCode(whale, []byte{0xbe, 0xef})
+	for j := 0; j < 20000; j++ {
+		statedb.SetState(whale, common.BigToHash(big.NewInt(int64(j+1))), common.BigToHash(big.NewInt(int64(j+1))))
+	}
+	root := commitTestState(t, db, statedb)
+
+	// Generate the snapshot of the state, waiting until it can be iterated
+	snaps, err := snapshot.New(diskdb, db.TrieDB(), 1, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for start := time.Now(); ; time.Sleep(time.Millisecond) {
+		it, err := snaps.AccountIterator(root, common.Hash{})
+		if err == nil {
+			it.Release()
+			break
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("snapshot generation timed out: %v", err)
+		}
+	}
+	return db, snaps, root
+}
+
+// commitTestState writes a test state into its database.
+func commitTestState(t *testing.T, db state.Database, statedb *state.StateDB) common.Hash {
+	root, err := statedb.Commit(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.TrieDB().Commit(root, false); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+// connectTestPeer connects a syncer to a peer serving the given state.
+func connectTestPeer(syncer *Syncer, source *trie.Database, snaps *snapshot.Tree, id byte) func() {
+	client, server := p2p.MsgPipe()
+
+	remote := NewPeer(snap1, p2p.NewPeer(discover.NodeID{id}, "server", nil), client)
+	go Handle(syncer.triedb, nil, syncer, remote)
+
+	local := NewPeer(snap1, p2p.NewPeer(discover.NodeID{0xff}, "client", nil), server)
+	go Handle(source, snaps, NewSyncer(ethdb.NewMemDatabase()), local)
+
+	// Wait for the registration of the remote peer
+	for {
+		syncer.lock.RLock()
+		_, ok := syncer.peers[remote.id]
+		syncer.lock.RUnlock()
+		if ok {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return func() {
+		client.Close()
+		server.Close()
+	}
+}
+
+// healTestState runs a trie node sync of a state, returning the number of nodes
+// retrieved.
+func healTestState(t *testing.T, source state.Database, db ethdb.Database, root common.Hash) int {
+	var (
+		sched = state.NewStateSync(root, db)
+		nodes int
+	)
+	for queue := sched.Missing(0); len(queue) > 0; queue = sched.Missing(0) {
+		results := make([]trie.SyncResult, len(queue))
+		for i, hash := range queue {
+			data, err := source.TrieDB().Node(hash)
+			if err != nil {
+				t.Fatalf("failed to retrieve node data for %x", hash)
+			}
+			results[i] = trie.SyncResult{Hash: hash, Data: data}
+		}
+		if _, index, err := sched.Process(results); err != nil {
+			t.Fatalf("failed to process result #%d: %v", index, err)
+		}
+		if _, err := sched.Commit(db); err != nil {
+			t.Fatalf("failed to commit data: %v", err)
+		}
+		nodes += len(queue)
+	}
+	return nodes
+}
+
+// checkTestState verifies that the complete state of a root is available.
+func checkTestState(t *testing.T, db ethdb.Database, root common.Hash) {
+	statedb, err := state.New(root, state.NewDatabase(db))
+	if err != nil {
+		t.Fatalf("state root missing: %v", err)
+	}
+	it := state.NewNodeIterator(statedb)
+	for it.Next() {
+	}
+	if it.Error != nil {
+		t.Fatalf("state incomplete: %v", it.Error)
+	}
+}
+
+// Tests that the state is retrieved in ranges from multiple peers, leaving only
+// the root node to be healed.
+func TestSync(t *testing.T) {
+	source, snaps, root := makeTestState(t, 1000)
+
+	db := ethdb.NewMemDatabase()
+	syncer := NewSyncer(db)
+	for i := byte(0); i < 3; i++ {
+		defer connectTestPeer(syncer, source.TrieDB(), snaps, i)()
+	}
+	if err := syncer.Sync(root, make(chan struct{})); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	if !syncer.complete() {
+		t.Fatalf("sync not complete")
+	}
+	if nodes := healTestState(t, source, db, root); nodes != 1 {
+		t.Errorf("healed node count mismatch: have %d, want %d", nodes, 1)
+	}
+	checkTestState(t, db, root)
+}
+
+// Tests that a state retrieved for a previous root is healed into a newer one,
+// and that peers without the requested state are skipped.
+func TestSyncHeal(t *testing.T) {
+	source, snaps, root := makeTestState(t, 1000)
+
+	db := ethdb.NewMemDatabase()
+	syncer := NewSyncer(db)
+
+	// A peer without the state suspends the sync without retrieving anything
+	defer connectTestPeer(syncer, trie.NewDatabase(ethdb.NewMemDatabase()), nil, 0)()
+	if err := syncer.Sync(root, make(chan struct{})); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	if db.Len() != 0 {
+		t.Fatalf("state written without serving peers: %d entries", db.Len())
+	}
+	defer connectTestPeer(syncer, source.TrieDB(), snaps, 1)()
+	if err := syncer.Sync(root, make(chan struct{})); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	// Modify the state and heal the retrieved one into it
+	statedb, _ := state.New(root, source)
+	for i := 0; i < 10; i++ {
+		addr := common.BigToAddress(big.NewInt(int64(i*50 + 1)))
+		statedb.SetBalance(addr, big.NewInt(0xfff))
+		statedb.SetState(addr, common.Hash{0x01}, common.Hash{0x01})
+	}
+	newRoot := commitTestState(t, source, statedb)
+
+	total := healTestState(t, source, ethdb.NewMemDatabase(), newRoot)
+	if nodes := healTestState(t, source, db, newRoot); nodes >= total/10 {
+		t.Errorf("healed too many nodes: have %d, total %d", nodes, total)
+	}
+	checkTestState(t, db, newRoot)
+}
+
+// Tests that the number of accounts whose storage is served in one response is
+// capped.
+func TestServiceStorageRangesLimit(t *testing.T) {
+	source, snaps, root := makeTestState(t, 10)
+
+	account := crypto.Keccak256Hash(common.BigToAddress(big.NewInt(2)).Bytes())
+	req := &getStorageRangesData{Root: root, Bytes: softResponseLimit}
+	for i := 0; i < 2*maxStorageLookups; i++ {
+		req.Accounts = append(req.Accounts, account)
+	}
+	res := serviceStorageRanges(source.TrieDB(), snaps, req)
+	if len(res.Slots) != maxStorageLookups {
+		t.Errorf("served storage range count mismatch: have %d, want %d", len(res.Slots), maxStorageLookups)
+	}
+}
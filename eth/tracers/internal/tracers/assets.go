@@ -3,6 +3,7 @@
 // 4byte_tracer.js
 // bigram_tracer.js
 // call_tracer.js
+// eni_tracer.js
 // evmdis_tracer.js
 // noop_tracer.js
 // opcount_tracer.js
@@ -137,6 +138,26 @@ func call_tracerJs() (*asset, error) {
 	return a, nil
 }
 
+var _eni_tracerJs = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x8d\x54\x5d\x6f\xdb\x46\x10\x7c\x96\x7e\xc5\xf6\x4d\x06\x14\xb2\x1f\x2f\x85\x12\x17\x50\x0c\xc9\x11\xe0\xc8\x86\x24\x37\x30\x8a\x3c\x9c\xc8\x25\x79\xe8\x89\x27\xdc\x1d\xa5\x08\x81\xff\x7b\x66\x8f\x64\xa4\x1a\x6e\x1b\x03\xb6\xac\xdb\xdd\xd9\xd9\x9d\xb9\x4b\x53\xba\xb1\xfb\x93\xd3\x65\x15\xe8\xd7\x9f\x7f\xf9\x9d\x36\x15\x53\x69\xdf\x70\xa8\xd8\x71\xb3\xa3\x69\x13\x2a\xeb\xfc\x30\x4d\x11\xd2\x9e\x0a\x6d\x98\xf0\xb9\x57\x2e\x90\x2d\x28\xbc\xc8\x37\x7a\xeb\x94\x3b\x25\x28\x68\x6b\x5e\x0d\x0b\x42\xe1\x98\xc9\xdb\x22\x1c\x95\xe3\x09\x9d\x6c\x43\x99\xaa\xc9\x71\xae\x7d\x70\x7a\xdb\x04\x34\x0a\xa4\xea\x3c\xb5\x8e\x76\x36\xd7\xc5\x49\x20\x71\xd6\xd4\x39\xbb\xd8\x3a\xb0\xdb\xf9\x9e\xc7\xed\xf2\x91\xee\xd8\x7b\xc4\x6e\xb9\x66\xa7\x0c\x3d\x34\x5b\xa3\x33\xba\xd3\x19\xd7\x9e\x49\x81\xb8\x9c\xf8\x8a\x73\xda\x46\x38\x29\x9c\x0b\x95\x75\x47\x85\xe6\x16\xf8\x2a\x68\x5b\x8f\x89\xb5\x30\xa7\x03\x3b\x8f\xef\xf4\x5b\xdf\xaa\x03\x1c\x93\x75\x02\x32\x52\x41\x06\x70\x64\xf7\x52\x77\x05\xd6\x27\x32\x2a\x9c\x4b\x7f\x60\x21\xe7\xb9\x73\xd2\x75\x6c\x53\xd9\x3d\x66\xac\x80\x8e\xa9\x8f\xda\x18\xda\x32\x35\x9e\x8b\xc6\x8c\x05\x0d\xc9\xf4\x69\xb1\xf9\x70\xff\xb8\xa1\xe9\xf2\x89\x3e\x4d\x57\xab\xe9\x72\xf3\xf4\x16\xc9\xd0\x0d\x51\x3e\x70\x0b\xa5\x77\x7b\xa3\x81\x8c\x11\x9d\xaa\xc3\x09\x93\x08\xc2\xc7\xd9\xea\xe6\x03\x4a\xa6\xef\x17\x77\x8b\xcd\x13\xe6\xa1\xf9\x62\xb3\x9c\xad\xd7\x34\xbf\x5f\xd1\x94\x1e\xa6\xab\xcd\xe2\xe6\xf1\x6e\xba\xa2\x87\xc7\xd5\xc3\xfd\x7a\x96\xd0\x9a\x85\x15\x4b\xfd\xff\xef\xbc\x88\xea\x61\xaf\x39\x07\xa5\x8d\xef\x37\xf1\x04\xc1\x3d\x38\x9a\x9c\x2a\x75\x60\x08\x9f\xb1\x3e\x80\xa1\xa2\x0c\x9e\xfc\x61\x51\x05\x4b\x19\x5b\x97\x71\xe6\x7f\x35\x24\x2d\x0a\xaa\x6d\x18\x93\x07\xf9\x77\x55\x08\xfb\x49\x9a\x1e\x8f\xc7\xa4\xac\x9b\xc4\xba\x32\x35\x2d\x9c\x4f\xff\x48\x86\x82\xc9\xb5\xde\x38\x95\xa1\x2f\xb4\x51\x14\xb0\x35\xaf\x32\x91\x57\xfe\xcf\x18\xc8\x3e\x68\xb4\x95\x8e\x35\xfc\x82\x19\x8a\xa6\x8e\x19\x1e\x02\x1e\xec\xdf\x18\x26\x54\xce\x36\x65\xd5\x3b\x6d\xb6\x5c\xc0\x23\x99\xcd\x61\x9d\x7f\x92\xd6\x8e\x94\x2b\x9b\x1d\xd7\xc1\x8f\xa9\x84\x53\x33\xeb\x83\x17\xff\x63\x35\xa1\x71\x35\x1d\x94\x69\x18\xfb\xfb\x3a\x1c\x00\x2e\x53\xc6\x78\xe1\x26\xb8\xc2\x45\x36\x26\xf8\xd2\x3a\x53\x3d\x0d\xe2\x2f\x9c\x35\x91\xb6\x75\xb8\x36\xc9\x70\x10\x2b\x27\xf4\xd7\xe7\xf1\x30\x22\xf9\xc0\x7b\x01\xea\x39\x8b\x62\xf0\x0d\x5c\xd9\x52\x6d\x1d\x28\x6d\xfe\xfc\xd8\xc1\x09\x8d\x81\xd4\x4d\xbe\xcf\x3c\x32\xb6\x1c\x53\xbe\xbd\xa2\xaf\xf4\xdc\x21\x17\xaa\x31\xe1\x12\xfa\x58\x75\x76\xc4\x26\x1b\x48\x79\x41\xae\xc0\xa8\x7d\xc3\xa2\x35\xca\x20\xd6\xff\x77\x0b\xc8\xf4\x6a\x83\x17\x8a\x74\xf8\xe7\xfd\x77\x4b\x45\x09\xae\x70\xcb\x15\x4f\x5b\x8e\xa6\x40\xbc\x68\x29\xcb\xea\x7a\x0e\x07\x83\x83\xc2\x6a\xea\x80\xd5\x5c\xc7\xef\x83\x3e\x6f\x12\xf5\x48\x4a\x0e\xf3\xbe\xf2\x6a\x2c\x09\x10\x15\xbb\x96\x9f\x3e\x61\x8a\x93\x2e\x08\x99\xdb\xd8\xf7\xe0\xad\x42\x0c\xa1\xe7\xb7\xf8\xa3\x0b\x1a\xf5\x81\x99\x73\xd6\x8d\xae\xe8\xa7\xeb\xeb\xf8\xfe\x15\x1a\xdc\x5b\x52\x83\xc8\x28\x61\xc9\x00\xaf\x17\x15\x02\xf4\x4c\x6c\x70\x15\x2f\x92\x3b\x4b\x9d\xb3\x57\xf1\xa0\x4b\xc7\x6f\xc0\x6b\x9f\x44\xa7\x24\xfb\xc6\x57\xa3\x58\x26\xd1\x7e\xf1\x8e\xfd\x6b\xe2\xa2\x22\xee\xbf\xdd\xb2\x6f\xef\xf6\x96\x11\xd1\x78\x0e\x95\x3c\x6e\x16\xde\xba\xf0\xb5\x8f\x70\x52\x83\x99\xe0\x89\x0e\xb8\xbb\xff\x72\xd7\x70\xcb\xa0\x4b\x7b\x7e\x29\x4d\xf8\x72\x56\xa6\x1b\xe8\x4c\x5b\xa8\x0e\x9f\x87\xdf\x00\xb9\xef\x15\x3a\xe3\x06\x00\x00")
+
+func eni_tracerJsBytes() ([]byte, error) {
+	return bindataRead(
+		_eni_tracerJs,
+		"eni_tracer.js",
+	)
+}
+
+func eni_tracerJs() (*asset, error) {
+	bytes, err := eni_tracerJsBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "eni_tracer.js", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xbd, 0x2c, 0xbc, 0xa5, 0xba, 0xb5, 0x6a, 0xfb, 0xab, 0xf9, 0x7d, 0xde, 0xf7, 0x7d, 0x86, 0x3c, 0x8, 0x5f, 0xbf, 0x3e, 0xe5, 0xa8, 0xa0, 0x4a, 0x3a, 0xcd, 0xa5, 0xa8, 0x95, 0x4a, 0xbe, 0xea}}
+	return a, nil
+}
+
 var _evmdis_tracerJs = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xac\x57\xdf\x6f\xda\xca\x12\x7e\x86\xbf\x62\x94\x27\x50\x29\x60\x63\x08\x38\x27\x47\xe2\xa6\xf4\x1c\xae\xd2\x24\x02\x72\x8f\x2a\x94\x87\x05\xc6\xb0\xaa\xf1\x5a\xbb\x6b\x72\xb8\x55\xfe\xf7\xab\xd9\x59\x03\xf9\x75\xdb\x4a\xa7\x0f\x3b\xb5\x77\xbe\x6f\xbe\x9d\x19\xcf\x92\x56\x0b\xae\x54\xbe\xd7\x72\xbd\xb1\x10\xb6\x83\x73\x98\x6d\x10\xd6\xea\x23\xda\x0d\x6a\x2c\xb6\x30\x2c\xec\x46\x69\x53\x6d\xb5\x60\xb6\x91\x06\x12\x99\x22\x48\x03\xb9\xd0\x16\x54\x02\xf6\x85\x7f\x2a\x17\x5a\xe8\x7d\xb3\xda\x6a\x31\xe6\xcd\x6d\x62\x48\x34\x22\x18\x95\xd8\x47\xa1\x31\x86\xbd\x2a\x60\x29\x32\xd0\xb8\x92\xc6\x6a\xb9\x28\x2c\x82\xb4\x20\xb2\x55\x4b\x69\xd8\xaa\x95\x4c\xf6\x44\x29\x2d\x14\xd9\x0a\xb5\x0b\x6d\x51\x6f\x4d\xa9\xe3\x8f\x9b\x7b\xb8\x46\x63\x50\xc3\x1f\x98\xa1\x16\x29\xdc\x15\x8b\x54\x2e\xe1\x5a\x2e\x31\x33\x08\xc2\x40\x4e\x6f\xcc\x06\x57\xb0\x70\x74\x04\xfc\x4c\x52\xa6\x5e\x0a\x7c\x56\x45\xb6\x12\x56\xaa\xac\x01\x28\x49\x39\xec\x50\x1b\xa9\x32\xe8\x94\xa1\x3c\x61\x03\x94\x26\x92\x9a\xb0\x74\x00\x0d\x2a\x27\x5c\x1d\x44\xb6\x87\x54\xd8\x23\xf4\x27\x12\x72\x3c\xf7\x0a\x64\xe6\xc2\x6c\x54\x8e\x60\x37\xc2\xd2\xa9\x1f\x65\x9a\xc2\x02\xa1\x30\x98\x14\x69\x83\xd8\x16\x85\x85\xbf\xc6\xb3\x3f\x6f\xef\x67\x30\xbc\xf9\x0a\x7f\x0d\x27\x93\xe1\xcd\xec\xeb\x05\x3c\x4a\xbb\x51\x85\x05\xdc\x21\x53\xc9\x6d\x9e\x4a\x5c\xc1\xa3\xd0\x5a\x64\x76\x0f\x2a\x21\x86\x2f\xa3\xc9\xd5\x9f\xc3\x9b\xd9\xf0\x5f\xe3\xeb\xf1\xec\x2b\x28\x0d\x9f\xc7\xb3\x9b\xd1\x74\x0a\x9f\x6f\x27\x30\x84\xbb\xe1\x64\x36\xbe\xba\xbf\x1e\x4e\xe0\xee\x7e\x72\x77\x3b\x1d\x35\x61\x8a\xa4\x0a\x09\xff\xe3\x9c\x27\xae\x7a\x1a\x61\x85\x56\xc8\xd4\x94\x99\xf8\xaa\x0a\x30\x1b\x55\xa4\x2b\xd8\x88\x1d\x82\xc6\x25\xca\x1d\xae\x40\xc0\x52\xe5\xfb\x9f\x2e\x2a\x71\x89\x54\x65\x6b\x77\xe6\x77\x1b\x12\xc6\x09\x64\xca\x36\xc0\x20\xc2\x6f\x1b\x6b\xf3\xb8\xd5\x7a\x7c\x7c\x6c\xae\xb3\xa2\xa9\xf4\xba\x95\x32\x9d\x69\xfd\xde\xac\x12\x27\xee\xb6\x2b\x69\x66\x5a\x2c\x51\x83\x46\x5b\xe8\xcc\x80\x29\x92\x84\xfc\x2c\xc8\x2c\x51\x7a\xeb\xda\x04\x12\xad\xb6\x20\xc0\x92\x2f\x58\x05\x39\x6a\xda\xf4\x14\x1f\x8d\xdd\xa7\x4e\xe6\x4a\x1a\x61\x0c\x6e\x17\xe9\xbe\x59\xfd\x5e\xad\x18\x2b\x96\xdf\x62\x98\x7f\x57\xb9\x89\x61\xfe\xf0\xf4\xd0\xa8\x56\x2b\x59\x5e\x98\x0d\x9a\x18\xbe\xb7\x63\x68\x37\x20\x88\x21\x68\x40\xe8\xd6\x8e\x5b\x23\xb7\x76\xdd\xda\x73\xeb\xb9\x5b\xfb\x6e\x1d\xb8\x35\x68\xb3\x61\x74\xc0\x6e\x01\xfb\x05\xec\x18\xb0\x67\xc8\x9e\xa1\x8f\xc3\x81\x42\x8e\x14\x72\xa8\x90\x63\x85\xcc\xd2\x61\x97\x88\x59\x22\x66\xe9\x32\x4b\x97\x59\xba\xec\xd2\x65\x96\xae\x17\xdc\x75\xe7\xe9\x32\x4b\xf7\x9c\x9f\x98\xa5\xcb\x2c\x3d\x3e\x72\x8f\x01\x3d\x7f\x44\x06\xf4\x58\x7c\x8f\x01\x3d\x06\xf4\x19\xd0\xe7\xb0\xfd\x90\x9f\x3a\x6c\x98\xa5\xcf\x61\xfb\x3d\x36\x1c\xb6\xcf\x2c\x7d\x66\x19\xb0\xf8\x41\xe0\xf6\x06\x1c\x6f\xc0\xf1\x06\x3e\xab\x65\x5a\x7d\x5e\xdb\x3e\xb1\xed\xd0\xdb\x8e\xb7\x91\xb7\x5d\x6f\x7d\xe6\xdb\x3e\xf5\x6d\x9f\xfb\xb6\xe7\x3b\xd4\xc9\xf3\x05\x9e\x2f\xf0\x7c\x81\xe7\x0b\x3c\x5f\x59\xc9\xb2\x94\x65\x2d\x7d\x31\x03\x5f\xcd\xc0\x97\x33\xf0\xf5\x0c\x7c\x41\x03\x5f\xd1\xc0\x97\x34\xf0\x35\x0d\x42\xcf\x17\xf6\x63\x08\xc9\x0e\x62\xe8\x34\x20\xe8\xb4\x63\x88\xc8\x06\x31\x74\xc9\x86\x31\xf4\xc8\x76\x62\x38\x27\x1b\xc5\xd0\x27\xdb\x8d\x61\x40\x96\xf8\xa8\x6b\x3b\x44\x48\x8c\x1d\x52\x48\x94\x1d\x92\x48\x9c\x11\x69\x24\xd2\x88\x44\x12\x6b\x44\x2a\x89\x36\x22\x99\xc4\x1b\x45\xac\x23\xea\xb2\x8e\xa8\xc7\x3a\xa2\x73\xd6\x41\xdd\xe7\x00\x03\xd6\x41\xfd\x47\x3a\xa8\x01\x49\x87\xeb\x40\xd2\xe1\x7a\x90\x74\xb8\x2e\x24\x4a\xea\x43\xa7\xc3\x75\x22\x91\x52\x2f\x3a\x1d\xae\x1b\x89\xd6\xf5\x23\xf1\xfa\x8e\x0c\x7a\x81\xb7\xa1\xb7\x1d\x6f\x23\x67\xc3\xc8\x7f\x45\x91\xff\x8c\x22\xff\x1d\x45\x1d\xbf\xef\xfd\xdc\x47\xf0\x44\xdf\x79\xab\x05\x1a\x4d\x91\x5a\x1a\xfe\x32\xdb\xa9\x6f\x34\x9e\x37\x98\x81\x48\x53\x37\xc7\x54\xbe\x54\x2b\x34\x3c\x1f\x17\x88\x19\x48\x8b\x5a\xd0\x05\xa1\x76\xa8\xe9\x6e\x2c\x27\x93\xa3\x23\x4c\x22\x33\x91\x96\xc4\x7e\x86\xd2\x60\x92\xd9\xba\x59\xad\xf0\xfb\x18\x92\x22\x5b\xd2\xe8\xaa\xd5\xe1\xbb\xa7\x00\xbb\x91\xa6\xe9\x46\xd2\xbc\xfd\xd0\x54\xb9\xb9\x80\x52\x67\x22\xde\x92\x49\xd4\x62\x69\x0b\x91\x02\xfe\x8d\xcb\xc2\xcd\x42\x95\x80\xc8\xbc\x72\x48\x78\xe0\x57\x1c\xfe\x24\x6a\xaa\xd6\x0d\x58\x2d\x28\x78\x19\xc2\x58\xcc\x4f\x23\xd0\xb5\x81\x3b\xd4\xfb\x92\xcb\x5d\x83\x14\xf2\x3f\x5f\x7c\x38\x24\x6a\xc2\xbd\xc9\x5c\xad\x54\x76\x42\x43\xa2\xc5\x16\xe1\xf2\xf4\x74\xc7\xff\x36\x53\xcc\xd6\x76\x03\x1f\x21\x78\xb8\xa8\x7a\x04\x6a\xad\x34\x5c\x42\xaa\xd6\xcd\x35\xda\x11\x3d\xd6\xea\x17\xd5\x4a\x45\x26\x50\x73\xbb\x4c\x5f\x71\xdc\xf3\x33\xf7\xea\xec\x01\x2e\x19\x4a\x9e\x4f\x80\xa9\x41\x20\x80\xa7\xf9\x84\xb9\xdd\xd4\xea\x70\x79\x2a\xc5\xc7\xf7\x74\x2a\xa7\x4b\x05\x2e\xf9\xa9\xa2\xf2\x18\xe8\x1f\x11\xa8\xbc\x69\xd5\x4d\xb1\x5d\xa0\xae\xd5\x1b\x6e\x7b\x45\x84\x10\xc3\x73\x7e\xde\x2b\xcb\x3c\x7f\x70\xcf\x4f\x24\xc9\xa9\x77\x8a\xa9\xb6\xe5\xc9\x7f\x87\xb6\x8f\xee\xce\x9e\x6b\xdc\xa9\x1c\x2e\xe1\xe0\x38\x7f\x05\xe1\x64\x11\x22\x51\xba\x46\x28\x09\x97\xd0\xbe\x00\x09\xbf\xf1\xd9\xfc\x0d\x36\x67\xb6\xa6\xca\x1f\x2e\x40\x7e\xf8\x50\x77\xa0\x8a\x7f\xcb\x1a\x9b\xe4\xea\x72\xc4\x09\xc9\x11\xbf\xd5\x64\xbd\x69\xd5\xd4\x6a\x99\xad\x6b\x41\xaf\xee\x72\x5f\x79\xa2\xc5\x3c\x4a\xbb\x64\x7f\x97\x12\xef\x54\xf7\x67\x58\x0a\x83\x70\x76\x35\xbc\xbe\x3e\x8b\xe1\xf8\x70\x75\xfb\x69\x74\x16\x1f\x0e\x29\x33\x63\xe9\xe7\x2b\x97\xf8\x24\x6e\xa7\xde\xdc\x89\xb4\xc0\xdb\x84\xeb\x7d\x70\x97\xff\xc5\xd7\xde\xd1\x2b\x6f\x2e\xe0\xfc\x6c\x2d\x8c\x6b\x87\x17\x80\xf6\xbb\x00\xab\xde\xf2\x0f\x9e\xa7\xe1\x39\xc4\x31\xbd\x85\x0a\x4f\x50\x2f\x30\x32\xcb\x0b\x7b\xc0\x6c\x71\xab\xf4\xbe\x69\xe8\x87\x4f\xcd\xe7\xa4\x71\x48\xce\x07\x7f\xee\x17\x14\xc7\x5e\xcf\x8a\x34\x7d\xbe\xc7\x73\xe4\x9d\x4d\x95\x73\x4e\xe6\xbe\x77\x4e\x3e\x02\xd7\x02\xec\xe7\xa3\x2d\x34\x8a\x6f\x17\xc7\x8a\x7e\x1a\x5d\x8f\xfe\x18\xce\x46\xcf\x2a\x3b\x9d\x0d\x67\xe3\x2b\x7e\xf5\xe3\xda\x86\xbf\x54\xdb\xd7\x9d\x70\x3c\x87\x3b\x06\xbc\x6a\xc1\xb7\x5b\xe0\x97\x7b\xe0\x97\x9a\xe0\x58\xd0\x7f\xa2\xa2\xff\xbf\xa4\xff\x74\x4d\x27\xa3\xd9\xfd\xe4\xe6\xa4\x74\xf4\xe7\xca\x4f\x7c\x33\xde\xf5\xed\xba\x05\xaf\xdc\x79\x7c\xf9\x2b\xee\x8d\xc6\x57\x85\x6d\xb8\xd0\x1f\x4a\xd6\x77\xf4\x4e\x67\xb7\x77\xc7\xde\xbb\x1f\x5f\x8d\x0f\x43\xe5\x47\x31\xda\x0d\x68\xbf\xc3\xfa\xef\xfb\x2f\x77\x9f\x46\xd3\x99\x67\x2a\x33\x9b\x2f\x0f\x9f\xe9\x1a\xed\xdd\x55\xed\x64\x06\xca\xa4\x9c\x7f\xd2\xdc\x51\x9a\xcb\xe9\x77\x40\xa7\x98\x1d\xe0\xcf\x6e\x0e\xf8\x08\xed\xbf\xbb\x78\xe4\x3a\x0e\xf7\x97\x05\xf3\x37\x98\x23\x3e\xd6\xf5\xd9\x45\x7a\x3c\xdd\xf3\x3b\x88\xf1\xd5\xca\x53\xf5\xa9\xfa\xbf\x00\x00\x00\xff\xff\x51\x4b\xdc\x7e\x62\x10\x00\x00")
 
 func evmdis_tracerJsBytes() ([]byte, error) {
@@ -354,6 +375,8 @@ var _bindata = map[string]func() (*asset, error){
 
 	"call_tracer.js": call_tracerJs,
 
+	"eni_tracer.js": eni_tracerJs,
+
 	"evmdis_tracer.js": evmdis_tracerJs,
 
 	"noop_tracer.js": noop_tracerJs,
@@ -411,6 +434,7 @@ var _bintree = &bintree{nil, map[string]*bintree{
 	"4byte_tracer.js":    {_4byte_tracerJs, map[string]*bintree{}},
 	"bigram_tracer.js":   {bigram_tracerJs, map[string]*bintree{}},
 	"call_tracer.js":     {call_tracerJs, map[string]*bintree{}},
+	"eni_tracer.js":      {eni_tracerJs, map[string]*bintree{}},
 	"evmdis_tracer.js":   {evmdis_tracerJs, map[string]*bintree{}},
 	"noop_tracer.js":     {noop_tracerJs, map[string]*bintree{}},
 	"opcount_tracer.js":  {opcount_tracerJs, map[string]*bintree{}},
@@ -0,0 +1,50 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// eniTracer is a transaction trace listing the native functions invoked through
+// the ENI opcode, along with their arguments, gas costs and return values.
+{
+	// calls is the list of ENI invocations in execution order.
+	calls: [],
+
+	// step is invoked for every opcode that the VM executes.
+	step: function(log, db) { },
+
+	// fault is invoked when the actual execution of an opcode fails.
+	fault: function(log, db) { },
+
+	// eni is invoked when the native function of an ENI opcode returned or
+	// failed.
+	eni: function(call, db) {
+		var entry = {
+			function: call.getFunction(),
+			args:     call.getArgs(),
+			gas:      call.getGas()
+		};
+		if (call.getError() !== undefined) {
+			entry.error = call.getError();
+		} else {
+			entry.return = call.getReturn();
+		}
+		this.calls.push(entry);
+	},
+
+	// result is invoked when all the opcodes have been iterated over and returns
+	// the final result of the tracing.
+	result: function(ctx, db) {
+		return this.calls;
+	}
+}
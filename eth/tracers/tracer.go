@@ -296,6 +296,9 @@ type Tracer struct {
 	depthValue *uint   // Swappable depth value wrapped by a log accessor
 	errorValue *string // Swappable error value wrapped by a log accessor
 
+	eniValue *vm.ENILog // Swappable ENI call wrapped by an eni accessor
+	hasENI   bool       // Whether the tracer exposes an eni() function
+
 	ctx map[string]interface{} // Transaction context gathered throughout execution
 	err error                  // Error, if one has occurred
 
@@ -305,7 +308,7 @@ type Tracer struct {
 
 // New instantiates a new tracer instance. code specifies a Javascript snippet,
 // which must evaluate to an expression returning an object with 'step', 'fault'
-// and 'result' functions.
+// and 'result' functions, and optionally an 'eni' function.
 func New(code string) (*Tracer, error) {
 	// Resolve any tracers by name and assemble the tracer object
 	if tracer, ok := tracer(code); ok {
@@ -409,6 +412,9 @@ func New(code string) (*Tracer, error) {
 	}
 	tracer.vm.Pop()
 
+	tracer.hasENI = tracer.vm.GetPropString(tracer.tracerObject, "eni")
+	tracer.vm.Pop()
+
 	// Tracer is valid, inject the big int library to access large numbers
 	tracer.vm.EvalString(bigIntegerJS)
 	tracer.vm.PutGlobalString("bigInt")
@@ -457,6 +463,39 @@ func New(code string) (*Tracer, error) {
 	tracer.dbWrapper.pushObject(tracer.vm)
 	tracer.vm.PutPropString(tracer.stateObject, "db")
 
+	eniObject := tracer.vm.PushObject()
+
+	tracer.vm.PushGoFunction(func(ctx *duktape.Context) int { ctx.PushString(tracer.eniValue.Function); return 1 })
+	tracer.vm.PutPropString(eniObject, "getFunction")
+
+	tracer.vm.PushGoFunction(func(ctx *duktape.Context) int { ctx.PushString(tracer.eniValue.Args); return 1 })
+	tracer.vm.PutPropString(eniObject, "getArgs")
+
+	tracer.vm.PushGoFunction(func(ctx *duktape.Context) int { ctx.PushUint(uint(tracer.eniValue.Gas)); return 1 })
+	tracer.vm.PutPropString(eniObject, "getGas")
+
+	tracer.vm.PushGoFunction(func(ctx *duktape.Context) int {
+		if tracer.eniValue.Error == "" {
+			ctx.PushString(tracer.eniValue.Return)
+		} else {
+			ctx.PushUndefined()
+		}
+		return 1
+	})
+	tracer.vm.PutPropString(eniObject, "getReturn")
+
+	tracer.vm.PushGoFunction(func(ctx *duktape.Context) int {
+		if tracer.eniValue.Error != "" {
+			ctx.PushString(tracer.eniValue.Error)
+		} else {
+			ctx.PushUndefined()
+		}
+		return 1
+	})
+	tracer.vm.PutPropString(eniObject, "getError")
+
+	tracer.vm.PutPropString(tracer.stateObject, "eni")
+
 	return tracer, nil
 }
 
@@ -562,6 +601,21 @@ func (jst *Tracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost
 	return nil
 }
 
+// CaptureENI implements the Tracer interface to trace the native call of an
+// ENI step, if the tracer exposes an eni() function.
+func (jst *Tracer) CaptureENI(env *vm.EVM, call *vm.ENILog) error {
+	if jst.err == nil && jst.hasENI {
+		jst.eniValue = call
+		jst.dbWrapper.db = env.StateDB
+
+		_, err := jst.call("eni", "eni", "db")
+		if err != nil {
+			jst.err = wrapError("eni", err)
+		}
+	}
+	return nil
+}
+
 // CaptureEnd is called after the call finishes to finalize the tracing.
 func (jst *Tracer) CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error) error {
 	jst.ctx["output"] = output
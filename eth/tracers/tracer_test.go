@@ -138,3 +138,23 @@ func TestHaltBetweenSteps(t *testing.T) {
 		t.Errorf("Expected timeout error, got %v", err)
 	}
 }
+
+func TestENITracer(t *testing.T) {
+	tracer, err := New("eniTracer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := vm.NewEVM(vm.Context{BlockNumber: big.NewInt(1)}, nil, params.TestChainConfig, vm.Config{Debug: true, Tracer: tracer})
+
+	tracer.CaptureENI(env, &vm.ENILog{Function: "add", Args: "[3,4]", Gas: 10, Return: "[7]"})
+	tracer.CaptureENI(env, &vm.ENILog{Function: "reverse", Args: "[\"abc\"]", Error: "out of gas"})
+
+	ret, err := tracer.GetResult()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"function":"add","args":"[3,4]","gas":10,"return":"[7]"},{"function":"reverse","args":"[\"abc\"]","gas":0,"error":"out of gas"}]`
+	if string(ret) != want {
+		t.Errorf("Expected return value to be %s, got %s", want, string(ret))
+	}
+}
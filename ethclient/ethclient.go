@@ -439,7 +439,7 @@ func (ec *Client) CallContract(ctx context.Context, msg ethereum.CallMsg, blockN
 	var hex hexutil.Bytes
 	err := ec.c.CallContext(ctx, &hex, "eth_call", toCallArg(msg), toBlockNumArg(blockNumber))
 	if err != nil {
-		return nil, err
+		return nil, callError(err)
 	}
 	return hex, nil
 }
@@ -450,7 +450,7 @@ func (ec *Client) PendingCallContract(ctx context.Context, msg ethereum.CallMsg)
 	var hex hexutil.Bytes
 	err := ec.c.CallContext(ctx, &hex, "eth_call", toCallArg(msg), "pending")
 	if err != nil {
-		return nil, err
+		return nil, callError(err)
 	}
 	return hex, nil
 }
@@ -509,3 +509,24 @@ func toCallArg(msg ethereum.CallMsg) interface{} {
 	}
 	return arg
 }
+
+// callError returns the overflow error a call was halted by, as packed in the
+// data of the call error, or the call error itself if it wasn't an overflow.
+func callError(err error) error {
+	de, ok := err.(rpc.DataError)
+	if !ok {
+		return err
+	}
+	hex, ok := de.ErrorData().(string)
+	if !ok {
+		return err
+	}
+	data, decodeErr := hexutil.Decode(hex)
+	if decodeErr != nil {
+		return err
+	}
+	if oe := types.UnpackOverflowError(data); oe != nil {
+		return oe
+	}
+	return err
+}
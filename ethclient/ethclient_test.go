@@ -16,7 +16,17 @@
 
 package ethclient
 
-import "github.com/ethereum/go-ethereum"
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
 
 // Verify that Client implements the ethereum interfaces.
 var (
@@ -32,3 +42,39 @@ var (
 	// _ = ethereum.PendingStateEventer(&Client{})
 	_ = ethereum.PendingContractCaller(&Client{})
 )
+
+// OverflowService fails all calls with the data of an overflow error, as calls
+// halted by a Lity checked arithmetic overflow do.
+type OverflowService struct {
+	err *types.OverflowError
+}
+
+type overflowCallError struct {
+	*types.OverflowError
+}
+
+func (e overflowCallError) ErrorData() interface{} { return hexutil.Bytes(e.Pack()) }
+
+func (s *OverflowService) Call(args map[string]interface{}, block string) (hexutil.Bytes, error) {
+	return nil, overflowCallError{s.err}
+}
+
+// Tests that calls halted by an overflow fail with the overflow error.
+func TestCallContractOverflow(t *testing.T) {
+	want := &types.OverflowError{OpCode: 0x01, Pc: 7, Operands: []*big.Int{big.NewInt(1), big.NewInt(2)}}
+
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", &OverflowService{want}); err != nil {
+		t.Fatalf("failed to register service: %v", err)
+	}
+	defer server.Stop()
+	client := NewClient(rpc.DialInProc(server))
+	defer client.Close()
+
+	if _, err := client.CallContract(context.Background(), ethereum.CallMsg{}, nil); !reflect.DeepEqual(err, want) {
+		t.Errorf("call error mismatch: have %v, want %v", err, want)
+	}
+	if _, err := client.PendingCallContract(context.Background(), ethereum.CallMsg{}); !reflect.DeepEqual(err, want) {
+		t.Errorf("pending call error mismatch: have %v, want %v", err, want)
+	}
+}
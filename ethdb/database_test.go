@@ -188,3 +188,64 @@ func testParallelPutGet(db ethdb.Database, t *testing.T) {
 	}
 	pending.Wait()
 }
+
+func TestLDB_Iterator(t *testing.T) {
+	db, remove := newTestLDB()
+	defer remove()
+	testIterator(db, t)
+}
+
+func TestMemoryDB_Iterator(t *testing.T) {
+	testIterator(ethdb.NewMemDatabase(), t)
+}
+
+func TestTable_Iterator(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	db.Put([]byte("s"), []byte("outside"))
+	db.Put([]byte("u"), []byte("outside"))
+	testIterator(ethdb.NewTable(db, "t"), t)
+}
+
+func testIterator(db ethdb.Database, t *testing.T) {
+	t.Parallel()
+
+	for _, k := range []string{"a", "b1", "b2", "b3", "c"} {
+		if err := db.Put([]byte(k), []byte("v"+k)); err != nil {
+			t.Fatalf("put failed: %v", err)
+		}
+	}
+	tests := []struct {
+		prefix, start string
+		keys          []string
+	}{
+		{"", "", []string{"a", "b1", "b2", "b3", "c"}},
+		{"b", "", []string{"b1", "b2", "b3"}},
+		{"b", "2", []string{"b2", "b3"}},
+		{"b", "15", []string{"b2", "b3"}},
+		{"b", "4", nil},
+		{"", "b2", []string{"b2", "b3", "c"}},
+		{"d", "", nil},
+	}
+	for i, tt := range tests {
+		var keys []string
+
+		it := db.NewIterator([]byte(tt.prefix), []byte(tt.start))
+		for it.Next() {
+			if !bytes.Equal(it.Value(), append([]byte("v"), it.Key()...)) {
+				t.Errorf("test %d: value mismatch for key %q: have %q", i, it.Key(), it.Value())
+			}
+			keys = append(keys, string(it.Key()))
+		}
+		if err := it.Error(); err != nil {
+			t.Errorf("test %d: iteration failed: %v", i, err)
+		}
+		it.Release()
+
+		if fmt.Sprint(keys) != fmt.Sprint(tt.keys) {
+			t.Errorf("test %d: keys mismatch: have %q, want %q", i, keys, tt.keys)
+		}
+	}
+	if err := db.Compact(nil, nil); err != nil {
+		t.Errorf("compaction failed: %v", err)
+	}
+}
@@ -30,10 +30,62 @@ type Deleter interface {
 	Delete(key []byte) error
 }
 
+// Iterator iterates over the key-value pairs of a database in ascending key
+// order. It must be released after use, and is not safe for concurrent use,
+// but iterators may be used concurrently with the database.
+type Iterator interface {
+	// Next moves the iterator to the next key-value pair, returning whether
+	// there is one. The iterator is exhausted when it returns false.
+	Next() bool
+
+	// Error returns any accumulated error. Exhausting the key-value pairs is
+	// not an error.
+	Error() error
+
+	// Key returns the key of the current key-value pair, or nil if done. The
+	// caller must not modify the returned slice, whose contents may change on
+	// the next call to Next.
+	Key() []byte
+
+	// Value returns the value of the current key-value pair, or nil if done.
+	// The caller must not modify the returned slice, whose contents may change
+	// on the next call to Next.
+	Value() []byte
+
+	// Release releases the resources held by the iterator.
+	Release()
+}
+
+// Iteratee wraps the iteration over the content of a database.
+type Iteratee interface {
+	// NewIterator creates an iterator over the content of the database whose
+	// keys begin with prefix, starting at prefix + start, or past it if there
+	// is no such key.
+	NewIterator(prefix []byte, start []byte) Iterator
+}
+
+// Stater wraps the retrieval of the internal statistics of a database.
+type Stater interface {
+	// Stat returns the value of a database property, like "leveldb.stats".
+	Stat(property string) (string, error)
+}
+
+// Compacter wraps the compaction of a database.
+type Compacter interface {
+	// Compact flattens the underlying data store for the given key range,
+	// discarding deleted and overwritten versions of the keys. A nil start
+	// is treated as a key before all keys and a nil limit as a key after all
+	// keys, so Compact(nil, nil) compacts the entire data store.
+	Compact(start []byte, limit []byte) error
+}
+
 // Database wraps all database operations. All methods are safe for concurrent use.
 type Database interface {
 	Putter
 	Deleter
+	Iteratee
+	Stater
+	Compacter
 	Get(key []byte) ([]byte, error)
 	Has(key []byte) (bool, error)
 	Close()
@@ -50,3 +102,32 @@ type Batch interface {
 	// Reset resets the batch for reuse
 	Reset()
 }
+
+// AncientReader wraps the read operations of an append-only ancient store,
+// holding the immutable part of the chain indexed by block number. Databases
+// backed by an ancient store implement it besides Database.
+type AncientReader interface {
+	// HasAncient returns whether an ancient item of the given kind exists.
+	HasAncient(kind string, number uint64) (bool, error)
+
+	// Ancient retrieves an ancient item of the given kind.
+	Ancient(kind string, number uint64) ([]byte, error)
+
+	// Ancients returns the number of items in the ancient store.
+	Ancients() (uint64, error)
+
+	// AncientSize returns the size of the ancient items of the given kind.
+	AncientSize(kind string) (uint64, error)
+}
+
+// AncientWriter wraps the write operations of an append-only ancient store.
+type AncientWriter interface {
+	// AppendAncient appends all the data of a block to the ancient store.
+	AppendAncient(number uint64, hash, header, body, receipts, td []byte) error
+
+	// TruncateAncients discards all but the first n ancient items.
+	TruncateAncients(n uint64) error
+
+	// Sync flushes all the ancient items to disk.
+	Sync() error
+}
@@ -0,0 +1,110 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/node"
+)
+
+// Tests that the schema and the resolvers match up.
+func TestBuildSchema(t *testing.T) {
+	if _, err := New(DefaultConfig, nil); err != nil {
+		t.Fatalf("could not create new service: %v", err)
+	}
+}
+
+// Tests that queries are served over HTTP from the chain of the backend.
+func TestServeQuery(t *testing.T) {
+	workspace, err := ioutil.TempDir("", "graphql-tester-")
+	if err != nil {
+		t.Fatalf("failed to create temporary datadir: %v", err)
+	}
+	defer os.RemoveAll(workspace)
+
+	stack, err := node.New(&node.Config{DataDir: workspace, UseLightweightKDF: true, Name: "graphql-tester"})
+	if err != nil {
+		t.Fatalf("failed to create node: %v", err)
+	}
+	faucet := common.HexToAddress("0x8605cdbbdb6d264aa742e77020dcbc58fcdce182")
+	ethConf := &eth.Config{
+		Genesis: core.DeveloperGenesisBlock(15, faucet),
+		Ethash:  ethash.Config{PowMode: ethash.ModeTest},
+	}
+	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) { return eth.New(ctx, ethConf, nil) }); err != nil {
+		t.Fatalf("failed to register Ethereum protocol: %v", err)
+	}
+	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		var ethServ *eth.Ethereum
+		if err := ctx.Service(&ethServ); err != nil {
+			return nil, err
+		}
+		return New(Config{Host: "127.0.0.1", Port: 0}, ethServ.APIBackend)
+	}); err != nil {
+		t.Fatalf("failed to register GraphQL service: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start test stack: %v", err)
+	}
+	defer stack.Stop()
+
+	var service *Service
+	if err := stack.Service(&service); err != nil {
+		t.Fatalf("failed to retrieve GraphQL service: %v", err)
+	}
+	query := fmt.Sprintf(`{"query": "{ block(number: 0) { number transactionCount account(address: \"%s\") { balance } } }"}`, faucet.Hex())
+	res, err := http.Post(fmt.Sprintf("http://%s/graphql", service.listener.Addr()), "application/json", strings.NewReader(query))
+	if err != nil {
+		t.Fatalf("failed to query block: %v", err)
+	}
+	defer res.Body.Close()
+
+	var result struct {
+		Data struct {
+			Block struct {
+				Number           string
+				TransactionCount int
+				Account          struct{ Balance string }
+			}
+		}
+		Errors []interface{}
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(result.Errors) > 0 {
+		t.Fatalf("query failed: %v", result.Errors)
+	}
+	block := result.Data.Block
+	if block.Number != "0x0" || block.TransactionCount != 0 {
+		t.Errorf("block mismatch: have number %s with %d txs, want 0x0 with 0", block.Number, block.TransactionCount)
+	}
+	if want := "0x" + ethConf.Genesis.Alloc[faucet].Balance.Text(16); block.Account.Balance != want {
+		t.Errorf("balance mismatch: have %s, want %s", block.Account.Balance, want)
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/rpc"
+	graphqlgo "github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/relay"
+)
+
+const (
+	// DefaultHost is the default interface the GraphQL server listens on.
+	DefaultHost = "localhost"
+
+	// DefaultPort is the default port the GraphQL server listens on, next to
+	// the one of the HTTP RPC server.
+	DefaultPort = 8547
+)
+
+// Config are the configuration parameters of the GraphQL service.
+type Config struct {
+	// Host is the host interface on which to start the GraphQL server. If this
+	// field is empty, the service is not started.
+	Host string `toml:",omitempty"`
+
+	// Port is the TCP port number on which to start the GraphQL server.
+	Port int `toml:",omitempty"`
+
+	// Cors is the Cross-Origin Resource Sharing header to send to requesting
+	// clients. Please be aware that CORS is a browser enforced security, it's
+	// fully useless for custom HTTP clients.
+	Cors []string `toml:",omitempty"`
+
+	// VirtualHosts is the list of virtual hostnames which are allowed on
+	// incoming requests. This is by default {'localhost'}.
+	VirtualHosts []string `toml:",omitempty"`
+}
+
+// DefaultConfig contains the default settings of the GraphQL service.
+var DefaultConfig = Config{
+	Port:         DefaultPort,
+	VirtualHosts: []string{"localhost"},
+}
+
+// Endpoint resolves the listening endpoint of the GraphQL server.
+func (c *Config) Endpoint() string {
+	return fmt.Sprintf("%s:%d", c.Host, c.Port)
+}
+
+// Service encapsulates a GraphQL service.
+type Service struct {
+	config   Config         // Listening endpoint and request filtering of the service
+	backend  ethapi.Backend // The backend that queries will operate on
+	handler  http.Handler   // The `http.Handler` used to answer queries
+	listener net.Listener   // The listening socket
+	server   *http.Server   // The server answering queries on the listener
+}
+
+// New constructs a new GraphQL service instance.
+func New(config Config, backend ethapi.Backend) (*Service, error) {
+	q := Resolver{backend}
+
+	s, err := graphqlgo.ParseSchema(schema, &q)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/graphql", &relay.Handler{Schema: s})
+
+	return &Service{
+		config:  config,
+		backend: backend,
+		handler: rpc.NewHTTPHandlerStack(mux, config.Cors, config.VirtualHosts),
+	}, nil
+}
+
+// Protocols returns the list of protocols exported by this service.
+func (s *Service) Protocols() []p2p.Protocol { return nil }
+
+// APIs returns the list of APIs exported by this service.
+func (s *Service) APIs() []rpc.API { return nil }
+
+// Start is called after all services have been constructed and the networking
+// layer was also initialized to spawn any goroutines required by the service.
+func (s *Service) Start(server *p2p.Server) error {
+	var err error
+	if s.listener, err = net.Listen("tcp", s.config.Endpoint()); err != nil {
+		return err
+	}
+	s.server = &http.Server{
+		Handler:      s.handler,
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 15 * time.Second,
+		IdleTimeout:  120 * time.Second,
+	}
+	go s.server.Serve(s.listener)
+
+	log.Info("GraphQL endpoint opened", "url", fmt.Sprintf("http://%s/graphql", s.listener.Addr()))
+	return nil
+}
+
+// Stop terminates all goroutines belonging to the service, blocking until they
+// are all terminated.
+func (s *Service) Stop() error {
+	if s.server != nil {
+		// Shutting down closes the listener, then waits for the queries in
+		// flight, at most as long as they may be written out.
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+
+		s.server.Shutdown(ctx)
+		s.server, s.listener = nil, nil
+		log.Info("GraphQL endpoint closed", "url", fmt.Sprintf("http://%s/graphql", s.config.Endpoint()))
+	}
+	return nil
+}
@@ -42,8 +42,6 @@ import (
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/rpc"
-	"github.com/syndtr/goleveldb/leveldb"
-	"github.com/syndtr/goleveldb/leveldb/util"
 )
 
 const (
@@ -611,12 +609,26 @@ type CallArgs struct {
 	Data     hexutil.Bytes   `json:"data"`
 }
 
-func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber, vmCfg vm.Config, timeout time.Duration) ([]byte, uint64, bool, error) {
+// callResult is the outcome of a call executed by doCall.
+type callResult struct {
+	ret    []byte
+	gas    uint64 // Gas used by the call
+	failed bool
+
+	from     common.Address // Sender the call was made from
+	gasPrice *big.Int       // Gas price the sender was charged at
+
+	freeGas      bool     // Whether the callee executed FREEGAS to pay for the call
+	sponsorPrice *big.Int // Gas price contracts are charged at
+	freeGasLimit uint64   // Gas limit above which zero priced calls are freegas ones
+}
+
+func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber, vmCfg vm.Config, timeout time.Duration) (*callResult, error) {
 	defer func(start time.Time) { log.Debug("Executing EVM call finished", "runtime", time.Since(start)) }(time.Now())
 
 	state, header, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
 	if state == nil || err != nil {
-		return nil, 0, false, err
+		return nil, err
 	}
 	// Set sender address or use a default if none specified
 	addr := args.From
@@ -654,7 +666,7 @@ func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr
 	// Get a new instance of the EVM.
 	evm, vmError, err := s.b.GetEVM(ctx, msg, state, header, vmCfg)
 	if err != nil {
-		return nil, 0, false, err
+		return nil, err
 	}
 	// Wait for the context to be done and cancel the evm. Even if the
 	// EVM has finished, cancelling may be done (repeatedly)
@@ -668,21 +680,71 @@ func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr
 	gp := new(core.GasPool).AddGas(math.MaxUint64)
 	res, gas, failed, err := core.ApplyMessage(evm, msg, gp)
 	if err := vmError(); err != nil {
-		return nil, 0, false, err
+		return nil, err
 	}
-	return res, gas, failed, err
+	result := &callResult{
+		ret:          res,
+		gas:          gas,
+		failed:       failed,
+		from:         addr,
+		gasPrice:     gasPrice,
+		sponsorPrice: evm.Context.Umbrella.DefaultGasPrice(),
+		freeGasLimit: evm.Context.Umbrella.FreeGasLimit().Uint64(),
+	}
+	// Calls halted by an overflow fail with its error, whose data is returned
+	// by calls reverting on overflows instead.
+	if err == nil && failed && !s.b.ChainConfig().IsOverflowRevert(header.Number) {
+		if oe := types.UnpackOverflowError(res); oe != nil {
+			err = overflowError{oe}
+		}
+	}
+	// The call is paid for by the sender, tell whether a zero priced one would
+	// have been paid for by the callee.
+	if args.To != nil {
+		result.freeGas = core.FreeGasTriggered(evm, *args.To)
+	}
+	return result, err
+}
+
+// CallOptions are the optional parameters of eth_call and eth_estimateGas.
+type CallOptions struct {
+	// Payment requests a result telling who pays for the gas of the call, the
+	// sender or the called contract executing FREEGAS, see CallResult and
+	// GasPayment.
+	Payment bool `json:"payment"`
 }
 
 // Call executes the given transaction on the state for the given block number.
 // It doesn't make and changes in the state/blockchain and is useful to execute and retrieve values.
-func (s *PublicBlockChainAPI) Call(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber) (hexutil.Bytes, error) {
-	result, _, _, err := s.doCall(ctx, args, blockNr, vm.Config{}, 5*time.Second)
-	return (hexutil.Bytes)(result), err
+// The returned data is extended with the payment of the gas if requested by opts.
+func (s *PublicBlockChainAPI) Call(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber, opts *CallOptions) (interface{}, error) {
+	if opts != nil && opts.Payment {
+		return s.callPayment(ctx, args, blockNr)
+	}
+	result, err := s.doCall(ctx, args, blockNr, vm.Config{}, 5*time.Second)
+	if result == nil {
+		return nil, err
+	}
+	return (hexutil.Bytes)(result.ret), err
 }
 
 // EstimateGas returns an estimate of the amount of gas needed to execute the
+// given transaction against the current pending block. The estimate is
+// extended with the payment of the gas if requested by opts.
+func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs, opts *CallOptions) (interface{}, error) {
+	if opts != nil && opts.Payment {
+		return s.estimateGasPayment(ctx, args)
+	}
+	return s.estimateGas(ctx, args)
+}
+
+// DoEstimateGas returns an estimate of the amount of gas needed to execute the
 // given transaction against the current pending block.
-func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs) (hexutil.Uint64, error) {
+func DoEstimateGas(ctx context.Context, b Backend, args CallArgs) (hexutil.Uint64, error) {
+	return NewPublicBlockChainAPI(b).estimateGas(ctx, args)
+}
+
+func (s *PublicBlockChainAPI) estimateGas(ctx context.Context, args CallArgs) (hexutil.Uint64, error) {
 	// Binary search the gas requirement, as it may be higher than the amount used
 	var (
 		lo  uint64 = params.TxGas - 1
@@ -705,8 +767,8 @@ func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs) (h
 	executable := func(gas uint64) bool {
 		args.Gas = hexutil.Uint64(gas)
 
-		_, _, failed, err := s.doCall(ctx, args, rpc.PendingBlockNumber, vm.Config{}, 0)
-		if err != nil || failed {
+		result, err := s.doCall(ctx, args, rpc.PendingBlockNumber, vm.Config{}, 0)
+		if err != nil || result.failed {
 			return false
 		}
 		return true
@@ -751,6 +813,7 @@ type StructLogRes struct {
 	Stack   *[]string          `json:"stack,omitempty"`
 	Memory  *[]string          `json:"memory,omitempty"`
 	Storage *map[string]string `json:"storage,omitempty"`
+	ENI     *vm.ENILog         `json:"eni,omitempty"`
 }
 
 // formatLogs formats EVM returned structured logs for json output
@@ -764,6 +827,7 @@ func FormatLogs(logs []vm.StructLog) []StructLogRes {
 			GasCost: trace.GasCost,
 			Depth:   trace.Depth,
 			Error:   trace.Err,
+			ENI:     trace.ENI,
 		}
 		if trace.Stack != nil {
 			stack := make([]string, len(trace.Stack))
@@ -1049,10 +1113,18 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(ctx context.Context, ha
 	if err != nil {
 		return nil, err
 	}
-	if len(receipts) <= int(index) {
+	// The receipts of scheduled transactions precede those of the block's own
+	// transactions, look the receipt up by hash rather than by position.
+	var receipt *types.Receipt
+	for _, r := range receipts {
+		if r.TxHash == hash {
+			receipt = r
+			break
+		}
+	}
+	if receipt == nil {
 		return nil, nil
 	}
-	receipt := receipts[index]
 
 	var signer types.Signer = types.FrontierSigner{}
 	if tx.Protected() {
@@ -1087,6 +1159,10 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(ctx context.Context, ha
 	if receipt.ContractAddress != (common.Address{}) {
 		fields["contractAddress"] = receipt.ContractAddress
 	}
+	// Failed transactions report the data they failed with, like overflow errors
+	if len(receipt.RevertReason) > 0 {
+		fields["revertReason"] = hexutil.Bytes(receipt.RevertReason)
+	}
 	return fields, nil
 }
 
@@ -1457,30 +1533,18 @@ func NewPrivateDebugAPI(b Backend) *PrivateDebugAPI {
 
 // ChaindbProperty returns leveldb properties of the chain database.
 func (api *PrivateDebugAPI) ChaindbProperty(property string) (string, error) {
-	ldb, ok := api.b.ChainDb().(interface {
-		LDB() *leveldb.DB
-	})
-	if !ok {
-		return "", fmt.Errorf("chaindbProperty does not work for memory databases")
-	}
 	if property == "" {
 		property = "leveldb.stats"
 	} else if !strings.HasPrefix(property, "leveldb.") {
 		property = "leveldb." + property
 	}
-	return ldb.LDB().GetProperty(property)
+	return api.b.ChainDb().Stat(property)
 }
 
 func (api *PrivateDebugAPI) ChaindbCompact() error {
-	ldb, ok := api.b.ChainDb().(interface {
-		LDB() *leveldb.DB
-	})
-	if !ok {
-		return fmt.Errorf("chaindbCompact does not work for memory databases")
-	}
 	for b := byte(0); b < 255; b++ {
 		log.Info("Compacting chain database", "range", fmt.Sprintf("0x%0.2X-0x%0.2X", b, b+1))
-		err := ldb.LDB().CompactRange(util.Range{Start: []byte{b}, Limit: []byte{b + 1}})
+		err := api.b.ChainDb().Compact([]byte{b}, []byte{b + 1})
 		if err != nil {
 			log.Error("Database compaction failed", "err", err)
 			return err
@@ -0,0 +1,155 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// GasPayment tells who pays for the gas of a transaction. Zero priced
+// transactions calling a function which executes FREEGAS, with more gas than
+// the free gas limit, are paid for by the called contract.
+type GasPayment struct {
+	FreeGas  bool           `json:"freeGas"`  // Whether the called function executes FREEGAS
+	Payer    common.Address `json:"payer"`    // Account paying for the gas
+	Gas      hexutil.Uint64 `json:"gas"`      // Gas limit the transaction must carry
+	GasPrice *hexutil.Big   `json:"gasPrice"` // Price the payer is charged for the gas
+	Balance  *hexutil.Big   `json:"balance"`  // Balance the payer needs to buy the gas
+}
+
+// newGasPayment describes the payment of a transaction carrying the given gas,
+// executed as the call of result.
+func newGasPayment(args CallArgs, result *callResult, gas uint64) *GasPayment {
+	payment := &GasPayment{
+		FreeGas:  result.freeGas,
+		Payer:    result.from,
+		GasPrice: (*hexutil.Big)(result.gasPrice),
+	}
+	if result.freeGas {
+		// The contract buys all the gas up front, which must exceed the
+		// free gas limit for the transaction to be sponsored.
+		if gas <= result.freeGasLimit {
+			gas = result.freeGasLimit + 1
+		}
+		payment.Payer = *args.To
+		payment.GasPrice = (*hexutil.Big)(result.sponsorPrice)
+	}
+	payment.Gas = hexutil.Uint64(gas)
+	payment.Balance = (*hexutil.Big)(new(big.Int).Mul(new(big.Int).SetUint64(gas), payment.GasPrice.ToInt()))
+	return payment
+}
+
+// overflowError is the error of calls halted by a Lity checked arithmetic
+// overflow. It reports the packed overflow error as its data, which is what
+// calls reverting on overflows past the OverflowRevert fork return.
+type overflowError struct {
+	*types.OverflowError
+}
+
+// ErrorData implements rpc.DataError.
+func (e overflowError) ErrorData() interface{} {
+	return hexutil.Bytes(e.Pack())
+}
+
+// CallResult is the outcome of a call, along with the payment of its gas.
+type CallResult struct {
+	ReturnValue hexutil.Bytes  `json:"returnValue"`
+	Failed      bool           `json:"failed"`
+	GasUsed     hexutil.Uint64 `json:"gasUsed"`
+	*GasPayment
+}
+
+// PublicLityAPI offers an API to simulate calls telling whether they are paid
+// for by the sender or sponsored by the called contract. The same results are
+// returned by eth_call and eth_estimateGas given the payment option.
+type PublicLityAPI struct {
+	b     Backend
+	chain *PublicBlockChainAPI
+}
+
+// NewPublicLityAPI creates a new Lity API.
+func NewPublicLityAPI(b Backend) *PublicLityAPI {
+	return &PublicLityAPI{b, NewPublicBlockChainAPI(b)}
+}
+
+// Call executes the given transaction on the state for the given block number,
+// like eth_call, and reports who pays for its gas. The gas limit defaults to
+// the gas used.
+func (s *PublicLityAPI) Call(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber) (*CallResult, error) {
+	return s.chain.callPayment(ctx, args, blockNr)
+}
+
+// callPayment is eth_call with the payment option, see PublicLityAPI.Call.
+func (s *PublicBlockChainAPI) callPayment(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber) (*CallResult, error) {
+	result, err := s.doCall(ctx, args, blockNr, vm.Config{}, 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	gas := uint64(args.Gas)
+	if gas == 0 {
+		gas = result.gas
+	}
+	return &CallResult{
+		ReturnValue: result.ret,
+		Failed:      result.failed,
+		GasUsed:     hexutil.Uint64(result.gas),
+		GasPayment:  newGasPayment(args, result, gas),
+	}, nil
+}
+
+// EstimateGas estimates the gas needed to execute the given transaction against
+// the current pending block, like eth_estimateGas, and reports who pays for it.
+// The gas of transactions sponsored by the contract is raised above the free
+// gas limit.
+func (s *PublicLityAPI) EstimateGas(ctx context.Context, args CallArgs) (*GasPayment, error) {
+	return s.chain.estimateGasPayment(ctx, args)
+}
+
+// estimateGasPayment is eth_estimateGas with the payment option, see
+// PublicLityAPI.EstimateGas.
+func (s *PublicBlockChainAPI) estimateGasPayment(ctx context.Context, args CallArgs) (*GasPayment, error) {
+	gas, err := s.estimateGas(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	args.Gas = gas
+	result, err := s.doCall(ctx, args, rpc.PendingBlockNumber, vm.Config{}, 0)
+	if err != nil {
+		return nil, err
+	}
+	return newGasPayment(args, result, uint64(gas)), nil
+}
+
+// IsFreeGasFunction reports whether calling the contract at the given address
+// with the given input executes FREEGAS on the pending state, i.e. whether the
+// contract pays for zero priced transactions making the call.
+func (s *PublicLityAPI) IsFreeGasFunction(ctx context.Context, address common.Address, data hexutil.Bytes) (bool, error) {
+	args := CallArgs{To: &address, Data: data}
+	result, err := s.chain.doCall(ctx, args, rpc.PendingBlockNumber, vm.Config{}, 5*time.Second)
+	if err != nil {
+		return false, err
+	}
+	return result.freeGas, nil
+}
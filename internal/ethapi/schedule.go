@@ -0,0 +1,101 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/schedule"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// RPCSchedule represents a transaction pending in the schedule queue.
+type RPCSchedule struct {
+	ID       hexutil.Uint64 `json:"id"`
+	Sender   common.Address `json:"sender"`
+	Receiver common.Address `json:"receiver"`
+	Data     hexutil.Bytes  `json:"data"`
+	Unixtime hexutil.Uint64 `json:"unixtime"`
+}
+
+func newRPCSchedule(entry *schedule.Entry) *RPCSchedule {
+	return &RPCSchedule{
+		ID:       hexutil.Uint64(entry.ID),
+		Sender:   entry.Sender,
+		Receiver: entry.Receiver,
+		Data:     hexutil.Bytes(entry.TxData),
+		Unixtime: hexutil.Uint64(entry.Unixtime),
+	}
+}
+
+// PublicScheduleAPI offers an API to inspect and cancel the transactions
+// emitted by the SCHEDULE opcode.
+type PublicScheduleAPI struct {
+	b      Backend
+	txPool *PublicTransactionPoolAPI
+}
+
+// NewPublicScheduleAPI creates a new schedule queue API.
+func NewPublicScheduleAPI(b Backend, nonceLock *AddrLocker) *PublicScheduleAPI {
+	return &PublicScheduleAPI{b, NewPublicTransactionPoolAPI(b, nonceLock)}
+}
+
+// Pending returns the scheduled transactions sent by or to the given address
+// which are pending at the given block, in the order they fall due.
+func (s *PublicScheduleAPI) Pending(ctx context.Context, address common.Address, blockNr rpc.BlockNumber) ([]*RPCSchedule, error) {
+	state, _, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	schedules := make([]*RPCSchedule, 0)
+	for _, entry := range schedule.Pending(state) {
+		if entry.Sender == address || entry.Receiver == address {
+			schedules = append(schedules, newRPCSchedule(entry))
+		}
+	}
+	return schedules, state.Error()
+}
+
+// GetSchedule returns the scheduled transaction with the given id, or nil if it
+// is not pending at the given block.
+func (s *PublicScheduleAPI) GetSchedule(ctx context.Context, id hexutil.Uint64, blockNr rpc.BlockNumber) (*RPCSchedule, error) {
+	state, _, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	entry := schedule.Get(state, uint64(id))
+	if entry == nil {
+		return nil, state.Error()
+	}
+	return newRPCSchedule(entry), state.Error()
+}
+
+// Cancel sends a transaction from the given account, which must be either the
+// sender or the receiver of the scheduled transaction, cancelling it.
+func (s *PublicScheduleAPI) Cancel(ctx context.Context, from common.Address, id hexutil.Uint64) (common.Hash, error) {
+	var (
+		to   = schedule.QueueAddress
+		data = hexutil.Bytes(schedule.CancelInput(uint64(id)))
+	)
+	return s.txPool.SendTransaction(ctx, SendTxArgs{
+		From: from,
+		To:   &to,
+		Data: &data,
+	})
+}
@@ -22,11 +22,14 @@ var Modules = map[string]string{
 	"chequebook": Chequebook_JS,
 	"clique":     Clique_JS,
 	"debug":      Debug_JS,
+	"eni":        ENI_JS,
 	"eth":        Eth_JS,
+	"lity":       Lity_JS,
 	"miner":      Miner_JS,
 	"net":        Net_JS,
 	"personal":   Personal_JS,
 	"rpc":        RPC_JS,
+	"schedule":   Schedule_JS,
 	"shh":        Shh_JS,
 	"swarmfs":    SWARMFS_JS,
 	"txpool":     TxPool_JS,
@@ -159,6 +162,17 @@ web3._extend({
 			name: 'stopWS',
 			call: 'admin_stopWS'
 		}),
+		new web3._extend.Method({
+			name: 'eniUpgrade',
+			call: 'admin_eniUpgrade',
+			params: 2,
+			inputFormatter: [null, web3._extend.utils.fromDecimal]
+		}),
+		new web3._extend.Method({
+			name: 'eniCancelUpgrade',
+			call: 'admin_eniCancelUpgrade',
+			params: 1
+		}),
 	],
 	properties: [
 		new web3._extend.Property({
@@ -390,6 +404,25 @@ web3._extend({
 });
 `
 
+const ENI_JS = `
+web3._extend({
+	property: 'eni',
+	methods: [
+		new web3._extend.Method({
+			name: 'libraryStatus',
+			call: 'eni_libraryStatus',
+			params: 1
+		}),
+	],
+	properties: [
+		new web3._extend.Property({
+			name: 'libraries',
+			getter: 'eni_listLibraries'
+		}),
+	]
+});
+`
+
 const Eth_JS = `
 web3._extend({
 	property: 'eth',
@@ -561,6 +594,58 @@ web3._extend({
 });
 `
 
+const Lity_JS = `
+web3._extend({
+	property: 'lity',
+	methods: [
+		new web3._extend.Method({
+			name: 'call',
+			call: 'lity_call',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputCallFormatter, web3._extend.formatters.inputDefaultBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'estimateGas',
+			call: 'lity_estimateGas',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputCallFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'isFreeGasFunction',
+			call: 'lity_isFreeGasFunction',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null]
+		}),
+	]
+});
+`
+
+const Schedule_JS = `
+web3._extend({
+	property: 'schedule',
+	methods: [
+		new web3._extend.Method({
+			name: 'pending',
+			call: 'schedule_pending',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'getSchedule',
+			call: 'schedule_getSchedule',
+			params: 2,
+			inputFormatter: [web3._extend.utils.fromDecimal, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'cancel',
+			call: 'schedule_cancel',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.utils.fromDecimal]
+		}),
+	]
+});
+`
+
 const Shh_JS = `
 web3._extend({
 	property: 'shh',
@@ -90,6 +90,7 @@ func NewLightChain(odr OdrBackend, config *params.ChainConfig, engine consensus.
 		bodyRLPCache: bodyRLPCache,
 		blockCache:   blockCache,
 		engine:       engine,
+		umbrella:     umbrella.NewStandalone(config.Umbrella),
 	}
 	var err error
 	bc.hc, err = core.NewHeaderChain(odr.Database(), config, bc.engine, bc.getProcInterrupt)
@@ -137,6 +138,13 @@ func (self *LightChain) getProcInterrupt() bool {
 	return atomic.LoadInt32(&self.procInterrupt) == 1
 }
 
+// SetUmbrella sets the umbrella which is used to communacate with Travis database,
+// replacing the standalone one configured by the chain config.
+func (self *LightChain) SetUmbrella(umbrella umbrella.Umbrella) {
+	self.umbrella = umbrella
+}
+
+// Umbrella retrieves the light chain's travis database interface.
 func (self *LightChain) Umbrella() umbrella.Umbrella {
 	return self.umbrella
 }
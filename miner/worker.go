@@ -31,6 +31,8 @@ import (
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/core/vm/eni"
+	"github.com/ethereum/go-ethereum/core/vm/umbrella"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/log"
@@ -418,6 +420,13 @@ func (self *worker) commitNewWork() {
 	if atomic.LoadInt32(&self.mining) == 1 {
 		header.Coinbase = self.coinbase
 	}
+	// Past the RandBeacon fork, wait for the umbrella's beacon of the block
+	if self.config.IsRandBeacon(header.Number) {
+		if b, ok := self.chain.Umbrella().(umbrella.Beacon); ok && b.GetBeacon(header.Number) == (common.Hash{}) {
+			log.Error("Missing randomness beacon", "number", header.Number)
+			return
+		}
+	}
 	if err := self.engine.Prepare(self.chain, header); err != nil {
 		log.Error("Failed to prepare header for mining", "err", err)
 		return
@@ -446,6 +455,17 @@ func (self *worker) commitNewWork() {
 	if self.config.DAOForkSupport && self.config.DAOForkBlock != nil && self.config.DAOForkBlock.Cmp(header.Number) == 0 {
 		misc.ApplyDAOHardFork(work.state)
 	}
+	// Don't build the block on the old version of an ENI library whose upgrade
+	// is due but not available yet, block import enables the upgrades.
+	if err := eni.Ready(header.Number.Uint64()); err != nil {
+		log.Error("ENI upgrades not ready", "err", err)
+		return
+	}
+
+	// Execute the scheduled transactions fallen due ahead of the pending ones
+	work.gasPool = new(core.GasPool).AddGas(header.GasLimit)
+	work.receipts = core.ApplyDueSchedules(self.config, self.chain, &header.Coinbase, work.gasPool, work.state, header, &header.GasUsed, vm.Config{})
+
 	pending, err := self.eth.TxPool().Pending()
 	if err != nil {
 		log.Error("Failed to fetch pending transactions", "err", err)
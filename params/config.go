@@ -27,6 +27,7 @@ import (
 var (
 	MainnetGenesisHash = common.HexToHash("0xd4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3")
 	TestnetGenesisHash = common.HexToHash("0x41941023680923e0fe4d74a34bdac8141f2540e3ae90623718e47d66d1ca4a2d")
+	RinkebyGenesisHash = common.HexToHash("0x6341fd3daf94b748c72ced5a5b26028f2474f5f00d824504e4fa37a75767e177")
 )
 
 var (
@@ -83,16 +84,16 @@ var (
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllEthashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, new(EthashConfig), nil}
+	AllEthashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, new(EthashConfig), nil, nil}
 
 	// AllCliqueProtocolChanges contains every protocol change (EIPs) introduced
 	// and accepted by the Ethereum core developers into the Clique consensus.
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, &CliqueConfig{Period: 0, Epoch: 30000}}
+	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, &CliqueConfig{Period: 0, Epoch: 30000}, nil}
 
-	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, new(EthashConfig), nil}
+	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, new(EthashConfig), nil, nil}
 	TestRules       = TestChainConfig.Rules(new(big.Int))
 )
 
@@ -119,9 +120,24 @@ type ChainConfig struct {
 	ByzantiumBlock      *big.Int `json:"byzantiumBlock,omitempty"`      // Byzantium switch block (nil = no fork, 0 = already on byzantium)
 	ConstantinopleBlock *big.Int `json:"constantinopleBlock,omitempty"` // Constantinople switch block (nil = no fork, 0 = already activated)
 
+	LityBlock       *big.Int `json:"lityBlock,omitempty"`       // Lity opcodes switch block (nil = no fork, 0 = already activated)
+	ScheduleBlock   *big.Int `json:"scheduleBlock,omitempty"`   // Native schedule queue switch block (nil = no fork, 0 = already activated)
+	LityGasBlock    *big.Int `json:"lityGasBlock,omitempty"`    // Lity opcode repricing and journaling switch block (nil = no fork, 0 = already activated)
+	RandBeaconBlock *big.Int `json:"randBeaconBlock,omitempty"` // RAND randomness beacon switch block (nil = no fork, 0 = already activated)
+	FixedPointBlock *big.Int `json:"fixedPointBlock,omitempty"` // Fixed point decimals bound, pricing and rounding switch block (nil = no fork, 0 = already activated)
+
+	// OverflowRevertBlock optionally makes Lity checked arithmetic overflows
+	// revert with their error, refunding the remaining gas, rather than halt
+	// exceptionally (nil = no fork, 0 = already activated).
+	OverflowRevertBlock *big.Int `json:"overflowRevertBlock,omitempty"`
+
 	// Various consensus engines
 	Ethash *EthashConfig `json:"ethash,omitempty"`
 	Clique *CliqueConfig `json:"clique,omitempty"`
+
+	// Umbrella configures the built-in umbrella used when the chain is not
+	// run by Travis
+	Umbrella *UmbrellaConfig `json:"umbrella,omitempty"`
 }
 
 // EthashConfig is the consensus engine configs for proof-of-work based sealing.
@@ -143,6 +159,14 @@ func (c *CliqueConfig) String() string {
 	return "clique"
 }
 
+// UmbrellaConfig is the configuration of the standalone umbrella, providing
+// the Lity opcodes with the chain parameters otherwise supplied by Travis.
+type UmbrellaConfig struct {
+	Validators      []common.Address `json:"validators"`      // Static validator set reported to ISVALIDATOR
+	DefaultGasPrice *big.Int         `json:"defaultGasPrice"` // Gas price charged to contracts paying freegas transactions
+	FreeGasLimit    uint64           `json:"freeGasLimit"`    // Gas limit above which zero priced transactions are freegas ones
+}
+
 // String implements the fmt.Stringer interface.
 func (c *ChainConfig) String() string {
 	var engine interface{}
@@ -154,7 +178,7 @@ func (c *ChainConfig) String() string {
 	default:
 		engine = "unknown"
 	}
-	return fmt.Sprintf("{ChainID: %v Homestead: %v DAO: %v DAOSupport: %v EIP150: %v EIP155: %v EIP158: %v Byzantium: %v Constantinople: %v Engine: %v}",
+	return fmt.Sprintf("{ChainID: %v Homestead: %v DAO: %v DAOSupport: %v EIP150: %v EIP155: %v EIP158: %v Byzantium: %v Constantinople: %v Lity: %v Schedule: %v LityGas: %v RandBeacon: %v FixedPoint: %v OverflowRevert: %v Engine: %v}",
 		c.ChainID,
 		c.HomesteadBlock,
 		c.DAOForkBlock,
@@ -164,6 +188,12 @@ func (c *ChainConfig) String() string {
 		c.EIP158Block,
 		c.ByzantiumBlock,
 		c.ConstantinopleBlock,
+		c.LityBlock,
+		c.ScheduleBlock,
+		c.LityGasBlock,
+		c.RandBeaconBlock,
+		c.FixedPointBlock,
+		c.OverflowRevertBlock,
 		engine,
 	)
 }
@@ -203,6 +233,36 @@ func (c *ChainConfig) IsConstantinople(num *big.Int) bool {
 	return isForked(c.ConstantinopleBlock, num)
 }
 
+// IsLity returns whether num is either equal to the Lity fork block or greater.
+func (c *ChainConfig) IsLity(num *big.Int) bool {
+	return isForked(c.LityBlock, num)
+}
+
+// IsSchedule returns whether num is either equal to the native schedule queue fork block or greater.
+func (c *ChainConfig) IsSchedule(num *big.Int) bool {
+	return isForked(c.ScheduleBlock, num)
+}
+
+// IsLityGas returns whether num is either equal to the Lity opcode repricing fork block or greater.
+func (c *ChainConfig) IsLityGas(num *big.Int) bool {
+	return isForked(c.LityGasBlock, num)
+}
+
+// IsRandBeacon returns whether num is either equal to the RAND randomness beacon fork block or greater.
+func (c *ChainConfig) IsRandBeacon(num *big.Int) bool {
+	return isForked(c.RandBeaconBlock, num)
+}
+
+// IsFixedPoint returns whether num is either equal to the fixed point arithmetic fork block or greater.
+func (c *ChainConfig) IsFixedPoint(num *big.Int) bool {
+	return isForked(c.FixedPointBlock, num)
+}
+
+// IsOverflowRevert returns whether num is either equal to the overflow revert fork block or greater.
+func (c *ChainConfig) IsOverflowRevert(num *big.Int) bool {
+	return isForked(c.OverflowRevertBlock, num)
+}
+
 // GasTable returns the gas table corresponding to the current phase (homestead or homestead reprice).
 //
 // The returned GasTable's fields shouldn't, under any circumstances, be changed.
@@ -266,6 +326,24 @@ func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *Confi
 	if isForkIncompatible(c.ConstantinopleBlock, newcfg.ConstantinopleBlock, head) {
 		return newCompatError("Constantinople fork block", c.ConstantinopleBlock, newcfg.ConstantinopleBlock)
 	}
+	if isForkIncompatible(c.LityBlock, newcfg.LityBlock, head) {
+		return newCompatError("Lity fork block", c.LityBlock, newcfg.LityBlock)
+	}
+	if isForkIncompatible(c.ScheduleBlock, newcfg.ScheduleBlock, head) {
+		return newCompatError("Schedule fork block", c.ScheduleBlock, newcfg.ScheduleBlock)
+	}
+	if isForkIncompatible(c.LityGasBlock, newcfg.LityGasBlock, head) {
+		return newCompatError("LityGas fork block", c.LityGasBlock, newcfg.LityGasBlock)
+	}
+	if isForkIncompatible(c.RandBeaconBlock, newcfg.RandBeaconBlock, head) {
+		return newCompatError("RandBeacon fork block", c.RandBeaconBlock, newcfg.RandBeaconBlock)
+	}
+	if isForkIncompatible(c.FixedPointBlock, newcfg.FixedPointBlock, head) {
+		return newCompatError("FixedPoint fork block", c.FixedPointBlock, newcfg.FixedPointBlock)
+	}
+	if isForkIncompatible(c.OverflowRevertBlock, newcfg.OverflowRevertBlock, head) {
+		return newCompatError("OverflowRevert fork block", c.OverflowRevertBlock, newcfg.OverflowRevertBlock)
+	}
 	return nil
 }
 
@@ -330,9 +408,11 @@ func (err *ConfigCompatError) Error() string {
 // Rules is a one time interface meaning that it shouldn't be used in between transition
 // phases.
 type Rules struct {
-	ChainID                                   *big.Int
-	IsHomestead, IsEIP150, IsEIP155, IsEIP158 bool
-	IsByzantium                               bool
+	ChainID                                     *big.Int
+	IsHomestead, IsEIP150, IsEIP155, IsEIP158   bool
+	IsByzantium, IsConstantinople               bool
+	IsLity, IsSchedule, IsLityGas, IsRandBeacon bool
+	IsFixedPoint, IsOverflowRevert              bool
 }
 
 // Rules ensures c's ChainID is not nil.
@@ -341,5 +421,5 @@ func (c *ChainConfig) Rules(num *big.Int) Rules {
 	if chainID == nil {
 		chainID = new(big.Int)
 	}
-	return Rules{ChainID: new(big.Int).Set(chainID), IsHomestead: c.IsHomestead(num), IsEIP150: c.IsEIP150(num), IsEIP155: c.IsEIP155(num), IsEIP158: c.IsEIP158(num), IsByzantium: c.IsByzantium(num)}
+	return Rules{ChainID: new(big.Int).Set(chainID), IsHomestead: c.IsHomestead(num), IsEIP150: c.IsEIP150(num), IsEIP155: c.IsEIP155(num), IsEIP158: c.IsEIP158(num), IsByzantium: c.IsByzantium(num), IsConstantinople: c.IsConstantinople(num), IsLity: c.IsLity(num), IsSchedule: c.IsSchedule(num), IsLityGas: c.IsLityGas(num), IsRandBeacon: c.IsRandBeacon(num), IsFixedPoint: c.IsFixedPoint(num), IsOverflowRevert: c.IsOverflowRevert(num)}
 }
@@ -77,6 +77,25 @@ const (
 	Bn256ScalarMulGas       uint64 = 40000  // Gas needed for an elliptic curve scalar multiplication
 	Bn256PairingBaseGas     uint64 = 100000 // Base price for an elliptic curve pairing check
 	Bn256PairingPerPointGas uint64 = 80000  // Per-point price for an elliptic curve pairing check
+
+	// Lity opcode gas prices
+
+	ScheduleGas       uint64 = 20000 // Once per SCHEDULE operation, reserving the storage of the scheduled transaction.
+	ScheduleDataGas   uint64 = 68    // Per byte of a scheduled transaction: its sender, receiver, due time and data.
+	ScheduleWalkGas   uint64 = 400   // Per pending scheduled transaction walked past to queue one falling due earlier.
+	ScheduleCancelGas uint64 = 5000  // Once per cancellation of a scheduled transaction
+	FreeGasGas        uint64 = 375   // Once per FREEGAS operation.
+	RandGas           uint64 = 450   // Once per RAND operation, hashing the seed material read from the state.
+	FixedPointGas     uint64 = 3     // Per decimal of the operands of a FMUL, SFMUL, FDIV or SFDIV operation.
+
+	MaxFixedPointDecimals uint64 = 80 // Maximum decimals of fixed point operands, as for the Lity fixedMxN types.
+
+	// ENI execution limits, enforced alike by every node
+
+	ENIGasInstructions    uint64 = 100000            // Instructions an ENI function may execute computing its gas cost.
+	ENIInstructionsPerGas uint64 = 100               // Instructions an ENI function may execute per unit of gas it charged.
+	ENIMemoryLimit        uint64 = 256 * 1024 * 1024 // Address space an ENI function may allocate on top of the node's.
+	ENIOutputLimit        uint64 = 1024 * 1024       // Maximum size of the JSON encoded result of an ENI function.
 )
 
 var (
@@ -49,6 +49,26 @@ func TestClientRequest(t *testing.T) {
 	}
 }
 
+func TestClientErrorData(t *testing.T) {
+	server := newTestServer("service", new(Service))
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	var resp string
+	err := client.Call(&resp, "service_dataError")
+	if err == nil {
+		t.Fatal("no error returned")
+	}
+	de, ok := err.(DataError)
+	if !ok {
+		t.Fatalf("error %v doesn't carry data", err)
+	}
+	if err.Error() != "data error" || de.ErrorData() != "data" {
+		t.Errorf("error mismatch: have %q with data %v, want %q with data %q", err, de.ErrorData(), "data error", "data")
+	}
+}
+
 func TestClientBatchRequest(t *testing.T) {
 	server := newTestServer("service", new(Service))
 	defer server.Stop()
@@ -92,6 +92,14 @@ type Error interface {
 	ErrorCode() int // returns the code
 }
 
+// DataError is implemented by errors carrying additional data, which callback
+// errors report in the data field of the error response and client errors
+// return as decoded from it.
+type DataError interface {
+	Error() string          // returns the message
+	ErrorData() interface{} // returns the error data
+}
+
 // ServerCodec implements reading, parsing and writing RPC messages for the server side of
 // a RPC session. Implementations must be go-routine safe since the codec can be called in
 // multiple go-routines concurrently.
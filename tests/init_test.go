@@ -40,6 +40,10 @@ var (
 	vmTestDir          = filepath.Join(baseDir, "VMTests")
 	rlpTestDir         = filepath.Join(baseDir, "RLPTests")
 	difficultyTestDir  = filepath.Join(baseDir, "BasicTests")
+
+	// Lity specific fixtures, kept apart from the upstream test suite.
+	lityDir          = filepath.Join(".", "lity")
+	lityStateTestDir = filepath.Join(lityDir, "GeneralStateTests")
 )
 
 func readJSON(reader io.Reader, value interface{}) error {
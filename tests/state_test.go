@@ -56,6 +56,27 @@ func TestState(t *testing.T) {
 	})
 }
 
+// TestLityState runs the Lity state tests, which exercise each Lity opcode both
+// with the Lity fork disabled and enabled.
+func TestLityState(t *testing.T) {
+	t.Parallel()
+
+	st := new(testMatcher)
+	st.walk(t, lityStateTestDir, func(t *testing.T, name string, test *StateTest) {
+		for _, subtest := range test.Subtests() {
+			subtest := subtest
+			key := fmt.Sprintf("%s/%d", subtest.Fork, subtest.Index)
+			name := name + "/" + key
+			t.Run(key, func(t *testing.T) {
+				withTrace(t, test.gasLimit(subtest), func(vmconfig vm.Config) error {
+					_, err := test.Run(subtest, vmconfig)
+					return st.checkFailure(t, name, err)
+				})
+			})
+		}
+	})
+}
+
 // Transactions with gasLimit above this value will not get a VM trace on failure.
 const traceErrorLimit = 400000
 
@@ -20,6 +20,7 @@ import (
 	"bytes"
 	crand "crypto/rand"
 	mrand "math/rand"
+	"sort"
 	"testing"
 	"time"
 
@@ -143,6 +144,194 @@ func TestMissingKeyProof(t *testing.T) {
 	}
 }
 
+type entrySlice []*kv
+
+func (p entrySlice) Len() int           { return len(p) }
+func (p entrySlice) Less(i, j int) bool { return bytes.Compare(p[i].k, p[j].k) < 0 }
+func (p entrySlice) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+
+// sortedEntries returns the entries of a random trie ordered by their keys.
+func sortedEntries(vals map[string]*kv) entrySlice {
+	var entries entrySlice
+	for _, kv := range vals {
+		entries = append(entries, kv)
+	}
+	sort.Sort(entries)
+	return entries
+}
+
+// rangeOf splits a sorted entry range into its keys and values.
+func rangeOf(entries entrySlice) ([][]byte, [][]byte) {
+	var keys, vals [][]byte
+	for _, entry := range entries {
+		keys = append(keys, entry.k)
+		vals = append(vals, entry.v)
+	}
+	return keys, vals
+}
+
+// Tests that random ranges of a trie are proven with the existent edge keys.
+func TestRangeProof(t *testing.T) {
+	trie, vals := randomTrie(4096)
+	entries := sortedEntries(vals)
+
+	for i := 0; i < 500; i++ {
+		start := mrand.Intn(len(entries))
+		end := mrand.Intn(len(entries)-start) + start + 1
+
+		proof := ethdb.NewMemDatabase()
+		if err := trie.Prove(entries[start].k, 0, proof); err != nil {
+			t.Fatalf("Failed to prove the first node %v", err)
+		}
+		if err := trie.Prove(entries[end-1].k, 0, proof); err != nil {
+			t.Fatalf("Failed to prove the last node %v", err)
+		}
+		keys, vals := rangeOf(entries[start:end])
+		more, err := VerifyRangeProof(trie.Hash(), keys[0], keys[len(keys)-1], keys, vals, proof)
+		if err != nil {
+			t.Fatalf("Case %d(%d->%d) expect no error, got %v", i, start, end-1, err)
+		}
+		if more != (end < len(entries)) {
+			t.Fatalf("Case %d(%d->%d) continuation mismatch: have %v", i, start, end-1, more)
+		}
+	}
+}
+
+// Tests that random ranges of a trie are proven with non-existent edge keys
+// enclosing the range.
+func TestRangeProofWithNonExistentProof(t *testing.T) {
+	trie, vals := randomTrie(4096)
+	entries := sortedEntries(vals)
+
+	for i := 0; i < 500; i++ {
+		start := mrand.Intn(len(entries)-2) + 1
+		end := mrand.Intn(len(entries)-start-1) + start + 1
+
+		// Pick edge keys strictly between the neighbouring entries
+		first := decreaseKey(common.CopyBytes(entries[start].k))
+		if bytes.Equal(first, entries[start-1].k) {
+			continue
+		}
+		last := increaseKey(common.CopyBytes(entries[end-1].k))
+		if bytes.Equal(last, entries[end].k) {
+			continue
+		}
+		proof := ethdb.NewMemDatabase()
+		if err := trie.Prove(first, 0, proof); err != nil {
+			t.Fatalf("Failed to prove the first node %v", err)
+		}
+		if err := trie.Prove(last, 0, proof); err != nil {
+			t.Fatalf("Failed to prove the last node %v", err)
+		}
+		keys, vals := rangeOf(entries[start:end])
+		if _, err := VerifyRangeProof(trie.Hash(), first, last, keys, vals, proof); err != nil {
+			t.Fatalf("Case %d(%d->%d) expect no error, got %v", i, start, end-1, err)
+		}
+	}
+}
+
+// Tests the special range proofs: the whole trie without any proof, a single
+// element and the empty range past the last element.
+func TestSpecialRangeProofs(t *testing.T) {
+	trie, vals := randomTrie(4096)
+	entries := sortedEntries(vals)
+
+	// All elements without edge proofs
+	keys, values := rangeOf(entries)
+	if more, err := VerifyRangeProof(trie.Hash(), nil, nil, keys, values, nil); err != nil || more {
+		t.Fatalf("Full range proof failed: more %v, err %v", more, err)
+	}
+	if _, err := VerifyRangeProof(trie.Hash(), nil, nil, keys[1:], values[1:], nil); err == nil {
+		t.Fatalf("Partial range accepted without proof")
+	}
+	// Single element with the same edge keys
+	proof := ethdb.NewMemDatabase()
+	trie.Prove(entries[100].k, 0, proof)
+	if more, err := VerifyRangeProof(trie.Hash(), entries[100].k, entries[100].k, keys[100:101], values[100:101], proof); err != nil || !more {
+		t.Fatalf("Single element proof failed: more %v, err %v", more, err)
+	}
+	// No element after the last key
+	last := increaseKey(common.CopyBytes(entries[len(entries)-1].k))
+	proof = ethdb.NewMemDatabase()
+	trie.Prove(last, 0, proof)
+	if more, err := VerifyRangeProof(trie.Hash(), last, nil, nil, nil, proof); err != nil || more {
+		t.Fatalf("Empty range proof failed: more %v, err %v", more, err)
+	}
+	// No element claimed before existing ones
+	first := decreaseKey(common.CopyBytes(entries[len(entries)-1].k))
+	proof = ethdb.NewMemDatabase()
+	trie.Prove(first, 0, proof)
+	if _, err := VerifyRangeProof(trie.Hash(), first, nil, nil, nil, proof); err == nil {
+		t.Fatalf("Empty range accepted with remaining elements")
+	}
+}
+
+// Tests that tampered ranges are rejected.
+func TestBadRangeProof(t *testing.T) {
+	trie, vals := randomTrie(4096)
+	entries := sortedEntries(vals)
+
+	for i := 0; i < 500; i++ {
+		start := mrand.Intn(len(entries))
+		end := mrand.Intn(len(entries)-start) + start + 1
+
+		proof := ethdb.NewMemDatabase()
+		trie.Prove(entries[start].k, 0, proof)
+		trie.Prove(entries[end-1].k, 0, proof)
+
+		keys, vals := rangeOf(entries[start:end])
+		first, last := keys[0], keys[len(keys)-1]
+		switch index := mrand.Intn(len(keys)); mrand.Intn(4) {
+		case 0:
+			// Modified value
+			vals[index] = randBytes(20)
+		case 1:
+			// Gapped entry
+			if len(keys) < 3 {
+				continue
+			}
+			index = mrand.Intn(len(keys)-2) + 1
+			keys = append(keys[:index:index], keys[index+1:]...)
+			vals = append(vals[:index:index], vals[index+1:]...)
+		case 2:
+			// Out of order
+			if len(keys) < 2 {
+				continue
+			}
+			index = mrand.Intn(len(keys) - 1)
+			keys[index], keys[index+1] = keys[index+1], keys[index]
+		case 3:
+			// Deleted entry
+			vals[index] = nil
+		}
+		if _, err := VerifyRangeProof(trie.Hash(), first, last, keys, vals, proof); err == nil {
+			t.Fatalf("Case %d(%d->%d) expected error, got nil", i, start, end-1)
+		}
+	}
+}
+
+// increaseKey returns the key incremented by one, in place.
+func increaseKey(key []byte) []byte {
+	for i := len(key) - 1; i >= 0; i-- {
+		key[i]++
+		if key[i] != 0x0 {
+			break
+		}
+	}
+	return key
+}
+
+// decreaseKey returns the key decremented by one, in place.
+func decreaseKey(key []byte) []byte {
+	for i := len(key) - 1; i >= 0; i-- {
+		key[i]--
+		if key[i] != 0xff {
+			break
+		}
+	}
+	return key
+}
+
 // mutateByte changes one byte in b.
 func mutateByte(b []byte) {
 	for r := mrand.Intn(len(b)); ; {